@@ -32,6 +32,7 @@ import (
 	"github.com/Mirantis/virtlet/pkg/config"
 	"github.com/Mirantis/virtlet/pkg/diag"
 	"github.com/Mirantis/virtlet/pkg/fs"
+	"github.com/Mirantis/virtlet/pkg/image"
 	"github.com/Mirantis/virtlet/pkg/manager"
 	"github.com/Mirantis/virtlet/pkg/nsfix"
 	"github.com/Mirantis/virtlet/pkg/tapmanager"
@@ -50,6 +51,12 @@ const (
 var (
 	dumpConfig     = flag.Bool("dump-config", false, "Dump node-specific Virtlet config as a shell script and exit")
 	dumpDiag       = flag.Bool("diag", false, "Dump diagnostics as JSON and exit")
+	diagSources    = flag.StringSlice("diag-sources", nil, "Comma-separated list of diagnostics sources to use with --diag (default: all)")
+	imageImport    = flag.String("image-import", "", "Import the image data read from stdin into the image store under the specified name and exit")
+	imageExport    = flag.String("image-export", "", "Write the data of the specified image from the image store to stdout and exit")
+	imageCommit    = flag.String("image-commit", "", "Save the root disk of the VM specified using --container-id into the image store under the specified name and exit")
+	containerID    = flag.String("container-id", "", "ID of the VM container to use with --image-commit")
+	quiesce        = flag.Bool("quiesce", false, "Freeze the guest filesystems using the guest agent while committing the VM with --image-commit")
 	displayVersion = flag.Bool("version", false, "Display version and exit")
 	versionFormat  = flag.String("version-format", "text", "Version format to use (text, short, json, yaml)")
 )
@@ -61,7 +68,7 @@ func configWithDefaults(cfg *v1.VirtletConfig) *v1.VirtletConfig {
 }
 
 func runVirtlet(config *v1.VirtletConfig, clientCfg clientcmd.ClientConfig, diagSet *diag.Set) {
-	manager := manager.NewVirtletManager(config, nil, clientCfg, diagSet)
+	manager := manager.NewVirtletManager(config, nil, clientCfg, os.Getenv(nodeNameEnv), diagSet)
 	if err := manager.Run(); err != nil {
 		glog.Errorf("Error: %v", err)
 		os.Exit(1)
@@ -74,7 +81,7 @@ func runTapManager(config *v1.VirtletConfig) {
 		glog.Errorf("Error initializing CNI client: %v", err)
 		os.Exit(1)
 	}
-	src, err := tapmanager.NewTapFDSource(cniClient, *config.EnableSriov, *config.CalicoSubnetSize)
+	src, err := tapmanager.NewTapFDSource(cniClient, *config.EnableSriov, !*config.DisableKVM, *config.CalicoSubnetSize)
 	if err != nil {
 		glog.Errorf("Error creating tap fd source: %v", err)
 		os.Exit(1)
@@ -129,7 +136,7 @@ func runDiagServer() *diag.Set {
 }
 
 func doDiag() {
-	dr, err := diag.RetrieveDiagnostics(diagSocket)
+	dr, err := diag.RetrieveDiagnostics(diagSocket, *diagSources...)
 	if err != nil {
 		glog.Errorf("Failed to retrieve diagnostics: %v", err)
 		os.Exit(1)
@@ -137,6 +144,35 @@ func doDiag() {
 	os.Stdout.Write(dr.ToJSON())
 }
 
+func doImageImport() {
+	ref, err := image.ImportImageViaServer(manager.ImageServerSocketPath, *imageImport, os.Stdin)
+	if err != nil {
+		glog.Errorf("Failed to import the image: %v", err)
+		os.Exit(1)
+	}
+	fmt.Println(ref)
+}
+
+func doImageExport() {
+	if _, err := image.ExportImageViaServer(manager.ImageServerSocketPath, *imageExport, os.Stdout); err != nil {
+		glog.Errorf("Failed to export the image: %v", err)
+		os.Exit(1)
+	}
+}
+
+func doImageCommit() {
+	if *containerID == "" {
+		glog.Error("--container-id must be specified")
+		os.Exit(1)
+	}
+	ref, err := image.CommitImageViaServer(manager.ImageServerSocketPath, *containerID, *imageCommit, *quiesce)
+	if err != nil {
+		glog.Errorf("Failed to commit the image: %v", err)
+		os.Exit(1)
+	}
+	fmt.Println(ref)
+}
+
 func main() {
 	nsfix.HandleReexec()
 	clientCfg := utils.BindFlags(flag.CommandLine)
@@ -164,6 +200,12 @@ func main() {
 		}
 	case *dumpDiag:
 		doDiag()
+	case *imageImport != "":
+		doImageImport()
+	case *imageExport != "":
+		doImageExport()
+	case *imageCommit != "":
+		doImageCommit()
 	default:
 		localConfig = configWithDefaults(localConfig)
 		go runTapManager(localConfig)
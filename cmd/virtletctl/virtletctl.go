@@ -53,6 +53,8 @@ func newRootCmd() *cobra.Command {
 	cmd.AddCommand(tools.NewVersionCommand(client, os.Stdout, nil))
 	cmd.AddCommand(tools.NewDiagCommand(client, os.Stdin, os.Stdout))
 	cmd.AddCommand(tools.NewValidateCommand(client, os.Stdin))
+	cmd.AddCommand(tools.NewImageCommand(client, os.Stdout))
+	cmd.AddCommand(tools.NewCommitCmd(client, os.Stdout))
 
 	for _, c := range cmd.Commands() {
 		c.PreRunE = func(*cobra.Command, []string) error {
@@ -0,0 +1,66 @@
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+)
+
+const (
+	// netRootPortLastSlot is the slot on pcie-root that's used for
+	// the root ports of the first 8 network interfaces, with the
+	// following interfaces using the preceding slots. These root
+	// ports aren't a part of the domain definition, so libvirt
+	// doesn't place its own devices into them. libvirt fills
+	// pcie-root slots starting from the first ones and reserves
+	// slot 0x1f, so the slots right below it are used here.
+	netRootPortLastSlot = 0x1c
+	// netRootPortsPerSlot is the number of the root ports that are
+	// placed into a single multifunction slot on pcie-root.
+	netRootPortsPerSlot = 8
+	// netRootPortFirstChassis is the chassis number of the first
+	// network interface root port. It's chosen to be above the
+	// chassis numbers that libvirt uses for its root ports, which
+	// match their controller indices.
+	netRootPortFirstChassis = 0xe0
+	// maxNetRootPorts is the maximum number of the network
+	// interfaces that can be added to a PCIe domain, limited by
+	// the available chassis numbers.
+	maxNetRootPorts = 0x100 - netRootPortFirstChassis
+)
+
+// netRootPortArgs returns the emulator args that add the PCIe root port
+// for the network interface number n along with the suffix to be added
+// to the device spec of the interface to place it into that port.
+func netRootPortArgs(n int) ([]string, string, error) {
+	if n >= maxNetRootPorts {
+		return nil, "", fmt.Errorf("too many network interfaces for a PCIe machine: %d", n+1)
+	}
+	id := fmt.Sprintf("netport%d", n)
+	slot := netRootPortLastSlot - n/netRootPortsPerSlot
+	function := n % netRootPortsPerSlot
+	multifunction := ""
+	if function == 0 {
+		multifunction = ",multifunction=on"
+	}
+	chassis := netRootPortFirstChassis + n
+	return []string{
+		"-device",
+		fmt.Sprintf("pcie-root-port,port=0x%x,chassis=%d,id=%s,bus=pcie.0,addr=0x%x.0x%x%s",
+			chassis, chassis, id, slot, function, multifunction),
+	}, fmt.Sprintf(",bus=%s,addr=0x0", id), nil
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/golang/glog"
+
+	"github.com/Mirantis/virtlet/pkg/config"
+)
+
+const (
+	swtpmBinary          = "swtpm"
+	swtpmSocketName      = "swtpm.sock"
+	swtpmStartupTimeout  = 10 * time.Second
+	swtpmStartupInterval = 100 * time.Millisecond
+)
+
+// withTPMArgs starts swtpm for the VM if it has vTPM enabled and
+// returns the emulator args with the TPM device added. swtpm keeps
+// its state in the directory that's passed by Virtlet and exits
+// after the emulator disconnects from it.
+func withTPMArgs(args []string) ([]string, error) {
+	stateDir := os.Getenv(config.TPMStateDirEnvVarName)
+	if stateDir == "" {
+		return args, nil
+	}
+
+	socketPath := filepath.Join(stateDir, swtpmSocketName)
+	// remove the stale socket left by the previous run, if any
+	if err := os.Remove(socketPath); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("can't remove stale swtpm socket %q: %v", socketPath, err)
+	}
+
+	cmd := exec.Command(swtpmBinary, "socket", "--tpm2",
+		"--tpmstate", "dir="+stateDir,
+		"--ctrl", "type=unixio,path="+socketPath,
+		"--log", "file="+filepath.Join(stateDir, "swtpm.log"),
+		"--terminate", "--daemon")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("error starting swtpm: %v; output:\n%s", err, out)
+	}
+
+	deadline := time.Now().Add(swtpmStartupTimeout)
+	for {
+		if _, err := os.Stat(socketPath); err == nil {
+			break
+		} else if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("can't stat swtpm socket %q: %v", socketPath, err)
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for swtpm socket %q", socketPath)
+		}
+		time.Sleep(swtpmStartupInterval)
+	}
+	glog.V(1).Infof("swtpm started using state dir %q", stateDir)
+
+	return append(args,
+		"-chardev", "socket,id=chrtpm,path="+socketPath,
+		"-tpmdev", "emulator,id=tpm0,chardev=chrtpm",
+		"-device", "tpm-crb,tpmdev=tpm0"), nil
+}
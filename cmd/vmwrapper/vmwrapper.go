@@ -21,11 +21,15 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"syscall"
 
 	"github.com/golang/glog"
 
 	"github.com/Mirantis/virtlet/pkg/config"
+	"github.com/Mirantis/virtlet/pkg/metadata/types"
 	"github.com/Mirantis/virtlet/pkg/network"
 	"github.com/Mirantis/virtlet/pkg/nsfix"
 	"github.com/Mirantis/virtlet/pkg/tapmanager"
@@ -34,9 +38,11 @@ import (
 )
 
 const (
-	fdSocketPath    = "/var/lib/virtlet/tapfdserver.sock"
-	defaultEmulator = "/usr/bin/qemu-system-x86_64" // FIXME
-	vmsProcFile     = "/var/lib/virtlet/vms.procfile"
+	fdSocketPath = "/var/lib/virtlet/tapfdserver.sock"
+	vmsProcFile  = "/var/lib/virtlet/vms.procfile"
+	// vmwrapper is symlinked as vmwrapper-<arch> for the guest
+	// architectures other than the host one
+	archProgramPrefix = "vmwrapper-"
 )
 
 type reexecArg struct {
@@ -44,13 +50,69 @@ type reexecArg struct {
 }
 
 func handleReexec(arg interface{}) (interface{}, error) {
-	args := arg.(*reexecArg).Args
+	// swtpm is started here so it runs in the same container
+	// as the emulator
+	args, err := withTPMArgs(arg.(*reexecArg).Args)
+	if err != nil {
+		return nil, err
+	}
 	if err := syscall.Exec(args[0], args, os.Environ()); err != nil {
 		return nil, fmt.Errorf("Can't exec emulator: %v", err)
 	}
 	return nil, nil // unreachable
 }
 
+// defaultEmulator returns QEMU binary to run when no emulator is
+// specified, which is the case when libvirt checks the emulator
+// capabilities. The guest architecture is determined from the name
+// vmwrapper is invoked as.
+func defaultEmulator() string {
+	arch := types.HostArch()
+	if name := filepath.Base(os.Args[0]); strings.HasPrefix(name, archProgramPrefix) {
+		arch = types.Arch(strings.TrimPrefix(name, archProgramPrefix))
+	}
+	return arch.EmulatorPath()
+}
+
+// joinFds returns a colon-separated list of fds as used by QEMU
+// -netdev tap option
+func joinFds(fds []int) string {
+	var items []string
+	for _, fd := range fds {
+		items = append(items, strconv.Itoa(fd))
+	}
+	return strings.Join(items, ":")
+}
+
+// tapNetdev returns -netdev argument for the tap interface. The tap
+// queue fds are followed by the vhost-net ones, if any.
+func tapNetdev(desc tapmanager.InterfaceDescription, fds []int) string {
+	if desc.QueueCount <= 1 {
+		netdev := fmt.Sprintf("tap,id=tap%d,fd=%d", desc.FdIndex, fds[desc.FdIndex])
+		if desc.Vhost {
+			netdev += fmt.Sprintf(",vhost=on,vhostfd=%d", fds[desc.FdIndex+1])
+		}
+		return netdev
+	}
+	queueFds := fds[desc.FdIndex : desc.FdIndex+desc.QueueCount]
+	netdev := fmt.Sprintf("tap,id=tap%d,fds=%s", desc.FdIndex, joinFds(queueFds))
+	if desc.Vhost {
+		vhostFds := fds[desc.FdIndex+desc.QueueCount : desc.FdIndex+2*desc.QueueCount]
+		netdev += fmt.Sprintf(",vhost=on,vhostfds=%s", joinFds(vhostFds))
+	}
+	return netdev
+}
+
+// multiQueueSuffix returns the options to add to virtio-net device
+// for multiqueue taps. Each queue needs a pair of MSI-X vectors for
+// tx and rx, plus there are vectors for config and control.
+func multiQueueSuffix(desc tapmanager.InterfaceDescription) string {
+	if desc.QueueCount <= 1 {
+		return ""
+	}
+	return fmt.Sprintf(",mq=on,vectors=%d", 2*desc.QueueCount+2)
+}
+
 func main() {
 	nsfix.RegisterReexec("vmwrapper", handleReexec, reexecArg{})
 	nsfix.HandleReexec()
@@ -91,10 +153,13 @@ func main() {
 	if emulator == "" {
 		// this happens during 'qemu -help' invocation by libvirt
 		// (capability check)
-		emulator = defaultEmulator
+		emulator = defaultEmulator()
 	} else {
 		netFdKey := os.Getenv(config.NetKeyEnvVarName)
 		nextToUseHostdevNo := 0
+		// on q35, each network interface is placed into a PCIe
+		// root port of its own that's added here
+		usePCIe := os.Getenv(config.NetPCIeRootPortsEnvVarName) != ""
 
 		if netFdKey != "" {
 			c := tapmanager.NewFDClient(fdSocketPath)
@@ -111,20 +176,31 @@ func main() {
 			}
 
 			for i, desc := range descriptions {
+				busSuffix := ""
+				if usePCIe {
+					portArgs, suffix, err := netRootPortArgs(i)
+					if err != nil {
+						glog.Errorf("Failed to add a PCIe root port for network interface %d: %v", i, err)
+						os.Exit(1)
+					}
+					netArgs = append(netArgs, portArgs...)
+					busSuffix = suffix
+				}
 				switch desc.Type {
-				case network.InterfaceTypeTap:
+				case network.InterfaceTypeTap, network.InterfaceTypeMacvtap:
 					netArgs = append(netArgs,
 						"-netdev",
-						fmt.Sprintf("tap,id=tap%d,fd=%d", desc.FdIndex, fds[desc.FdIndex]),
+						tapNetdev(desc, fds),
 						"-device",
-						fmt.Sprintf("virtio-net-pci,netdev=tap%d,id=net%d,mac=%s", desc.FdIndex, i, desc.HardwareAddr),
+						fmt.Sprintf("%s,netdev=tap%d,id=net%d,mac=%s%s%s", desc.Model.QEMUDevice(), desc.FdIndex, i, desc.HardwareAddr, multiQueueSuffix(desc), busSuffix),
 					)
 				case network.InterfaceTypeVF:
 					netArgs = append(netArgs,
 						"-device",
-						fmt.Sprintf("vfio-pci,host=%s,id=hostdev%d",
+						fmt.Sprintf("vfio-pci,host=%s,id=hostdev%d%s",
 							desc.PCIAddress[5:],
 							nextToUseHostdevNo,
+							busSuffix,
 						),
 					)
 					nextToUseHostdevNo += 1
@@ -158,6 +234,10 @@ func main() {
 			glog.Errorf("Can't set cpusets for emulator: %v", err)
 			os.Exit(1)
 		}
+		if args, err = withTPMArgs(args); err != nil {
+			glog.Errorf("Can't set up vTPM: %v", err)
+			os.Exit(1)
+		}
 		// this log hides errors returned by libvirt virError
 		// because of libvirt's output parsing approach
 		// glog.V(0).Infof("Executing emulator: %s", strings.Join(args, " "))
@@ -56,6 +56,11 @@ fi
 chown root.root /vmwrapper
 chmod ug+s /vmwrapper
 
+# vmwrapper runs QEMU for the guest architecture it's invoked as
+for arch in x86_64 aarch64 ppc64le; do
+  ln -fs /vmwrapper "/vmwrapper-${arch}"
+done
+
 if [[ ${testmode} ]]; then
   # leftover socket prevents libvirt from initializing correctly
   rm -f /var/lib/libvirt/qemu/capabilities.monitor.sock
@@ -33,8 +33,23 @@ type TranslationRule struct {
 
 	// Transport is the optional transport profile name to be used for the downloading
 	Transport string `yaml:"transport,omitempty" json:"transport,omitempty"`
+
+	// Mirrors is the ordered list of alternative image URLs that are tried if the download from URL fails.
+	// The same replacements as for URL can be used here
+	Mirrors []string `yaml:"mirrors,omitempty" json:"mirrors,omitempty"`
+
+	// MirrorSelection specifies the order in which URL and Mirrors are tried: "ordered" (default) means
+	// the listed order and "fastest" means the order of the response times
+	MirrorSelection string `yaml:"mirrorSelection,omitempty" json:"mirrorSelection,omitempty"`
 }
 
+const (
+	// MirrorSelectionOrdered denotes trying the image mirrors in the listed order
+	MirrorSelectionOrdered = "ordered"
+	// MirrorSelectionFastest denotes trying the image mirrors in the order of their response times
+	MirrorSelectionFastest = "fastest"
+)
+
 // ImageTranslation is a single translation config with optional prefix name
 type ImageTranslation struct {
 	// Prefix allows to have several config-sets and distinguish them by using `prefix/imageName` notation. Optional.
@@ -60,6 +75,58 @@ type TransportProfile struct {
 
 	// Proxy server to use for downloading
 	Proxy string `yaml:"proxy,omitempty" json:"proxy,omitempty"`
+
+	// MaxBytesPerSecond limits the total download rate of all the images that use this profile. <= 0 is no limit (default)
+	MaxBytesPerSecond int64 `yaml:"maxBytesPerSecond,omitempty" json:"maxBytesPerSecond,omitempty"`
+
+	// Auth is the HTTP authentication config
+	Auth *HTTPAuth `yaml:"auth,omitempty" json:"auth,omitempty"`
+
+	// Headers is the list of additional HTTP headers to send with the download requests
+	Headers []HTTPHeader `yaml:"headers,omitempty" json:"headers,omitempty"`
+}
+
+// HTTPAuth has the HTTP authentication parameters
+type HTTPAuth struct {
+	// Username is the user name for the basic authentication
+	Username *SecretValue `yaml:"username,omitempty" json:"username,omitempty"`
+
+	// Password is the password for the basic authentication
+	Password *SecretValue `yaml:"password,omitempty" json:"password,omitempty"`
+
+	// BearerToken is the token to pass in the Authorization header. Takes precedence over Username and Password
+	BearerToken *SecretValue `yaml:"bearerToken,omitempty" json:"bearerToken,omitempty"`
+}
+
+// HTTPHeader is an additional HTTP header with either literal value or the value taken from a Secret
+type HTTPHeader struct {
+	// Name is the name of the header
+	Name string `yaml:"name" json:"name"`
+
+	// Value is the literal value of the header
+	Value string `yaml:"value,omitempty" json:"value,omitempty"`
+
+	// SecretKeyRef references the Secret key that holds the value of the header
+	SecretKeyRef *SecretKeySelector `yaml:"secretKeyRef,omitempty" json:"secretKeyRef,omitempty"`
+}
+
+// SecretValue is either a literal value or a reference to a Secret key that holds the value
+type SecretValue struct {
+	// Value is the literal value
+	Value string `yaml:"value,omitempty" json:"value,omitempty"`
+
+	// SecretKeyRef references the Secret key that holds the value
+	SecretKeyRef *SecretKeySelector `yaml:"secretKeyRef,omitempty" json:"secretKeyRef,omitempty"`
+}
+
+// SecretKeySelector references a key of a Secret. Secrets can only be used in
+// VirtletImageMapping resources and must reside in the same namespace
+type SecretKeySelector struct {
+	// Name is the name of the Secret
+	Name string `yaml:"name" json:"name"`
+
+	// Key is the key within the Secret
+	Key string `yaml:"key" json:"key"`
 }
 
 // TLSConfig has the TLS transport parameters
@@ -84,15 +151,62 @@ type TLSCertificate struct {
 	Key string `yaml:"key,omitempty" json:"key,omitempty"`
 }
 
+// VirtletImageMappingConditionType is the type of VirtletImageMapping condition
+type VirtletImageMappingConditionType string
+
+const (
+	// VirtletImageMappingValid condition indicates whether the mapping has passed the validation
+	VirtletImageMappingValid VirtletImageMappingConditionType = "Valid"
+)
+
+// VirtletImageMappingCondition describes the state of VirtletImageMapping at a certain point
+type VirtletImageMappingCondition struct {
+	// Type is the type of the condition
+	Type VirtletImageMappingConditionType `json:"type"`
+
+	// Status is the status of the condition, one of "True", "False" or "Unknown"
+	Status string `json:"status"`
+
+	// LastTransitionTime is the last time the condition transitioned from one status to another
+	LastTransitionTime meta_v1.Time `json:"lastTransitionTime,omitempty"`
+
+	// Reason is a brief machine-readable explanation for the condition's last transition
+	Reason string `json:"reason,omitempty"`
+
+	// Message is a human-readable description of the details of the last transition
+	Message string `json:"message,omitempty"`
+}
+
+// VirtletImageMappingNodeStatus describes the state of VirtletImageMapping on a particular node
+type VirtletImageMappingNodeStatus struct {
+	// SpecHash is the hash of the mapping spec that was last applied on the node
+	SpecHash string `json:"specHash,omitempty"`
+
+	// LastApplied is the time when the spec was first applied on the node
+	LastApplied meta_v1.Time `json:"lastApplied,omitempty"`
+
+	// Error describes the problem that prevents the mapping from being used on the node, if any
+	Error string `json:"error,omitempty"`
+}
+
+// VirtletImageMappingStatus is the status of VirtletImageMapping
+type VirtletImageMappingStatus struct {
+	// Conditions is the list of the conditions of the mapping
+	Conditions []VirtletImageMappingCondition `json:"conditions,omitempty"`
+
+	// Nodes maps the node names to the per-node status of the mapping
+	Nodes map[string]VirtletImageMappingNodeStatus `json:"nodes,omitempty"`
+}
+
 // +genclient
-// +genclient:noStatus
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
 // VirtletImageMapping represents an ImageTranslation wrapped in k8s object.
 type VirtletImageMapping struct {
 	meta_v1.TypeMeta   `json:",inline"`
 	meta_v1.ObjectMeta `json:"metadata"`
-	Spec               ImageTranslation `json:"spec"`
+	Spec               ImageTranslation          `json:"spec"`
+	Status             VirtletImageMappingStatus `json:"status,omitempty"`
 }
 
 // ConfigName returns the name of the config.
@@ -68,6 +68,29 @@ type VirtletConfig struct {
 	LogLevel *int `json:"logLevel,omitempty"`
 	// Kubelet's root dir
 	KubeletRootDir *string `json:"kubeletRootDir,omitempty"`
+	// ImageStoreSizeLimit specifies the maximum size of the image
+	// store as a resource quantity, e.g. "20Gi". Empty string means
+	// no limit.
+	ImageStoreSizeLimit *string `json:"imageStoreSizeLimit,omitempty"`
+	// ImageStoreHighWatermark specifies the image store usage,
+	// in percents of ImageStoreSizeLimit, that triggers the eviction
+	// of the least recently used images.
+	ImageStoreHighWatermark *int `json:"imageStoreHighWatermark,omitempty"`
+	// ImageStoreLowWatermark specifies the image store usage,
+	// in percents of ImageStoreSizeLimit, that the image eviction
+	// tries to reach.
+	ImageStoreLowWatermark *int `json:"imageStoreLowWatermark,omitempty"`
+	// ImageDownloadRateLimit specifies the maximum total image
+	// download rate in bytes per second as a resource quantity,
+	// e.g. "10Mi". Empty string means no limit.
+	ImageDownloadRateLimit *string `json:"imageDownloadRateLimit,omitempty"`
+	// MaxConcurrentImageDownloads specifies the maximum number of
+	// images that can be downloaded at the same time. 0 means no limit.
+	MaxConcurrentImageDownloads *int `json:"maxConcurrentImageDownloads,omitempty"`
+	// ImagePreloadDir specifies the directory with the image files
+	// to import into the image store on startup. Empty string means
+	// such directory is not used.
+	ImagePreloadDir *string `json:"imagePreloadDir,omitempty"`
 }
 
 // VirtletConfigMappingSpec is the contents of a VirtletConfigMapping.
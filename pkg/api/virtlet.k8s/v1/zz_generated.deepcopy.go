@@ -24,13 +24,83 @@ import (
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HTTPAuth) DeepCopyInto(out *HTTPAuth) {
+	*out = *in
+	if in.Username != nil {
+		in, out := &in.Username, &out.Username
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(SecretValue)
+			(*in).DeepCopyInto(*out)
+		}
+	}
+	if in.Password != nil {
+		in, out := &in.Password, &out.Password
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(SecretValue)
+			(*in).DeepCopyInto(*out)
+		}
+	}
+	if in.BearerToken != nil {
+		in, out := &in.BearerToken, &out.BearerToken
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(SecretValue)
+			(*in).DeepCopyInto(*out)
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HTTPAuth.
+func (in *HTTPAuth) DeepCopy() *HTTPAuth {
+	if in == nil {
+		return nil
+	}
+	out := new(HTTPAuth)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HTTPHeader) DeepCopyInto(out *HTTPHeader) {
+	*out = *in
+	if in.SecretKeyRef != nil {
+		in, out := &in.SecretKeyRef, &out.SecretKeyRef
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(SecretKeySelector)
+			**out = **in
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HTTPHeader.
+func (in *HTTPHeader) DeepCopy() *HTTPHeader {
+	if in == nil {
+		return nil
+	}
+	out := new(HTTPHeader)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ImageTranslation) DeepCopyInto(out *ImageTranslation) {
 	*out = *in
 	if in.Rules != nil {
 		in, out := &in.Rules, &out.Rules
 		*out = make([]TranslationRule, len(*in))
-		copy(*out, *in)
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 	if in.Transports != nil {
 		in, out := &in.Transports, &out.Transports
@@ -54,6 +124,47 @@ func (in *ImageTranslation) DeepCopy() *ImageTranslation {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SecretKeySelector) DeepCopyInto(out *SecretKeySelector) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecretKeySelector.
+func (in *SecretKeySelector) DeepCopy() *SecretKeySelector {
+	if in == nil {
+		return nil
+	}
+	out := new(SecretKeySelector)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SecretValue) DeepCopyInto(out *SecretValue) {
+	*out = *in
+	if in.SecretKeyRef != nil {
+		in, out := &in.SecretKeyRef, &out.SecretKeyRef
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(SecretKeySelector)
+			**out = **in
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecretValue.
+func (in *SecretValue) DeepCopy() *SecretValue {
+	if in == nil {
+		return nil
+	}
+	out := new(SecretValue)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TLSCertificate) DeepCopyInto(out *TLSCertificate) {
 	*out = *in
@@ -94,6 +205,11 @@ func (in *TLSConfig) DeepCopy() *TLSConfig {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TranslationRule) DeepCopyInto(out *TranslationRule) {
 	*out = *in
+	if in.Mirrors != nil {
+		in, out := &in.Mirrors, &out.Mirrors
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -128,6 +244,22 @@ func (in *TransportProfile) DeepCopyInto(out *TransportProfile) {
 			(*in).DeepCopyInto(*out)
 		}
 	}
+	if in.Auth != nil {
+		in, out := &in.Auth, &out.Auth
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(HTTPAuth)
+			(*in).DeepCopyInto(*out)
+		}
+	}
+	if in.Headers != nil {
+		in, out := &in.Headers, &out.Headers
+		*out = make([]HTTPHeader, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -324,6 +456,60 @@ func (in *VirtletConfig) DeepCopyInto(out *VirtletConfig) {
 			**out = **in
 		}
 	}
+	if in.ImageStoreSizeLimit != nil {
+		in, out := &in.ImageStoreSizeLimit, &out.ImageStoreSizeLimit
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(string)
+			**out = **in
+		}
+	}
+	if in.ImageStoreHighWatermark != nil {
+		in, out := &in.ImageStoreHighWatermark, &out.ImageStoreHighWatermark
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(int)
+			**out = **in
+		}
+	}
+	if in.ImageStoreLowWatermark != nil {
+		in, out := &in.ImageStoreLowWatermark, &out.ImageStoreLowWatermark
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(int)
+			**out = **in
+		}
+	}
+	if in.ImageDownloadRateLimit != nil {
+		in, out := &in.ImageDownloadRateLimit, &out.ImageDownloadRateLimit
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(string)
+			**out = **in
+		}
+	}
+	if in.MaxConcurrentImageDownloads != nil {
+		in, out := &in.MaxConcurrentImageDownloads, &out.MaxConcurrentImageDownloads
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(int)
+			**out = **in
+		}
+	}
+	if in.ImagePreloadDir != nil {
+		in, out := &in.ImagePreloadDir, &out.ImagePreloadDir
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(string)
+			**out = **in
+		}
+	}
 	return
 }
 
@@ -437,6 +623,7 @@ func (in *VirtletImageMapping) DeepCopyInto(out *VirtletImageMapping) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 	return
 }
 
@@ -458,6 +645,23 @@ func (in *VirtletImageMapping) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VirtletImageMappingCondition) DeepCopyInto(out *VirtletImageMappingCondition) {
+	*out = *in
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VirtletImageMappingCondition.
+func (in *VirtletImageMappingCondition) DeepCopy() *VirtletImageMappingCondition {
+	if in == nil {
+		return nil
+	}
+	out := new(VirtletImageMappingCondition)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VirtletImageMappingList) DeepCopyInto(out *VirtletImageMappingList) {
 	*out = *in
@@ -490,3 +694,52 @@ func (in *VirtletImageMappingList) DeepCopyObject() runtime.Object {
 	}
 	return nil
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VirtletImageMappingNodeStatus) DeepCopyInto(out *VirtletImageMappingNodeStatus) {
+	*out = *in
+	in.LastApplied.DeepCopyInto(&out.LastApplied)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VirtletImageMappingNodeStatus.
+func (in *VirtletImageMappingNodeStatus) DeepCopy() *VirtletImageMappingNodeStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(VirtletImageMappingNodeStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VirtletImageMappingStatus) DeepCopyInto(out *VirtletImageMappingStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]VirtletImageMappingCondition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Nodes != nil {
+		in, out := &in.Nodes, &out.Nodes
+		*out = make(map[string]VirtletImageMappingNodeStatus, len(*in))
+		for key, val := range *in {
+			newVal := new(VirtletImageMappingNodeStatus)
+			val.DeepCopyInto(newVal)
+			(*out)[key] = *newVal
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VirtletImageMappingStatus.
+func (in *VirtletImageMappingStatus) DeepCopy() *VirtletImageMappingStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(VirtletImageMappingStatus)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -100,6 +100,18 @@ func (c *FakeVirtletImageMappings) Update(virtletImageMapping *virtlet_k8s_v1.Vi
 	return obj.(*virtlet_k8s_v1.VirtletImageMapping), err
 }
 
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *FakeVirtletImageMappings) UpdateStatus(virtletImageMapping *virtlet_k8s_v1.VirtletImageMapping) (*virtlet_k8s_v1.VirtletImageMapping, error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewUpdateSubresourceAction(virtletimagemappingsResource, "status", c.ns, virtletImageMapping), &virtlet_k8s_v1.VirtletImageMapping{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*virtlet_k8s_v1.VirtletImageMapping), err
+}
+
 // Delete takes name of the virtletImageMapping and deletes it. Returns an error if one occurs.
 func (c *FakeVirtletImageMappings) Delete(name string, options *v1.DeleteOptions) error {
 	_, err := c.Fake.
@@ -37,6 +37,7 @@ type VirtletImageMappingsGetter interface {
 type VirtletImageMappingInterface interface {
 	Create(*v1.VirtletImageMapping) (*v1.VirtletImageMapping, error)
 	Update(*v1.VirtletImageMapping) (*v1.VirtletImageMapping, error)
+	UpdateStatus(*v1.VirtletImageMapping) (*v1.VirtletImageMapping, error)
 	Delete(name string, options *meta_v1.DeleteOptions) error
 	DeleteCollection(options *meta_v1.DeleteOptions, listOptions meta_v1.ListOptions) error
 	Get(name string, options meta_v1.GetOptions) (*v1.VirtletImageMapping, error)
@@ -120,6 +121,22 @@ func (c *virtletImageMappings) Update(virtletImageMapping *v1.VirtletImageMappin
 	return
 }
 
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+
+func (c *virtletImageMappings) UpdateStatus(virtletImageMapping *v1.VirtletImageMapping) (result *v1.VirtletImageMapping, err error) {
+	result = &v1.VirtletImageMapping{}
+	err = c.client.Put().
+		Namespace(c.ns).
+		Resource("virtletimagemappings").
+		Name(virtletImageMapping.Name).
+		SubResource("status").
+		Body(virtletImageMapping).
+		Do().
+		Into(result)
+	return
+}
+
 // Delete takes name of the virtletImageMapping and deletes it. Returns an error if one occurs.
 func (c *virtletImageMappings) Delete(name string, options *meta_v1.DeleteOptions) error {
 	return c.client.Delete().
@@ -21,15 +21,18 @@ import (
 )
 
 // GetPodIP retrieves the IP address of the pod as a string. It uses
-// the first IPv4 address if finds. If it fails to determine the pod
+// the first IPv4 address if finds, falling back to the first IPv6
+// address for IPv6-only pods. If it fails to determine the pod
 // IP or the result argument is nil, it returns an empty string.
 func GetPodIP(result *cnicurrent.Result) string {
 	if result == nil {
 		return ""
 	}
-	for _, ip := range result.IPs {
-		if ip.Version == "4" {
-			return ip.Address.IP.String()
+	for _, version := range []string{"4", "6"} {
+		for _, ip := range result.IPs {
+			if ip.Version == version {
+				return ip.Address.IP.String()
+			}
 		}
 	}
 	return ""
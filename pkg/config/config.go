@@ -79,6 +79,25 @@ const (
 
 	kubeletRootDir    = "/var/lib/kubelet/pods"
 	kubeletRootDirEnv = "KUBELET_ROOT_DIR"
+
+	defaultImageStoreSizeLimit     = ""
+	imageStoreSizeLimitEnv         = "VIRTLET_IMAGE_STORE_SIZE_LIMIT"
+	defaultImageStoreHighWatermark = 90
+	imageStoreHighWatermarkEnv     = "VIRTLET_IMAGE_STORE_HIGH_WATERMARK"
+	defaultImageStoreLowWatermark  = 80
+	imageStoreLowWatermarkEnv      = "VIRTLET_IMAGE_STORE_LOW_WATERMARK"
+
+	defaultImageDownloadRateLimit      = ""
+	imageDownloadRateLimitEnv          = "VIRTLET_IMAGE_DOWNLOAD_RATE_LIMIT"
+	defaultMaxConcurrentImageDownloads = 0
+	maxConcurrentImageDownloadsEnv     = "VIRTLET_MAX_CONCURRENT_IMAGE_DOWNLOADS"
+
+	defaultImagePreloadDir = ""
+	imagePreloadDirEnv     = "VIRTLET_IMAGE_PRELOAD_DIR"
+
+	// quantityPattern matches the resource quantities without
+	// fractional parts
+	quantityPattern = "^([0-9]+([kMGTPE]|[KMGTPE]i)?)?$"
 )
 
 func configFieldSet(c *virtlet_v1.VirtletConfig) *fieldSet {
@@ -103,6 +122,12 @@ func configFieldSet(c *virtlet_v1.VirtletConfig) *fieldSet {
 	fs.addStringField("cpuModel", "cpu-model", "", "CPU model to use in libvirt domain definition (libvirt's default value will be used if not set)", cpuModelEnv, defaultCPUModel, &c.CPUModel)
 	fs.addIntField("streamPort", "stream-port", "", "configurable port to the virtlet server", streamPortEnv, defaultStreamPort, 1, 65535, &c.StreamPort)
 	fs.addStringField("kubeletRootDir", "kubelet-root-dir", "", "Pod's root dir in kubelet", kubeletRootDirEnv, kubeletRootDir, &c.KubeletRootDir)
+	fs.addStringFieldWithPattern("imageStoreSizeLimit", "image-store-size-limit", "", "Maximum size of the image store, e.g. 20Gi (no limit if not set)", imageStoreSizeLimitEnv, defaultImageStoreSizeLimit, quantityPattern, &c.ImageStoreSizeLimit)
+	fs.addIntField("imageStoreHighWatermark", "image-store-high-watermark", "", "Image store usage, in percents of the size limit, that triggers the eviction of unused images", imageStoreHighWatermarkEnv, defaultImageStoreHighWatermark, 1, 100, &c.ImageStoreHighWatermark)
+	fs.addIntField("imageStoreLowWatermark", "image-store-low-watermark", "", "Image store usage, in percents of the size limit, to reduce the store to when evicting unused images", imageStoreLowWatermarkEnv, defaultImageStoreLowWatermark, 0, 100, &c.ImageStoreLowWatermark)
+	fs.addStringFieldWithPattern("imageDownloadRateLimit", "image-download-rate-limit", "", "Maximum total image download rate in bytes per second, e.g. 10Mi (no limit if not set)", imageDownloadRateLimitEnv, defaultImageDownloadRateLimit, quantityPattern, &c.ImageDownloadRateLimit)
+	fs.addIntField("maxConcurrentImageDownloads", "max-concurrent-image-downloads", "", "Maximum number of images that can be downloaded at the same time (0 means no limit)", maxConcurrentImageDownloadsEnv, defaultMaxConcurrentImageDownloads, 0, 1000, &c.MaxConcurrentImageDownloads)
+	fs.addStringField("imagePreloadDir", "image-preload-dir", "", "Directory with the image files to import into the image store on startup (not used if not set)", imagePreloadDirEnv, defaultImagePreloadDir, &c.ImagePreloadDir)
 	// this field duplicates glog's --v, so no option for it, which is signified
 	// by "+" here (it's only for doc)
 	fs.addIntField("logLevel", "+v", "", "Log level to use", logLevelEnv, 1, 0, math.MaxInt32, &c.LogLevel)
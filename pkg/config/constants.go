@@ -27,4 +27,8 @@ const (
 	LogPathEnvVarName = "VIRTLET_CONTAINER_LOG_PATH"
 	// NetKeyEnvVarName contains name of env variable passed from virtlet to vmwrapper
 	NetKeyEnvVarName = "VIRTLET_NET_KEY"
+	// NetPCIeRootPortsEnvVarName contains name of env variable passed from virtlet to vmwrapper
+	NetPCIeRootPortsEnvVarName = "VIRTLET_NET_PCIE_ROOT_PORTS"
+	// TPMStateDirEnvVarName contains name of env variable passed from virtlet to vmwrapper
+	TPMStateDirEnvVarName = "VIRTLET_TPM_STATE_DIR"
 )
@@ -79,6 +79,9 @@ func GetCRDDefinitions() []runtime.Object {
 					Kind:       "VirtletImageMapping",
 					ShortNames: []string{"vim"},
 				},
+				Subresources: &apiext.CustomResourceSubresources{
+					Status: &apiext.CustomResourceSubresourceStatus{},
+				},
 			},
 		},
 		&apiext.CustomResourceDefinition{
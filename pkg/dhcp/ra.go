@@ -0,0 +1,137 @@
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dhcp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// Router advertisements as defined in rfc4861
+const (
+	icmpv6RouterSolicitation  = 133
+	icmpv6RouterAdvertisement = 134
+
+	ndOptSourceLinkLayerAddr = 1
+	ndOptPrefixInfo          = 3
+	ndOptMTU                 = 5
+
+	raCurHopLimit = 64
+	// M (managed address configuration) and O (other
+	// configuration) flags that make the VM use DHCPv6
+	raFlagsManagedAndOther = 0xc0
+	// L (on-link) flag for the prefix. A (autonomous address
+	// configuration) flag is not set because the VM must use the
+	// address provided by CNI.
+	prefixFlagOnLink = 0x80
+
+	unsolicitedRAInterval = 60 * time.Second
+)
+
+var (
+	allNodes   = net.ParseIP("ff02::1")
+	allRouters = net.ParseIP("ff02::2")
+)
+
+// routerAdvertisement returns an ICMPv6 router advertisement message
+// that tells the VM to use DHCPv6 for address configuration.
+// Router lifetime is zero because the container network namespace
+// doesn't route the traffic of the VM, so the default route must be
+// provided by other means. The checksum is filled in by the kernel.
+func routerAdvertisement(hwAddr net.HardwareAddr, mtu uint16, prefix *net.IPNet) []byte {
+	var b bytes.Buffer
+	b.Write([]byte{icmpv6RouterAdvertisement, 0, 0, 0, raCurHopLimit, raFlagsManagedAndOther})
+	// router lifetime, reachable time, retransmission timer
+	b.Write(make([]byte, 10))
+
+	b.Write([]byte{ndOptSourceLinkLayerAddr, 1})
+	b.Write(hwAddr)
+
+	b.Write([]byte{ndOptMTU, 1, 0, 0})
+	binary.Write(&b, binary.BigEndian, uint32(mtu))
+
+	if prefix != nil {
+		if ones, bits := prefix.Mask.Size(); bits == 8*net.IPv6len && ones < bits {
+			b.Write([]byte{ndOptPrefixInfo, 4, byte(ones), prefixFlagOnLink})
+			binary.Write(&b, binary.BigEndian, uint32(dhcpv6LeaseTime))
+			binary.Write(&b, binary.BigEndian, uint32(dhcpv6LeaseTime))
+			b.Write(make([]byte, 4))
+			b.Write(prefix.IP.Mask(prefix.Mask).To16())
+		}
+	}
+
+	return b.Bytes()
+}
+
+func (s *Server) routerAdvertisement(l *ipv6Link) []byte {
+	var prefix *net.IPNet
+	if cfg := s.getIPv6Config(l.csnIfaceNo); cfg != nil {
+		prefix = &cfg.Address
+	}
+	return routerAdvertisement(l.hwAddr, s.config.Interfaces[l.csnIfaceNo].MTU, prefix)
+}
+
+func (s *Server) sendRouterAdvertisement(l *ipv6Link, dst net.IP) {
+	addr := &net.IPAddr{IP: dst, Zone: l.name}
+	if _, err := l.raConn.WriteTo(s.routerAdvertisement(l), addr); err != nil {
+		// this may happen while the link-local address
+		// of the link is still tentative
+		glog.V(3).Infof("Failed to send router advertisement to %s: %v", addr, err)
+	}
+}
+
+// sendUnsolicitedRouterAdvertisements periodically sends router
+// advertisements to all nodes on the link until the server is closed
+func (s *Server) sendUnsolicitedRouterAdvertisements(l *ipv6Link) {
+	ticker := time.NewTicker(unsolicitedRAInterval)
+	defer ticker.Stop()
+	for {
+		s.sendRouterAdvertisement(l, allNodes)
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Server) serveRouterSolicitations(l *ipv6Link) error {
+	buf := make([]byte, 1500)
+	for {
+		n, addr, err := l.raConn.ReadFrom(buf)
+		if err != nil {
+			return fmt.Errorf("receiving ICMPv6 packet on %s: %v", l.name, err)
+		}
+		if n == 0 || buf[0] != icmpv6RouterSolicitation {
+			continue
+		}
+		glog.V(2).Infof("Received router solicitation from %s on %s", addr, l.name)
+
+		// reply with unicast unless the source address is
+		// unspecified, see rfc4861 section 6.2.6
+		dst := allNodes
+		if ipAddr, ok := addr.(*net.IPAddr); ok && !ipAddr.IP.IsUnspecified() {
+			dst = ipAddr.IP
+		}
+		s.sendRouterAdvertisement(l, dst)
+	}
+}
@@ -26,6 +26,7 @@ import (
 	"io"
 	"net"
 	"strings"
+	"sync"
 
 	cnicurrent "github.com/containernetworking/cni/pkg/types/current"
 	"github.com/golang/glog"
@@ -46,13 +47,19 @@ var (
 
 // Server implements a DHCP server that runs in the container network namespace.
 type Server struct {
-	config   *network.ContainerSideNetwork
-	listener *dhcp4.Conn
+	config    *network.ContainerSideNetwork
+	listener  *dhcp4.Conn
+	ipv6Links []*ipv6Link
+	stopCh    chan struct{}
+	wg        sync.WaitGroup
 }
 
 // NewServer returns an initialized instance of Server.
 func NewServer(config *network.ContainerSideNetwork) *Server {
-	return &Server{config: config}
+	return &Server{
+		config: config,
+		stopCh: make(chan struct{}),
+	}
 }
 
 // SetupListener sets up a DHCP4 listener that listens on the default DHCP
@@ -68,13 +75,21 @@ func (s *Server) SetupListener(laddr string) error {
 	return nil
 }
 
-// Close shuts down DHCP listener.
+// Close shuts down DHCP listeners.
 func (s *Server) Close() error {
+	close(s.stopCh)
+	for _, l := range s.ipv6Links {
+		l.close()
+	}
+	s.wg.Wait()
 	return s.listener.Close()
 }
 
 // Serve waits in an endless loop for DHCP requests and answers them.
+// DHCPv6 requests and router solicitations are handled in separate
+// goroutines.
 func (s *Server) Serve() error {
+	s.serveIPv6()
 	for {
 		pkt, intf, err := s.listener.RecvDHCP()
 		if err != nil {
@@ -280,6 +295,10 @@ func (s *Server) getStaticRoutes(ip net.IPNet) (router, routes []byte, err error
 			return nil, nil, fmt.Errorf("invalid route: %#v", route)
 		}
 		dstIP := route.Dst.IP.To4()
+		if dstIP == nil {
+			// IPv6 routes can't be passed via DHCPv4
+			continue
+		}
 		gw := route.GW
 		if gw == nil {
 			// FIXME: this should not be really needed for newer CNI
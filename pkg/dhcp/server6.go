@@ -0,0 +1,448 @@
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dhcp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+
+	cnicurrent "github.com/containernetworking/cni/pkg/types/current"
+	"github.com/golang/glog"
+
+	"github.com/Mirantis/virtlet/pkg/network"
+)
+
+// DHCPv6 as defined in rfc3315
+const (
+	dhcpv6ServerPort = 547
+	dhcpv6ClientPort = 546
+
+	dhcpv6MsgSolicit            = 1
+	dhcpv6MsgAdvertise          = 2
+	dhcpv6MsgRequest            = 3
+	dhcpv6MsgConfirm            = 4
+	dhcpv6MsgRenew              = 5
+	dhcpv6MsgRebind             = 6
+	dhcpv6MsgReply              = 7
+	dhcpv6MsgRelease            = 8
+	dhcpv6MsgDecline            = 9
+	dhcpv6MsgInformationRequest = 11
+
+	dhcpv6OptClientID    = 1
+	dhcpv6OptServerID    = 2
+	dhcpv6OptIANA        = 3
+	dhcpv6OptIAAddr      = 5
+	dhcpv6OptStatusCode  = 13
+	dhcpv6OptRapidCommit = 14
+	// options 23 and 24 are defined in rfc3646
+	dhcpv6OptDNSServers = 23
+	dhcpv6OptDomainList = 24
+
+	dhcpv6StatusSuccess   = 0
+	dhcpv6StatusNotOnLink = 4
+
+	// DUID-LL, ethernet
+	duidTypeLL     = 3
+	hwTypeEthernet = 1
+
+	// 43200 - 12h
+	dhcpv6RenewalTime = 43200
+	// 64800 - 18h
+	dhcpv6RebindingTime = 64800
+	// 86400 - full 24h
+	dhcpv6LeaseTime = 86400
+)
+
+var (
+	allDHCPServersAndRelays = net.ParseIP("ff02::1:2")
+	defaultDNS6             = net.ParseIP("2001:4860:4860::8888")
+)
+
+// ipv6Link describes a link in the container network namespace
+// that's used to serve DHCPv6 and router advertisements to the VM
+type ipv6Link struct {
+	name string
+	// csnIfaceNo is the index of the corresponding interface
+	// of the container side network
+	csnIfaceNo int
+	hwAddr     net.HardwareAddr
+	dhcpConn   net.PacketConn
+	raConn     net.PacketConn
+	// unsolicitedRAs is true if router advertisements
+	// multicast on this link can't leak outside the pod
+	unsolicitedRAs bool
+}
+
+func (l *ipv6Link) close() {
+	if err := l.dhcpConn.Close(); err != nil {
+		glog.Warningf("Error closing DHCPv6 connection on %s: %v", l.name, err)
+	}
+	if err := l.raConn.Close(); err != nil {
+		glog.Warningf("Error closing ICMPv6 connection on %s: %v", l.name, err)
+	}
+}
+
+type dhcpv6Option struct {
+	code uint16
+	data []byte
+}
+
+type dhcpv6Packet struct {
+	msgType       byte
+	transactionID [3]byte
+	options       []dhcpv6Option
+}
+
+func parseDHCPv6Options(b []byte) ([]dhcpv6Option, error) {
+	var options []dhcpv6Option
+	for len(b) > 0 {
+		if len(b) < 4 {
+			return nil, errors.New("truncated DHCPv6 option header")
+		}
+		code := binary.BigEndian.Uint16(b)
+		size := int(binary.BigEndian.Uint16(b[2:]))
+		if len(b) < 4+size {
+			return nil, fmt.Errorf("truncated DHCPv6 option %d", code)
+		}
+		options = append(options, dhcpv6Option{code: code, data: b[4 : 4+size]})
+		b = b[4+size:]
+	}
+	return options, nil
+}
+
+func parseDHCPv6(b []byte) (*dhcpv6Packet, error) {
+	if len(b) < 4 {
+		return nil, errors.New("DHCPv6 packet too short")
+	}
+	options, err := parseDHCPv6Options(b[4:])
+	if err != nil {
+		return nil, err
+	}
+	p := &dhcpv6Packet{
+		msgType: b[0],
+		options: options,
+	}
+	copy(p.transactionID[:], b[1:4])
+	return p, nil
+}
+
+func writeDHCPv6Option(b *bytes.Buffer, code uint16, data []byte) {
+	binary.Write(b, binary.BigEndian, code)
+	binary.Write(b, binary.BigEndian, uint16(len(data)))
+	b.Write(data)
+}
+
+func (p *dhcpv6Packet) marshal() []byte {
+	var b bytes.Buffer
+	b.WriteByte(p.msgType)
+	b.Write(p.transactionID[:])
+	for _, opt := range p.options {
+		writeDHCPv6Option(&b, opt.code, opt.data)
+	}
+	return b.Bytes()
+}
+
+func (p *dhcpv6Packet) option(code uint16) []byte {
+	for _, opt := range p.options {
+		if opt.code == code {
+			return opt.data
+		}
+	}
+	return nil
+}
+
+func (p *dhcpv6Packet) hasOption(code uint16) bool {
+	for _, opt := range p.options {
+		if opt.code == code {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *dhcpv6Packet) addOption(code uint16, data []byte) {
+	p.options = append(p.options, dhcpv6Option{code: code, data: data})
+}
+
+func duid(hwAddr net.HardwareAddr) []byte {
+	var b bytes.Buffer
+	binary.Write(&b, binary.BigEndian, uint16(duidTypeLL))
+	binary.Write(&b, binary.BigEndian, uint16(hwTypeEthernet))
+	b.Write(hwAddr)
+	return b.Bytes()
+}
+
+func statusCode(code uint16) []byte {
+	return []byte{byte(code >> 8), byte(code & 0xff)}
+}
+
+func ianaOption(iaid []byte, addr net.IP) []byte {
+	var iaAddr bytes.Buffer
+	iaAddr.Write(addr.To16())
+	binary.Write(&iaAddr, binary.BigEndian, uint32(dhcpv6LeaseTime))
+	binary.Write(&iaAddr, binary.BigEndian, uint32(dhcpv6LeaseTime))
+
+	var b bytes.Buffer
+	b.Write(iaid)
+	binary.Write(&b, binary.BigEndian, uint32(dhcpv6RenewalTime))
+	binary.Write(&b, binary.BigEndian, uint32(dhcpv6RebindingTime))
+	writeDHCPv6Option(&b, dhcpv6OptIAAddr, iaAddr.Bytes())
+	return b.Bytes()
+}
+
+// iaAddresses returns the addresses included in the IA_NA options
+// of the packet
+func (p *dhcpv6Packet) iaAddresses() ([]net.IP, error) {
+	var r []net.IP
+	for _, opt := range p.options {
+		if opt.code != dhcpv6OptIANA {
+			continue
+		}
+		if len(opt.data) < 12 {
+			return nil, errors.New("IA_NA option too short")
+		}
+		iaOptions, err := parseDHCPv6Options(opt.data[12:])
+		if err != nil {
+			return nil, err
+		}
+		for _, iaOpt := range iaOptions {
+			if iaOpt.code != dhcpv6OptIAAddr {
+				continue
+			}
+			if len(iaOpt.data) < net.IPv6len {
+				return nil, errors.New("IAADDR option too short")
+			}
+			r = append(r, net.IP(iaOpt.data[:net.IPv6len]))
+		}
+	}
+	return r, nil
+}
+
+func (s *Server) dnsServers6() []byte {
+	var b bytes.Buffer
+	for _, nsIP := range s.config.Result.DNS.Nameservers {
+		ip := net.ParseIP(nsIP)
+		if ip != nil && ip.To4() == nil {
+			b.Write(ip)
+		}
+	}
+	if b.Len() == 0 {
+		return defaultDNS6
+	}
+	return b.Bytes()
+}
+
+// getIPv6Config returns IPv6 config for the VM interface that
+// corresponds to the specified interface of the container side
+// network
+func (s *Server) getIPv6Config(csnIfaceNo int) *cnicurrent.IPConfig {
+	interfaceNo := s.getInterfaceNo(s.config.Interfaces[csnIfaceNo].HardwareAddr)
+	if interfaceNo < 0 {
+		return nil
+	}
+	for _, curCfg := range s.config.Result.IPs {
+		if curCfg.Version == "6" && curCfg.Interface == interfaceNo {
+			return curCfg
+		}
+	}
+	return nil
+}
+
+// prepareResponse6 returns a response for the DHCPv6 packet received
+// on the link that's connected to the specified interface of the
+// container side network. It returns nil if the packet must be
+// ignored.
+func (s *Server) prepareResponse6(pkt *dhcpv6Packet, csnIfaceNo int, serverHwAddr net.HardwareAddr) (*dhcpv6Packet, error) {
+	serverID := duid(serverHwAddr)
+	if reqServerID := pkt.option(dhcpv6OptServerID); reqServerID != nil && !bytes.Equal(reqServerID, serverID) {
+		// the packet is addressed to another server
+		return nil, nil
+	}
+
+	cfg := s.getIPv6Config(csnIfaceNo)
+	if cfg == nil {
+		return nil, fmt.Errorf("IPv6 config for interface %s is not specified in CNI config", s.config.Interfaces[csnIfaceNo].HardwareAddr)
+	}
+
+	p := &dhcpv6Packet{
+		msgType:       dhcpv6MsgReply,
+		transactionID: pkt.transactionID,
+	}
+	clientID := pkt.option(dhcpv6OptClientID)
+	if clientID != nil {
+		p.addOption(dhcpv6OptClientID, clientID)
+	} else if pkt.msgType != dhcpv6MsgInformationRequest {
+		return nil, errors.New("DHCPv6 packet without client id")
+	}
+	p.addOption(dhcpv6OptServerID, serverID)
+
+	switch pkt.msgType {
+	case dhcpv6MsgSolicit, dhcpv6MsgRequest, dhcpv6MsgRenew, dhcpv6MsgRebind:
+		if pkt.msgType == dhcpv6MsgSolicit {
+			if pkt.hasOption(dhcpv6OptRapidCommit) {
+				p.addOption(dhcpv6OptRapidCommit, nil)
+			} else {
+				p.msgType = dhcpv6MsgAdvertise
+			}
+		}
+		// there's only one address for the interface,
+		// so it's only given to the first IA
+		if iana := pkt.option(dhcpv6OptIANA); iana != nil {
+			if len(iana) < 12 {
+				return nil, errors.New("IA_NA option too short")
+			}
+			p.addOption(dhcpv6OptIANA, ianaOption(iana[:4], cfg.Address.IP))
+		}
+	case dhcpv6MsgConfirm:
+		addrs, err := pkt.iaAddresses()
+		if err != nil {
+			return nil, err
+		}
+		status := uint16(dhcpv6StatusSuccess)
+		for _, addr := range addrs {
+			if !cfg.Address.Contains(addr) {
+				status = dhcpv6StatusNotOnLink
+			}
+		}
+		p.addOption(dhcpv6OptStatusCode, statusCode(status))
+		return p, nil
+	case dhcpv6MsgRelease, dhcpv6MsgDecline:
+		p.addOption(dhcpv6OptStatusCode, statusCode(dhcpv6StatusSuccess))
+		return p, nil
+	case dhcpv6MsgInformationRequest:
+	default:
+		glog.Warningf("Ignoring DHCPv6 packet of type %d", pkt.msgType)
+		return nil, nil
+	}
+
+	p.addOption(dhcpv6OptDNSServers, s.dnsServers6())
+	if len(s.config.Result.DNS.Search) != 0 {
+		// unlike DHCPv4, each domain name must be terminated
+		domainList, err := compressedDomainList(s.config.Result.DNS.Search)
+		if err != nil {
+			return nil, err
+		}
+		p.addOption(dhcpv6OptDomainList, append(domainList, 0))
+	}
+
+	return p, nil
+}
+
+func (s *Server) serveDHCPv6(l *ipv6Link) error {
+	buf := make([]byte, 1500)
+	for {
+		n, addr, err := l.dhcpConn.ReadFrom(buf)
+		if err != nil {
+			return fmt.Errorf("receiving DHCPv6 packet on %s: %v", l.name, err)
+		}
+		pkt, err := parseDHCPv6(buf[:n])
+		if err != nil {
+			glog.Warningf("Bad DHCPv6 packet from %s on %s: %v", addr, l.name, err)
+			continue
+		}
+		glog.V(2).Infof("Received DHCPv6 packet of type %d from %s on %s", pkt.msgType, addr, l.name)
+
+		resp, err := s.prepareResponse6(pkt, l.csnIfaceNo, l.hwAddr)
+		if err != nil {
+			glog.Warningf("Failed to construct DHCPv6 response for %s on %s: %v", addr, l.name, err)
+			continue
+		}
+		if resp == nil {
+			continue
+		}
+
+		udpAddr, ok := addr.(*net.UDPAddr)
+		if !ok {
+			glog.Warningf("Unexpected DHCPv6 client address %s", addr)
+			continue
+		}
+		dst := &net.UDPAddr{IP: udpAddr.IP, Port: dhcpv6ClientPort, Zone: l.name}
+		glog.V(2).Infof("Sending DHCPv6 packet of type %d to %s", resp.msgType, dst)
+		if _, err := l.dhcpConn.WriteTo(resp.marshal(), dst); err != nil {
+			glog.Warningf("Failed to send DHCPv6 response to %s: %v", dst, err)
+		}
+	}
+}
+
+// SetupIPv6Listeners sets up DHCPv6 and router solicitation listeners
+// on the specified links. The links must be listed in the order of
+// the interfaces of the container side network, with empty names
+// for the interfaces that don't need the listeners. The links that
+// correspond to the interfaces without IPv6 configuration are
+// skipped.
+func (s *Server) SetupIPv6Listeners(linkNames []string) error {
+	for i, linkName := range linkNames {
+		if linkName == "" || s.getIPv6Config(i) == nil {
+			continue
+		}
+		ifi, err := net.InterfaceByName(linkName)
+		if err != nil {
+			return fmt.Errorf("can't find link %q: %v", linkName, err)
+		}
+		dhcpConn, err := listenIPv6(linkName, syscall.SOCK_DGRAM, syscall.IPPROTO_UDP, dhcpv6ServerPort, allDHCPServersAndRelays)
+		if err != nil {
+			return fmt.Errorf("failed to set up DHCPv6 listener on %q: %v", linkName, err)
+		}
+		raConn, err := listenIPv6(linkName, syscall.SOCK_RAW, syscall.IPPROTO_ICMPV6, 0, allRouters)
+		if err != nil {
+			dhcpConn.Close()
+			return fmt.Errorf("failed to set up router solicitation listener on %q: %v", linkName, err)
+		}
+		s.ipv6Links = append(s.ipv6Links, &ipv6Link{
+			name:           linkName,
+			csnIfaceNo:     i,
+			hwAddr:         ifi.HardwareAddr,
+			dhcpConn:       dhcpConn,
+			raConn:         raConn,
+			unsolicitedRAs: s.config.Interfaces[i].Type != network.InterfaceTypeVF,
+		})
+	}
+
+	return nil
+}
+
+func (s *Server) runIPv6(f func() error) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		if err := f(); err != nil {
+			select {
+			case <-s.stopCh:
+			default:
+				glog.Errorf("IPv6 server error: %v", err)
+			}
+		}
+	}()
+}
+
+func (s *Server) serveIPv6() {
+	for _, l := range s.ipv6Links {
+		l := l
+		s.runIPv6(func() error { return s.serveDHCPv6(l) })
+		s.runIPv6(func() error { return s.serveRouterSolicitations(l) })
+		if l.unsolicitedRAs {
+			s.runIPv6(func() error {
+				s.sendUnsolicitedRouterAdvertisements(l)
+				return nil
+			})
+		}
+	}
+}
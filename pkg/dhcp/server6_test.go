@@ -0,0 +1,182 @@
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dhcp
+
+import (
+	"encoding/hex"
+	"net"
+	"strings"
+	"testing"
+
+	cnitypes "github.com/containernetworking/cni/pkg/types"
+	cnicurrent "github.com/containernetworking/cni/pkg/types/current"
+
+	"github.com/Mirantis/virtlet/pkg/network"
+)
+
+const (
+	vmMac     = "42:a4:a6:22:80:2e"
+	serverMac = "02:00:00:00:00:01"
+	// client id option with DUID-LL for vmMac
+	clientIDHex = "0001 000a 00030001" + "42a4a622802e"
+)
+
+func mustParseMAC(s string) net.HardwareAddr {
+	hwAddr, err := net.ParseMAC(s)
+	if err != nil {
+		panic(err)
+	}
+	return hwAddr
+}
+
+func mustParseCIDR(s string) net.IPNet {
+	ip, ipNet, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	ipNet.IP = ip
+	return *ipNet
+}
+
+func mustDecodeHex(s string) []byte {
+	b, err := hex.DecodeString(strings.Replace(s, " ", "", -1))
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func newTestServer() *Server {
+	return NewServer(&network.ContainerSideNetwork{
+		Result: &cnicurrent.Result{
+			Interfaces: []*cnicurrent.Interface{
+				{
+					Name: "eth0",
+					Mac:  vmMac,
+				},
+			},
+			IPs: []*cnicurrent.IPConfig{
+				{
+					Version:   "4",
+					Interface: 0,
+					Address:   mustParseCIDR("10.1.90.5/24"),
+				},
+				{
+					Version:   "6",
+					Interface: 0,
+					Address:   mustParseCIDR("fd00:1234::5/64"),
+				},
+			},
+			DNS: cnitypes.DNS{
+				Nameservers: []string{"10.96.0.10", "fd00:96::10"},
+				Search:      []string{"example.com"},
+			},
+		},
+		Interfaces: []*network.InterfaceDescription{
+			{
+				Type:         network.InterfaceTypeTap,
+				HardwareAddr: mustParseMAC(vmMac),
+				MTU:          1500,
+			},
+		},
+	})
+}
+
+func TestDHCPv6Responses(t *testing.T) {
+	serverIDHex := "0002 000a 00030001" + "020000000001"
+	iaNAHex := "0003 0028 00000001 0000a8c0 0000fd20" +
+		"0005 0018 fd001234000000000000000000000005 00015180 00015180"
+	dnsHex := "0017 0010 fd000096000000000000000000000010" +
+		"0018 000d 076578616d706c6503636f6d00"
+	for _, tc := range []struct {
+		name, request, response string
+	}{
+		{
+			name:     "solicit",
+			request:  "01 123456 " + clientIDHex + "0003 000c 00000001 00000000 00000000",
+			response: "02 123456 " + clientIDHex + serverIDHex + iaNAHex + dnsHex,
+		},
+		{
+			name:     "solicit with rapid commit",
+			request:  "01 123456 " + clientIDHex + "000e 0000 0003 000c 00000001 00000000 00000000",
+			response: "07 123456 " + clientIDHex + serverIDHex + "000e 0000" + iaNAHex + dnsHex,
+		},
+		{
+			name:     "request",
+			request:  "03 abcdef " + clientIDHex + serverIDHex + "0003 000c 00000001 00000000 00000000",
+			response: "07 abcdef " + clientIDHex + serverIDHex + iaNAHex + dnsHex,
+		},
+		{
+			name:    "request for another server",
+			request: "03 abcdef " + clientIDHex + "0002 000a 00030001 020000000002 0003 000c 00000001 00000000 00000000",
+		},
+		{
+			name:     "information request",
+			request:  "0b abcdef",
+			response: "07 abcdef" + serverIDHex + dnsHex,
+		},
+		{
+			name: "confirm on-link address",
+			request: "04 abcdef " + clientIDHex + "0003 0028 00000001 00000000 00000000" +
+				"0005 0018 fd001234000000000000000000000005 00000000 00000000",
+			response: "07 abcdef " + clientIDHex + serverIDHex + "000d 0002 0000",
+		},
+		{
+			name: "confirm address that's not on-link",
+			request: "04 abcdef " + clientIDHex + "0003 0028 00000001 00000000 00000000" +
+				"0005 0018 fd004321000000000000000000000005 00000000 00000000",
+			response: "07 abcdef " + clientIDHex + serverIDHex + "000d 0002 0004",
+		},
+		{
+			name:     "release",
+			request:  "08 abcdef " + clientIDHex + serverIDHex + iaNAHex,
+			response: "07 abcdef " + clientIDHex + serverIDHex + "000d 0002 0000",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			pkt, err := parseDHCPv6(mustDecodeHex(tc.request))
+			if err != nil {
+				t.Fatalf("parseDHCPv6(): %v", err)
+			}
+			resp, err := newTestServer().prepareResponse6(pkt, 0, mustParseMAC(serverMac))
+			if err != nil {
+				t.Fatalf("prepareResponse6(): %v", err)
+			}
+			var actual string
+			if resp != nil {
+				actual = hex.EncodeToString(resp.marshal())
+			}
+			expected := hex.EncodeToString(mustDecodeHex(tc.response))
+			if actual != expected {
+				t.Errorf("bad response:\n%s\ninstead of\n%s", actual, expected)
+			}
+		})
+	}
+}
+
+func TestRouterAdvertisement(t *testing.T) {
+	prefix := mustParseCIDR("fd00:1234::5/64")
+	actual := hex.EncodeToString(routerAdvertisement(mustParseMAC(serverMac), 1450, &prefix))
+	expected := hex.EncodeToString(mustDecodeHex(
+		"86 00 0000 40 c0 0000 00000000 00000000" +
+			"01 01 020000000001" +
+			"05 01 0000 000005aa" +
+			"03 04 40 80 00015180 00015180 00000000 fd001234000000000000000000000000"))
+	if actual != expected {
+		t.Errorf("bad router advertisement:\n%s\ninstead of\n%s", actual, expected)
+	}
+}
@@ -0,0 +1,85 @@
+// +build linux
+
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dhcp
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+)
+
+// listenIPv6 returns a connection that's bound to the specified link
+// and has joined the specified multicast group on it. For datagram
+// sockets, the connection is bound to the specified port. Raw sockets
+// use the maximum hop limit as required for neighbor discovery.
+func listenIPv6(linkName string, sockType, proto, port int, group net.IP) (net.PacketConn, error) {
+	ifi, err := net.InterfaceByName(linkName)
+	if err != nil {
+		return nil, fmt.Errorf("can't find link %q: %v", linkName, err)
+	}
+
+	fd, err := syscall.Socket(syscall.AF_INET6, sockType|syscall.SOCK_CLOEXEC|syscall.SOCK_NONBLOCK, proto)
+	if err != nil {
+		return nil, fmt.Errorf("socket(): %v", err)
+	}
+	f := os.NewFile(uintptr(fd), fmt.Sprintf("ipv6-%s", linkName))
+	defer f.Close()
+
+	if err := syscall.SetsockoptString(fd, syscall.SOL_SOCKET, syscall.SO_BINDTODEVICE, linkName); err != nil {
+		return nil, fmt.Errorf("failed to bind socket to %q: %v", linkName, err)
+	}
+	for _, opt := range []struct {
+		level, name, value int
+	}{
+		{syscall.IPPROTO_IPV6, syscall.IPV6_MULTICAST_IF, ifi.Index},
+		{syscall.IPPROTO_IPV6, syscall.IPV6_MULTICAST_LOOP, 0},
+	} {
+		if err := syscall.SetsockoptInt(fd, opt.level, opt.name, opt.value); err != nil {
+			return nil, fmt.Errorf("setsockopt(): %v", err)
+		}
+	}
+	if sockType == syscall.SOCK_RAW {
+		for _, name := range []int{syscall.IPV6_MULTICAST_HOPS, syscall.IPV6_UNICAST_HOPS} {
+			if err := syscall.SetsockoptInt(fd, syscall.IPPROTO_IPV6, name, 255); err != nil {
+				return nil, fmt.Errorf("setsockopt(): %v", err)
+			}
+		}
+	} else {
+		if err := syscall.SetsockoptInt(fd, syscall.IPPROTO_IPV6, syscall.IPV6_V6ONLY, 1); err != nil {
+			return nil, fmt.Errorf("setsockopt(): %v", err)
+		}
+		// there can be several DHCPv6 sockets bound to
+		// different links in the same network namespace
+		if err := syscall.SetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 1); err != nil {
+			return nil, fmt.Errorf("setsockopt(): %v", err)
+		}
+		if err := syscall.Bind(fd, &syscall.SockaddrInet6{Port: port}); err != nil {
+			return nil, fmt.Errorf("bind(): %v", err)
+		}
+	}
+
+	mreq := &syscall.IPv6Mreq{Interface: uint32(ifi.Index)}
+	copy(mreq.Multiaddr[:], group.To16())
+	if err := syscall.SetsockoptIPv6Mreq(fd, syscall.IPPROTO_IPV6, syscall.IPV6_JOIN_GROUP, mreq); err != nil {
+		return nil, fmt.Errorf("failed to join multicast group %s on %q: %v", group, linkName, err)
+	}
+
+	return net.FilePacketConn(f)
+}
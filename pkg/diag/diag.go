@@ -55,6 +55,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"net"
@@ -169,8 +170,9 @@ func (ds *Set) RegisterDiagSource(name string, source Source) {
 }
 
 // RunDiagnostics collects the diagnostic information from all of the
-// available sources.
-func (ds *Set) RunDiagnostics() Result {
+// available sources. If any source names are specified, only these
+// sources are used.
+func (ds *Set) RunDiagnostics(sourceNames ...string) Result {
 	ds.Lock()
 	defer ds.Unlock()
 	r := Result{
@@ -178,7 +180,21 @@ func (ds *Set) RunDiagnostics() Result {
 		IsDir:    true,
 		Children: make(map[string]Result),
 	}
-	for name, src := range ds.sources {
+	sources := ds.sources
+	if len(sourceNames) != 0 {
+		sources = make(map[string]Source)
+		for _, name := range sourceNames {
+			if src, found := ds.sources[name]; found {
+				sources[name] = src
+			} else {
+				r.Children[name] = Result{
+					Name:  name,
+					Error: fmt.Sprintf("unknown diagnostics source %q", name),
+				}
+			}
+		}
+	}
+	for name, src := range sources {
 		dr, err := src.DiagnosticInfo()
 		if dr.Name == "" {
 			dr.Name = name
@@ -195,9 +211,17 @@ func (ds *Set) RunDiagnostics() Result {
 	return r
 }
 
+// request denotes a diagnostics request that's sent by the client
+// after connecting to the server.
+type request struct {
+	// Sources contains the names of the sources to use.
+	// If it's empty, all of the sources are used.
+	Sources []string `json:"sources,omitempty"`
+}
+
 // Server denotes a diagnostics server that listens on a unix domain
-// socket and spews out a piece of JSON content on a socket
-// connection.
+// socket, reads a request and spews out a piece of JSON content on a
+// socket connection.
 type Server struct {
 	sync.Mutex
 	ds     *Set
@@ -216,7 +240,11 @@ func NewServer(diagSet *Set) *Server {
 
 func (s *Server) dump(conn net.Conn) error {
 	defer conn.Close()
-	r := s.ds.RunDiagnostics()
+	var req request
+	if err := json.NewDecoder(conn).Decode(&req); err != nil && err != io.EOF {
+		return fmt.Errorf("error decoding diagnostics request: %v", err)
+	}
+	r := s.ds.RunDiagnostics(req.Sources...)
 	bs, err := json.Marshal(&r)
 	if err != nil {
 		return fmt.Errorf("error marshalling diagnostics info: %v", err)
@@ -298,8 +326,9 @@ func (s *Server) Stop() {
 }
 
 // RetrieveDiagnostics retrieves the diagnostic info from the
-// specified UNIX domain socket.
-func RetrieveDiagnostics(socketPath string) (Result, error) {
+// specified UNIX domain socket. If any source names are specified,
+// only these sources are queried.
+func RetrieveDiagnostics(socketPath string, sourceNames ...string) (Result, error) {
 	addr, err := net.ResolveUnixAddr("unix", socketPath)
 	if err != nil {
 		return Result{}, fmt.Errorf("failed to resolve unix addr %q: %v", socketPath, err)
@@ -309,8 +338,20 @@ func RetrieveDiagnostics(socketPath string) (Result, error) {
 	if err != nil {
 		return Result{}, fmt.Errorf("can't connect to %q: %v", socketPath, err)
 	}
+	defer conn.Close()
+
+	bs, err := json.Marshal(request{Sources: sourceNames})
+	if err != nil {
+		return Result{}, fmt.Errorf("error marshalling diagnostics request: %v", err)
+	}
+	if _, err := conn.Write(bs); err != nil {
+		return Result{}, fmt.Errorf("can't send diagnostics request: %v", err)
+	}
+	if err := conn.CloseWrite(); err != nil {
+		return Result{}, fmt.Errorf("can't send diagnostics request: %v", err)
+	}
 
-	bs, err := ioutil.ReadAll(conn)
+	bs, err = ioutil.ReadAll(conn)
 	if err != nil {
 		return Result{}, fmt.Errorf("can't read diagnostics: %v", err)
 	}
@@ -176,3 +176,35 @@ func TestDiagServer(t *testing.T) {
 		t.Errorf("Bad dir structure after Unpack(). Expected:\n%s--- got ---\n%s", spew.Sdump(expectedFiles), spew.Sdump(files))
 	}
 }
+
+func TestDiagServerSourceSelection(t *testing.T) {
+	dt := newDiagTester(t)
+	defer dt.teardown()
+	expectedResult := Result{
+		Name:  "diagnostics",
+		IsDir: true,
+		Children: map[string]Result{
+			"foo": {
+				Name: "foo",
+				Ext:  "txt",
+				Data: "this is foo",
+			},
+			"simple_text": {
+				Name: "simple_text",
+				Ext:  "txt",
+				Data: "baz",
+			},
+			"nosuchsource": {
+				Name:  "nosuchsource",
+				Error: `unknown diagnostics source "nosuchsource"`,
+			},
+		},
+	}
+	dr, err := RetrieveDiagnostics(dt.socketPath, "foo", "simple_text", "nosuchsource")
+	if err != nil {
+		t.Fatalf("RetrieveDiagnostics(): %v", err)
+	}
+	if !reflect.DeepEqual(expectedResult, dr) {
+		t.Errorf("Bad diag result. Expected:\n%s--- got ---\n%s", spew.Sdump(expectedResult), spew.Sdump(dr))
+	}
+}
@@ -22,6 +22,9 @@ import (
 	"syscall"
 )
 
+// hugetlbfsMagic is the filesystem type magic number of hugetlbfs
+const hugetlbfsMagic = 0x958458f6
+
 // GetFsStatsForPath returns the info about inode usage and space usage
 // (in bytes) for the filesystem that contains the provided path.
 func GetFsStatsForPath(path string) (uint64, uint64, error) {
@@ -31,3 +34,17 @@ func GetFsStatsForPath(path string) (uint64, uint64, error) {
 	}
 	return (fs.Blocks - fs.Bfree) * uint64(fs.Bsize), fs.Files - fs.Ffree, nil
 }
+
+// GetHugePageSizeForPath returns the size of huge pages (in bytes)
+// for the filesystem that contains the provided path if it's hugetlbfs,
+// or 0 otherwise.
+func GetHugePageSizeForPath(path string) (uint64, error) {
+	fs := syscall.Statfs_t{}
+	if err := syscall.Statfs(path, &fs); err != nil {
+		return 0, err
+	}
+	if uint32(fs.Type) != hugetlbfsMagic {
+		return 0, nil
+	}
+	return uint64(fs.Bsize), nil
+}
@@ -26,3 +26,8 @@ import (
 func GetFsStatsForPath(path string) (uint64, uint64, error) {
 	return 0, 0, errors.New("not implemented")
 }
+
+// GetHugePageSizeForPath is a placeholder for an unimplemented function
+func GetHugePageSizeForPath(path string) (uint64, error) {
+	return 0, errors.New("not implemented")
+}
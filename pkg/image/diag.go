@@ -0,0 +1,46 @@
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package image
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/Mirantis/virtlet/pkg/diag"
+)
+
+const (
+	// PullProgressDiagSourceName is the name of the diagnostics
+	// source that provides the progress of the active image pulls.
+	PullProgressDiagSourceName = "image-pulls"
+)
+
+// GetPullProgressSource returns a Source that dumps the progress
+// of the active image pulls as JSON.
+func GetPullProgressSource(store Store) diag.Source {
+	return diag.NewSimpleTextSource("json", func() (string, error) {
+		pulls := store.ActivePulls()
+		if pulls == nil {
+			pulls = []PullProgress{}
+		}
+		bs, err := json.Marshal(pulls)
+		if err != nil {
+			return "", fmt.Errorf("error marshalling pull progress: %v", err)
+		}
+		return string(bs), nil
+	})
+}
@@ -31,6 +31,7 @@ import (
 	"time"
 
 	"github.com/golang/glog"
+	digest "github.com/opencontainers/go-digest"
 )
 
 const (
@@ -43,6 +44,14 @@ type Endpoint struct {
 	// configured default one is used.
 	URL string
 
+	// Mirrors are the alternative URLs of the image that are tried
+	// in order if the download from URL fails
+	Mirrors []string
+
+	// FastestMirror makes the downloader try URL and Mirrors in the
+	// order of their response times
+	FastestMirror bool
+
 	// MaxRedirects is the maximum number of redirects that downloader is allowed to follow. -1 for stdlib default (fails on request #10)
 	MaxRedirects int
 
@@ -57,6 +66,74 @@ type Endpoint struct {
 
 	// Transport profile name for this endpoint. Provided for logging/debugging
 	ProfileName string
+
+	// MaxBytesPerSecond limits the total download rate of the endpoints
+	// that use the same transport profile. <= 0 is no limit (default)
+	MaxBytesPerSecond int64
+
+	// Credentials are used to authenticate with the image server.
+	// nil means no authentication (default)
+	Credentials *Credentials
+
+	// Headers are the additional HTTP headers to send with the requests
+	Headers map[string]string
+}
+
+// Credentials specify the credentials for the image server
+type Credentials struct {
+	// Username is the user name for the basic authentication
+	Username string
+
+	// Password is the password for the basic authentication
+	Password string
+
+	// BearerToken is passed to the server in the Authorization
+	// header. It takes precedence over Username and Password
+	BearerToken string
+}
+
+// fingerprint returns a string that identifies the credentials
+// without disclosing them
+func (c *Credentials) fingerprint() string {
+	if c == nil {
+		return ""
+	}
+	return digest.FromString(strings.Join([]string{c.Username, c.Password, c.BearerToken}, "\x00")).Hex()
+}
+
+// authRoundTripper adds the credentials and the additional headers
+// to the requests. To avoid leaking them when the server redirects
+// the client elsewhere, e.g. to a CDN, only the requests that are
+// sent to the host of the original URL are modified.
+type authRoundTripper struct {
+	host    string
+	creds   *Credentials
+	headers map[string]string
+	rt      http.RoundTripper
+}
+
+func (a *authRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host != a.host {
+		return a.rt.RoundTrip(req)
+	}
+	// RoundTrip must not modify the request
+	newReq := new(http.Request)
+	*newReq = *req
+	newReq.Header = make(http.Header, len(req.Header)+len(a.headers)+1)
+	for k, v := range req.Header {
+		newReq.Header[k] = append([]string(nil), v...)
+	}
+	for k, v := range a.headers {
+		newReq.Header.Set(k, v)
+	}
+	switch {
+	case a.creds == nil:
+	case a.creds.BearerToken != "":
+		newReq.Header.Set("Authorization", "Bearer "+a.creds.BearerToken)
+	default:
+		newReq.SetBasicAuth(a.creds.Username, a.creds.Password)
+	}
+	return a.rt.RoundTrip(newReq)
 }
 
 // TLSConfig has the TLS transport parameters
@@ -86,8 +163,18 @@ type Downloader interface {
 	DownloadFile(ctx context.Context, endpoint Endpoint, w io.Writer) error
 }
 
+// SizeReceiver is an optional interface that can be implemented
+// by the writers passed to DownloadFile() so the downloader can
+// tell them the total size of the file being downloaded.
+type SizeReceiver interface {
+	// SetTotalSize sets the total size of the file
+	SetTotalSize(size int64)
+}
+
 type defaultDownloader struct {
 	protocol string
+	limiter  *downloadLimiter
+	mirrors  *mirrorTracker
 }
 
 // NewDownloader returns the default downloader for 'protocol'.
@@ -95,7 +182,17 @@ type defaultDownloader struct {
 // 'protocol://location' and saves it in temporary file in default
 // system directory for temporary files
 func NewDownloader(protocol string) Downloader {
-	return &defaultDownloader{protocol}
+	return NewLimitedDownloader(protocol, DownloadLimits{})
+}
+
+// NewLimitedDownloader returns the default downloader for 'protocol'
+// that enforces the specified node-wide download limits.
+func NewLimitedDownloader(protocol string, limits DownloadLimits) Downloader {
+	return &defaultDownloader{
+		protocol: protocol,
+		limiter:  newDownloadLimiter(limits),
+		mirrors:  newMirrorTracker(),
+	}
 }
 
 func buildTLSConfig(config *TLSConfig, profileName string) (*tls.Config, error) {
@@ -157,11 +254,32 @@ func createTransport(endpoint Endpoint) (*http.Transport, error) {
 	}, nil
 }
 
-func createHTTPClient(endpoint Endpoint) (*http.Client, error) {
-	transport, err := createTransport(endpoint)
+// createHTTPClient returns an HTTP client for the endpoint. The
+// credentials and the additional headers of the endpoint are only
+// sent to the host of authURL, which must be the original URL of the
+// image, so they don't leak to the mirrors.
+func createHTTPClient(endpoint Endpoint, authURL string) (*http.Client, error) {
+	baseTransport, err := createTransport(endpoint)
 	if err != nil {
 		return nil, err
 	}
+	transport := http.RoundTripper(baseTransport)
+
+	if endpoint.Credentials != nil || len(endpoint.Headers) != 0 {
+		u, err := url.Parse(authURL)
+		if err != nil {
+			return nil, fmt.Errorf("bad image URL %q: %v", authURL, err)
+		}
+		if endpoint.Credentials != nil && u.Scheme != "https" {
+			glog.Warningf("Sending the image server credentials over insecure connection to %s", u.Host)
+		}
+		transport = &authRoundTripper{
+			host:    u.Host,
+			creds:   endpoint.Credentials,
+			headers: endpoint.Headers,
+			rt:      transport,
+		}
+	}
 
 	var checkRedirects func(req *http.Request, via []*http.Request) error
 	if endpoint.MaxRedirects >= 0 {
@@ -180,42 +298,127 @@ func createHTTPClient(endpoint Endpoint) (*http.Client, error) {
 	}, nil
 }
 
-func (d *defaultDownloader) DownloadFile(ctx context.Context, endpoint Endpoint, w io.Writer) error {
-	url := endpoint.URL
+func (d *defaultDownloader) fullURL(url string) string {
 	if !strings.Contains(url, "://") {
-		url = fmt.Sprintf("%s://%s", d.protocol, url)
+		return fmt.Sprintf("%s://%s", d.protocol, url)
 	}
+	return url
+}
 
-	client, err := createHTTPClient(endpoint)
+// downloadFrom downloads the file from the specified URL. The boolean
+// value returned is true if any data was written to w.
+// Transport errors and 5xx responses are returned as mirrorError.
+func (d *defaultDownloader) downloadFrom(ctx context.Context, endpoint Endpoint, url string, w io.Writer) (bool, error) {
+	client, err := createHTTPClient(endpoint, d.fullURL(endpoint.URL))
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	glog.V(2).Infof("Start downloading %s", url)
 
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		return err
+		return false, err
 	}
 	req = req.WithContext(ctx)
 	resp, err := client.Do(req)
 	if err != nil {
-		return err
+		return false, &mirrorError{err}
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("bad http status %q", resp.Status)
+	if err := checkStatus(resp); err != nil {
+		return false, err
 	}
 
-	if _, err = io.CopyBuffer(w, resp.Body, make([]byte, copyBufferSize)); err != nil {
-		return err
+	if sr, ok := w.(SourceReceiver); ok {
+		sr.SetSourceURL(url)
+	}
+	if sr, ok := w.(SizeReceiver); ok && resp.ContentLength >= 0 {
+		sr.SetTotalSize(resp.ContentLength)
+	}
+
+	cw := &countingWriter{w: d.limiter.wrapWriter(ctx, endpoint, w)}
+	if _, err = io.CopyBuffer(cw, resp.Body, make([]byte, copyBufferSize)); err != nil {
+		if cw.err == nil {
+			// failed to read the response body
+			err = &mirrorError{err}
+		}
+		return cw.n != 0, err
 	}
 
 	if f, ok := w.(*os.File); ok {
 		glog.V(2).Infof("Data from url %s saved as %q", url, f.Name())
 	}
-	return nil
+	return true, nil
+}
+
+func (d *defaultDownloader) DownloadFile(ctx context.Context, endpoint Endpoint, w io.Writer) error {
+	if err := d.limiter.acquire(ctx); err != nil {
+		return err
+	}
+	defer d.limiter.release()
+
+	if len(endpoint.Mirrors) == 0 {
+		_, err := d.downloadFrom(ctx, endpoint, d.fullURL(endpoint.URL), w)
+		return err
+	}
+
+	var urls []string
+	for _, u := range append([]string{endpoint.URL}, endpoint.Mirrors...) {
+		if u != "" {
+			urls = append(urls, d.fullURL(u))
+		}
+	}
+	urls = d.mirrors.order(urls)
+	if endpoint.FastestMirror {
+		urls = d.mirrors.orderByLatency(ctx, endpoint, d.fullURL(endpoint.URL), urls)
+	}
+
+	var errs []string
+	for n, url := range urls {
+		written, err := d.downloadFrom(ctx, endpoint, url, w)
+		if err == nil {
+			d.mirrors.markHealthy(url)
+			glog.V(1).Infof("Downloaded %s from mirror %d of %d: %s", endpoint.URL, n+1, len(urls), url)
+			return nil
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		if _, ok := err.(*mirrorError); ok {
+			d.mirrors.markFailed(url)
+		}
+		glog.Warningf("Error downloading %s from mirror %s: %v", endpoint.URL, url, err)
+		errs = append(errs, fmt.Sprintf("%s: %v", url, err))
+		if written {
+			rw, ok := w.(Rewinder)
+			if !ok {
+				return err
+			}
+			if err := rw.Rewind(); err != nil {
+				return fmt.Errorf("can't retry the download using another mirror: %v", err)
+			}
+		}
+	}
+	return fmt.Errorf("all the mirrors failed:\n%s", strings.Join(errs, "\n"))
+}
+
+// countingWriter counts the bytes written to the underlying writer
+// and records the write error, if any.
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (cw *countingWriter) Write(data []byte) (int, error) {
+	n, err := cw.w.Write(data)
+	cw.n += int64(n)
+	if err != nil {
+		cw.err = err
+	}
+	return n, err
 }
 
 // Note that the tests for defaultDownloader are in 'imagetranslation' package (FIXME)
@@ -20,9 +20,11 @@ import (
 	"bytes"
 	"context"
 	"crypto/tls"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -152,3 +154,441 @@ func TestNotFound(t *testing.T) {
 		t.Errorf("bad error message for nonexistent image")
 	}
 }
+
+func TestDownloadWithCredentials(t *testing.T) {
+	var cdnAuth []string
+	cdn := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cdnAuth = append(cdnAuth, r.Header.Get("Authorization"))
+		w.Write([]byte("foobar"))
+	}))
+	defer cdn.Close()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch user, password, ok := r.BasicAuth(); {
+		case r.Header.Get("Authorization") == "Bearer sometoken":
+		case ok && user == "user" && password == "secret":
+		default:
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.String() {
+		case "/base.qcow2":
+			w.Write([]byte("foobar"))
+		case "/cdn.qcow2":
+			http.Redirect(w, r, cdn.URL+"/base.qcow2", http.StatusFound)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	for _, tc := range []struct {
+		name     string
+		path     string
+		creds    *Credentials
+		mustFail bool
+	}{
+		{
+			name:     "no credentials",
+			path:     "/base.qcow2",
+			mustFail: true,
+		},
+		{
+			name:     "bad password",
+			path:     "/base.qcow2",
+			creds:    &Credentials{Username: "user", Password: "foobar"},
+			mustFail: true,
+		},
+		{
+			name:  "basic auth",
+			path:  "/base.qcow2",
+			creds: &Credentials{Username: "user", Password: "secret"},
+		},
+		{
+			name:  "bearer token",
+			path:  "/base.qcow2",
+			creds: &Credentials{BearerToken: "sometoken"},
+		},
+		{
+			name:  "redirect to another host",
+			path:  "/cdn.qcow2",
+			creds: &Credentials{BearerToken: "sometoken"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cdnAuth = nil
+			downloader := NewDownloader("http")
+			var buf bytes.Buffer
+			err := downloader.DownloadFile(context.Background(), Endpoint{
+				URL:          ts.Listener.Addr().String() + tc.path,
+				MaxRedirects: -1,
+				Credentials:  tc.creds,
+			}, &buf)
+			switch {
+			case tc.mustFail && err == nil:
+				t.Errorf("DownloadFile() didn't fail")
+			case tc.mustFail && !strings.Contains(err.Error(), "Unauthorized"):
+				t.Errorf("DownloadFile() returned unexpected error: %v", err)
+			case !tc.mustFail && err != nil:
+				t.Errorf("DownloadFile(): %v", err)
+			case !tc.mustFail && buf.String() != "foobar":
+				t.Errorf("bad content: %q instead of %q", buf.String(), "foobar")
+			}
+			for _, auth := range cdnAuth {
+				if auth != "" {
+					t.Errorf("credentials leaked to another host: %q", auth)
+				}
+			}
+		})
+	}
+}
+
+func waitForQueuedDownloads(downloader Downloader, n int) error {
+	l := downloader.(*defaultDownloader).limiter
+	for i := 0; i < 1000; i++ {
+		l.Lock()
+		queued := len(l.waiters)
+		l.Unlock()
+		if queued == n {
+			return nil
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return fmt.Errorf("timed out waiting for %d queued downloads", n)
+}
+
+func TestDownloadConcurrencyLimit(t *testing.T) {
+	release := make(chan struct{})
+	requests := make(chan string, 10)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- r.URL.Path
+		<-release
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write([]byte("foobar"))
+	}))
+	defer ts.Close()
+
+	downloader := NewLimitedDownloader("http", DownloadLimits{MaxConcurrentDownloads: 1})
+	names := []string{"/a.qcow2", "/b.qcow2", "/c.qcow2"}
+	errCh := make(chan error, len(names))
+	for n, name := range names {
+		go func(name string) {
+			var buf bytes.Buffer
+			errCh <- downloader.DownloadFile(context.Background(), Endpoint{
+				URL: ts.Listener.Addr().String() + name,
+			}, &buf)
+		}(name)
+		if n == 0 {
+			if path := <-requests; path != name {
+				t.Fatalf("bad request path %q instead of %q", path, name)
+			}
+		} else if err := waitForQueuedDownloads(downloader, n); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, name := range names[1:] {
+		release <- struct{}{}
+		if path := <-requests; path != name {
+			t.Errorf("bad request path %q instead of %q", path, name)
+		}
+		if len(requests) != 0 {
+			t.Errorf("the concurrent download limit is exceeded")
+		}
+	}
+	release <- struct{}{}
+	for range names {
+		if err := <-errCh; err != nil {
+			t.Errorf("DownloadFile(): %v", err)
+		}
+	}
+}
+
+func TestCancelQueuedDownload(t *testing.T) {
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write([]byte("foobar"))
+	}))
+	defer ts.Close()
+
+	downloader := NewLimitedDownloader("http", DownloadLimits{MaxConcurrentDownloads: 1})
+	ep := Endpoint{URL: ts.Listener.Addr().String() + "/base.qcow2"}
+	errCh := make(chan error, 1)
+	go func() {
+		var buf bytes.Buffer
+		errCh <- downloader.DownloadFile(context.Background(), ep, &buf)
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		if err := waitForQueuedDownloads(downloader, 1); err != nil {
+			t.Error(err)
+		}
+		cancel()
+	}()
+	var buf bytes.Buffer
+	switch err := downloader.DownloadFile(ctx, ep, &buf); {
+	case err == nil:
+		t.Errorf("DownloadFile() didn't return error after being cancelled")
+	case !strings.Contains(err.Error(), "context canceled"):
+		t.Errorf("DownloadFile() is expected to return Cancelled error but returned %q", err)
+	}
+
+	close(release)
+	if err := <-errCh; err != nil {
+		t.Errorf("DownloadFile(): %v", err)
+	}
+	// the download slot must be available again
+	verifyLimitedDownload(t, downloader, "foobar", ep)
+}
+
+func verifyLimitedDownload(t *testing.T, downloader Downloader, content string, ep Endpoint) {
+	var buf bytes.Buffer
+	if err := downloader.DownloadFile(context.Background(), ep, &buf); err != nil {
+		t.Fatalf("DownloadFile(): %v", err)
+	}
+	if buf.String() != content {
+		t.Errorf("bad content: %q instead of %q", buf.String(), content)
+	}
+}
+
+func TestDownloadRateLimit(t *testing.T) {
+	content := strings.Repeat("x", 96*1024)
+	ts := httptest.NewServer(downloadHandler(content))
+	defer ts.Close()
+	for _, tc := range []struct {
+		name   string
+		limits DownloadLimits
+		ep     Endpoint
+	}{
+		{
+			name:   "node-wide limit",
+			limits: DownloadLimits{MaxBytesPerSecond: 64 * 1024},
+			ep:     Endpoint{URL: ts.Listener.Addr().String() + "/base.qcow2"},
+		},
+		{
+			name: "transport profile limit",
+			ep: Endpoint{
+				URL:               ts.Listener.Addr().String() + "/base.qcow2",
+				ProfileName:       "slow",
+				MaxBytesPerSecond: 64 * 1024,
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			downloader := NewLimitedDownloader("http", tc.limits)
+			start := time.Now()
+			// the first 64 KiB are downloaded at once, and the
+			// rest takes about 0.5s
+			verifyLimitedDownload(t, downloader, content, tc.ep)
+			if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+				t.Errorf("the download rate limit is not enforced: the download took %v", elapsed)
+			}
+		})
+	}
+}
+
+type mirrorTestWriter struct {
+	bytes.Buffer
+	sourceURL string
+	rewinds   int
+}
+
+func (w *mirrorTestWriter) SetSourceURL(url string) {
+	w.sourceURL = url
+}
+
+func (w *mirrorTestWriter) Rewind() error {
+	w.Reset()
+	w.rewinds++
+	return nil
+}
+
+type mirrorServer struct {
+	*httptest.Server
+	sync.Mutex
+	hits   int
+	probes int
+}
+
+func newMirrorServer(handler http.HandlerFunc) *mirrorServer {
+	s := &mirrorServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.Lock()
+		if r.Method == "HEAD" {
+			s.probes++
+		} else {
+			s.hits++
+		}
+		s.Unlock()
+		handler(w, r)
+	}))
+	return s
+}
+
+func (s *mirrorServer) url() string {
+	return s.Listener.Addr().String() + "/base.qcow2"
+}
+
+func (s *mirrorServer) verifyHits(t *testing.T, hits, probes int) {
+	s.Lock()
+	defer s.Unlock()
+	if s.hits != hits || s.probes != probes {
+		t.Errorf("bad number of requests for %s: %d GETs and %d HEADs instead of %d and %d", s.url(), s.hits, s.probes, hits, probes)
+	}
+}
+
+func serviceUnavailable(w http.ResponseWriter, r *http.Request) {
+	http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+}
+
+func TestDownloadMirrorFailover(t *testing.T) {
+	down := newMirrorServer(serviceUnavailable)
+	defer down.Close()
+	up := newMirrorServer(downloadHandler("foobar"))
+	defer up.Close()
+	downloader := NewDownloader("http")
+	ep := Endpoint{URL: down.url(), Mirrors: []string{up.url()}}
+
+	for i := 0; i < 2; i++ {
+		var w mirrorTestWriter
+		if err := downloader.DownloadFile(context.Background(), ep, &w); err != nil {
+			t.Fatalf("DownloadFile(): %v", err)
+		}
+		if w.String() != "foobar" {
+			t.Errorf("bad content: %q instead of %q", w.String(), "foobar")
+		}
+		if w.sourceURL != "http://"+up.url() {
+			t.Errorf("bad source url %q", w.sourceURL)
+		}
+	}
+	// the failed mirror isn't tried again for some time
+	down.verifyHits(t, 1, 0)
+	up.verifyHits(t, 2, 0)
+
+	var w mirrorTestWriter
+	ep.Mirrors = []string{"", down.url()}
+	switch err := downloader.DownloadFile(context.Background(), ep, &w); {
+	case err == nil:
+		t.Errorf("DownloadFile() didn't fail")
+	case !strings.Contains(err.Error(), "all the mirrors failed"):
+		t.Errorf("DownloadFile() returned unexpected error: %v", err)
+	}
+}
+
+func TestDownloadMirrorMissingImage(t *testing.T) {
+	mirror := newMirrorServer(downloadHandler("foobar"))
+	defer mirror.Close()
+	up := newMirrorServer(downloadHandler("foobar"))
+	defer up.Close()
+	downloader := NewDownloader("http")
+
+	// a missing image doesn't make the mirror considered failed
+	ep := Endpoint{URL: mirror.Listener.Addr().String() + "/missing.qcow2", Mirrors: []string{up.url()}}
+	var w mirrorTestWriter
+	if err := downloader.DownloadFile(context.Background(), ep, &w); err != nil {
+		t.Fatalf("DownloadFile(): %v", err)
+	}
+	mirror.verifyHits(t, 1, 0)
+
+	ep = Endpoint{URL: mirror.url(), Mirrors: []string{up.url()}}
+	w = mirrorTestWriter{}
+	if err := downloader.DownloadFile(context.Background(), ep, &w); err != nil {
+		t.Fatalf("DownloadFile(): %v", err)
+	}
+	if w.sourceURL != "http://"+mirror.url() {
+		t.Errorf("bad source url %q", w.sourceURL)
+	}
+	mirror.verifyHits(t, 2, 0)
+	up.verifyHits(t, 1, 0)
+}
+
+func TestDownloadMirrorCredentials(t *testing.T) {
+	var mirrorAuth []string
+	mirror := newMirrorServer(func(w http.ResponseWriter, r *http.Request) {
+		mirrorAuth = append(mirrorAuth, r.Header.Get("Authorization"), r.Header.Get("X-Custom"))
+		downloadHandler("foobar")(w, r)
+	})
+	defer mirror.Close()
+	down := newMirrorServer(serviceUnavailable)
+	defer down.Close()
+
+	var w mirrorTestWriter
+	ep := Endpoint{
+		URL:           down.url(),
+		Mirrors:       []string{mirror.url()},
+		FastestMirror: true,
+		Credentials:   &Credentials{BearerToken: "sometoken"},
+		Headers:       map[string]string{"X-Custom": "foo"},
+	}
+	if err := NewDownloader("http").DownloadFile(context.Background(), ep, &w); err != nil {
+		t.Fatalf("DownloadFile(): %v", err)
+	}
+	mirror.verifyHits(t, 1, 1)
+	for _, v := range mirrorAuth {
+		if v != "" {
+			t.Errorf("credentials or headers leaked to the mirror: %q", v)
+		}
+	}
+}
+
+func TestDownloadFastestMirror(t *testing.T) {
+	slow := newMirrorServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "HEAD" {
+			time.Sleep(300 * time.Millisecond)
+		}
+		downloadHandler("foobar")(w, r)
+	})
+	defer slow.Close()
+	fast := newMirrorServer(downloadHandler("foobar"))
+	defer fast.Close()
+	down := newMirrorServer(serviceUnavailable)
+	defer down.Close()
+
+	var w mirrorTestWriter
+	downloader := NewDownloader("http")
+	ep := Endpoint{URL: down.url(), Mirrors: []string{slow.url(), fast.url()}, FastestMirror: true}
+	if err := downloader.DownloadFile(context.Background(), ep, &w); err != nil {
+		t.Fatalf("DownloadFile(): %v", err)
+	}
+	if w.String() != "foobar" {
+		t.Errorf("bad content: %q instead of %q", w.String(), "foobar")
+	}
+	if w.sourceURL != "http://"+fast.url() {
+		t.Errorf("bad source url %q", w.sourceURL)
+	}
+	down.verifyHits(t, 0, 1)
+	slow.verifyHits(t, 0, 1)
+	fast.verifyHits(t, 1, 1)
+}
+
+func TestDownloadMirrorRewind(t *testing.T) {
+	broken := newMirrorServer(func(w http.ResponseWriter, r *http.Request) {
+		// the connection is closed after sending just
+		// a part of the declared content
+		w.Header().Set("Content-Length", "1000")
+		w.Write([]byte("bad"))
+	})
+	defer broken.Close()
+	up := newMirrorServer(downloadHandler("foobar"))
+	defer up.Close()
+	ep := Endpoint{URL: broken.url(), Mirrors: []string{up.url()}}
+
+	var w mirrorTestWriter
+	if err := NewDownloader("http").DownloadFile(context.Background(), ep, &w); err != nil {
+		t.Fatalf("DownloadFile(): %v", err)
+	}
+	if w.String() != "foobar" {
+		t.Errorf("bad content: %q instead of %q", w.String(), "foobar")
+	}
+	if w.rewinds != 1 {
+		t.Errorf("bad number of rewinds: %d instead of 1", w.rewinds)
+	}
+
+	// can't switch to another mirror if the writer can't be rewound
+	var buf bytes.Buffer
+	if err := NewDownloader("http").DownloadFile(context.Background(), ep, &buf); err == nil {
+		t.Errorf("DownloadFile() didn't fail")
+	}
+}
@@ -0,0 +1,166 @@
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package image
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/golang/glog"
+	digest "github.com/opencontainers/go-digest"
+)
+
+// SizeLimit specifies the maximum size of the image store along
+// with the watermarks that control the eviction of unused images.
+type SizeLimit struct {
+	// MaxBytes is the maximum total size of the image data files.
+	// Zero means no limit.
+	MaxBytes uint64
+	// HighWatermark is the store usage, in percents of MaxBytes,
+	// that triggers the eviction of the unused images.
+	HighWatermark int
+	// LowWatermark is the store usage, in percents of MaxBytes,
+	// that the eviction tries to bring the store down to.
+	LowWatermark int
+}
+
+func (l SizeLimit) threshold(percent int) uint64 {
+	p := uint64(percent)
+	return l.MaxBytes/100*p + l.MaxBytes%100*p/100
+}
+
+// dataFileInfo describes an image data file.
+type dataFileInfo struct {
+	hexDigest string
+	size      uint64
+	// lastUsed is the time when the image was last pulled or
+	// used to create a container. It's stored as the modification
+	// time of the data file.
+	lastUsed time.Time
+}
+
+// SetSizeLimit sets the size limit for the store. Upon reaching
+// the high watermark, the least recently used images that aren't
+// referenced by any containers are removed until the store usage
+// drops to the low watermark.
+func (s *FileStore) SetSizeLimit(limit SizeLimit) {
+	s.Lock()
+	defer s.Unlock()
+	s.sizeLimit = limit
+}
+
+// dataDirUsage returns the list of complete image data files along
+// with the total number of bytes and files in the data directory,
+// including the partially downloaded images.
+func (s *FileStore) dataDirUsage() ([]dataFileInfo, uint64, uint64, error) {
+	infos, err := ioutil.ReadDir(s.dataDir())
+	switch {
+	case os.IsNotExist(err):
+		return nil, 0, 0, nil
+	case err != nil:
+		return nil, 0, 0, fmt.Errorf("readdir %q: %v", s.dataDir(), err)
+	}
+	var files []dataFileInfo
+	var usedBytes uint64
+	for _, fi := range infos {
+		if !fi.Mode().IsRegular() {
+			continue
+		}
+		usedBytes += uint64(fi.Size())
+		if digest.NewDigestFromHex(string(digest.SHA256), fi.Name()).Validate() != nil {
+			// partially downloaded image
+			continue
+		}
+		files = append(files, dataFileInfo{
+			hexDigest: fi.Name(),
+			size:      uint64(fi.Size()),
+			lastUsed:  fi.ModTime(),
+		})
+	}
+	return files, usedBytes, uint64(len(infos)), nil
+}
+
+func (s *FileStore) evictImages() error {
+	s.Lock()
+	defer s.Unlock()
+	return s.evictImagesUnlocked()
+}
+
+// evictImagesUnlocked removes the least recently used images that
+// aren't referenced by containers or active pulls if the size of
+// the store exceeds the high watermark, until the size drops to
+// the low watermark.
+func (s *FileStore) evictImagesUnlocked() error {
+	if s.sizeLimit.MaxBytes == 0 {
+		return nil
+	}
+	files, usedBytes, _, err := s.dataDirUsage()
+	if err != nil {
+		return err
+	}
+	if usedBytes <= s.sizeLimit.threshold(s.sizeLimit.HighWatermark) {
+		return nil
+	}
+
+	referenced, err := s.getReferencedHexDigests()
+	if err != nil {
+		return err
+	}
+	images, err := s.listImagesUnlocked("")
+	if err != nil {
+		return err
+	}
+	imageNames := make(map[string][]string)
+	for _, img := range images {
+		if hexDigest, err := img.hexDigest(); err != nil {
+			glog.Warningf("Eviction: error calculating digest for image %q: %v", img.Name, err)
+		} else {
+			imageNames[hexDigest] = append(imageNames[hexDigest], img.Name)
+		}
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].lastUsed.Before(files[j].lastUsed) })
+	target := s.sizeLimit.threshold(s.sizeLimit.LowWatermark)
+	for _, f := range files {
+		if usedBytes <= target {
+			break
+		}
+		if referenced[f.hexDigest] {
+			continue
+		}
+		for _, name := range imageNames[f.hexDigest] {
+			linkFileName := s.linkFileName(name)
+			if err := os.Remove(linkFileName); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("error removing %q: %v", linkFileName, err)
+			}
+		}
+		dataFileName := s.dataFileName(f.hexDigest)
+		glog.V(1).Infof("Eviction: removing image file %q (%d bytes, last used at %s)", dataFileName, f.size, f.lastUsed)
+		if err := os.Remove(dataFileName); err != nil {
+			return fmt.Errorf("error removing %q: %v", dataFileName, err)
+		}
+		usedBytes -= f.size
+	}
+
+	if usedBytes > s.sizeLimit.MaxBytes {
+		glog.Warningf("Image store size (%d bytes) exceeds the limit of %d bytes, but the remaining images are in use", usedBytes, s.sizeLimit.MaxBytes)
+	}
+	return nil
+}
@@ -19,6 +19,9 @@ package image
 import (
 	"context"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
 	"sort"
 
 	"github.com/docker/distribution/reference"
@@ -103,6 +106,54 @@ func (s *FakeStore) RemoveImage(name string) error {
 	return nil
 }
 
+// ImportImage implements ImportImage method of Store interface.
+func (s *FakeStore) ImportImage(name string, r io.Reader) (string, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	return s.addImage("ImportImage", name, data)
+}
+
+// CommitImage implements CommitImage method of Store interface.
+func (s *FakeStore) CommitImage(name string, write func(path string) error) (string, error) {
+	tempFile, err := ioutil.TempFile("", "fake-image-")
+	if err != nil {
+		return "", err
+	}
+	tempFile.Close()
+	defer os.Remove(tempFile.Name())
+	if err := write(tempFile.Name()); err != nil {
+		return "", err
+	}
+	data, err := ioutil.ReadFile(tempFile.Name())
+	if err != nil {
+		return "", err
+	}
+	return s.addImage("CommitImage", name, data)
+}
+
+func (s *FakeStore) addImage(recName, name string, data []byte) (string, error) {
+	name, _ = image.SplitImageName(name)
+	d := digest.FromBytes(data)
+	named, err := reference.WithName(name)
+	if err != nil {
+		return "", err
+	}
+	withDigest, err := reference.WithDigest(named, d)
+	if err != nil {
+		return "", err
+	}
+	s.images[name] = &image.Image{
+		Digest: d.String(),
+		Name:   name,
+		Path:   "/fake/volume/" + name,
+		Size:   uint64(len(data)),
+	}
+	s.rec.Rec(recName, s.images[name])
+	return withDigest.String(), nil
+}
+
 // GC implements GC method of Store interface.
 func (s *FakeStore) GC() error {
 	var err error
@@ -128,11 +179,21 @@ func (s *FakeStore) GetImagePathDigestAndVirtualSize(imageName string) (string,
 	return img.Path, digest.Digest(img.Digest), img.Size, nil
 }
 
+// MarkImageUsed implements MarkImageUsed method of Store interface.
+func (s *FakeStore) MarkImageUsed(ref string) error {
+	return nil
+}
+
 // SetRefGetter implements SetRefGetter method of Store interface.
 func (s *FakeStore) SetRefGetter(imageRefGetter image.RefGetter) {
 	s.refGetter = imageRefGetter
 }
 
+// ActivePulls implements ActivePulls method of Store interface.
+func (s *FakeStore) ActivePulls() []image.PullProgress {
+	return nil
+}
+
 // FilesystemStats implements FilesystemStats method from Store interface.
 func (s *FakeStore) FilesystemStats() (*types.FilesystemStats, error) {
 	return &types.FilesystemStats{
@@ -19,18 +19,19 @@ package image
 import (
 	"context"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/aykevl/osfs"
 	"github.com/docker/distribution/reference"
 	"github.com/golang/glog"
 	digest "github.com/opencontainers/go-digest"
 
-	"github.com/Mirantis/virtlet/pkg/fs"
 	"github.com/Mirantis/virtlet/pkg/metadata/types"
 )
 
@@ -54,6 +55,24 @@ func (img *Image) hexDigest() (string, error) {
 // Translator translates image name to a Endpoint.
 type Translator func(context.Context, string) Endpoint
 
+// TranslatorWithCredentials returns a Translator that sets the
+// specified credentials for the endpoints returned by the original
+// translator unless these endpoints already have credentials from
+// the transport profiles. If creds is nil, the original translator
+// is returned.
+func TranslatorWithCredentials(translator Translator, creds *Credentials) Translator {
+	if creds == nil {
+		return translator
+	}
+	return func(ctx context.Context, name string) Endpoint {
+		ep := translator(ctx, name)
+		if ep.Credentials == nil {
+			ep.Credentials = creds
+		}
+		return ep
+	}
+}
+
 // RefGetter is a function that returns the list of images
 // that are currently in use.
 type RefGetter func() (map[string]bool, error)
@@ -78,6 +97,16 @@ type Store interface {
 	// RemoveImage removes the specified image.
 	RemoveImage(name string) error
 
+	// ImportImage places the image data read from r into the
+	// store under the specified name and returns the image ref.
+	ImportImage(name string, r io.Reader) (string, error)
+
+	// CommitImage invokes write with the path of a temporary file
+	// in the store that's protected from GC, then places the file
+	// produced by write into the store under the specified name
+	// and returns the image ref.
+	CommitImage(name string, write func(path string) error) (string, error)
+
 	// GC removes all unused or partially downloaded images.
 	GC() error
 
@@ -86,10 +115,20 @@ type Store interface {
 	// image. It accepts an image reference or a digest.
 	GetImagePathDigestAndVirtualSize(ref string) (string, digest.Digest, uint64, error)
 
+	// MarkImageUsed updates the last use time of the specified
+	// image, which is used to pick the images to evict when the
+	// store exceeds its size limit. It accepts an image reference
+	// or a digest.
+	MarkImageUsed(ref string) error
+
 	// SetRefGetter sets a function that will be used to determine
 	// the set of images that are currently in use.
 	SetRefGetter(imageRefGetter RefGetter)
 
+	// ActivePulls returns the progress info for the image
+	// downloads that are currently in progress.
+	ActivePulls() []PullProgress
+
 	// FilesystemStats returns disk space and inode usage info for this store.
 	FilesystemStats() (*types.FilesystemStats, error)
 
@@ -109,6 +148,10 @@ type FileStore struct {
 	downloader Downloader
 	vsizeFunc  VirtualSizeFunc
 	refGetter  RefGetter
+	sizeLimit  SizeLimit
+	pullLock   sync.Mutex
+	pulls      map[string]*pull
+	tempFiles  map[string]bool
 }
 
 var _ Store = &FileStore{}
@@ -125,6 +168,8 @@ func NewFileStore(dir string, downloader Downloader, vsizeFunc VirtualSizeFunc)
 		dir:        dir,
 		downloader: downloader,
 		vsizeFunc:  vsizeFunc,
+		pulls:      make(map[string]*pull),
+		tempFiles:  make(map[string]bool),
 	}
 }
 
@@ -170,8 +215,11 @@ func (s *FileStore) renameIfNewOrDelete(oldPath string, newPath string) (bool, e
 	}
 }
 
-func (s *FileStore) getImageHexDigestsInUse() (map[string]bool, error) {
-	imagesInUse := make(map[string]bool)
+// getReferencedHexDigests returns the set of the hex digests
+// of the images that are used by the containers, as well as
+// the names of the data files used by the active pulls.
+func (s *FileStore) getReferencedHexDigests() (map[string]bool, error) {
+	referenced := make(map[string]bool)
 	var imgList []string
 	if s.refGetter != nil {
 		refSet, err := s.refGetter()
@@ -186,9 +234,18 @@ func (s *FileStore) getImageHexDigestsInUse() (map[string]bool, error) {
 	}
 	for _, imgSpec := range imgList {
 		if d := GetHexDigest(imgSpec); d != "" {
-			imagesInUse[d] = true
+			referenced[d] = true
 		}
 	}
+	s.addFilesUsedByPulls(referenced)
+	return referenced, nil
+}
+
+func (s *FileStore) getImageHexDigestsInUse() (map[string]bool, error) {
+	imagesInUse, err := s.getReferencedHexDigests()
+	if err != nil {
+		return nil, err
+	}
 	images, err := s.listImagesUnlocked("")
 	if err != nil {
 		return nil, err
@@ -252,6 +309,34 @@ func (s *FileStore) placeImage(tempPath string, dataName string, imageName strin
 		return fmt.Errorf("error placing the image %q to %q: %v", imageName, dataName, err)
 	}
 
+	if err := s.linkImageUnlocked(dataName, imageName); err != nil {
+		if isNew {
+			if err := os.Remove(dataPath); err != nil {
+				glog.Warningf("error removing %q: %v", dataPath, err)
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *FileStore) placeData(tempPath string, dataName string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	if _, err := s.renameIfNewOrDelete(tempPath, s.dataFileName(dataName)); err != nil {
+		return fmt.Errorf("error placing the image data to %q: %v", dataName, err)
+	}
+	return nil
+}
+
+func (s *FileStore) linkImage(dataName string, imageName string) error {
+	s.Lock()
+	defer s.Unlock()
+	return s.linkImageUnlocked(dataName, imageName)
+}
+
+func (s *FileStore) linkImageUnlocked(dataName string, imageName string) error {
 	if err := os.MkdirAll(s.linkDir(), 0777); err != nil {
 		return fmt.Errorf("mkdir %q: %v", s.linkDir(), err)
 	}
@@ -272,11 +357,6 @@ func (s *FileStore) placeImage(tempPath string, dataName string, imageName strin
 	}
 
 	if err := os.Symlink(filepath.Join("../data/", dataName), linkFileName); err != nil {
-		if isNew {
-			if err := os.Remove(dataPath); err != nil {
-				glog.Warningf("error removing %q: %v", dataPath, err)
-			}
-		}
 		return fmt.Errorf("error creating symbolic link %q for image %q: %v", linkFileName, imageName, err)
 	}
 	return nil
@@ -392,6 +472,82 @@ func (s *FileStore) PullImage(ctx context.Context, name string, translator Trans
 	name, specDigest := SplitImageName(name)
 	ep := translator(ctx, name)
 	glog.V(1).Infof("Image translation: %q -> %q", name, ep.URL)
+	p, err := s.fetchImage(ctx, name, ep)
+	if err != nil {
+		return "", err
+	}
+	// keep the pull active till the image is linked
+	// so the data file isn't removed by GC
+	defer s.releasePull(ep, p)
+	glog.V(1).Infof("Image %q pulled from %s", name, s.sourceURL(p))
+
+	d := p.digest
+	if specDigest != "" && d != specDigest {
+		return "", fmt.Errorf("image digest mismatch: %s instead of %s", d, specDigest)
+	}
+	if err := s.linkImage(d.Hex(), name); err != nil {
+		return "", err
+	}
+	if err := s.MarkImageUsed(d.String()); err != nil {
+		glog.Warningf("Error updating the last use time of %q: %v", name, err)
+	}
+	// the freshly pulled image can't be evicted here
+	// because the pull is still active
+	if err := s.evictImages(); err != nil {
+		glog.Warningf("Error evicting unused images: %v", err)
+	}
+	named, err := reference.WithName(name)
+	if err != nil {
+		return "", err
+	}
+	withDigest, err := reference.WithDigest(named, d)
+	if err != nil {
+		return "", err
+	}
+	return withDigest.String(), nil
+}
+
+// fetchImage makes sure the image data from the specified endpoint
+// is present in the data directory. If the same endpoint is already
+// being downloaded by another PullImage call, fetchImage waits for
+// that download to complete instead of starting a new one. Upon
+// success, it returns the finished pull that must be released by
+// the caller using releasePull().
+func (s *FileStore) fetchImage(ctx context.Context, name string, ep Endpoint) (*pull, error) {
+	for {
+		p, isNew := s.acquirePull(name, ep)
+		if isNew {
+			d, err := s.downloadImage(ctx, p, ep)
+			s.finishPull(ep, p, d, err, err != nil && ctx.Err() != nil)
+		} else {
+			glog.V(1).Infof("Waiting for the download of %q started by another pull", ep.URL)
+			select {
+			case <-p.done:
+			case <-ctx.Done():
+				s.releasePull(ep, p)
+				return nil, fmt.Errorf("error downloading %q: %v", ep.URL, ctx.Err())
+			}
+		}
+		switch {
+		case p.err == nil:
+			return p, nil
+		case !isNew && p.cancelled && ctx.Err() == nil:
+			// the download was aborted because the PullImage
+			// call that has started it was cancelled, but we
+			// still need the image
+			s.releasePull(ep, p)
+			continue
+		default:
+			s.releasePull(ep, p)
+			return nil, p.err
+		}
+	}
+}
+
+// downloadImage downloads the image from the specified endpoint,
+// places its data file into the data directory and returns its
+// digest.
+func (s *FileStore) downloadImage(ctx context.Context, p *pull, ep Endpoint) (digest.Digest, error) {
 	if err := os.MkdirAll(s.dataDir(), 0777); err != nil {
 		return "", fmt.Errorf("mkdir %q: %v", s.dataDir(), err)
 	}
@@ -404,7 +560,8 @@ func (s *FileStore) PullImage(ctx context.Context, name string, translator Trans
 			tempFile.Close()
 		}
 	}()
-	if err := s.downloader.DownloadFile(ctx, ep, tempFile); err != nil {
+	s.updatePull(p, filepath.Base(tempFile.Name()), "")
+	if err := s.downloader.DownloadFile(ctx, ep, &progressWriter{s: s, w: tempFile, p: p}); err != nil {
 		tempFile.Close()
 		if err := os.Remove(tempFile.Name()); err != nil {
 			glog.Warningf("Error removing %q: %v", tempFile.Name(), err)
@@ -425,21 +582,11 @@ func (s *FileStore) PullImage(ctx context.Context, name string, translator Trans
 	}
 	fileName := tempFile.Name()
 	tempFile = nil
-	if specDigest != "" && d != specDigest {
-		return "", fmt.Errorf("image digest mismatch: %s instead of %s", d, specDigest)
-	}
-	if err := s.placeImage(fileName, d.Hex(), name); err != nil {
+	s.updatePull(p, filepath.Base(fileName), d)
+	if err := s.placeData(fileName, d.Hex()); err != nil {
 		return "", err
 	}
-	named, err := reference.WithName(name)
-	if err != nil {
-		return "", err
-	}
-	withDigest, err := reference.WithDigest(named, d)
-	if err != nil {
-		return "", err
-	}
-	return withDigest.String(), nil
+	return d, nil
 }
 
 // RemoveImage implements RemoveImage method of Store interface.
@@ -472,7 +619,7 @@ func (s *FileStore) GC() error {
 			glog.Warningf("GC: removing %q: %v", m, err)
 		}
 	}
-	return nil
+	return s.evictImagesUnlocked()
 }
 
 // GetImagePathDigestAndVirtualSize implements GetImagePathDigestAndVirtualSize method of Store interface.
@@ -481,24 +628,51 @@ func (s *FileStore) GetImagePathDigestAndVirtualSize(ref string) (string, digest
 	defer s.Unlock()
 	glog.V(3).Infof("GetImagePathDigestAndVirtualSize(): %q", ref)
 
+	path, d, err := s.getImagePathAndDigestUnlocked(ref)
+	if err != nil {
+		return "", "", 0, err
+	}
+	vsize, err := s.vsizeFunc(path)
+	if err != nil {
+		return "", "", 0, fmt.Errorf("error getting image size for %q: %v", path, err)
+	}
+	return path, d, vsize, nil
+}
+
+// MarkImageUsed implements MarkImageUsed method of Store interface.
+func (s *FileStore) MarkImageUsed(ref string) error {
+	s.Lock()
+	defer s.Unlock()
+	path, _, err := s.getImagePathAndDigestUnlocked(ref)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if err := os.Chtimes(path, now, now); err != nil {
+		return fmt.Errorf("error updating the modification time of %q: %v", path, err)
+	}
+	return nil
+}
+
+func (s *FileStore) getImagePathAndDigestUnlocked(ref string) (string, digest.Digest, error) {
 	var pathViaDigest, pathViaName string
 	// parsing digest as ref gives bad results
 	d, err := digest.Parse(ref)
 	if err == nil {
 		if d.Algorithm() != digest.SHA256 {
-			return "", "", 0, fmt.Errorf("bad image digest (need sha256): %q", d)
+			return "", "", fmt.Errorf("bad image digest (need sha256): %q", d)
 		}
 		pathViaDigest = s.dataFileName(d.Hex())
 	} else {
 		parsed, err := reference.Parse(ref)
 		if err != nil {
-			return "", "", 0, fmt.Errorf("bad image reference %q: %v", ref, err)
+			return "", "", fmt.Errorf("bad image reference %q: %v", ref, err)
 		}
 
 		d = ""
 		if digested, ok := parsed.(reference.Digested); ok {
 			if digested.Digest().Algorithm() != digest.SHA256 {
-				return "", "", 0, fmt.Errorf("bad image digest (need sha256): %q", digested.Digest())
+				return "", "", fmt.Errorf("bad image digest (need sha256): %q", digested.Digest())
 			}
 			d = digested.Digest()
 			pathViaDigest = s.dataFileName(d.Hex())
@@ -518,28 +692,24 @@ func (s *FileStore) GetImagePathDigestAndVirtualSize(ref string) (string, digest
 	path := pathViaDigest
 	switch {
 	case pathViaDigest == "" && pathViaName == "":
-		return "", "", 0, fmt.Errorf("bad image reference %q", ref)
+		return "", "", fmt.Errorf("bad image reference %q", ref)
 	case pathViaDigest == "":
 		path = pathViaName
 	case pathViaName != "":
 		fi1, err := os.Stat(pathViaName)
 		if err != nil {
-			return "", "", 0, err
+			return "", "", err
 		}
 		fi2, err := os.Stat(pathViaDigest)
 		if err != nil {
-			return "", "", 0, err
+			return "", "", err
 		}
 		if !os.SameFile(fi1, fi2) {
-			return "", "", 0, fmt.Errorf("digest / name path mismatch: %q vs %q", pathViaDigest, pathViaName)
+			return "", "", fmt.Errorf("digest / name path mismatch: %q vs %q", pathViaDigest, pathViaName)
 		}
 	}
 
-	vsize, err := s.vsizeFunc(path)
-	if err != nil {
-		return "", "", 0, fmt.Errorf("error getting image size for %q: %v", path, err)
-	}
-	return path, d, vsize, nil
+	return path, d, nil
 }
 
 // SetRefGetter implements SetRefGetter method of Store interface.
@@ -591,11 +761,12 @@ func GetHexDigest(imageSpec string) string {
 }
 
 // FilesystemStats returns disk space and inode usage info for this store.
-// TODO: instead of returning data from filesystem we should retrieve from
-// metadata store sizes of images and sum them, or even retrieve precalculated
-// sum. That's because same filesystem could be used by other things than images.
+// Only the image data files are taken into account as the same filesystem
+// may be used by other things than images.
 func (s *FileStore) FilesystemStats() (*types.FilesystemStats, error) {
-	occupiedBytes, occupiedInodes, err := fs.GetFsStatsForPath(s.dir)
+	s.Lock()
+	_, occupiedBytes, occupiedInodes, err := s.dataDirUsage()
+	s.Unlock()
 	if err != nil {
 		return nil, err
 	}
@@ -19,6 +19,7 @@ package image
 import (
 	"context"
 	"crypto/sha256"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -29,6 +30,7 @@ import (
 	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/davecgh/go-spew/spew"
 )
@@ -45,6 +47,7 @@ type fakeDownloader struct {
 	t         *testing.T
 	cancelled bool
 	started   chan struct{}
+	release   chan struct{}
 }
 
 var _ Downloader = &fakeDownloader{}
@@ -53,7 +56,7 @@ var _ Downloader = &fakeDownloader{}
 // endpoint's url passed to it into the file instead of actually
 // downloading it.
 func newFakeDownloader(t *testing.T) *fakeDownloader {
-	return &fakeDownloader{t: t, started: make(chan struct{}, 100)}
+	return &fakeDownloader{t: t, started: make(chan struct{}, 100), release: make(chan struct{})}
 }
 
 func (d *fakeDownloader) DownloadFile(ctx context.Context, endpoint Endpoint, w io.Writer) error {
@@ -63,6 +66,16 @@ func (d *fakeDownloader) DownloadFile(ctx context.Context, endpoint Endpoint, w
 		d.cancelled = true
 		return ctx.Err()
 	}
+	if sr, ok := w.(SizeReceiver); ok {
+		sr.SetTotalSize(int64(len(endpoint.URL) + 3))
+	}
+	if strings.Contains(endpoint.URL, "slow") {
+		select {
+		case <-d.release:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
 	if f, ok := w.(*os.File); ok {
 		d.t.Logf("fakeDownloader: writing %q to %q", endpoint.URL, f.Name())
 	}
@@ -483,6 +496,192 @@ func TestCancelPullImage(t *testing.T) {
 	}
 }
 
+func TestConcurrentPulls(t *testing.T) {
+	tst := newIfsTester(t)
+	defer tst.teardown()
+
+	imageNames := []string{"slow", "slow:latest", "slow"}
+	expectedRef := "slow@sha256:" + sha256str("###slow")
+	errCh := make(chan error, len(imageNames))
+	for _, name := range imageNames {
+		go func(name string) {
+			ref, err := tst.store.PullImage(context.Background(), name, tst.translateImageName)
+			if err == nil && ref != expectedRef {
+				err = fmt.Errorf("bad image ref returned: %q instead of %q", ref, expectedRef)
+			}
+			errCh <- err
+		}(name)
+	}
+
+	var pulls []PullProgress
+	for i := 0; i < 1000; i++ {
+		pulls = tst.store.ActivePulls()
+		if len(pulls) == 1 && pulls[0].Waiters == len(imageNames) {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	switch {
+	case len(pulls) != 1:
+		t.Fatalf("expected exactly one active pull, got:\n%s", spew.Sdump(pulls))
+	case pulls[0].URL != "slow" || pulls[0].BytesTotal != 7 || pulls[0].BytesDone != 0:
+		t.Errorf("bad pull progress:\n%s", spew.Sdump(pulls[0]))
+	case pulls[0].Waiters != len(imageNames):
+		t.Errorf("bad waiter count %d instead of %d", pulls[0].Waiters, len(imageNames))
+	}
+
+	close(tst.downloader.release)
+	for range imageNames {
+		if err := <-errCh; err != nil {
+			t.Errorf("PullImage(): %v", err)
+		}
+	}
+
+	if n := len(tst.downloader.started); n != 1 {
+		t.Errorf("the image was downloaded %d times instead of once", n)
+	}
+	if pulls := tst.store.ActivePulls(); len(pulls) != 0 {
+		t.Errorf("unexpected active pulls after the download:\n%s", spew.Sdump(pulls))
+	}
+	tst.verifySubpathContents("links/slow", "###slow")
+	tst.verifyDataFiles(sha256str("###slow"))
+}
+
+func (tst *ifsTester) setLastUsed(hexDigest string, t time.Time) {
+	p := tst.subpath("data/" + hexDigest)
+	if err := os.Chtimes(p, t, t); err != nil {
+		tst.t.Fatalf("Chtimes(): %q: %v", p, err)
+	}
+}
+
+func TestImageEviction(t *testing.T) {
+	tst := newIfsTester(t)
+	defer tst.teardown()
+	// each of the images takes 10 bytes, so the eviction starts
+	// when the 3rd image is pulled and stops after removing
+	// one of the images
+	tst.store.SetSizeLimit(SizeLimit{MaxBytes: 30, HighWatermark: 90, LowWatermark: 70})
+
+	var refs, hexDigests []string
+	for n, name := range []string{"image1x", "image2x", "image3x", "image4x"} {
+		hexDigests = append(hexDigests, sha256str("###"+name))
+		refs = append(refs, name+"@sha256:"+hexDigests[n])
+	}
+	now := time.Now()
+	tst.pullImage("image1x", refs[0])
+	tst.setLastUsed(hexDigests[0], now.Add(-3*time.Hour))
+	tst.pullImage("image2x", refs[1])
+	tst.setLastUsed(hexDigests[1], now.Add(-2*time.Hour))
+	tst.verifyDataFiles(hexDigests[0], hexDigests[1])
+
+	// image1x is the least recently used one, but it's in use
+	tst.referencedImages = []string{refs[0]}
+	tst.pullImage("image3x", refs[2])
+	tst.verifyDataFiles(hexDigests[0], hexDigests[2])
+	tst.verifySubpathContents("links/image1x", "###image1x")
+	tst.verifySubpathContents("links/image3x", "###image3x")
+	if _, err := os.Lstat(tst.subpath("links/image2x")); !os.IsNotExist(err) {
+		t.Errorf("the link for the evicted image wasn't removed")
+	}
+
+	tst.referencedImages = nil
+	tst.setLastUsed(hexDigests[2], now.Add(-time.Hour))
+	if err := tst.store.MarkImageUsed("image1x"); err != nil {
+		t.Fatalf("MarkImageUsed(): %v", err)
+	}
+	tst.pullImage("image4x", refs[3])
+	tst.verifyDataFiles(hexDigests[0], hexDigests[3])
+	tst.verifyImage("image1x", "###image1x")
+	tst.verifyImage("image4x", "###image4x")
+}
+
+func TestImportImage(t *testing.T) {
+	tst := newIfsTester(t)
+	defer tst.teardown()
+	for i := 0; i < 2; i++ {
+		ref, err := tst.store.ImportImage(tst.images[0].Name, strings.NewReader("###example.com:1234/foo/bar"))
+		if err != nil {
+			t.Fatalf("ImportImage(): %v", err)
+		}
+		if ref != tst.refs[0] {
+			t.Errorf("bad image ref returned: %q instead of %q", ref, tst.refs[0])
+		}
+		tst.verifyListImages("", tst.images[0])
+		tst.verifySubpathContents("links/example.com:1234%foo%bar", "###example.com:1234/foo/bar")
+		tst.verifyImage(tst.refs[0], "###example.com:1234/foo/bar")
+		tst.verifyDataFiles(sha256str("###example.com:1234/foo/bar"))
+	}
+
+	// same data, different name
+	tst.pullImage(tst.images[1].Name, tst.refs[1])
+	if _, err := tst.store.ImportImage(tst.images[2].Name, strings.NewReader("###baz")); err != nil {
+		t.Fatalf("ImportImage(): %v", err)
+	}
+	tst.verifyListImages("", tst.images[1], tst.images[0], tst.images[2])
+	tst.verifyDataFiles(sha256str("###example.com:1234/foo/bar"), sha256str("###baz"))
+
+	refWithBadDigest := tst.images[0].Name + "@sha256:0000000000000000000000000000000000000000000000000000000000000000"
+	if _, err := tst.store.ImportImage(refWithBadDigest, strings.NewReader("###foobar")); err == nil {
+		t.Errorf("ImportImage() didn't return an error for an image with bad digest")
+	}
+	tst.verifyDataFiles(sha256str("###example.com:1234/foo/bar"), sha256str("###baz"))
+}
+
+func TestCommitImage(t *testing.T) {
+	tst := newIfsTester(t)
+	defer tst.teardown()
+	ref, err := tst.store.CommitImage(tst.images[0].Name, func(path string) error {
+		// the temporary file must survive GC
+		if err := tst.store.GC(); err != nil {
+			t.Errorf("GC(): %v", err)
+		}
+		return ioutil.WriteFile(path, []byte("###example.com:1234/foo/bar"), 0666)
+	})
+	if err != nil {
+		t.Fatalf("CommitImage(): %v", err)
+	}
+	if ref != tst.refs[0] {
+		t.Errorf("bad image ref returned: %q instead of %q", ref, tst.refs[0])
+	}
+	tst.verifyListImages("", tst.images[0])
+	tst.verifyImage(tst.refs[0], "###example.com:1234/foo/bar")
+	tst.verifyDataFiles(sha256str("###example.com:1234/foo/bar"))
+
+	if _, err := tst.store.CommitImage(tst.images[2].Name, func(path string) error {
+		return errors.New("commit failed")
+	}); err == nil {
+		t.Errorf("CommitImage() didn't return an error when the write function failed")
+	}
+	tst.verifyListImages("", tst.images[0])
+	tst.verifyDataFiles(sha256str("###example.com:1234/foo/bar"))
+}
+
+func TestPreloadImages(t *testing.T) {
+	tst := newIfsTester(t)
+	defer tst.teardown()
+	preloadDir := tst.subpath("preload")
+	if err := os.Mkdir(preloadDir, 0777); err != nil {
+		t.Fatalf("Mkdir(): %v", err)
+	}
+	for name, contents := range map[string]string{
+		"example.com:1234%foo%bar": "###example.com:1234/foo/bar",
+		"baz":                      "###xxbaz",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(preloadDir, name), []byte(contents), 0666); err != nil {
+			t.Fatalf("WriteFile(): %v", err)
+		}
+	}
+	// the images that are already present aren't replaced
+	tst.pullImage(tst.images[1].Name, tst.refs[1])
+
+	if err := tst.store.PreloadImages(preloadDir); err != nil {
+		t.Fatalf("PreloadImages(): %v", err)
+	}
+	tst.verifyListImages("", tst.images[1], tst.images[0])
+	tst.verifyImage(tst.refs[0], "###example.com:1234/foo/bar")
+	tst.verifyImage(tst.refs[1], "###baz")
+}
+
 func TestVerifyImageChecksum(t *testing.T) {
 	tst := newIfsTester(t)
 	defer tst.teardown()
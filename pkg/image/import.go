@@ -0,0 +1,172 @@
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package image
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/distribution/reference"
+	"github.com/golang/glog"
+	digest "github.com/opencontainers/go-digest"
+)
+
+// ImportImage implements ImportImage method of Store interface.
+func (s *FileStore) ImportImage(name string, r io.Reader) (string, error) {
+	return s.storeImage(name, func(tempFile *os.File) (digest.Digest, error) {
+		digester := digest.SHA256.Digester()
+		if _, err := io.Copy(io.MultiWriter(tempFile, digester.Hash()), r); err != nil {
+			return "", err
+		}
+		return digester.Digest(), nil
+	})
+}
+
+// CommitImage implements CommitImage method of Store interface.
+func (s *FileStore) CommitImage(name string, write func(path string) error) (string, error) {
+	return s.storeImage(name, func(tempFile *os.File) (digest.Digest, error) {
+		if err := write(tempFile.Name()); err != nil {
+			return "", err
+		}
+		// the file may have been recreated by the writer, so
+		// it's reopened to calculate the digest
+		f, err := os.Open(tempFile.Name())
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		return digest.FromReader(f)
+	})
+}
+
+// storeImage creates a temporary file in the data directory that's
+// protected from GC, invokes fill to write the image data into it
+// and then places the image into the store under the specified name.
+// fill must return the digest of the data written.
+func (s *FileStore) storeImage(name string, fill func(tempFile *os.File) (digest.Digest, error)) (string, error) {
+	name, specDigest := SplitImageName(name)
+	named, err := reference.WithName(name)
+	if err != nil {
+		return "", fmt.Errorf("bad image name %q: %v", name, err)
+	}
+
+	if err := os.MkdirAll(s.dataDir(), 0777); err != nil {
+		return "", fmt.Errorf("mkdir %q: %v", s.dataDir(), err)
+	}
+	tempFile, err := ioutil.TempFile(s.dataDir(), "part_")
+	if err != nil {
+		return "", fmt.Errorf("failed to create a temporary file: %v", err)
+	}
+	tempName := filepath.Base(tempFile.Name())
+	s.addTempFile(tempName)
+	defer s.removeTempFile(tempName)
+	placed := false
+	defer func() {
+		if placed {
+			return
+		}
+		tempFile.Close()
+		if err := os.Remove(tempFile.Name()); err != nil && !os.IsNotExist(err) {
+			glog.Warningf("Error removing %q: %v", tempFile.Name(), err)
+		}
+	}()
+
+	d, err := fill(tempFile)
+	if err != nil {
+		return "", fmt.Errorf("error importing image %q: %v", name, err)
+	}
+	if err := tempFile.Close(); err != nil {
+		return "", fmt.Errorf("closing %q: %v", tempFile.Name(), err)
+	}
+	if specDigest != "" && d != specDigest {
+		return "", fmt.Errorf("image digest mismatch: %s instead of %s", d, specDigest)
+	}
+	if err := s.placeImage(tempFile.Name(), d.Hex(), name); err != nil {
+		return "", err
+	}
+	placed = true
+	if err := s.MarkImageUsed(d.String()); err != nil {
+		glog.Warningf("Error updating the last use time of %q: %v", name, err)
+	}
+
+	withDigest, err := reference.WithDigest(named, d)
+	if err != nil {
+		return "", err
+	}
+	return withDigest.String(), nil
+}
+
+// PreloadImages imports the image files from the specified directory
+// unless the store already contains images with the same names. The
+// file names are used as the image names, with '%' characters replaced
+// by '/' like in the names of the image links.
+func (s *FileStore) PreloadImages(dir string) error {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("readdir %q: %v", dir, err)
+	}
+	for _, fi := range infos {
+		if !fi.Mode().IsRegular() {
+			continue
+		}
+		name := strings.Replace(fi.Name(), "%", "/", -1)
+		switch img, err := s.ImageStatus(name); {
+		case err != nil:
+			glog.Warningf("Preloading images: skipping %q: %v", fi.Name(), err)
+			continue
+		case img != nil:
+			glog.V(3).Infof("Preloading images: image %q is already present", name)
+			continue
+		}
+		if err := s.preloadImage(filepath.Join(dir, fi.Name()), name); err != nil {
+			glog.Warningf("Preloading images: %v", err)
+		}
+	}
+	return nil
+}
+
+func (s *FileStore) preloadImage(path, name string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("can't open %q: %v", path, err)
+	}
+	defer f.Close()
+	ref, err := s.ImportImage(name, f)
+	if err != nil {
+		return err
+	}
+	glog.V(1).Infof("Preloaded image %q from %q", ref, path)
+	return nil
+}
+
+// addTempFile protects the specified file in the data directory
+// from being removed by GC.
+func (s *FileStore) addTempFile(name string) {
+	s.pullLock.Lock()
+	defer s.pullLock.Unlock()
+	s.tempFiles[name] = true
+}
+
+func (s *FileStore) removeTempFile(name string) {
+	s.pullLock.Lock()
+	defer s.pullLock.Unlock()
+	delete(s.tempFiles, name)
+}
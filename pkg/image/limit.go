@@ -0,0 +1,224 @@
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package image
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	// rateLimitChunkSize is the maximum number of bytes
+	// written at once by the rate limited writers, which
+	// makes the concurrent downloads share the bandwidth
+	// more evenly.
+	rateLimitChunkSize = 32 * 1024
+)
+
+// DownloadLimits specifies the node-wide limits for the image
+// downloads.
+type DownloadLimits struct {
+	// MaxConcurrentDownloads is the maximum number of the
+	// downloads that may be performed at the same time. The
+	// downloads that exceed this number are queued and
+	// started in the order of arrival. Zero means no limit.
+	MaxConcurrentDownloads int
+	// MaxBytesPerSecond is the maximum total download rate.
+	// Zero means no limit.
+	MaxBytesPerSecond int64
+}
+
+// rateLimiter is a token bucket that limits the number of bytes
+// transferred per second.
+type rateLimiter struct {
+	sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(bytesPerSecond int64) *rateLimiter {
+	burst := float64(bytesPerSecond)
+	if burst < rateLimitChunkSize {
+		burst = rateLimitChunkSize
+	}
+	return &rateLimiter{
+		rate:   float64(bytesPerSecond),
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+	}
+}
+
+// wait blocks till n bytes can be transferred. The bytes are
+// reserved right away, so the concurrent callers are served in
+// the order of their arrival.
+func (l *rateLimiter) wait(ctx context.Context, n int) error {
+	l.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	l.tokens -= float64(n)
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.Unlock()
+
+	if delay == 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// rateLimitedWriter passes the data to the underlying writer
+// at the rate permitted by the limiters.
+type rateLimitedWriter struct {
+	ctx      context.Context
+	w        io.Writer
+	limiters []*rateLimiter
+}
+
+func (rw *rateLimitedWriter) Write(data []byte) (int, error) {
+	written := 0
+	for len(data) > 0 {
+		n := len(data)
+		if n > rateLimitChunkSize {
+			n = rateLimitChunkSize
+		}
+		for _, l := range rw.limiters {
+			if err := l.wait(rw.ctx, n); err != nil {
+				return written, err
+			}
+		}
+		m, err := rw.w.Write(data[:n])
+		written += m
+		if err != nil {
+			return written, err
+		}
+		data = data[n:]
+	}
+	return written, nil
+}
+
+// downloadLimiter enforces the download limits.
+type downloadLimiter struct {
+	sync.Mutex
+	maxActive       int
+	active          int
+	waiters         []chan struct{}
+	nodeLimiter     *rateLimiter
+	profileLimiters map[string]*rateLimiter
+}
+
+func newDownloadLimiter(limits DownloadLimits) *downloadLimiter {
+	l := &downloadLimiter{
+		maxActive:       limits.MaxConcurrentDownloads,
+		profileLimiters: make(map[string]*rateLimiter),
+	}
+	if limits.MaxBytesPerSecond > 0 {
+		l.nodeLimiter = newRateLimiter(limits.MaxBytesPerSecond)
+	}
+	return l
+}
+
+// acquire waits till the download can be started. If it returns
+// no error, the caller must call release() after the download is
+// finished.
+func (l *downloadLimiter) acquire(ctx context.Context) error {
+	l.Lock()
+	if l.maxActive <= 0 || (l.active < l.maxActive && len(l.waiters) == 0) {
+		l.active++
+		l.Unlock()
+		return nil
+	}
+	ch := make(chan struct{})
+	l.waiters = append(l.waiters, ch)
+	l.Unlock()
+
+	select {
+	case <-ch:
+		return nil
+	case <-ctx.Done():
+		l.Lock()
+		defer l.Unlock()
+		for n, w := range l.waiters {
+			if w == ch {
+				l.waiters = append(l.waiters[:n], l.waiters[n+1:]...)
+				return ctx.Err()
+			}
+		}
+		// the download slot was already handed over
+		// to this caller, pass it on
+		l.releaseUnlocked()
+		return ctx.Err()
+	}
+}
+
+func (l *downloadLimiter) release() {
+	l.Lock()
+	defer l.Unlock()
+	l.releaseUnlocked()
+}
+
+func (l *downloadLimiter) releaseUnlocked() {
+	if len(l.waiters) == 0 {
+		l.active--
+		return
+	}
+	// hand over the download slot to the first waiter
+	close(l.waiters[0])
+	l.waiters = l.waiters[1:]
+}
+
+// wrapWriter returns a writer that enforces the bandwidth limits
+// for the specified endpoint.
+func (l *downloadLimiter) wrapWriter(ctx context.Context, endpoint Endpoint, w io.Writer) io.Writer {
+	var limiters []*rateLimiter
+	if l.nodeLimiter != nil {
+		limiters = append(limiters, l.nodeLimiter)
+	}
+	if endpoint.MaxBytesPerSecond > 0 {
+		l.Lock()
+		key := fmt.Sprintf("%s|%d", endpoint.ProfileName, endpoint.MaxBytesPerSecond)
+		pl, found := l.profileLimiters[key]
+		if !found {
+			pl = newRateLimiter(endpoint.MaxBytesPerSecond)
+			l.profileLimiters[key] = pl
+		}
+		l.Unlock()
+		limiters = append(limiters, pl)
+	}
+	if len(limiters) == 0 {
+		return w
+	}
+	return &rateLimitedWriter{ctx: ctx, w: w, limiters: limiters}
+}
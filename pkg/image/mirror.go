@@ -0,0 +1,200 @@
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package image
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+const (
+	// mirrorRetryInterval is the time during which a mirror that
+	// has failed is tried only after the healthy ones
+	mirrorRetryInterval = time.Minute
+	// mirrorProbeTimeout is the time limit for the requests that
+	// are used to find the fastest mirror
+	mirrorProbeTimeout = 5 * time.Second
+)
+
+// SourceReceiver is an optional interface that can be implemented
+// by the writers passed to DownloadFile() so the downloader can tell
+// them the URL the file is actually being downloaded from, which may
+// be one of the mirrors.
+type SourceReceiver interface {
+	// SetSourceURL sets the URL of the file being downloaded
+	SetSourceURL(url string)
+}
+
+// Rewinder is an optional interface that can be implemented by the
+// writers passed to DownloadFile() so the downloader can discard
+// the data written so far and retry the download using another
+// mirror if the current one fails in the middle of the transfer.
+type Rewinder interface {
+	// Rewind discards the data written so far
+	Rewind() error
+}
+
+// mirrorError wraps the errors that indicate a problem with the
+// mirror server itself, i.e. transport errors and 5xx responses, as
+// opposed to e.g. a missing image. Only such errors make the mirror
+// server considered failed.
+type mirrorError struct {
+	err error
+}
+
+func (e *mirrorError) Error() string {
+	return e.err.Error()
+}
+
+// checkStatus returns an error if the response status isn't 200 OK.
+// For 5xx statuses, the error is a mirrorError.
+func checkStatus(resp *http.Response) error {
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	err := fmt.Errorf("bad http status %q", resp.Status)
+	if resp.StatusCode >= 500 {
+		return &mirrorError{err}
+	}
+	return err
+}
+
+// mirrorTracker keeps track of the mirror failures.
+type mirrorTracker struct {
+	sync.Mutex
+	failedAt map[string]time.Time
+}
+
+func newMirrorTracker() *mirrorTracker {
+	return &mirrorTracker{failedAt: make(map[string]time.Time)}
+}
+
+// mirrorKey returns the key identifying the mirror server for
+// the specified URL.
+func mirrorKey(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil || parsed.Host == "" {
+		return u
+	}
+	return parsed.Scheme + "://" + parsed.Host
+}
+
+func (t *mirrorTracker) markFailed(u string) {
+	t.Lock()
+	defer t.Unlock()
+	t.failedAt[mirrorKey(u)] = time.Now()
+}
+
+func (t *mirrorTracker) markHealthy(u string) {
+	t.Lock()
+	defer t.Unlock()
+	delete(t.failedAt, mirrorKey(u))
+}
+
+func (t *mirrorTracker) isHealthy(u string) bool {
+	t.Lock()
+	defer t.Unlock()
+	failedAt, found := t.failedAt[mirrorKey(u)]
+	return !found || time.Since(failedAt) >= mirrorRetryInterval
+}
+
+// order returns the URLs with the ones that have failed recently
+// moved to the end of the list, keeping their relative order.
+func (t *mirrorTracker) order(urls []string) []string {
+	var healthy, failed []string
+	for _, u := range urls {
+		if t.isHealthy(u) {
+			healthy = append(healthy, u)
+		} else {
+			failed = append(failed, u)
+		}
+	}
+	return append(healthy, failed...)
+}
+
+type probeResult struct {
+	url     string
+	latency time.Duration
+	err     error
+}
+
+// probeMirror sends a HEAD request to the URL and returns the time
+// it took to receive the response. authURL is the original URL of
+// the image.
+func probeMirror(ctx context.Context, endpoint Endpoint, authURL, u string) (time.Duration, error) {
+	client, err := createHTTPClient(endpoint, authURL)
+	if err != nil {
+		return 0, err
+	}
+	client.Timeout = mirrorProbeTimeout
+	req, err := http.NewRequest("HEAD", u, nil)
+	if err != nil {
+		return 0, err
+	}
+	start := time.Now()
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return 0, &mirrorError{err}
+	}
+	resp.Body.Close()
+	if err := checkStatus(resp); err != nil {
+		return 0, err
+	}
+	return time.Since(start), nil
+}
+
+// orderByLatency probes the URLs concurrently and returns them in
+// the order of their response times, followed by the URLs that
+// couldn't be probed.
+func (t *mirrorTracker) orderByLatency(ctx context.Context, endpoint Endpoint, authURL string, urls []string) []string {
+	results := make([]probeResult, len(urls))
+	var wg sync.WaitGroup
+	for n, u := range urls {
+		wg.Add(1)
+		go func(n int, u string) {
+			defer wg.Done()
+			latency, err := probeMirror(ctx, endpoint, authURL, u)
+			results[n] = probeResult{url: u, latency: latency, err: err}
+		}(n, u)
+	}
+	wg.Wait()
+
+	var ok, failed []string
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].err == nil && (results[j].err != nil || results[i].latency < results[j].latency)
+	})
+	for _, r := range results {
+		if r.err != nil {
+			glog.Warningf("Error probing the image mirror %s: %v", r.url, r.err)
+			if _, ok := r.err.(*mirrorError); ok {
+				t.markFailed(r.url)
+			}
+			failed = append(failed, r.url)
+			continue
+		}
+		glog.V(2).Infof("Image mirror %s responded in %v", r.url, r.latency)
+		ok = append(ok, r.url)
+	}
+	return append(ok, failed...)
+}
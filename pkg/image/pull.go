@@ -0,0 +1,251 @@
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package image
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	digest "github.com/opencontainers/go-digest"
+)
+
+// PullProgress describes an image download that's currently in
+// progress.
+type PullProgress struct {
+	// Name is the name of the image that started the download.
+	Name string `json:"name"`
+	// URL is the URL the image is being downloaded from,
+	// which may be one of the mirrors.
+	URL string `json:"url"`
+	// ProfileName is the name of the transport profile used
+	// for the download, if any.
+	ProfileName string `json:"profileName,omitempty"`
+	// BytesDone is the number of bytes downloaded so far.
+	BytesDone int64 `json:"bytesDone"`
+	// BytesTotal is the total size of the image, or -1 if the
+	// size is not known.
+	BytesTotal int64 `json:"bytesTotal"`
+	// Waiters is the number of PullImage calls that are waiting
+	// for this download to finish.
+	Waiters int `json:"waiters"`
+	// StartedAt is the time when the download was started.
+	StartedAt time.Time `json:"startedAt"`
+}
+
+// pull denotes an image download that is shared between all of the
+// concurrent PullImage calls for the same endpoint.
+type pull struct {
+	// bytesDone and bytesTotal are accessed atomically
+	bytesDone  int64
+	bytesTotal int64
+
+	name        string
+	profileName string
+	startedAt   time.Time
+	done        chan struct{}
+
+	// the following fields are protected by FileStore's pullLock
+	url      string
+	waiters  int
+	tempName string
+	digest   digest.Digest
+	err      error
+	// cancelled is set if the download was aborted because
+	// the context of the PullImage call that has started it
+	// was cancelled
+	cancelled bool
+}
+
+func newPull(name string, ep Endpoint) *pull {
+	return &pull{
+		bytesTotal:  -1,
+		name:        name,
+		url:         ep.URL,
+		profileName: ep.ProfileName,
+		startedAt:   time.Now(),
+		done:        make(chan struct{}),
+		waiters:     1,
+	}
+}
+
+// pullKey returns the key used to find the pull for the endpoint.
+// The pulls that use different credentials aren't shared so that
+// a pull with invalid credentials doesn't make another one fail.
+func pullKey(ep Endpoint) string {
+	return fmt.Sprintf("%s|%s|%s", ep.ProfileName, ep.URL, ep.Credentials.fingerprint())
+}
+
+func (p *pull) progress() PullProgress {
+	return PullProgress{
+		Name:        p.name,
+		URL:         p.url,
+		ProfileName: p.profileName,
+		BytesDone:   atomic.LoadInt64(&p.bytesDone),
+		BytesTotal:  atomic.LoadInt64(&p.bytesTotal),
+		Waiters:     p.waiters,
+		StartedAt:   p.startedAt,
+	}
+}
+
+// progressWriter wraps the file that receives the downloaded data
+// and updates the progress info of the pull.
+type progressWriter struct {
+	s *FileStore
+	w *os.File
+	p *pull
+}
+
+var _ SizeReceiver = &progressWriter{}
+var _ SourceReceiver = &progressWriter{}
+var _ Rewinder = &progressWriter{}
+
+func (pw *progressWriter) Write(data []byte) (int, error) {
+	n, err := pw.w.Write(data)
+	atomic.AddInt64(&pw.p.bytesDone, int64(n))
+	return n, err
+}
+
+// SetTotalSize implements SetTotalSize method of SizeReceiver interface.
+func (pw *progressWriter) SetTotalSize(size int64) {
+	atomic.StoreInt64(&pw.p.bytesTotal, size)
+}
+
+// SetSourceURL implements SetSourceURL method of SourceReceiver interface.
+func (pw *progressWriter) SetSourceURL(url string) {
+	pw.s.pullLock.Lock()
+	defer pw.s.pullLock.Unlock()
+	pw.p.url = url
+}
+
+// Rewind implements Rewind method of Rewinder interface.
+func (pw *progressWriter) Rewind() error {
+	if _, err := pw.w.Seek(0, os.SEEK_SET); err != nil {
+		return err
+	}
+	if err := pw.w.Truncate(0); err != nil {
+		return err
+	}
+	atomic.StoreInt64(&pw.p.bytesDone, 0)
+	atomic.StoreInt64(&pw.p.bytesTotal, -1)
+	return nil
+}
+
+// sourceURL returns the URL the image was downloaded from.
+func (s *FileStore) sourceURL(p *pull) string {
+	s.pullLock.Lock()
+	defer s.pullLock.Unlock()
+	return p.url
+}
+
+// acquirePull returns the pull for the specified endpoint, creating
+// it if necessary. The second returned value is true if a new pull
+// was created, in which case the caller is responsible for performing
+// the download.
+func (s *FileStore) acquirePull(name string, ep Endpoint) (*pull, bool) {
+	s.pullLock.Lock()
+	defer s.pullLock.Unlock()
+	key := pullKey(ep)
+	if p, found := s.pulls[key]; found {
+		p.waiters++
+		return p, false
+	}
+	p := newPull(name, ep)
+	s.pulls[key] = p
+	return p, true
+}
+
+// releasePull decrements the waiter count of the pull, removing
+// the pull from the active pull list when it drops to zero.
+func (s *FileStore) releasePull(ep Endpoint, p *pull) {
+	s.pullLock.Lock()
+	defer s.pullLock.Unlock()
+	p.waiters--
+	key := pullKey(ep)
+	if p.waiters == 0 && s.pulls[key] == p {
+		delete(s.pulls, key)
+	}
+}
+
+// finishPull records the result of the download and wakes up
+// the waiters. Failed pulls are removed from the active pull list
+// right away so that subsequent PullImage calls can start a new
+// download.
+func (s *FileStore) finishPull(ep Endpoint, p *pull, d digest.Digest, err error, cancelled bool) {
+	s.pullLock.Lock()
+	defer s.pullLock.Unlock()
+	p.tempName = ""
+	p.digest = d
+	p.err = err
+	p.cancelled = cancelled
+	if err != nil {
+		key := pullKey(ep)
+		if s.pulls[key] == p {
+			delete(s.pulls, key)
+		}
+	}
+	close(p.done)
+}
+
+// updatePull records the names of the files that are currently
+// used by the pull so they're not removed by GC.
+func (s *FileStore) updatePull(p *pull, tempName string, d digest.Digest) {
+	s.pullLock.Lock()
+	defer s.pullLock.Unlock()
+	p.tempName = tempName
+	p.digest = d
+}
+
+// addFilesUsedByPulls adds the names of the data files that are
+// being written or are about to be linked by the active pulls
+// and imports to the specified set.
+func (s *FileStore) addFilesUsedByPulls(inUse map[string]bool) {
+	s.pullLock.Lock()
+	defer s.pullLock.Unlock()
+	for name := range s.tempFiles {
+		inUse[name] = true
+	}
+	for _, p := range s.pulls {
+		if p.tempName != "" {
+			inUse[p.tempName] = true
+		}
+		if p.digest != "" {
+			inUse[p.digest.Hex()] = true
+		}
+	}
+}
+
+// ActivePulls implements ActivePulls method of Store interface.
+func (s *FileStore) ActivePulls() []PullProgress {
+	s.pullLock.Lock()
+	defer s.pullLock.Unlock()
+	var r []PullProgress
+	for _, p := range s.pulls {
+		select {
+		case <-p.done:
+			// the download is finished, just some of the
+			// waiters didn't pick up the result yet
+			continue
+		default:
+		}
+		r = append(r, p.progress())
+	}
+	sort.Slice(r, func(i, j int) bool { return r[i].StartedAt.Before(r[j].StartedAt) })
+	return r
+}
@@ -0,0 +1,320 @@
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package image
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+const (
+	importOp = "import"
+	exportOp = "export"
+	commitOp = "commit"
+)
+
+// serverRequest is sent by the client as a single line of JSON.
+// For import requests, it's followed by the image data.
+type serverRequest struct {
+	Op   string `json:"op"`
+	Name string `json:"name"`
+	// ContainerID specifies the VM for commit requests
+	ContainerID string `json:"containerID,omitempty"`
+	// Quiesce specifies whether the guest filesystems must be
+	// frozen while committing the VM's root disk
+	Quiesce bool `json:"quiesce,omitempty"`
+}
+
+// serverResponse is sent by the server as a single line of JSON.
+// For successful export requests, it's followed by the image data.
+type serverResponse struct {
+	Ref   string `json:"ref,omitempty"`
+	Size  int64  `json:"size,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// RootVolumeCommitter writes the flattened root disk of the VM
+// identified by containerID to targetPath, optionally quiescing
+// the guest while doing so.
+type RootVolumeCommitter func(containerID, targetPath string, quiesce bool) error
+
+// Server handles image import, export and commit requests for the
+// image store over a UNIX domain socket.
+type Server struct {
+	sync.Mutex
+	store     Store
+	committer RootVolumeCommitter
+	ln        net.Listener
+	doneCh    chan struct{}
+}
+
+// NewServer makes a new image server for the specified store.
+func NewServer(store Store) *Server {
+	return &Server{store: store}
+}
+
+// SetRootVolumeCommitter sets the function that's used to handle
+// commit requests.
+func (s *Server) SetRootVolumeCommitter(committer RootVolumeCommitter) {
+	s.Lock()
+	defer s.Unlock()
+	s.committer = committer
+}
+
+func writeResponse(w io.Writer, resp serverResponse) error {
+	bs, err := json.Marshal(resp)
+	if err != nil {
+		return fmt.Errorf("error marshalling the response: %v", err)
+	}
+	_, err = w.Write(append(bs, '\n'))
+	return err
+}
+
+func (s *Server) importImage(name string, r io.Reader, w io.Writer) error {
+	ref, err := s.store.ImportImage(name, r)
+	if err != nil {
+		return writeResponse(w, serverResponse{Error: err.Error()})
+	}
+	return writeResponse(w, serverResponse{Ref: ref})
+}
+
+func (s *Server) exportImage(name string, w io.Writer) error {
+	img, err := s.store.ImageStatus(name)
+	switch {
+	case err != nil:
+		return writeResponse(w, serverResponse{Error: err.Error()})
+	case img == nil:
+		return writeResponse(w, serverResponse{Error: fmt.Sprintf("image %q not found", name)})
+	}
+	f, err := os.Open(img.Path)
+	if err != nil {
+		return writeResponse(w, serverResponse{Error: err.Error()})
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return writeResponse(w, serverResponse{Error: err.Error()})
+	}
+	if err := writeResponse(w, serverResponse{Ref: img.Name + "@" + img.Digest, Size: fi.Size()}); err != nil {
+		return err
+	}
+	_, err = io.CopyN(w, f, fi.Size())
+	return err
+}
+
+func (s *Server) commitImage(containerID, name string, quiesce bool, w io.Writer) error {
+	s.Lock()
+	committer := s.committer
+	s.Unlock()
+	if committer == nil {
+		return writeResponse(w, serverResponse{Error: "committing VMs is not supported"})
+	}
+	ref, err := s.store.CommitImage(name, func(path string) error {
+		return committer(containerID, path, quiesce)
+	})
+	if err != nil {
+		return writeResponse(w, serverResponse{Error: err.Error()})
+	}
+	return writeResponse(w, serverResponse{Ref: ref})
+}
+
+func (s *Server) handle(conn net.Conn) error {
+	defer conn.Close()
+	br := bufio.NewReader(conn)
+	line, err := br.ReadBytes('\n')
+	if err != nil {
+		return fmt.Errorf("error reading the request: %v", err)
+	}
+	var req serverRequest
+	if err := json.Unmarshal(line, &req); err != nil {
+		return fmt.Errorf("error unmarshalling the request: %v", err)
+	}
+	switch req.Op {
+	case importOp:
+		return s.importImage(req.Name, br, conn)
+	case exportOp:
+		return s.exportImage(req.Name, conn)
+	case commitOp:
+		return s.commitImage(req.ContainerID, req.Name, req.Quiesce, conn)
+	default:
+		return writeResponse(conn, serverResponse{Error: fmt.Sprintf("bad op %q", req.Op)})
+	}
+}
+
+// Serve makes the server listen on the specified socket path. If
+// readyCh is not nil, it'll be closed when the server is ready to
+// accept connections. This function doesn't return till the server
+// stops listening.
+func (s *Server) Serve(socketPath string, readyCh chan struct{}) error {
+	err := syscall.Unlink(socketPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	s.Lock()
+	s.doneCh = make(chan struct{})
+	defer close(s.doneCh)
+	s.ln, err = net.Listen("unix", socketPath)
+	s.Unlock()
+	if err != nil {
+		return err
+	}
+	defer s.ln.Close()
+	if readyCh != nil {
+		close(readyCh)
+	}
+	var tempDelay time.Duration // how long to sleep on accept failure
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			if ne, ok := err.(interface {
+				Temporary() bool
+			}); !ok || !ne.Temporary() {
+				glog.V(1).Infof("done serving; Accept = %v", err)
+				return err
+			}
+			if tempDelay == 0 {
+				tempDelay = 5 * time.Millisecond
+			} else {
+				tempDelay *= 2
+			}
+			if max := 1 * time.Second; tempDelay > max {
+				tempDelay = max
+			}
+			glog.Warningf("Accept error: %v; retrying in %v", err, tempDelay)
+			<-time.After(tempDelay)
+			continue
+		}
+		tempDelay = 0
+
+		go func() {
+			if err := s.handle(conn); err != nil {
+				glog.Warningf("Error handling image server request: %v", err)
+			}
+		}()
+	}
+}
+
+// Stop stops the server.
+func (s *Server) Stop() {
+	s.Lock()
+	if s.ln != nil {
+		s.ln.Close()
+		s.Unlock()
+		<-s.doneCh
+		s.doneCh = nil
+	} else {
+		s.Unlock()
+	}
+}
+
+func sendServerRequest(socketPath string, req serverRequest, data io.Reader) (*net.UnixConn, *bufio.Reader, *serverResponse, error) {
+	addr, err := net.ResolveUnixAddr("unix", socketPath)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to resolve unix addr %q: %v", socketPath, err)
+	}
+	conn, err := net.DialUnix("unix", nil, addr)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("can't connect to %q: %v", socketPath, err)
+	}
+	bs, err := json.Marshal(req)
+	if err == nil {
+		_, err = conn.Write(append(bs, '\n'))
+	}
+	if err == nil && data != nil {
+		_, err = io.Copy(conn, data)
+	}
+	if err == nil {
+		err = conn.CloseWrite()
+	}
+	if err != nil {
+		conn.Close()
+		return nil, nil, nil, fmt.Errorf("can't send the image server request: %v", err)
+	}
+
+	br := bufio.NewReader(conn)
+	line, err := br.ReadBytes('\n')
+	if err != nil {
+		conn.Close()
+		return nil, nil, nil, fmt.Errorf("can't read the image server response: %v", err)
+	}
+	var resp serverResponse
+	if err := json.Unmarshal(line, &resp); err != nil {
+		conn.Close()
+		return nil, nil, nil, fmt.Errorf("error unmarshalling the image server response: %v", err)
+	}
+	if resp.Error != "" {
+		conn.Close()
+		return nil, nil, nil, errors.New(resp.Error)
+	}
+	return conn, br, &resp, nil
+}
+
+// ImportImageViaServer imports the image data read from r into the
+// store under the specified name using the image server listening
+// on the specified socket. It returns the image ref.
+func ImportImageViaServer(socketPath, name string, r io.Reader) (string, error) {
+	conn, _, resp, err := sendServerRequest(socketPath, serverRequest{Op: importOp, Name: name}, r)
+	if err != nil {
+		return "", err
+	}
+	conn.Close()
+	return resp.Ref, nil
+}
+
+// ExportImageViaServer writes the data of the specified image to w
+// using the image server listening on the specified socket. It
+// returns the image ref.
+func ExportImageViaServer(socketPath, name string, w io.Writer) (string, error) {
+	conn, br, resp, err := sendServerRequest(socketPath, serverRequest{Op: exportOp, Name: name}, nil)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	if _, err := io.CopyN(w, br, resp.Size); err != nil {
+		return "", fmt.Errorf("error exporting image %q: %v", name, err)
+	}
+	return resp.Ref, nil
+}
+
+// CommitImageViaServer saves the root disk of the VM identified by
+// containerID into the store under the specified name using the
+// image server listening on the specified socket. If quiesce is
+// true, the guest filesystems are frozen while the disk is being
+// copied. It returns the image ref.
+func CommitImageViaServer(socketPath, containerID, name string, quiesce bool) (string, error) {
+	conn, _, resp, err := sendServerRequest(socketPath, serverRequest{
+		Op:          commitOp,
+		Name:        name,
+		ContainerID: containerID,
+		Quiesce:     quiesce,
+	}, nil)
+	if err != nil {
+		return "", err
+	}
+	conn.Close()
+	return resp.Ref, nil
+}
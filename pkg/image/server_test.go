@@ -0,0 +1,99 @@
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package image
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestImageServer(t *testing.T) {
+	tst := newIfsTester(t)
+	defer tst.teardown()
+
+	socketPath := filepath.Join(tst.tmpDir, "image.sock")
+	s := NewServer(tst.store)
+	readyCh := make(chan struct{})
+	go s.Serve(socketPath, readyCh)
+	defer s.Stop()
+	<-readyCh
+
+	ref, err := ImportImageViaServer(socketPath, "foo/bar:latest", strings.NewReader("###foobar"))
+	if err != nil {
+		t.Fatalf("ImportImageViaServer(): %v", err)
+	}
+	expectedRef := "foo/bar@sha256:" + sha256str("###foobar")
+	if ref != expectedRef {
+		t.Errorf("bad image ref returned: %q instead of %q", ref, expectedRef)
+	}
+	tst.verifySubpathContents("links/foo%bar", "###foobar")
+	tst.verifyImage("foo/bar", "###foobar")
+
+	var buf bytes.Buffer
+	ref, err = ExportImageViaServer(socketPath, "foo/bar", &buf)
+	switch {
+	case err != nil:
+		t.Errorf("ExportImageViaServer(): %v", err)
+	case ref != expectedRef:
+		t.Errorf("bad image ref returned: %q instead of %q", ref, expectedRef)
+	case buf.String() != "###foobar":
+		t.Errorf("bad exported data: %q", buf.String())
+	}
+
+	_, err = ExportImageViaServer(socketPath, "nosuchimage", &buf)
+	switch {
+	case err == nil:
+		t.Errorf("no error returned for a nonexistent image")
+	case !strings.Contains(err.Error(), "not found"):
+		t.Errorf("bad error message for a nonexistent image: %v", err)
+	}
+
+	_, err = ImportImageViaServer(socketPath, "foo/bar@sha256:"+sha256str("###baz"), strings.NewReader("###foobar"))
+	switch {
+	case err == nil:
+		t.Errorf("no error returned for an image with bad digest")
+	case !strings.Contains(err.Error(), "digest mismatch"):
+		t.Errorf("bad error message for an image with bad digest: %v", err)
+	}
+
+	_, err = CommitImageViaServer(socketPath, "container-id", "foo/baz", false)
+	switch {
+	case err == nil:
+		t.Errorf("no error returned for a commit without a committer")
+	case !strings.Contains(err.Error(), "not supported"):
+		t.Errorf("bad error message for a commit without a committer: %v", err)
+	}
+
+	s.SetRootVolumeCommitter(func(containerID, targetPath string, quiesce bool) error {
+		if containerID != "container-id" || !quiesce {
+			t.Errorf("bad commit args: containerID %q, quiesce %v", containerID, quiesce)
+		}
+		return ioutil.WriteFile(targetPath, []byte("###committed"), 0666)
+	})
+	ref, err = CommitImageViaServer(socketPath, "container-id", "foo/baz", true)
+	expectedRef = "foo/baz@sha256:" + sha256str("###committed")
+	switch {
+	case err != nil:
+		t.Errorf("CommitImageViaServer(): %v", err)
+	case ref != expectedRef:
+		t.Errorf("bad image ref returned: %q instead of %q", ref, expectedRef)
+	}
+	tst.verifyImage("foo/baz", "###committed")
+}
@@ -18,21 +18,49 @@ package imagetranslation
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/json"
 	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/golang/glog"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/util/retry"
 
+	"github.com/Mirantis/virtlet/pkg/api/virtlet.k8s/v1"
 	virtletclient "github.com/Mirantis/virtlet/pkg/client/clientset/versioned"
+	virtletinformers "github.com/Mirantis/virtlet/pkg/client/informers/externalversions"
+	virtletlisters "github.com/Mirantis/virtlet/pkg/client/listers/virtlet.k8s/v1"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+const (
+	// crdResyncPeriod is the interval between the periodic
+	// reloads of VirtletImageMappings, which are needed to
+	// pick up the changes in the Secrets they refer to
+	crdResyncPeriod = 5 * time.Minute
+	// crdSyncTimeout is the time limit for the initial
+	// retrieval of VirtletImageMappings by the informer
+	crdSyncTimeout = 30 * time.Second
+)
+
 type crdConfigSource struct {
+	sync.Mutex
 	clientCfg     clientcmd.ClientConfig
 	virtletClient virtletclient.Interface
+	kubeClient    kubernetes.Interface
+	lister        virtletlisters.VirtletImageMappingLister
 	namespace     string
+	nodeName      string
 }
 
-var _ ConfigSource = &crdConfigSource{}
+var _ WatchableConfigSource = &crdConfigSource{}
 
 func (cs *crdConfigSource) setup() error {
 	if cs.virtletClient != nil {
@@ -58,24 +86,302 @@ func (cs *crdConfigSource) Configs(ctx context.Context) ([]TranslationConfig, er
 		return nil, err
 	}
 
-	list, err := cs.virtletClient.VirtletV1().VirtletImageMappings(cs.namespace).List(meta_v1.ListOptions{})
+	mappings, err := cs.listMappings()
 	if err != nil {
 		return nil, err
 	}
 
 	var r []TranslationConfig
-	for n := range list.Items {
-		r = append(r, &list.Items[n])
+	secrets := make(map[string]map[string][]byte)
+	for _, mapping := range mappings {
+		// the validation and the spec hash must use the spec
+		// as it's stored in Kubernetes, without the secrets
+		problems := ValidateTranslation(&mapping.Spec)
+		for _, p := range problems {
+			glog.Warningf("Image translation config %s: %s", mapping.Name, p)
+		}
+		hash := specHash(&mapping.Spec)
+		err := cs.resolveSecrets(&mapping.Spec, secrets)
+		if err != nil {
+			glog.Warningf("Skipping image translation config %s: %v", mapping.Name, err)
+		}
+		cs.reportStatus(mapping, hash, problems, err)
+		if err == nil {
+			r = append(r, mapping)
+		}
 	}
 	return r, nil
 }
 
+// listMappings returns VirtletImageMappings from the informer cache
+// if the source is being watched, or from the apiserver otherwise.
+// The objects returned can be modified by the caller.
+func (cs *crdConfigSource) listMappings() ([]*v1.VirtletImageMapping, error) {
+	cs.Lock()
+	lister := cs.lister
+	cs.Unlock()
+	if lister == nil {
+		list, err := cs.virtletClient.VirtletV1().VirtletImageMappings(cs.namespace).List(meta_v1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		mappings := make([]*v1.VirtletImageMapping, len(list.Items))
+		for n := range list.Items {
+			mappings[n] = &list.Items[n]
+		}
+		return mappings, nil
+	}
+
+	cached, err := lister.VirtletImageMappings(cs.namespace).List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	// the objects in the informer cache must not be modified
+	mappings := make([]*v1.VirtletImageMapping, len(cached))
+	for n, mapping := range cached {
+		mappings[n] = mapping.DeepCopy()
+	}
+	return mappings, nil
+}
+
+// Watch implements WatchableConfigSource Watch
+func (cs *crdConfigSource) Watch(ctx context.Context, onChange func()) error {
+	if err := cs.setup(); err != nil {
+		return err
+	}
+
+	// the informer is stopped if the initial sync fails
+	watchCtx, cancelWatch := context.WithCancel(ctx)
+	synced := false
+	defer func() {
+		if !synced {
+			cancelWatch()
+		}
+	}()
+	factory := virtletinformers.NewFilteredSharedInformerFactory(cs.virtletClient, crdResyncPeriod, cs.namespace, nil)
+	informer := factory.Virtlet().V1().VirtletImageMappings()
+	notify := func() {
+		// the events that arrive before the initial sync
+		// is complete are ignored as the configs are loaded
+		// after Watch() returns
+		cs.Lock()
+		watching := cs.lister != nil
+		cs.Unlock()
+		if watching {
+			onChange()
+		}
+	}
+	informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) { notify() },
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldMapping := oldObj.(*v1.VirtletImageMapping)
+			newMapping := newObj.(*v1.VirtletImageMapping)
+			// skip the status updates, but not the periodic
+			// resyncs that don't change the resource version
+			if oldMapping.ResourceVersion != newMapping.ResourceVersion && reflect.DeepEqual(oldMapping.Spec, newMapping.Spec) {
+				return
+			}
+			notify()
+		},
+		DeleteFunc: func(obj interface{}) { notify() },
+	})
+	factory.Start(watchCtx.Done())
+
+	syncCtx, cancelSync := context.WithTimeout(watchCtx, crdSyncTimeout)
+	defer cancelSync()
+	if !cache.WaitForCacheSync(syncCtx.Done(), informer.Informer().HasSynced) {
+		return fmt.Errorf("timed out waiting for VirtletImageMappings to be retrieved")
+	}
+	synced = true
+
+	cs.Lock()
+	defer cs.Unlock()
+	cs.lister = informer.Lister()
+	return nil
+}
+
+// specHash returns a hash of VirtletImageMapping spec that's used
+// to tell whether the spec has changed since it was last applied.
+func specHash(spec *v1.ImageTranslation) string {
+	data, err := json.Marshal(spec)
+	if err != nil {
+		glog.Warningf("Error marshalling image translation: %v", err)
+		return ""
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(data))[:16]
+}
+
+// updateMappingStatus updates the status of VirtletImageMapping
+// for the node using the results of validating and applying its spec.
+// It returns true if the status has changed.
+func updateMappingStatus(status *v1.VirtletImageMappingStatus, nodeName, hash string, problems []string, applyErr error, now meta_v1.Time) bool {
+	changed := false
+	cond := v1.VirtletImageMappingCondition{
+		Type:   v1.VirtletImageMappingValid,
+		Status: "True",
+		Reason: "Valid",
+	}
+	if len(problems) != 0 {
+		cond.Status = "False"
+		cond.Reason = "ValidationFailed"
+		cond.Message = strings.Join(problems, "; ")
+	}
+	found := false
+	for n, c := range status.Conditions {
+		if c.Type != cond.Type {
+			continue
+		}
+		found = true
+		if c.Status == cond.Status && c.Reason == cond.Reason && c.Message == cond.Message {
+			break
+		}
+		cond.LastTransitionTime = c.LastTransitionTime
+		if c.Status != cond.Status {
+			cond.LastTransitionTime = now
+		}
+		status.Conditions[n] = cond
+		changed = true
+	}
+	if !found {
+		cond.LastTransitionTime = now
+		status.Conditions = append(status.Conditions, cond)
+		changed = true
+	}
+
+	nodeStatus := v1.VirtletImageMappingNodeStatus{SpecHash: hash}
+	if applyErr != nil {
+		nodeStatus.Error = applyErr.Error()
+	}
+	oldNodeStatus, found := status.Nodes[nodeName]
+	if found && oldNodeStatus.SpecHash == hash {
+		if oldNodeStatus.Error == nodeStatus.Error {
+			return changed
+		}
+		nodeStatus.LastApplied = oldNodeStatus.LastApplied
+	} else {
+		nodeStatus.LastApplied = now
+	}
+	if status.Nodes == nil {
+		status.Nodes = make(map[string]v1.VirtletImageMappingNodeStatus)
+	}
+	status.Nodes[nodeName] = nodeStatus
+	return true
+}
+
+// reportStatus writes the results of validating and applying
+// VirtletImageMapping to its status if they've changed. The status is
+// only updated if the node name is known.
+func (cs *crdConfigSource) reportStatus(mapping *v1.VirtletImageMapping, hash string, problems []string, applyErr error) {
+	if cs.nodeName == "" {
+		return
+	}
+	now := meta_v1.Now()
+	if !updateMappingStatus(mapping.Status.DeepCopy(), cs.nodeName, hash, problems, applyErr, now) {
+		return
+	}
+	client := cs.virtletClient.VirtletV1().VirtletImageMappings(cs.namespace)
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		current, err := client.Get(mapping.Name, meta_v1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if !updateMappingStatus(&current.Status, cs.nodeName, hash, problems, applyErr, now) {
+			return nil
+		}
+		_, err = client.UpdateStatus(current)
+		return err
+	})
+	if err != nil {
+		glog.Warningf("Error updating the status of image translation config %s: %v", mapping.Name, err)
+	}
+}
+
+func (cs *crdConfigSource) ensureKubeClient() error {
+	if cs.kubeClient != nil {
+		return nil
+	}
+
+	config, err := cs.clientCfg.ClientConfig()
+	if err != nil {
+		return err
+	}
+
+	kubeClient, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return fmt.Errorf("can't create kubernetes api client: %v", err)
+	}
+	cs.kubeClient = kubeClient
+	return nil
+}
+
+// getSecretValue returns the value of the specified Secret key.
+// The Secrets are cached in the secrets map, but as the configs are
+// reloaded upon each image pull, any changes to the Secrets are
+// picked up by the subsequent pulls.
+func (cs *crdConfigSource) getSecretValue(ref *v1.SecretKeySelector, secrets map[string]map[string][]byte) (string, error) {
+	data, found := secrets[ref.Name]
+	if !found {
+		if err := cs.ensureKubeClient(); err != nil {
+			return "", err
+		}
+		secret, err := cs.kubeClient.CoreV1().Secrets(cs.namespace).Get(ref.Name, meta_v1.GetOptions{})
+		if err != nil {
+			return "", fmt.Errorf("can't get secret %q: %v", ref.Name, err)
+		}
+		data = secret.Data
+		secrets[ref.Name] = data
+	}
+	value, found := data[ref.Key]
+	if !found {
+		return "", fmt.Errorf("key %q not found in secret %q", ref.Key, ref.Name)
+	}
+	return string(value), nil
+}
+
+// resolveSecrets replaces the references to Secrets in the transport
+// profiles with the actual values.
+func (cs *crdConfigSource) resolveSecrets(tr *v1.ImageTranslation, secrets map[string]map[string][]byte) error {
+	resolve := func(value *string, ref **v1.SecretKeySelector) error {
+		if *ref == nil {
+			return nil
+		}
+		v, err := cs.getSecretValue(*ref, secrets)
+		if err != nil {
+			return err
+		}
+		*value = v
+		*ref = nil
+		return nil
+	}
+	for name, profile := range tr.Transports {
+		if profile.Auth != nil {
+			for _, v := range []*v1.SecretValue{profile.Auth.Username, profile.Auth.Password, profile.Auth.BearerToken} {
+				if v == nil {
+					continue
+				}
+				if err := resolve(&v.Value, &v.SecretKeyRef); err != nil {
+					return fmt.Errorf("transport profile %s: %v", name, err)
+				}
+			}
+		}
+		for n := range profile.Headers {
+			h := &profile.Headers[n]
+			if err := resolve(&h.Value, &h.SecretKeyRef); err != nil {
+				return fmt.Errorf("transport profile %s: header %q: %v", name, h.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
 // Description implements ConfigSource Description
 func (cs *crdConfigSource) Description() string {
 	return fmt.Sprintf("Kubernetes VirtletImageMapping resources in namespace %q", cs.namespace)
 }
 
-// NewCRDSource is a factory for CRD-based config source
-func NewCRDSource(namespace string, clientCfg clientcmd.ClientConfig) ConfigSource {
-	return &crdConfigSource{namespace: namespace, clientCfg: clientCfg}
+// NewCRDSource is a factory for CRD-based config source. If nodeName
+// is not empty, the results of validating and applying the mappings
+// on this node are written to the status of VirtletImageMappings.
+func NewCRDSource(namespace, nodeName string, clientCfg clientcmd.ClientConfig) ConfigSource {
+	return &crdConfigSource{namespace: namespace, nodeName: nodeName, clientCfg: clientCfg}
 }
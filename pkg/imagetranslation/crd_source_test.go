@@ -19,14 +19,19 @@ package imagetranslation
 import (
 	"context"
 	"reflect"
+	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/ghodss/yaml"
+	"k8s.io/api/core/v1"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakekube "k8s.io/client-go/kubernetes/fake"
 
 	virtlet_v1 "github.com/Mirantis/virtlet/pkg/api/virtlet.k8s/v1"
 	"github.com/Mirantis/virtlet/pkg/client/clientset/versioned/fake"
+	"github.com/Mirantis/virtlet/pkg/image"
 )
 
 func TestCRDConfigSource(t *testing.T) {
@@ -75,7 +80,7 @@ func TestCRDConfigSource(t *testing.T) {
 		},
 	}
 	clientset := fake.NewSimpleClientset(srcConfigs[0], srcConfigs[1], srcConfigs[2])
-	cs := NewCRDSource("foobar", nil)
+	cs := NewCRDSource("foobar", "", nil)
 	cs.(*crdConfigSource).virtletClient = clientset
 
 	desc := cs.Description()
@@ -100,3 +105,221 @@ func TestCRDConfigSource(t *testing.T) {
 		t.Errorf("Bad config list.\n--- expected configs ---\n%s\n--- actual configs ---\n%s", expected, actual)
 	}
 }
+
+func TestCRDConfigSourceSecrets(t *testing.T) {
+	mapping := func(name, secretName string) *virtlet_v1.VirtletImageMapping {
+		return &virtlet_v1.VirtletImageMapping{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name:      name,
+				Namespace: "foobar",
+			},
+			Spec: virtlet_v1.ImageTranslation{
+				Rules: []virtlet_v1.TranslationRule{
+					{
+						Name:      name,
+						URL:       "https://example.com/" + name + ".qcow2",
+						Transport: "artifacts",
+					},
+				},
+				Transports: map[string]virtlet_v1.TransportProfile{
+					"artifacts": {
+						Auth: &virtlet_v1.HTTPAuth{
+							Username: &virtlet_v1.SecretValue{Value: "user"},
+							Password: &virtlet_v1.SecretValue{
+								SecretKeyRef: &virtlet_v1.SecretKeySelector{Name: secretName, Key: "password"},
+							},
+						},
+						Headers: []virtlet_v1.HTTPHeader{
+							{
+								Name:         "X-Api-Token",
+								SecretKeyRef: &virtlet_v1.SecretKeySelector{Name: secretName, Key: "token"},
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+	kubeClient := fakekube.NewSimpleClientset(&v1.Secret{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "artifacts",
+			Namespace: "foobar",
+		},
+		Data: map[string][]byte{
+			"password": []byte("secret"),
+			"token":    []byte("apitoken"),
+		},
+	})
+	clientset := fake.NewSimpleClientset(mapping("mapping1", "artifacts"), mapping("mapping2", "nosuchsecret"))
+	cs := NewCRDSource("foobar", "", nil)
+	cs.(*crdConfigSource).virtletClient = clientset
+	cs.(*crdConfigSource).kubeClient = kubeClient
+
+	configs, err := cs.Configs(context.Background())
+	if err != nil {
+		t.Fatalf("Configs(): %v", err)
+	}
+	// mapping2 is skipped because the secret doesn't exist
+	if len(configs) != 1 || configs[0].ConfigName() != "mapping1" {
+		t.Fatalf("Bad config list: %#v", configs)
+	}
+
+	translator := NewImageNameTranslator(false)
+	translator.LoadConfigs(context.Background(), cs)
+	ep := translator.Translate("mapping1")
+	expectedCreds := &image.Credentials{Username: "user", Password: "secret"}
+	if !reflect.DeepEqual(ep.Credentials, expectedCreds) {
+		t.Errorf("Bad credentials: %#v instead of %#v", ep.Credentials, expectedCreds)
+	}
+	expectedHeaders := map[string]string{"X-Api-Token": "apitoken"}
+	if !reflect.DeepEqual(ep.Headers, expectedHeaders) {
+		t.Errorf("Bad headers: %#v instead of %#v", ep.Headers, expectedHeaders)
+	}
+}
+
+func TestCRDConfigSourceStatus(t *testing.T) {
+	mappings := []*virtlet_v1.VirtletImageMapping{
+		{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name:      "good",
+				Namespace: "foobar",
+			},
+			Spec: virtlet_v1.ImageTranslation{
+				Rules: []virtlet_v1.TranslationRule{
+					{
+						Name: "testimage1",
+						URL:  "https://example.com/testimage1.qcow2",
+					},
+				},
+			},
+		},
+		{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name:      "bad",
+				Namespace: "foobar",
+			},
+			Spec: virtlet_v1.ImageTranslation{
+				Rules: []virtlet_v1.TranslationRule{
+					{
+						Regex:     "foo(",
+						URL:       "https://example.com/foo.qcow2",
+						Transport: "nosuchprofile",
+					},
+				},
+			},
+		},
+	}
+	clientset := fake.NewSimpleClientset(mappings[0], mappings[1])
+	cs := NewCRDSource("foobar", "node1", nil)
+	cs.(*crdConfigSource).virtletClient = clientset
+
+	// invalid mappings are still used
+	configs, err := cs.Configs(context.Background())
+	if err != nil {
+		t.Fatalf("Configs(): %v", err)
+	}
+	if len(configs) != 2 {
+		t.Errorf("Bad config list: %#v", configs)
+	}
+
+	getStatus := func(name string) virtlet_v1.VirtletImageMappingStatus {
+		m, err := clientset.VirtletV1().VirtletImageMappings("foobar").Get(name, meta_v1.GetOptions{})
+		if err != nil {
+			t.Fatalf("Get(): %v", err)
+		}
+		return m.Status
+	}
+	goodStatus := getStatus("good")
+	if len(goodStatus.Conditions) != 1 || goodStatus.Conditions[0].Status != "True" {
+		t.Errorf("Bad conditions for the valid mapping: %#v", goodStatus.Conditions)
+	}
+	nodeStatus, found := goodStatus.Nodes["node1"]
+	if !found || nodeStatus.SpecHash == "" || nodeStatus.LastApplied.IsZero() || nodeStatus.Error != "" {
+		t.Errorf("Bad node status for the valid mapping: %#v", goodStatus.Nodes)
+	}
+
+	badStatus := getStatus("bad")
+	if len(badStatus.Conditions) != 1 || badStatus.Conditions[0].Status != "False" {
+		t.Fatalf("Bad conditions for the invalid mapping: %#v", badStatus.Conditions)
+	}
+	for _, s := range []string{"invalid regexp", "unknown transport profile"} {
+		if !strings.Contains(badStatus.Conditions[0].Message, s) {
+			t.Errorf("%q not found in the condition message: %q", s, badStatus.Conditions[0].Message)
+		}
+	}
+
+	// the status must not be updated if nothing has changed
+	clientset.ClearActions()
+	if _, err := cs.Configs(context.Background()); err != nil {
+		t.Fatalf("Configs(): %v", err)
+	}
+	for _, action := range clientset.Actions() {
+		if action.GetVerb() == "update" {
+			t.Errorf("Unexpected update: %#v", action)
+		}
+	}
+}
+
+func TestCRDConfigSourceWatch(t *testing.T) {
+	mapping := func(name, imageName string) *virtlet_v1.VirtletImageMapping {
+		return &virtlet_v1.VirtletImageMapping{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name:      name,
+				Namespace: "foobar",
+			},
+			Spec: virtlet_v1.ImageTranslation{
+				Rules: []virtlet_v1.TranslationRule{
+					{
+						Name: imageName,
+						URL:  "https://example.com/" + imageName + ".qcow2",
+					},
+				},
+			},
+		}
+	}
+	clientset := fake.NewSimpleClientset(mapping("mapping1", "testimage1"))
+	cs := NewCRDSource("foobar", "", nil)
+	cs.(*crdConfigSource).virtletClient = clientset
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := make(chan struct{}, 100)
+	if err := cs.(WatchableConfigSource).Watch(ctx, func() { changes <- struct{}{} }); err != nil {
+		t.Fatalf("Watch(): %v", err)
+	}
+
+	configNames := func() []string {
+		configs, err := cs.Configs(context.Background())
+		if err != nil {
+			t.Fatalf("Configs(): %v", err)
+		}
+		var names []string
+		for _, cfg := range configs {
+			names = append(names, cfg.ConfigName())
+		}
+		sort.Strings(names)
+		return names
+	}
+	if names := configNames(); !reflect.DeepEqual(names, []string{"mapping1"}) {
+		t.Errorf("Bad config list: %v", names)
+	}
+
+	// the configs must be retrieved from the informer cache
+	clientset.ClearActions()
+	configNames()
+	if actions := clientset.Actions(); len(actions) != 0 {
+		t.Errorf("Unexpected apiserver requests: %#v", actions)
+	}
+
+	if _, err := clientset.VirtletV1().VirtletImageMappings("foobar").Create(mapping("mapping2", "testimage2")); err != nil {
+		t.Fatalf("Create(): %v", err)
+	}
+	select {
+	case <-changes:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for the change notification")
+	}
+	if names := configNames(); !reflect.DeepEqual(names, []string{"mapping1", "mapping2"}) {
+		t.Errorf("Bad config list: %v", names)
+	}
+}
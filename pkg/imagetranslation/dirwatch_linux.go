@@ -0,0 +1,91 @@
+// +build linux
+
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package imagetranslation
+
+import (
+	"context"
+	"fmt"
+	"unsafe"
+
+	"github.com/golang/glog"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// dirWatchMask specifies the inotify events that denote
+	// changes in the directory. ConfigMap volumes are updated
+	// by replacing a symlink, which causes IN_MOVED_TO event.
+	dirWatchMask = unix.IN_CREATE | unix.IN_DELETE | unix.IN_CLOSE_WRITE | unix.IN_MODIFY |
+		unix.IN_MOVED_FROM | unix.IN_MOVED_TO | unix.IN_ATTRIB | unix.IN_DELETE_SELF | unix.IN_MOVE_SELF
+	// dirWatchPollTimeout is the timeout in milliseconds after
+	// which the watcher checks whether it should stop
+	dirWatchPollTimeout = 1000
+	dirWatchBufSize     = 65536
+)
+
+// watchDirectory starts watching the directory for changes using
+// inotify, invoking onChange after each batch of changes until ctx is
+// cancelled.
+func watchDirectory(ctx context.Context, dir string, onChange func()) error {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return fmt.Errorf("inotify_init1(): %v", err)
+	}
+	if _, err := unix.InotifyAddWatch(fd, dir, dirWatchMask); err != nil {
+		unix.Close(fd)
+		return fmt.Errorf("can't watch directory %q: %v", dir, err)
+	}
+	go func() {
+		defer unix.Close(fd)
+		buf := make([]byte, dirWatchBufSize)
+		pollFds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}
+		for ctx.Err() == nil {
+			n, err := unix.Poll(pollFds, dirWatchPollTimeout)
+			switch {
+			case err == unix.EINTR || n == 0:
+				continue
+			case err != nil:
+				glog.Errorf("Error watching directory %q: poll(): %v", dir, err)
+				return
+			}
+			n, err = unix.Read(fd, buf)
+			switch {
+			case err == unix.EINTR || err == unix.EAGAIN:
+				continue
+			case err != nil:
+				glog.Errorf("Error watching directory %q: read(): %v", dir, err)
+				return
+			}
+			stop := false
+			for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+				event := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+				if event.Mask&unix.IN_IGNORED != 0 {
+					stop = true
+				}
+				offset += unix.SizeofInotifyEvent + int(event.Len)
+			}
+			onChange()
+			if stop {
+				glog.Warningf("Directory %q was removed, not watching it anymore", dir)
+				return
+			}
+		}
+	}()
+	return nil
+}
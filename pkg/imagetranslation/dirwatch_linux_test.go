@@ -0,0 +1,77 @@
+// +build linux
+
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package imagetranslation
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileSourceWatch(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "image-translations")
+	if err != nil {
+		t.Fatalf("TempDir(): %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := make(chan struct{}, 100)
+	cs := NewFileConfigSource(tmpDir).(WatchableConfigSource)
+	if err := cs.Watch(ctx, func() { changes <- struct{}{} }); err != nil {
+		t.Fatalf("Watch(): %v", err)
+	}
+
+	waitForChange := func(what string) {
+		select {
+		case <-changes:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for the change notification (%s)", what)
+		}
+		// drain the notifications for the same change
+		for {
+			select {
+			case <-changes:
+			case <-time.After(100 * time.Millisecond):
+				return
+			}
+		}
+	}
+
+	configPath := filepath.Join(tmpDir, "config1.yaml")
+	if err := ioutil.WriteFile(configPath, []byte("translations: []\n"), 0644); err != nil {
+		t.Fatalf("WriteFile(): %v", err)
+	}
+	waitForChange("file created")
+
+	newPath := filepath.Join(tmpDir, "config2.yaml")
+	if err := os.Rename(configPath, newPath); err != nil {
+		t.Fatalf("Rename(): %v", err)
+	}
+	waitForChange("file renamed")
+
+	if err := os.Remove(newPath); err != nil {
+		t.Fatalf("Remove(): %v", err)
+	}
+	waitForChange("file removed")
+}
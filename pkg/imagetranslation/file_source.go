@@ -31,7 +31,7 @@ type fileConfigSource struct {
 	configsDirectory string
 }
 
-var _ ConfigSource = fileConfigSource{}
+var _ WatchableConfigSource = fileConfigSource{}
 
 type fileConfig struct {
 	name string
@@ -61,6 +61,11 @@ func (cs fileConfigSource) Description() string {
 	return fmt.Sprintf("local directory %s", cs.configsDirectory)
 }
 
+// Watch implements WatchableConfigSource Watch
+func (cs fileConfigSource) Watch(ctx context.Context, onChange func()) error {
+	return watchDirectory(ctx, cs.configsDirectory, onChange)
+}
+
 // Name implements TranslationConfig Name
 func (c fileConfig) ConfigName() string {
 	return c.name
@@ -41,6 +41,18 @@ type ConfigSource interface {
 	Description() string
 }
 
+// WatchableConfigSource is a data-source that can notify about the
+// changes in its configs, so they don't need to be reloaded upon
+// each image pull
+type WatchableConfigSource interface {
+	ConfigSource
+
+	// Watch starts watching for the config changes in background,
+	// invoking onChange after the configs have changed, until ctx is
+	// cancelled. It returns an error if the configs can't be watched.
+	Watch(ctx context.Context, onChange func()) error
+}
+
 // ImageNameTranslator is the main translator interface
 type ImageNameTranslator interface {
 	// LoadConfigs initializes translator with configs from supplied data sources. All previous mappings are discarded.
@@ -48,4 +60,19 @@ type ImageNameTranslator interface {
 
 	// Translate translates image name to ins Endpoint. If no suitable mapping was found, the default Endpoint is returned
 	Translate(name string) image.Endpoint
+
+	// FindRule returns the description of the rule that matches the image name or nil if there's no such rule
+	FindRule(name string) *RuleMatch
+}
+
+// RuleMatch describes the translation rule that matches an image name
+type RuleMatch struct {
+	// ConfigName is the name of the config that contains the rule
+	ConfigName string
+	// RuleIndex is the index of the rule in the config
+	RuleIndex int
+	// Rule is the rule as it's specified in the config
+	Rule v1.TranslationRule
+	// Endpoint is the result of the translation
+	Endpoint image.Endpoint
 }
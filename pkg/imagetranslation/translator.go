@@ -22,9 +22,9 @@ import (
 	"crypto/ecdsa"
 	"crypto/rsa"
 	"crypto/x509"
-	"encoding/pem"
 	"errors"
 	"regexp"
+	"sort"
 	"strings"
 	"time"
 
@@ -35,9 +35,54 @@ import (
 	"github.com/Mirantis/virtlet/pkg/image"
 )
 
+// compiledRule is a translation rule that's prepared for matching
+type compiledRule struct {
+	index int
+	rule  v1.TranslationRule
+	re    *regexp.Regexp
+	// endpoint holds the translation result for name-based rules
+	endpoint image.Endpoint
+}
+
+// compiledConfig is a translation config that's prepared for matching
+type compiledConfig struct {
+	name        string
+	prefix      string
+	translation *v1.ImageTranslation
+	nameRules   []compiledRule
+	regexpRules []compiledRule
+}
+
+func compileConfig(name string, translation *v1.ImageTranslation, allowRegexp bool) compiledConfig {
+	c := compiledConfig{
+		name:        name,
+		prefix:      translation.Prefix + "/",
+		translation: translation,
+	}
+	for n, r := range translation.Rules {
+		if r.Name != "" {
+			c.nameRules = append(c.nameRules, compiledRule{
+				index:    n,
+				rule:     r,
+				endpoint: convertEndpoint(r, translation),
+			})
+		}
+		if r.Regex == "" || !allowRegexp {
+			continue
+		}
+		re, err := regexp.Compile(r.Regex)
+		if err != nil {
+			glog.V(2).Infof("invalid regexp in image translation config: %q", r.Regex)
+			continue
+		}
+		c.regexpRules = append(c.regexpRules, compiledRule{index: n, rule: r, re: re})
+	}
+	return c
+}
+
 type imageNameTranslator struct {
-	allowRegexp  bool
-	translations map[string]*v1.ImageTranslation
+	allowRegexp bool
+	configs     []compiledConfig
 }
 
 // LoadConfigs implements ImageNameTranslator LoadConfigs
@@ -59,20 +104,40 @@ func (t *imageNameTranslator) LoadConfigs(ctx context.Context, sources ...Config
 			translations[cfg.ConfigName()] = &body
 		}
 	}
-	t.translations = translations
+
+	var configNames []string
+	for configName := range translations {
+		configNames = append(configNames, configName)
+	}
+	sort.Strings(configNames)
+	configs := make([]compiledConfig, len(configNames))
+	for n, configName := range configNames {
+		configs[n] = compileConfig(configName, translations[configName], t.allowRegexp)
+	}
+	t.configs = configs
 }
 
 func convertEndpoint(rule v1.TranslationRule, config *v1.ImageTranslation) image.Endpoint {
+	switch rule.MirrorSelection {
+	case "", v1.MirrorSelectionOrdered, v1.MirrorSelectionFastest:
+	default:
+		glog.Warningf("bad mirror selection mode %q for the image URL %q, using %q", rule.MirrorSelection, rule.URL, v1.MirrorSelectionOrdered)
+	}
 	profile, exists := config.Transports[rule.Transport]
 	if !exists {
 		return image.Endpoint{
-			URL:          rule.URL,
-			MaxRedirects: -1,
+			URL:           rule.URL,
+			Mirrors:       rule.Mirrors,
+			FastestMirror: rule.MirrorSelection == v1.MirrorSelectionFastest,
+			MaxRedirects:  -1,
 		}
 	}
 	if profile.TimeoutMilliseconds < 0 {
 		profile.TimeoutMilliseconds = 0
 	}
+	if profile.MaxBytesPerSecond < 0 {
+		profile.MaxBytesPerSecond = 0
+	}
 	maxRedirects := -1
 	if profile.MaxRedirects != nil {
 		maxRedirects = *profile.MaxRedirects
@@ -82,33 +147,9 @@ func convertEndpoint(rule v1.TranslationRule, config *v1.ImageTranslation) image
 	if profile.TLS != nil {
 		var certificates []image.TLSCertificate
 		for i, record := range profile.TLS.Certificates {
-			var x509Certs []*x509.Certificate
-			var privateKey crypto.PrivateKey
-
-			for _, data := range [2]string{record.Key, record.Cert} {
-				dataBytes := []byte(data)
-				for {
-					block, rest := pem.Decode(dataBytes)
-					if block == nil {
-						break
-					}
-					if block.Type == "CERTIFICATE" {
-						c, err := x509.ParseCertificate(block.Bytes)
-						if err != nil {
-							glog.V(2).Infof("error decoding certificate #%d from transport profile %s", i, rule.Transport)
-						} else {
-							x509Certs = append(x509Certs, c)
-						}
-					} else if privateKey == nil && strings.HasSuffix(block.Type, "PRIVATE KEY") {
-						k, err := parsePrivateKey(block.Bytes)
-						if err != nil {
-							glog.V(2).Infof("error decoding private key #%d from transport profile %s", i, rule.Transport)
-						} else {
-							privateKey = k
-						}
-					}
-					dataBytes = rest
-				}
+			x509Certs, privateKey, errs := decodeTLSCertificate(record)
+			for _, err := range errs {
+				glog.V(2).Infof("certificate #%d from transport profile %s: %v", i, rule.Transport, err)
 			}
 
 			for _, c := range x509Certs {
@@ -126,16 +167,61 @@ func convertEndpoint(rule v1.TranslationRule, config *v1.ImageTranslation) image
 		}
 	}
 
+	var headers map[string]string
+	for _, h := range profile.Headers {
+		if headers == nil {
+			headers = make(map[string]string)
+		}
+		headers[h.Name] = secretValue(h.Value, h.SecretKeyRef, rule.Transport)
+	}
+
 	return image.Endpoint{
-		URL:          rule.URL,
-		Timeout:      time.Millisecond * time.Duration(profile.TimeoutMilliseconds),
-		Proxy:        profile.Proxy,
-		ProfileName:  rule.Transport,
-		MaxRedirects: maxRedirects,
-		TLS:          tlsConfig,
+		URL:               rule.URL,
+		Mirrors:           rule.Mirrors,
+		FastestMirror:     rule.MirrorSelection == v1.MirrorSelectionFastest,
+		Timeout:           time.Millisecond * time.Duration(profile.TimeoutMilliseconds),
+		Proxy:             profile.Proxy,
+		ProfileName:       rule.Transport,
+		MaxRedirects:      maxRedirects,
+		TLS:               tlsConfig,
+		MaxBytesPerSecond: profile.MaxBytesPerSecond,
+		Credentials:       convertAuth(profile.Auth, rule.Transport),
+		Headers:           headers,
 	}
 }
 
+// secretValue returns the value of a profile setting. The references
+// to the Secrets must be resolved by the config source beforehand.
+func secretValue(value string, ref *v1.SecretKeySelector, profileName string) string {
+	if value == "" && ref != nil {
+		glog.Warningf("unresolved reference to key %q of secret %q in transport profile %s", ref.Key, ref.Name, profileName)
+	}
+	return value
+}
+
+func convertAuth(auth *v1.HTTPAuth, profileName string) *image.Credentials {
+	if auth == nil {
+		return nil
+	}
+	var creds image.Credentials
+	for _, item := range []struct {
+		v   *v1.SecretValue
+		dst *string
+	}{
+		{auth.Username, &creds.Username},
+		{auth.Password, &creds.Password},
+		{auth.BearerToken, &creds.BearerToken},
+	} {
+		if item.v != nil {
+			*item.dst = secretValue(item.v.Value, item.v.SecretKeyRef, profileName)
+		}
+	}
+	if creds == (image.Credentials{}) {
+		return nil
+	}
+	return &creds
+}
+
 func parsePrivateKey(der []byte) (crypto.PrivateKey, error) {
 	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
 		return key, nil
@@ -155,41 +241,56 @@ func parsePrivateKey(der []byte) (crypto.PrivateKey, error) {
 	return nil, errors.New("tls: failed to parse private key")
 }
 
-// Translate implements ImageNameTranslator Translate
-func (t *imageNameTranslator) Translate(name string) image.Endpoint {
-	for _, translation := range t.translations {
-		prefix := translation.Prefix + "/"
+// FindRule implements ImageNameTranslator FindRule
+func (t *imageNameTranslator) FindRule(name string) *RuleMatch {
+	for _, c := range t.configs {
 		unprefixedName := name
-		if prefix != "/" {
-			if !strings.HasPrefix(name, prefix) {
+		if c.prefix != "/" {
+			if !strings.HasPrefix(name, c.prefix) {
 				continue
 			}
-			unprefixedName = name[len(prefix):]
+			unprefixedName = name[len(c.prefix):]
 		}
-		for _, r := range translation.Rules {
-			if r.Name != "" && r.Name == unprefixedName {
-				return convertEndpoint(r, translation)
+		for _, r := range c.nameRules {
+			if r.rule.Name == unprefixedName {
+				return &RuleMatch{
+					ConfigName: c.name,
+					RuleIndex:  r.index,
+					Rule:       r.rule,
+					Endpoint:   r.endpoint,
+				}
 			}
 		}
-		if !t.allowRegexp {
-			continue
-		}
-		for _, r := range translation.Rules {
-			if r.Regex == "" {
+		for _, r := range c.regexpRules {
+			submatchIndexes := r.re.FindStringSubmatchIndex(unprefixedName)
+			if len(submatchIndexes) == 0 {
 				continue
 			}
-			re, err := regexp.Compile(r.Regex)
-			if err != nil {
-				glog.V(2).Infof("invalid regexp in image translation config: %q", r.Regex)
-				continue
+			expanded := r.rule
+			expanded.URL = string(r.re.ExpandString(nil, r.rule.URL, unprefixedName, submatchIndexes))
+			if len(r.rule.Mirrors) != 0 {
+				mirrors := make([]string, len(r.rule.Mirrors))
+				for n, m := range r.rule.Mirrors {
+					mirrors[n] = string(r.re.ExpandString(nil, m, unprefixedName, submatchIndexes))
+				}
+				expanded.Mirrors = mirrors
 			}
-			submatchIndexes := re.FindStringSubmatchIndex(unprefixedName)
-			if len(submatchIndexes) > 0 {
-				r.URL = string(re.ExpandString(nil, r.URL, unprefixedName, submatchIndexes))
-				return convertEndpoint(r, translation)
+			return &RuleMatch{
+				ConfigName: c.name,
+				RuleIndex:  r.index,
+				Rule:       r.rule,
+				Endpoint:   convertEndpoint(expanded, c.translation),
 			}
 		}
 	}
+	return nil
+}
+
+// Translate implements ImageNameTranslator Translate
+func (t *imageNameTranslator) Translate(name string) image.Endpoint {
+	if match := t.FindRule(name); match != nil {
+		return match.Endpoint
+	}
 	glog.V(1).Infof("Using URL %q without translation", name)
 	return image.Endpoint{URL: name, MaxRedirects: -1}
 }
@@ -202,20 +303,18 @@ func NewImageNameTranslator(allowRegexp bool) ImageNameTranslator {
 }
 
 // GetDefaultImageTranslator returns a default image translation that
-// uses CRDs and a config directory
-func GetDefaultImageTranslator(imageTranslationConfigsDir string, allowRegexp bool, clientCfg clientcmd.ClientConfig) image.Translator {
+// uses CRDs and a config directory. The configs are watched for
+// changes and only reloaded when they change. nodeName is used to
+// report the status of the CRDs and may be empty.
+func GetDefaultImageTranslator(imageTranslationConfigsDir string, allowRegexp bool, clientCfg clientcmd.ClientConfig, nodeName string) image.Translator {
 	var sources []ConfigSource
 	if clientCfg != nil {
-		sources = append(sources, NewCRDSource("kube-system", clientCfg))
+		sources = append(sources, NewCRDSource("kube-system", nodeName, clientCfg))
 	}
 	if imageTranslationConfigsDir != "" {
 		sources = append(sources, NewFileConfigSource(imageTranslationConfigsDir))
 	}
-	return func(ctx context.Context, name string) image.Endpoint {
-		translator := NewImageNameTranslator(allowRegexp)
-		translator.LoadConfigs(ctx, sources...)
-		return translator.Translate(name)
-	}
+	return newWatchingTranslator(allowRegexp, sources).translate
 }
 
 // GetEmptyImageTranslator returns an empty image translator that
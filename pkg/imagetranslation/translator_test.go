@@ -18,6 +18,7 @@ package imagetranslation
 
 import (
 	"context"
+	"reflect"
 	"testing"
 
 	"github.com/Mirantis/virtlet/pkg/api/virtlet.k8s/v1"
@@ -55,13 +56,31 @@ func TestTranslations(t *testing.T) {
 				},
 			},
 		},
+		"config3": {
+			Prefix: "mirrored",
+			Rules: []v1.TranslationRule{
+				{
+					Regex:           `^fedora/(\d+)`,
+					URL:             "https://dc1.example.com/fedora-$1.qcow2",
+					Mirrors:         []string{"https://dc2.example.com/fedora-$1.qcow2", "https://dc3.example.com/fedora-$1.qcow2"},
+					MirrorSelection: "fastest",
+				},
+				{
+					Name:    "cirros",
+					URL:     "https://dc1.example.com/cirros.qcow2",
+					Mirrors: []string{"https://dc2.example.com/cirros.qcow2"},
+				},
+			},
+		},
 	}
 
 	for _, tc := range []struct {
-		name        string
-		allowRegexp bool
-		imageName   string
-		expectedURL string
+		name            string
+		allowRegexp     bool
+		imageName       string
+		expectedURL     string
+		expectedMirrors []string
+		expectFastest   bool
 	}{
 		{
 			name:        "strict translation",
@@ -123,6 +142,24 @@ func TestTranslations(t *testing.T) {
 			imageName:   "prod/image1",
 			expectedURL: "http://example.net/alt_1.qcow2",
 		},
+		{
+			name:            "translation with mirrors",
+			allowRegexp:     false,
+			imageName:       "mirrored/cirros",
+			expectedURL:     "https://dc1.example.com/cirros.qcow2",
+			expectedMirrors: []string{"https://dc2.example.com/cirros.qcow2"},
+		},
+		{
+			name:        "regexp translation with mirrors",
+			allowRegexp: true,
+			imageName:   "mirrored/fedora/29",
+			expectedURL: "https://dc1.example.com/fedora-29.qcow2",
+			expectedMirrors: []string{
+				"https://dc2.example.com/fedora-29.qcow2",
+				"https://dc3.example.com/fedora-29.qcow2",
+			},
+			expectFastest: true,
+		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
 			translator := NewImageNameTranslator(tc.allowRegexp).(*imageNameTranslator)
@@ -131,6 +168,53 @@ func TestTranslations(t *testing.T) {
 			if tc.expectedURL != endpoint.URL {
 				t.Errorf("expected URL %q, but got %q", tc.expectedURL, endpoint.URL)
 			}
+			if !reflect.DeepEqual(tc.expectedMirrors, endpoint.Mirrors) {
+				t.Errorf("expected mirrors %#v, but got %#v", tc.expectedMirrors, endpoint.Mirrors)
+			}
+			if tc.expectFastest != endpoint.FastestMirror {
+				t.Errorf("expected FastestMirror to be %v, but got %v", tc.expectFastest, endpoint.FastestMirror)
+			}
 		})
 	}
 }
+
+func TestFindRule(t *testing.T) {
+	rule := v1.TranslationRule{
+		Regex:   `^ubuntu/(\d+\.\d+)$`,
+		URL:     "https://example.com/ubuntu-$1.img",
+		Mirrors: []string{"https://mirror.example.com/ubuntu-$1.img"},
+	}
+	configs := map[string]v1.ImageTranslation{
+		"config1": {
+			Prefix: "test",
+			Rules: []v1.TranslationRule{
+				{
+					Name: "cirros",
+					URL:  "https://example.com/cirros.img",
+				},
+				rule,
+			},
+		},
+	}
+	translator := NewImageNameTranslator(true)
+	translator.LoadConfigs(context.Background(), NewFakeConfigSource(configs))
+
+	match := translator.FindRule("test/ubuntu/16.04")
+	if match == nil {
+		t.Fatalf("no rule found")
+	}
+	if match.ConfigName != "config1" || match.RuleIndex != 1 || !reflect.DeepEqual(match.Rule, rule) {
+		t.Errorf("Bad rule match: %#v", match)
+	}
+	if match.Endpoint.URL != "https://example.com/ubuntu-16.04.img" {
+		t.Errorf("Bad URL: %q", match.Endpoint.URL)
+	}
+	expectedMirrors := []string{"https://mirror.example.com/ubuntu-16.04.img"}
+	if !reflect.DeepEqual(match.Endpoint.Mirrors, expectedMirrors) {
+		t.Errorf("Bad mirrors: %#v instead of %#v", match.Endpoint.Mirrors, expectedMirrors)
+	}
+
+	if match := translator.FindRule("ubuntu/16.04"); match != nil {
+		t.Errorf("Unexpected rule match for an unprefixed name: %#v", match)
+	}
+}
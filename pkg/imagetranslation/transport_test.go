@@ -317,6 +317,83 @@ func TestImageDownloadWithTimeout(t *testing.T) {
 	}
 }
 
+func TestImageDownloadWithAuth(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Api-Token") != "apitoken" {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		switch user, password, ok := r.BasicAuth(); {
+		case r.URL.String() == "/bearer.qcow2" && r.Header.Get("Authorization") == "Bearer sometoken":
+		case r.URL.String() == "/basic.qcow2" && ok && user == "user" && password == "secret":
+		default:
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		}
+	})
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	headers := []v1.HTTPHeader{{Name: "X-Api-Token", Value: "apitoken"}}
+	config := v1.ImageTranslation{
+		Rules: []v1.TranslationRule{
+			{
+				Name:      "basic",
+				URL:       "%/basic.qcow2",
+				Transport: "basic",
+			},
+			{
+				Name:      "bearer",
+				URL:       "%/bearer.qcow2",
+				Transport: "bearer",
+			},
+			{
+				Name:      "noheaders",
+				URL:       "%/bearer.qcow2",
+				Transport: "noheaders",
+			},
+		},
+		Transports: map[string]v1.TransportProfile{
+			"basic": {
+				Auth: &v1.HTTPAuth{
+					Username: &v1.SecretValue{Value: "user"},
+					Password: &v1.SecretValue{Value: "secret"},
+				},
+				Headers: headers,
+			},
+			"bearer": {
+				Auth: &v1.HTTPAuth{
+					BearerToken: &v1.SecretValue{Value: "sometoken"},
+				},
+				Headers: headers,
+			},
+			"noheaders": {
+				Auth: &v1.HTTPAuth{
+					BearerToken: &v1.SecretValue{Value: "sometoken"},
+				},
+			},
+		},
+	}
+
+	downloader := image.NewDownloader("http")
+	for _, tst := range []struct {
+		image    string
+		mustFail bool
+	}{
+		{image: "basic"},
+		{image: "bearer"},
+		{image: "noheaders", mustFail: true},
+	} {
+		t.Run(tst.image, func(t *testing.T) {
+			err := downloader.DownloadFile(context.Background(), translate(config, tst.image, ts), ioutil.Discard)
+			if err == nil && tst.mustFail {
+				t.Error("no error happened when the download was expected to fail")
+			} else if err != nil && !tst.mustFail {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
 func TestImageDownloadTLS(t *testing.T) {
 	ca, caKey := testutils.GenerateCert(t, true, "CA", nil, nil)
 	cert, key := testutils.GenerateCert(t, false, "127.0.0.1", ca, caKey)
@@ -0,0 +1,141 @@
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package imagetranslation
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Mirantis/virtlet/pkg/api/virtlet.k8s/v1"
+)
+
+// decodeTLSCertificate decodes the PEM blocks of a TLS certificate
+// record. It returns the certificates and the private key that could
+// be parsed along with the list of errors encountered.
+func decodeTLSCertificate(record v1.TLSCertificate) ([]*x509.Certificate, crypto.PrivateKey, []error) {
+	var x509Certs []*x509.Certificate
+	var privateKey crypto.PrivateKey
+	var errs []error
+	for _, data := range [2]string{record.Key, record.Cert} {
+		dataBytes := []byte(data)
+		for {
+			block, rest := pem.Decode(dataBytes)
+			if block == nil {
+				break
+			}
+			if block.Type == "CERTIFICATE" {
+				c, err := x509.ParseCertificate(block.Bytes)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("error decoding certificate: %v", err))
+				} else {
+					x509Certs = append(x509Certs, c)
+				}
+			} else if privateKey == nil && strings.HasSuffix(block.Type, "PRIVATE KEY") {
+				k, err := parsePrivateKey(block.Bytes)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("error decoding private key: %v", err))
+				} else {
+					privateKey = k
+				}
+			}
+			dataBytes = rest
+		}
+	}
+	return x509Certs, privateKey, errs
+}
+
+// ValidateTranslation checks the image translation config for
+// errors that would otherwise only be discovered when pulling the
+// images. It returns the list of problems found, which is empty if
+// the config is valid.
+func ValidateTranslation(tr *v1.ImageTranslation) []string {
+	var problems []string
+	addProblem := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	for n, r := range tr.Rules {
+		ruleName := fmt.Sprintf("rule #%d", n+1)
+		switch {
+		case r.Name == "" && r.Regex == "":
+			addProblem("%s: either name or regexp must be specified", ruleName)
+		case r.Name != "" && r.Regex != "":
+			addProblem("%s: name and regexp can't be specified at the same time", ruleName)
+		case r.Regex != "":
+			if _, err := regexp.Compile(r.Regex); err != nil {
+				addProblem("%s: invalid regexp %q: %v", ruleName, r.Regex, err)
+			}
+		}
+		if r.URL == "" {
+			addProblem("%s: url is empty", ruleName)
+		}
+		for _, m := range r.Mirrors {
+			if m == "" {
+				addProblem("%s: empty mirror url", ruleName)
+			}
+		}
+		switch r.MirrorSelection {
+		case "", v1.MirrorSelectionOrdered, v1.MirrorSelectionFastest:
+		default:
+			addProblem("%s: bad mirror selection mode %q", ruleName, r.MirrorSelection)
+		}
+		if r.Transport != "" {
+			if _, found := tr.Transports[r.Transport]; !found {
+				addProblem("%s: unknown transport profile %q", ruleName, r.Transport)
+			}
+		}
+	}
+
+	var profileNames []string
+	for name := range tr.Transports {
+		profileNames = append(profileNames, name)
+	}
+	sort.Strings(profileNames)
+	for _, name := range profileNames {
+		profile := tr.Transports[name]
+		profileName := fmt.Sprintf("transport profile %s", name)
+		if profile.Proxy != "" {
+			if _, err := url.Parse(profile.Proxy); err != nil {
+				addProblem("%s: bad proxy url %q: %v", profileName, profile.Proxy, err)
+			}
+		}
+		if profile.TLS != nil {
+			for i, record := range profile.TLS.Certificates {
+				certs, _, errs := decodeTLSCertificate(record)
+				for _, err := range errs {
+					addProblem("%s: certificate #%d: %v", profileName, i+1, err)
+				}
+				if len(certs) == 0 && len(errs) == 0 {
+					addProblem("%s: certificate #%d: no PEM encoded certificates found", profileName, i+1)
+				}
+			}
+		}
+		for _, h := range profile.Headers {
+			if h.Name == "" {
+				addProblem("%s: header with an empty name", profileName)
+			}
+		}
+	}
+
+	return problems
+}
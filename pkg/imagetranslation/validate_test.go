@@ -0,0 +1,134 @@
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package imagetranslation
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Mirantis/virtlet/pkg/api/virtlet.k8s/v1"
+)
+
+const badCertPEM = `-----BEGIN CERTIFICATE-----
+Zm9vYmFy
+-----END CERTIFICATE-----
+`
+
+func TestValidateTranslation(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		translation v1.ImageTranslation
+		// the problems are matched by prefix so the error
+		// messages coming from Go libraries are not checked
+		problems []string
+	}{
+		{
+			name: "valid",
+			translation: v1.ImageTranslation{
+				Rules: []v1.TranslationRule{
+					{
+						Name:      "cirros",
+						URL:       "https://example.com/cirros.img",
+						Transport: "profile1",
+					},
+					{
+						Regex:           `^ubuntu/(\d+\.\d+)$`,
+						URL:             "https://example.com/ubuntu-$1.img",
+						Mirrors:         []string{"https://mirror.example.com/ubuntu-$1.img"},
+						MirrorSelection: v1.MirrorSelectionFastest,
+					},
+				},
+				Transports: map[string]v1.TransportProfile{
+					"profile1": {
+						Proxy:   "http://proxy.example.com",
+						Headers: []v1.HTTPHeader{{Name: "X-Foo", Value: "bar"}},
+					},
+				},
+			},
+		},
+		{
+			name: "bad rules",
+			translation: v1.ImageTranslation{
+				Rules: []v1.TranslationRule{
+					{
+						URL: "https://example.com/cirros.img",
+					},
+					{
+						Name:  "foo",
+						Regex: "foo",
+						URL:   "https://example.com/foo.img",
+					},
+					{
+						Regex:           "foo(",
+						MirrorSelection: "random",
+						Mirrors:         []string{""},
+						Transport:       "nosuchprofile",
+					},
+				},
+			},
+			problems: []string{
+				"rule #1: either name or regexp must be specified",
+				"rule #2: name and regexp can't be specified at the same time",
+				"rule #3: invalid regexp \"foo(\": ",
+				"rule #3: url is empty",
+				"rule #3: empty mirror url",
+				"rule #3: bad mirror selection mode \"random\"",
+				"rule #3: unknown transport profile \"nosuchprofile\"",
+			},
+		},
+		{
+			name: "bad transport profiles",
+			translation: v1.ImageTranslation{
+				Transports: map[string]v1.TransportProfile{
+					"profile2": {
+						TLS: &v1.TLSConfig{
+							Certificates: []v1.TLSCertificate{
+								{Cert: "foobar"},
+							},
+						},
+						Headers: []v1.HTTPHeader{{Value: "bar"}},
+					},
+					"profile1": {
+						Proxy: "http://proxy example.com:abc",
+						TLS: &v1.TLSConfig{
+							Certificates: []v1.TLSCertificate{
+								{Cert: badCertPEM},
+							},
+						},
+					},
+				},
+			},
+			problems: []string{
+				"transport profile profile1: bad proxy url \"http://proxy example.com:abc\": ",
+				"transport profile profile1: certificate #1: error decoding certificate: ",
+				"transport profile profile2: certificate #1: no PEM encoded certificates found",
+				"transport profile profile2: header with an empty name",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			problems := ValidateTranslation(&tc.translation)
+			ok := len(problems) == len(tc.problems)
+			for n := 0; ok && n < len(problems); n++ {
+				ok = strings.HasPrefix(problems[n], tc.problems[n])
+			}
+			if !ok {
+				t.Errorf("Bad validation result:\n%s\ninstead of\n%s", strings.Join(problems, "\n"), strings.Join(tc.problems, "\n"))
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package imagetranslation
+
+import (
+	"context"
+	"sync"
+
+	"github.com/golang/glog"
+
+	"github.com/Mirantis/virtlet/pkg/image"
+)
+
+// watchingTranslator keeps the translation rules loaded from the
+// config sources up to date, reloading them when the sources report
+// changes. If some of the sources can't be watched, the configs are
+// reloaded upon each translation.
+type watchingTranslator struct {
+	sync.RWMutex
+	allowRegexp bool
+	sources     []ConfigSource
+	startOnce   sync.Once
+	pollSources bool
+	translator  ImageNameTranslator
+	// reloadGen is the number of reloads started so far and
+	// translatorGen is the number of the reload that has
+	// produced the current translator. They're used to discard
+	// the results of the reloads that finish out of order.
+	reloadGen     uint64
+	translatorGen uint64
+}
+
+func newWatchingTranslator(allowRegexp bool, sources []ConfigSource) *watchingTranslator {
+	return &watchingTranslator{
+		allowRegexp: allowRegexp,
+		sources:     sources,
+	}
+}
+
+// start starts watching the config sources and loads the configs.
+// The watching continues until ctx is cancelled.
+func (t *watchingTranslator) start(ctx context.Context) {
+	for _, source := range t.sources {
+		ws, ok := source.(WatchableConfigSource)
+		if !ok {
+			t.pollSources = true
+			continue
+		}
+		if err := ws.Watch(ctx, func() { t.reload(ctx) }); err != nil {
+			glog.Warningf("Can't watch image translation configs from %s, will reload them upon each image pull: %v", source.Description(), err)
+			t.pollSources = true
+		}
+	}
+	t.reload(ctx)
+}
+
+// reload loads the configs from the sources and replaces the current
+// translation rules with them unless a reload that has started later
+// has already done so.
+func (t *watchingTranslator) reload(ctx context.Context) {
+	t.Lock()
+	t.reloadGen++
+	gen := t.reloadGen
+	t.Unlock()
+
+	translator := NewImageNameTranslator(t.allowRegexp)
+	translator.LoadConfigs(ctx, t.sources...)
+	t.Lock()
+	defer t.Unlock()
+	if gen < t.translatorGen {
+		return
+	}
+	t.translator = translator
+	t.translatorGen = gen
+}
+
+func (t *watchingTranslator) translate(ctx context.Context, name string) image.Endpoint {
+	t.startOnce.Do(func() {
+		// the watching must not be tied to the
+		// context of a particular image pull
+		t.start(context.Background())
+	})
+	if t.pollSources {
+		t.reload(ctx)
+	}
+	t.RLock()
+	defer t.RUnlock()
+	return t.translator.Translate(name)
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package imagetranslation
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/Mirantis/virtlet/pkg/api/virtlet.k8s/v1"
+)
+
+type fakeWatchableSource struct {
+	sync.Mutex
+	url       string
+	loadCount int
+	watchErr  error
+	onChange  func()
+}
+
+var _ WatchableConfigSource = &fakeWatchableSource{}
+
+func (s *fakeWatchableSource) Configs(ctx context.Context) ([]TranslationConfig, error) {
+	s.Lock()
+	defer s.Unlock()
+	s.loadCount++
+	return []TranslationConfig{
+		objectConfig{
+			name: "config1",
+			translation: v1.ImageTranslation{
+				Rules: []v1.TranslationRule{{Name: "cirros", URL: s.url}},
+			},
+		},
+	}, nil
+}
+
+func (s *fakeWatchableSource) Description() string {
+	return "fake watchable source"
+}
+
+func (s *fakeWatchableSource) Watch(ctx context.Context, onChange func()) error {
+	if s.watchErr != nil {
+		return s.watchErr
+	}
+	s.onChange = onChange
+	return nil
+}
+
+func (s *fakeWatchableSource) setURL(url string) {
+	s.Lock()
+	defer s.Unlock()
+	s.url = url
+}
+
+func (s *fakeWatchableSource) getLoadCount() int {
+	s.Lock()
+	defer s.Unlock()
+	return s.loadCount
+}
+
+func TestWatchingTranslator(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		watchErr error
+		// loadCounts specifies the expected number of
+		// config loads after each translation
+		loadCounts []int
+	}{
+		{
+			name:       "watched",
+			loadCounts: []int{1, 1, 2},
+		},
+		{
+			name:       "not watched",
+			watchErr:   errors.New("can't watch"),
+			loadCounts: []int{2, 3, 4},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			source := &fakeWatchableSource{url: "https://example.com/cirros1.img", watchErr: tc.watchErr}
+			translate := newWatchingTranslator(false, []ConfigSource{source}).translate
+			ctx := context.Background()
+			check := func(n int, expectedURL string) {
+				if url := translate(ctx, "cirros").URL; url != expectedURL {
+					t.Errorf("translation %d: bad url %q instead of %q", n, url, expectedURL)
+				}
+				if loadCount := source.getLoadCount(); loadCount != tc.loadCounts[n] {
+					t.Errorf("translation %d: bad config load count %d instead of %d", n, loadCount, tc.loadCounts[n])
+				}
+			}
+
+			check(0, "https://example.com/cirros1.img")
+			check(1, "https://example.com/cirros1.img")
+			source.setURL("https://example.com/cirros2.img")
+			if source.onChange != nil {
+				source.onChange()
+			}
+			check(2, "https://example.com/cirros2.img")
+		})
+	}
+}
+
+// slowSource blocks the first config load until it's released
+type slowSource struct {
+	fakeWatchableSource
+	firstLoadStarted chan struct{}
+	release          chan struct{}
+}
+
+func (s *slowSource) Configs(ctx context.Context) ([]TranslationConfig, error) {
+	configs, err := s.fakeWatchableSource.Configs(ctx)
+	if s.getLoadCount() == 1 {
+		close(s.firstLoadStarted)
+		<-s.release
+	}
+	return configs, err
+}
+
+func TestWatchingTranslatorOutOfOrderReloads(t *testing.T) {
+	source := &slowSource{
+		fakeWatchableSource: fakeWatchableSource{url: "https://example.com/cirros1.img"},
+		firstLoadStarted:    make(chan struct{}),
+		release:             make(chan struct{}),
+	}
+	translator := newWatchingTranslator(false, []ConfigSource{source})
+	ctx := context.Background()
+	done := make(chan struct{})
+	go func() {
+		translator.reload(ctx)
+		close(done)
+	}()
+	<-source.firstLoadStarted
+	source.setURL("https://example.com/cirros2.img")
+	translator.reload(ctx)
+	close(source.release)
+	<-done
+
+	translator.RLock()
+	defer translator.RUnlock()
+	expectedURL := "https://example.com/cirros2.img"
+	if url := translator.translator.Translate("cirros").URL; url != expectedURL {
+		t.Errorf("bad url %q instead of %q after out-of-order reloads", url, expectedURL)
+	}
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package libvirttools
+
+import (
+	"github.com/Mirantis/virtlet/pkg/metadata/types"
+)
+
+const (
+	vmwrapperPath = "/vmwrapper"
+)
+
+// hostArch is the architecture of the host. It can be replaced in tests
+var hostArch = types.HostArch()
+
+// guestArch describes the devices and features that differ between
+// the guest architectures.
+type guestArch struct {
+	videoModel string
+	inputBus   string
+	acpi       bool
+	// tcgCPUModel is the CPU model to use when the guest is
+	// emulated using TCG, if QEMU default isn't good enough
+	tcgCPUModel string
+}
+
+var guestArchs = map[types.Arch]guestArch{
+	types.ArchX86_64: {
+		videoModel: "cirrus",
+		inputBus:   "usb",
+		acpi:       true,
+	},
+	types.ArchAArch64: {
+		videoModel: "virtio",
+		inputBus:   "virtio",
+		acpi:       true,
+		// QEMU uses 32-bit cortex-a15 by default
+		tcgCPUModel: "cortex-a57",
+	},
+	types.ArchPPC64LE: {
+		videoModel: "vga",
+		inputBus:   "usb",
+	},
+}
+
+// archForConfig returns the guest architecture of the VM.
+func archForConfig(config *types.VMConfig) types.Arch {
+	if config.ParsedAnnotations == nil || config.ParsedAnnotations.Arch == "" {
+		return types.ArchX86_64
+	}
+	return config.ParsedAnnotations.Arch
+}
+
+// vmwrapperForArch returns the path to vmwrapper to be used as the
+// emulator for the guest architecture. libvirt checks the
+// capabilities of the emulator binary by running it, so vmwrapper is
+// symlinked as vmwrapper-<arch> for the guest architectures other
+// than the host one to make it run the matching QEMU binary.
+func vmwrapperForArch(arch types.Arch) string {
+	if arch == hostArch {
+		return vmwrapperPath
+	}
+	return vmwrapperPath + "-" + string(arch)
+}
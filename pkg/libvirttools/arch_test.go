@@ -0,0 +1,173 @@
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package libvirttools
+
+import (
+	"reflect"
+	"testing"
+
+	libvirtxml "github.com/libvirt/libvirt-go-xml"
+
+	vconfig "github.com/Mirantis/virtlet/pkg/config"
+	"github.com/Mirantis/virtlet/pkg/metadata/types"
+)
+
+func TestGuestArch(t *testing.T) {
+	oldHostArch := hostArch
+	defer func() {
+		hostArch = oldHostArch
+	}()
+	hostArch = types.ArchX86_64
+
+	for _, tc := range []struct {
+		name             string
+		arch             types.Arch
+		machineType      types.MachineType
+		firmware         types.Firmware
+		useKvm           bool
+		expectedType     string
+		expectedWrapper  string
+		expectedEmulator string
+		expectedOSArch   string
+		expectedMachine  string
+		expectedVideo    string
+		expectedInputBus string
+		expectACPI       bool
+		expectedCPU      *libvirtxml.DomainCPU
+		expectedLoader   string
+	}{
+		{
+			name:             "x86_64 with kvm",
+			arch:             types.ArchX86_64,
+			machineType:      types.MachineTypePC,
+			firmware:         types.FirmwareBIOS,
+			useKvm:           true,
+			expectedType:     "kvm",
+			expectedWrapper:  "/vmwrapper",
+			expectedEmulator: "/usr/bin/kvm",
+			expectedVideo:    "cirrus",
+			expectedInputBus: "usb",
+			expectACPI:       true,
+		},
+		{
+			name:             "x86_64 without kvm",
+			arch:             types.ArchX86_64,
+			machineType:      types.MachineTypeQ35,
+			firmware:         types.FirmwareBIOS,
+			expectedType:     "qemu",
+			expectedWrapper:  "/vmwrapper",
+			expectedEmulator: "/usr/bin/qemu-system-x86_64",
+			expectedMachine:  "q35",
+			expectedVideo:    "cirrus",
+			expectedInputBus: "usb",
+			expectACPI:       true,
+		},
+		{
+			name:             "aarch64",
+			arch:             types.ArchAArch64,
+			machineType:      types.MachineTypeVirt,
+			firmware:         types.FirmwareUEFI,
+			useKvm:           true,
+			expectedType:     "qemu",
+			expectedWrapper:  "/vmwrapper-aarch64",
+			expectedEmulator: "/usr/bin/qemu-system-aarch64",
+			expectedOSArch:   "aarch64",
+			expectedMachine:  "virt",
+			expectedVideo:    "virtio",
+			expectedInputBus: "virtio",
+			expectACPI:       true,
+			expectedCPU: &libvirtxml.DomainCPU{
+				Mode:  "custom",
+				Model: &libvirtxml.DomainCPUModel{Value: "cortex-a57"},
+			},
+			expectedLoader: aavmfCodePath,
+		},
+		{
+			name:             "ppc64le",
+			arch:             types.ArchPPC64LE,
+			machineType:      types.MachineTypePSeries,
+			firmware:         types.FirmwareBIOS,
+			useKvm:           true,
+			expectedType:     "qemu",
+			expectedWrapper:  "/vmwrapper-ppc64le",
+			expectedEmulator: "/usr/bin/qemu-system-ppc64",
+			expectedOSArch:   "ppc64le",
+			expectedMachine:  "pseries",
+			expectedVideo:    "vga",
+			expectedInputBus: "usb",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			config := &types.VMConfig{
+				PodSandboxID: "69eec606-0493-5825-73a4-c5e0c0236155",
+				ParsedAnnotations: &types.VirtletAnnotations{
+					VCPUCount:   1,
+					Arch:        tc.arch,
+					MachineType: tc.machineType,
+					Firmware:    tc.firmware,
+				},
+			}
+			ds := &domainSettings{
+				useKvm:   tc.useKvm,
+				vcpuNum:  1,
+				cpuModel: types.CPUModelHostModel,
+				arch:     archForConfig(config),
+			}
+			domain := ds.createDomain(config)
+			if domain.Type != tc.expectedType {
+				t.Errorf("bad domain type %q instead of %q", domain.Type, tc.expectedType)
+			}
+			if domain.Devices.Emulator != tc.expectedWrapper {
+				t.Errorf("bad emulator %q instead of %q", domain.Devices.Emulator, tc.expectedWrapper)
+			}
+			emulator := ""
+			for _, env := range domain.QEMUCommandline.Envs {
+				if env.Name == vconfig.EmulatorEnvVarName {
+					emulator = env.Value
+				}
+			}
+			if emulator != tc.expectedEmulator {
+				t.Errorf("bad emulator passed to vmwrapper %q instead of %q", emulator, tc.expectedEmulator)
+			}
+			if domain.OS.Type.Arch != tc.expectedOSArch {
+				t.Errorf("bad arch %q instead of %q", domain.OS.Type.Arch, tc.expectedOSArch)
+			}
+			if domain.OS.Type.Machine != tc.expectedMachine {
+				t.Errorf("bad machine type %q instead of %q", domain.OS.Type.Machine, tc.expectedMachine)
+			}
+			if video := domain.Devices.Videos[0].Model.Type; video != tc.expectedVideo {
+				t.Errorf("bad video model %q instead of %q", video, tc.expectedVideo)
+			}
+			if bus := domain.Devices.Inputs[0].Bus; bus != tc.expectedInputBus {
+				t.Errorf("bad input bus %q instead of %q", bus, tc.expectedInputBus)
+			}
+			if (domain.Features.ACPI != nil) != tc.expectACPI {
+				t.Errorf("bad ACPI feature setting: %#v", domain.Features.ACPI)
+			}
+			if tc.arch != types.ArchX86_64 && !reflect.DeepEqual(domain.CPU, tc.expectedCPU) {
+				t.Errorf("bad cpu: %#v instead of %#v", domain.CPU, tc.expectedCPU)
+			}
+			loader := ""
+			if domain.OS.Loader != nil {
+				loader = domain.OS.Loader.Path
+			}
+			if loader != tc.expectedLoader {
+				t.Errorf("bad loader %q instead of %q", loader, tc.expectedLoader)
+			}
+		})
+	}
+}
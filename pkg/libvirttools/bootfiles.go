@@ -0,0 +1,144 @@
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package libvirttools
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/golang/glog"
+
+	"github.com/Mirantis/virtlet/pkg/metadata/types"
+)
+
+const (
+	bootFilenameTemplate = "boot-*"
+	kernelFileRole       = "kernel"
+	initrdFileRole       = "initrd"
+)
+
+// bootFilePath returns the path to the kernel or initrd file that's
+// stored in the config directory because it comes from a ConfigMap
+// or a Secret.
+func bootFilePath(dir, domainUUID, role string) string {
+	return filepath.Join(dir, fmt.Sprintf("boot-%s-%s", domainUUID, role))
+}
+
+// setupBootFiles returns the paths to the kernel and initrd files to be
+// used for direct kernel boot, writing the files that come from
+// ConfigMaps and Secrets to the config directory. It returns empty
+// paths if direct kernel boot isn't requested for the VM.
+func (v *VirtualizationTool) setupBootFiles(config *types.VMConfig) (string, string, error) {
+	if config.ParsedAnnotations == nil || config.ParsedAnnotations.Kernel == nil {
+		return "", "", nil
+	}
+	kernelPath, err := v.setupBootFile(config, config.ParsedAnnotations.Kernel, config.KernelImageRef, kernelFileRole)
+	if err != nil {
+		return "", "", err
+	}
+	var initrdPath string
+	if config.ParsedAnnotations.Initrd != nil {
+		initrdPath, err = v.setupBootFile(config, config.ParsedAnnotations.Initrd, config.InitrdImageRef, initrdFileRole)
+		if err != nil {
+			v.removeBootFiles(config.DomainUUID)
+			return "", "", err
+		}
+	}
+	return kernelPath, initrdPath, nil
+}
+
+func (v *VirtualizationTool) setupBootFile(config *types.VMConfig, src *types.BootFileSource, imageRef, role string) (string, error) {
+	switch src.Kind {
+	case types.BootFileSourceImage:
+		if imageRef == "" {
+			return "", fmt.Errorf("%s image %q is not pulled", role, src.Name)
+		}
+		path, _, _, err := v.ImageManager().GetImagePathDigestAndVirtualSize(imageRef)
+		if err != nil {
+			return "", fmt.Errorf("error getting the path of %s image %q: %v", role, imageRef, err)
+		}
+		return path, nil
+	case types.BootFileSourceFile:
+		return mountedFilePath(config, src.Name)
+	case types.BootFileSourceConfigMap, types.BootFileSourceSecret:
+		loader := types.GetExternalDataLoader()
+		if loader == nil {
+			return "", errors.New("no external data loader set")
+		}
+		data, err := loader.LoadBootFile(config.PodNamespace, src)
+		if err != nil {
+			return "", fmt.Errorf("error loading %s from %s %q: %v", role, src.Kind, src.Name, err)
+		}
+		if err := os.MkdirAll(configIsoDir, 0777); err != nil {
+			return "", fmt.Errorf("error making config directory %q: %v", configIsoDir, err)
+		}
+		path := bootFilePath(configIsoDir, config.DomainUUID, role)
+		if err := ioutil.WriteFile(path, data, 0644); err != nil {
+			return "", fmt.Errorf("error writing %s file %q: %v", role, path, err)
+		}
+		return path, nil
+	default:
+		return "", fmt.Errorf("bad %s source kind %q", role, src.Kind)
+	}
+}
+
+// mountedFilePath returns the host path for the specified path
+// inside the container which must reside on one of container mounts.
+func mountedFilePath(config *types.VMConfig, containerPath string) (string, error) {
+	if !filepath.IsAbs(containerPath) {
+		return "", fmt.Errorf("%q is not an absolute path", containerPath)
+	}
+	containerPath = filepath.Clean(containerPath)
+	var mount *types.VMMount
+	for n, m := range config.Mounts {
+		mountPath := filepath.Clean(m.ContainerPath)
+		if isSubpath(containerPath, mountPath) &&
+			(mount == nil || len(mountPath) > len(filepath.Clean(mount.ContainerPath))) {
+			mount = &config.Mounts[n]
+		}
+	}
+	if mount == nil {
+		return "", fmt.Errorf("%q is not on any of the volumes mounted into the container", containerPath)
+	}
+	hostPath := filepath.Clean(mount.HostPath)
+	path := filepath.Join(hostPath, strings.TrimPrefix(containerPath, filepath.Clean(mount.ContainerPath)))
+	if !isSubpath(path, hostPath) {
+		return "", fmt.Errorf("%q points outside of the volume mounted at %q", containerPath, mount.ContainerPath)
+	}
+	return path, nil
+}
+
+// isSubpath returns true if the specified clean path is the same
+// as the clean base path or resides under it.
+func isSubpath(path, base string) bool {
+	return path == base || strings.HasPrefix(path, strings.TrimSuffix(base, "/")+"/")
+}
+
+// removeBootFiles removes the kernel and initrd files stored in the
+// config directory for the specified domain, if there are any.
+func (v *VirtualizationTool) removeBootFiles(domainUUID string) {
+	for _, role := range []string{kernelFileRole, initrdFileRole} {
+		path := bootFilePath(configIsoDir, domainUUID, role)
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			glog.Warningf("Cannot remove boot file %q: %v", path, err)
+		}
+	}
+}
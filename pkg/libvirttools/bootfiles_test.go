@@ -0,0 +1,147 @@
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package libvirttools
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakekube "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/Mirantis/virtlet/pkg/metadata/types"
+	testutils "github.com/Mirantis/virtlet/pkg/utils/testing"
+)
+
+func TestBootFiles(t *testing.T) {
+	fc := fakekube.NewSimpleClientset(&v1.ConfigMap{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "bootcfg",
+			Namespace: "testns",
+		},
+		BinaryData: map[string][]byte{
+			"initrd": []byte("initrd content"),
+		},
+	})
+	for _, tc := range []struct {
+		name           string
+		kernel         *types.BootFileSource
+		initrd         *types.BootFileSource
+		kernelImageRef string
+		expectedKernel string
+		expectedInitrd string
+		expectError    bool
+	}{
+		{
+			name: "no direct kernel boot",
+		},
+		{
+			name:           "kernel image and initrd from a configmap",
+			kernel:         &types.BootFileSource{Kind: types.BootFileSourceImage, Name: "fake/image1"},
+			initrd:         &types.BootFileSource{Kind: types.BootFileSourceConfigMap, Name: "bootcfg", Key: "initrd"},
+			kernelImageRef: "fake/image1",
+			expectedKernel: "/fake/volume/path",
+			expectedInitrd: "__config__/boot-" + testUUID + "-initrd",
+		},
+		{
+			name:           "kernel from a volume",
+			kernel:         &types.BootFileSource{Kind: types.BootFileSourceFile, Name: "/data/boot/vmlinuz"},
+			expectedKernel: "/var/lib/kubelet/pods/foo/volumes/data/boot/vmlinuz",
+		},
+		{
+			name:           "kernel path with dot-dot elements",
+			kernel:         &types.BootFileSource{Kind: types.BootFileSourceFile, Name: "/data/other/../boot/./vmlinuz"},
+			expectedKernel: "/var/lib/kubelet/pods/foo/volumes/data/boot/vmlinuz",
+		},
+		{
+			name:        "kernel outside of the volumes via dot-dot elements",
+			kernel:      &types.BootFileSource{Kind: types.BootFileSourceFile, Name: "/data/../../etc/shadow"},
+			expectError: true,
+		},
+		{
+			name:        "kernel outside of the volumes",
+			kernel:      &types.BootFileSource{Kind: types.BootFileSourceFile, Name: "/boot/vmlinuz"},
+			expectError: true,
+		},
+		{
+			name:        "kernel image not pulled",
+			kernel:      &types.BootFileSource{Kind: types.BootFileSourceImage, Name: "fake/image1"},
+			expectError: true,
+		},
+		{
+			name:           "missing configmap key",
+			kernel:         &types.BootFileSource{Kind: types.BootFileSourceImage, Name: "fake/image1"},
+			initrd:         &types.BootFileSource{Kind: types.BootFileSourceConfigMap, Name: "bootcfg", Key: "kernel"},
+			kernelImageRef: "fake/image1",
+			expectError:    true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ct := newContainerTester(t, testutils.NewToplevelRecorder(), nil, nil)
+			defer ct.teardown()
+			withExternalDataLoader(&defaultExternalDataLoader{kubeClient: fc}, func() {
+				config := &types.VMConfig{
+					DomainUUID:   testUUID,
+					PodNamespace: "testns",
+					Mounts: []types.VMMount{
+						{ContainerPath: "/data", HostPath: "/var/lib/kubelet/pods/foo/volumes/data"},
+						{ContainerPath: "/data/other", HostPath: "/var/lib/kubelet/pods/foo/volumes/other"},
+					},
+					ParsedAnnotations: &types.VirtletAnnotations{
+						Kernel: tc.kernel,
+						Initrd: tc.initrd,
+					},
+					KernelImageRef: tc.kernelImageRef,
+				}
+				kernelPath, initrdPath, err := ct.virtTool.setupBootFiles(config)
+				if tc.expectError {
+					if err == nil {
+						t.Errorf("setupBootFiles didn't return an error")
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("setupBootFiles: %v", err)
+				}
+				if kernelPath != tc.expectedKernel {
+					t.Errorf("bad kernel path %q instead of %q", kernelPath, tc.expectedKernel)
+				}
+				if tc.expectedInitrd != "" {
+					tc.expectedInitrd = filepath.Join(ct.tmpDir, tc.expectedInitrd)
+				}
+				if initrdPath != tc.expectedInitrd {
+					t.Errorf("bad initrd path %q instead of %q", initrdPath, tc.expectedInitrd)
+				}
+				if initrdPath == "" {
+					return
+				}
+				if data, err := ioutil.ReadFile(initrdPath); err != nil {
+					t.Errorf("can't read initrd file: %v", err)
+				} else if string(data) != "initrd content" {
+					t.Errorf("bad initrd file content %q", data)
+				}
+				ct.virtTool.removeBootFiles(testUUID)
+				if _, err := os.Stat(initrdPath); !os.IsNotExist(err) {
+					t.Errorf("initrd file was not removed")
+				}
+			})
+		})
+	}
+}
@@ -202,6 +202,9 @@ func (g *CloudInitGenerator) generateNetworkConfigurationNoCloud() ([]byte, erro
 		// where a dummy sandbox is used
 		return []byte("version: 1\n"), nil
 	}
+	if len(g.config.ParsedAnnotations.NICModels) != 0 {
+		return g.generateNetworkConfigurationNoCloudV2()
+	}
 	cniResult := g.config.ContainerSideNetwork.Result
 
 	var config []map[string]interface{}
@@ -242,16 +245,113 @@ func (g *CloudInitGenerator) generateNetworkConfigurationNoCloud() ([]byte, erro
 	return []byte("version: 1\n" + string(r)), nil
 }
 
+// generateNetworkConfigurationNoCloudV2 generates network config
+// version 2 which is used when the NIC models are set because,
+// unlike version 1, it makes it possible to match the interfaces
+// by the guest driver along with the mac address.
+func (g *CloudInitGenerator) generateNetworkConfigurationNoCloudV2() ([]byte, error) {
+	cniResult := g.config.ContainerSideNetwork.Result
+
+	ethernets := make(map[string]interface{})
+	for i, iface := range cniResult.Interfaces {
+		if iface.Sandbox == "" {
+			// skip host interfaces
+			continue
+		}
+		mtu, err := mtuForMacAddress(iface.Mac, g.config.ContainerSideNetwork.Interfaces)
+		if err != nil {
+			return nil, err
+		}
+		match := map[string]interface{}{
+			"macaddress": iface.Mac,
+		}
+		if driver := g.nicDriverForMacAddress(iface.Mac); driver != "" {
+			match["driver"] = driver
+		}
+		ethernetConf := map[string]interface{}{
+			"match":    match,
+			"set-name": iface.Name,
+			"mtu":      mtu,
+		}
+
+		var addresses []string
+		var routes []map[string]interface{}
+		for _, subnet := range g.getSubnetsForNthInterface(i, cniResult) {
+			if subnet["type"] == "dhcp" {
+				ethernetConf["dhcp4"] = true
+				continue
+			}
+			addresses = append(addresses, v2CIDR(subnet["address"], subnet["netmask"]))
+			subnetRoutes, _ := subnet["routes"].([]map[string]interface{})
+			for _, route := range subnetRoutes {
+				routes = append(routes, map[string]interface{}{
+					"to":  v2CIDR(route["network"], route["netmask"]),
+					"via": route["gateway"],
+				})
+			}
+		}
+		if addresses != nil {
+			ethernetConf["addresses"] = addresses
+		}
+		if routes != nil {
+			ethernetConf["routes"] = routes
+		}
+
+		// version 2 config only has per-interface nameservers
+		if cniResult.DNS.Nameservers != nil {
+			nameservers := map[string]interface{}{
+				"addresses": cniResult.DNS.Nameservers,
+			}
+			if cniResult.DNS.Search != nil {
+				nameservers["search"] = cniResult.DNS.Search
+			}
+			ethernetConf["nameservers"] = nameservers
+		}
+
+		ethernets[iface.Name] = ethernetConf
+	}
+
+	r, err := yaml.Marshal(map[string]interface{}{
+		"ethernets": ethernets,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return []byte("version: 2\n" + string(r)), nil
+}
+
+// v2CIDR converts an address or a network from version 1 network
+// config, which uses separate netmask for IPv4, to CIDR notation
+// used by version 2 config.
+func v2CIDR(address, netmask interface{}) string {
+	if netmask == nil {
+		// IPv6 addresses are already in CIDR notation
+		return address.(string)
+	}
+	ones, _ := net.IPMask(net.ParseIP(netmask.(string)).To4()).Size()
+	return fmt.Sprintf("%s/%d", address, ones)
+}
+
 func (g *CloudInitGenerator) getSubnetsForNthInterface(interfaceNo int, cniResult *cnicurrent.Result) []map[string]interface{} {
 	var subnets []map[string]interface{}
 	routes := append(cniResult.Routes[:0:0], cniResult.Routes...)
-	gotDefault := false
+	// there can be one default route per address family
+	gotDefault := make(map[bool]bool)
 	for _, ipConfig := range cniResult.IPs {
 		if ipConfig.Interface == interfaceNo {
-			subnet := map[string]interface{}{
-				"type":    "static",
-				"address": ipConfig.Address.IP.String(),
-				"netmask": net.IP(ipConfig.Address.Mask).String(),
+			isIPv6 := ipConfig.Address.IP.To4() == nil
+			var subnet map[string]interface{}
+			if isIPv6 {
+				subnet = map[string]interface{}{
+					"type":    "static6",
+					"address": ipConfig.Address.String(),
+				}
+			} else {
+				subnet = map[string]interface{}{
+					"type":    "static",
+					"address": ipConfig.Address.IP.String(),
+					"netmask": net.IP(ipConfig.Address.Mask).String(),
+				}
 			}
 
 			var subnetRoutes []map[string]interface{}
@@ -260,8 +360,11 @@ func (g *CloudInitGenerator) getSubnetsForNthInterface(interfaceNo int, cniResul
 			allRoutesLen := len(routes)
 			for i := range routes {
 				cniRoute := routes[allRoutesLen-1-i]
+				if (cniRoute.Dst.IP.To4() == nil) != isIPv6 {
+					continue
+				}
 				var gw net.IP
-				if cniRoute.GW != nil && ipConfig.Address.Contains(cniRoute.GW) {
+				if cniRoute.GW != nil && gatewayReachable(ipConfig.Address, cniRoute.GW) {
 					gw = cniRoute.GW
 				} else if cniRoute.GW == nil && !ipConfig.Gateway.IsUnspecified() {
 					gw = ipConfig.Gateway
@@ -269,16 +372,24 @@ func (g *CloudInitGenerator) getSubnetsForNthInterface(interfaceNo int, cniResul
 					continue
 				}
 				if ones, _ := cniRoute.Dst.Mask.Size(); ones == 0 {
-					if gotDefault {
+					if gotDefault[isIPv6] {
 						glog.Warning("cloud-init: got more than one default route, using only the first one")
 						continue
 					}
-					gotDefault = true
+					gotDefault[isIPv6] = true
 				}
-				route := map[string]interface{}{
-					"network": cniRoute.Dst.IP.String(),
-					"netmask": net.IP(cniRoute.Dst.Mask).String(),
-					"gateway": gw.String(),
+				var route map[string]interface{}
+				if isIPv6 {
+					route = map[string]interface{}{
+						"network": cniRoute.Dst.String(),
+						"gateway": gw.String(),
+					}
+				} else {
+					route = map[string]interface{}{
+						"network": cniRoute.Dst.IP.String(),
+						"netmask": net.IP(cniRoute.Dst.Mask).String(),
+						"gateway": gw.String(),
+					}
 				}
 				subnetRoutes = append(subnetRoutes, route)
 				routes = append(routes[:allRoutesLen-1-i], routes[allRoutesLen-i:]...)
@@ -387,7 +498,7 @@ func routesForIP(sourceIP net.IPNet, allRoutes []*cnitypes.Route) []map[string]i
 	// so we are returning there all routes with gateway accessible
 	// by particular source ip address.
 	for _, route := range allRoutes {
-		if sourceIP.Contains(route.GW) {
+		if gatewayReachable(sourceIP, route.GW) {
 			routes = append(routes, map[string]interface{}{
 				"network": route.Dst.IP.String(),
 				"netmask": net.IP(route.Dst.Mask).String(),
@@ -399,6 +510,17 @@ func routesForIP(sourceIP net.IPNet, allRoutes []*cnitypes.Route) []map[string]i
 	return routes
 }
 
+// gatewayReachable returns true if the gateway can be reached
+// directly from the specified address, that is, if it belongs to the
+// address' subnet or, for IPv6, if it's a link-local one, which is
+// common for IPv6 routers.
+func gatewayReachable(address net.IPNet, gw net.IP) bool {
+	if address.Contains(gw) {
+		return true
+	}
+	return address.IP.To4() == nil && gw.To4() == nil && gw.IsLinkLocalUnicast()
+}
+
 func mtuForMacAddress(mac string, ifaces []*network.InterfaceDescription) (uint16, error) {
 	for _, iface := range ifaces {
 		if iface.HardwareAddr.String() == strings.ToLower(mac) {
@@ -408,6 +530,30 @@ func mtuForMacAddress(mac string, ifaces []*network.InterfaceDescription) (uint1
 	return 0, fmt.Errorf("interface with mac address %q not found in ContainerSideNetwork", mac)
 }
 
+// nicDriverForMacAddress returns the name of the guest driver for the
+// NIC model of the tap interface with the specified mac address, so
+// cloud-init can match the interface by both mac address and driver.
+// The driver can only be specified in NoCloud network config
+// version 2, as neither version 1 nor Config Drive network data
+// support matching the interfaces by the driver.
+// It returns an empty string if NIC models aren't set explicitly.
+func (g *CloudInitGenerator) nicDriverForMacAddress(mac string) string {
+	if g.config.ParsedAnnotations == nil || len(g.config.ParsedAnnotations.NICModels) == 0 {
+		return ""
+	}
+	for i, iface := range g.config.ContainerSideNetwork.Interfaces {
+		if iface.HardwareAddr.String() != strings.ToLower(mac) {
+			continue
+		}
+		if !iface.Type.UsesTap() {
+			// SR-IOV VFs use the host NIC drivers
+			return ""
+		}
+		return network.NICModelForInterface(g.config.ParsedAnnotations.NICModels, i).GuestDriver()
+	}
+	return ""
+}
+
 // IsoPath returns a full path to iso image with configuration for VM pod.
 func (g *CloudInitGenerator) IsoPath() string {
 	return filepath.Join(g.isoDir, fmt.Sprintf("config-%s.iso", g.config.DomainUUID))
@@ -574,8 +720,9 @@ func (g *CloudInitGenerator) generateMounts(volumeMap diskPathMap) ([]interface{
 	var r []interface{}
 	var mountScriptLines []string
 	for _, m := range g.config.Mounts {
-		// Skip file based mounts (including secrets and config maps).
-		if isRegularFile(m.HostPath) ||
+		// Skip file based mounts (including secrets and config maps)
+		// and hugetlbfs mounts which are used to back the VM memory.
+		if isRegularFile(m.HostPath) || isHugetlbfs(m.HostPath) ||
 			strings.Contains(m.HostPath, "kubernetes.io~secret") ||
 			strings.Contains(m.HostPath, "kubernetes.io~configmap") {
 			continue
@@ -88,6 +88,79 @@ func buildNetworkedPodConfig(cniResult *cnicurrent.Result, imageTypeName string)
 	}
 }
 
+func dualStackCNIResult() *cnicurrent.Result {
+	return &cnicurrent.Result{
+		Interfaces: []*cnicurrent.Interface{
+			{
+				Name:    "cni0",
+				Mac:     "00:11:22:33:44:55",
+				Sandbox: "/var/run/netns/bae464f1-6ee7-4ee2-826e-33293a9de95e",
+			},
+		},
+		IPs: []*cnicurrent.IPConfig{
+			{
+				Version: "4",
+				Address: net.IPNet{
+					IP:   net.IPv4(1, 1, 1, 1),
+					Mask: net.CIDRMask(8, 32),
+				},
+				Gateway:   net.IPv4(1, 2, 3, 4),
+				Interface: 0,
+			},
+			{
+				Version: "6",
+				Address: net.IPNet{
+					IP:   net.ParseIP("fd00:1234::5"),
+					Mask: net.CIDRMask(64, 128),
+				},
+				Gateway:   net.ParseIP("fd00:1234::1"),
+				Interface: 0,
+			},
+		},
+		Routes: []*cnitypes.Route{
+			{
+				Dst: net.IPNet{
+					IP:   net.IPv4zero,
+					Mask: net.CIDRMask(0, 32),
+				},
+				GW: net.IPv4(1, 2, 3, 4),
+			},
+			{
+				Dst: net.IPNet{
+					IP:   net.IPv6zero,
+					Mask: net.CIDRMask(0, 128),
+				},
+				GW: net.ParseIP("fd00:1234::1"),
+			},
+			{
+				Dst: net.IPNet{
+					IP:   net.ParseIP("fd00:4321::"),
+					Mask: net.CIDRMask(48, 128),
+				},
+				GW: net.ParseIP("fd00:1234::2"),
+			},
+		},
+		DNS: cnitypes.DNS{
+			Nameservers: []string{"1.2.3.4", "fd00:96::10"},
+			Search:      []string{"some", "search"},
+		},
+	}
+}
+
+func withNICModels(config *types.VMConfig, models ...network.NICModel) *types.VMConfig {
+	config.ParsedAnnotations.NICModels = models
+	return config
+}
+
+// linkLocalGatewayCNIResult returns a dual-stack CNI result with
+// the IPv6 default route via a link-local router address
+func linkLocalGatewayCNIResult() *cnicurrent.Result {
+	result := dualStackCNIResult()
+	result.IPs[1].Gateway = net.ParseIP("fe80::1")
+	result.Routes[1].GW = net.ParseIP("fe80::1")
+	return result
+}
+
 func TestCloudInitGenerator(t *testing.T) {
 	tmpDir, err := ioutil.TempDir("", "fake-flexvol")
 	if err != nil {
@@ -497,6 +570,59 @@ func TestCloudInitGenerator(t *testing.T) {
 			}, "nocloud"),
 			verifyNetworkConfig: true,
 		},
+		{
+			name: "pod with nic model",
+			config: withNICModels(buildNetworkedPodConfig(&cnicurrent.Result{
+				Interfaces: []*cnicurrent.Interface{
+					{
+						Name:    "cni0",
+						Mac:     "00:11:22:33:44:55",
+						Sandbox: "/var/run/netns/bae464f1-6ee7-4ee2-826e-33293a9de95e",
+					},
+				},
+				IPs: []*cnicurrent.IPConfig{
+					{
+						Version: "4",
+						Address: net.IPNet{
+							IP:   net.IPv4(1, 1, 1, 1),
+							Mask: net.CIDRMask(8, 32),
+						},
+						Gateway:   net.IPv4(1, 2, 3, 4),
+						Interface: 0,
+					},
+				},
+			}, "nocloud"), network.NICModelE1000),
+			verifyNetworkConfig: true,
+		},
+		{
+			name:                "pod with nic model and dual-stack network",
+			config:              withNICModels(buildNetworkedPodConfig(dualStackCNIResult(), "nocloud"), network.NICModelE1000),
+			verifyNetworkConfig: true,
+		},
+		{
+			name: "pod with nic model - configdrive",
+			config: withNICModels(buildNetworkedPodConfig(&cnicurrent.Result{
+				Interfaces: []*cnicurrent.Interface{
+					{
+						Name:    "cni0",
+						Mac:     "00:11:22:33:44:55",
+						Sandbox: "/var/run/netns/bae464f1-6ee7-4ee2-826e-33293a9de95e",
+					},
+				},
+				IPs: []*cnicurrent.IPConfig{
+					{
+						Version: "4",
+						Address: net.IPNet{
+							IP:   net.IPv4(1, 1, 1, 1),
+							Mask: net.CIDRMask(8, 32),
+						},
+						Gateway:   net.IPv4(1, 2, 3, 4),
+						Interface: 0,
+					},
+				},
+			}, "configdrive"), network.NICModelE1000),
+			verifyNetworkConfig: true,
+		},
 		{
 			name: "pod with multiple network interfaces",
 			config: buildNetworkedPodConfig(&cnicurrent.Result{
@@ -663,6 +789,26 @@ func TestCloudInitGenerator(t *testing.T) {
 			}, "configdrive"),
 			verifyNetworkConfig: true,
 		},
+		{
+			name:                "pod with dual-stack network config",
+			config:              buildNetworkedPodConfig(dualStackCNIResult(), "nocloud"),
+			verifyNetworkConfig: true,
+		},
+		{
+			name:                "pod with dual-stack network config - configdrive",
+			config:              buildNetworkedPodConfig(dualStackCNIResult(), "configdrive"),
+			verifyNetworkConfig: true,
+		},
+		{
+			name:                "pod with link-local IPv6 gateway",
+			config:              buildNetworkedPodConfig(linkLocalGatewayCNIResult(), "nocloud"),
+			verifyNetworkConfig: true,
+		},
+		{
+			name:                "pod with link-local IPv6 gateway - configdrive",
+			config:              buildNetworkedPodConfig(linkLocalGatewayCNIResult(), "configdrive"),
+			verifyNetworkConfig: true,
+		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
 			// we're not invoking actual iso generation here so "/foobar"
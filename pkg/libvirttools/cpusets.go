@@ -20,8 +20,11 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"strconv"
 
 	libvirtxml "github.com/libvirt/libvirt-go-xml"
+	"k8s.io/kubernetes/pkg/kubelet/cm/cpuset"
 
 	vconfig "github.com/Mirantis/virtlet/pkg/config"
 	"github.com/Mirantis/virtlet/pkg/utils/cgroups"
@@ -30,10 +33,13 @@ import (
 const (
 	procfsLocation      = "/proc"
 	emulatorProcessName = "qemu-system-x86_64"
+	sysfsCPUDir         = "/sys/devices/system/cpu"
 )
 
 // UpdateCpusetsInContainerDefinition updates libvirt domain definition for the VM
 // setting the environment variable which is used by vmwrapper to pin to the specified cpuset
+// and aligning VM NUMA settings with the host NUMA nodes of the cpuset. If the cpuset is
+// exclusive to the container, vCPUs, emulator threads and iothreads are pinned to separate CPUs
 func (v *VirtualizationTool) UpdateCpusetsInContainerDefinition(containerID, cpusets string) error {
 	domain, err := v.domainConn.LookupDomainByUUIDString(containerID)
 	if err != nil {
@@ -47,9 +53,9 @@ func (v *VirtualizationTool) UpdateCpusetsInContainerDefinition(containerID, cpu
 
 	found := false
 	envvars := domainxml.QEMUCommandline.Envs
-	for _, envvar := range envvars {
+	for n, envvar := range envvars {
 		if envvar.Name == vconfig.CpusetsEnvVarName {
-			envvar.Value = cpusets
+			envvars[n].Value = cpusets
 			found = true
 		}
 	}
@@ -60,6 +66,14 @@ func (v *VirtualizationTool) UpdateCpusetsInContainerDefinition(containerID, cpu
 		})
 	}
 
+	if err := v.alignNUMATopology(domainxml, cpusets); err != nil {
+		return err
+	}
+
+	if err := v.pinCPUs(domainxml, containerID, cpusets); err != nil {
+		return err
+	}
+
 	if err := domain.Undefine(); err != nil {
 		return err
 	}
@@ -117,3 +131,109 @@ func (v *VirtualizationTool) getEmulatorPidFileLocation(containerID string) (str
 		containerID[:13], container.Config.PodName,
 	), nil
 }
+
+// isExclusiveCPUSet returns true if the cpuset is assigned by the
+// static policy of kubelet CPU manager. The static policy gives
+// exclusive CPUs to the containers of Guaranteed pods that have
+// integer CPU limits, and the number of the CPUs is equal to the
+// limit.
+func (v *VirtualizationTool) isExclusiveCPUSet(containerID string, cpus cpuset.CPUSet) (bool, error) {
+	container, err := v.metadataStore.Container(containerID).Retrieve()
+	if err != nil {
+		return false, err
+	}
+	if container == nil || container.Config.CPUPeriod <= 0 || container.Config.CPUQuota <= 0 {
+		return false, nil
+	}
+	period, quota := container.Config.CPUPeriod, container.Config.CPUQuota
+	return quota%period == 0 && int(quota/period) == cpus.Size(), nil
+}
+
+// orderCPUsByCores returns the CPUs ordered in such way that hyperthread
+// siblings go one after another, so they match the threads of guest
+// vCPU cores.
+func (v *VirtualizationTool) orderCPUsByCores(cpus cpuset.CPUSet) ([]int, error) {
+	var r []int
+	seen := make(map[int]bool)
+	for _, cpu := range cpus.ToSlice() {
+		if seen[cpu] {
+			continue
+		}
+		siblings, err := v.readSysfsCPUSet(filepath.Join(sysfsCPUDir, fmt.Sprintf("cpu%d", cpu), "topology", "thread_siblings_list"))
+		if err != nil {
+			if _, ok := err.(*os.PathError); !ok {
+				return nil, err
+			}
+			siblings = cpuset.NewCPUSet(cpu)
+		}
+		for _, sibling := range siblings.Intersection(cpus).ToSlice() {
+			if !seen[sibling] {
+				seen[sibling] = true
+				r = append(r, sibling)
+			}
+		}
+	}
+	return r, nil
+}
+
+// setCPUPinning pins each vCPU of the domain to its own CPU from the
+// list, and the emulator threads and iothreads to the rest of CPUs.
+// If there are no CPUs left for them, they share the CPUs with the
+// vCPUs. If there are not enough CPUs for each vCPU, or the list is
+// empty, the pinning is removed.
+func setCPUPinning(domain *libvirtxml.Domain, cpus []int) {
+	if domain.CPUTune == nil {
+		domain.CPUTune = &libvirtxml.DomainCPUTune{}
+	}
+	domain.CPUTune.VCPUPin = nil
+	domain.CPUTune.EmulatorPin = nil
+	domain.CPUTune.IOThreadPin = nil
+
+	vcpuNum := domain.VCPU.Value
+	if len(cpus) == 0 || len(cpus) < vcpuNum {
+		return
+	}
+
+	for n := 0; n < vcpuNum; n++ {
+		domain.CPUTune.VCPUPin = append(domain.CPUTune.VCPUPin, libvirtxml.DomainCPUTuneVCPUPin{
+			VCPU:   uint(n),
+			CPUSet: strconv.Itoa(cpus[n]),
+		})
+	}
+
+	otherCPUs := cpus[vcpuNum:]
+	if len(otherCPUs) == 0 {
+		otherCPUs = cpus
+	}
+	otherCPUSet := cpuset.NewCPUSet(otherCPUs...).String()
+	domain.CPUTune.EmulatorPin = &libvirtxml.DomainCPUTuneEmulatorPin{CPUSet: otherCPUSet}
+	for n := uint(1); n <= domain.IOThreads; n++ {
+		domain.CPUTune.IOThreadPin = append(domain.CPUTune.IOThreadPin, libvirtxml.DomainCPUTuneIOThreadPin{
+			IOThread: n,
+			CPUSet:   otherCPUSet,
+		})
+	}
+}
+
+// pinCPUs sets up the pinning of vCPUs, emulator threads and iothreads
+// of the domain if the cpuset is exclusive to the container.
+func (v *VirtualizationTool) pinCPUs(domain *libvirtxml.Domain, containerID, cpusets string) error {
+	var cpus []int
+	if cpusets != "" {
+		cpuSet, err := cpuset.Parse(cpusets)
+		if err != nil {
+			return fmt.Errorf("bad cpuset %q: %v", cpusets, err)
+		}
+		exclusive, err := v.isExclusiveCPUSet(containerID, cpuSet)
+		if err != nil {
+			return fmt.Errorf("error checking whether the cpuset is exclusive: %v", err)
+		}
+		if exclusive {
+			if cpus, err = v.orderCPUsByCores(cpuSet); err != nil {
+				return fmt.Errorf("error getting CPU topology: %v", err)
+			}
+		}
+	}
+	setCPUPinning(domain, cpus)
+	return nil
+}
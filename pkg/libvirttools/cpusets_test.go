@@ -18,9 +18,14 @@ package libvirttools
 
 import (
 	"fmt"
+	"reflect"
 	"testing"
 
+	libvirtxml "github.com/libvirt/libvirt-go-xml"
+	"k8s.io/kubernetes/pkg/kubelet/cm/cpuset"
+
 	fakemeta "github.com/Mirantis/virtlet/pkg/metadata/fake"
+	"github.com/Mirantis/virtlet/pkg/utils"
 	testutils "github.com/Mirantis/virtlet/pkg/utils/testing"
 	"github.com/Mirantis/virtlet/tests/gm"
 )
@@ -34,7 +39,7 @@ func TestUpdateCpusets(t *testing.T) {
 
 	sandbox := fakemeta.GetSandboxes(1)[0]
 	ct.setPodSandbox(sandbox)
-	containerID := ct.createContainer(sandbox, nil, nil)
+	containerID := ct.createContainer(sandbox, nil, nil, nil)
 	pidFilePath := fmt.Sprintf("/run/libvirt/qemu/virtlet-%s-%s.pid", containerID[:13], sandbox.Name)
 	files[pidFilePath] = "4242"
 
@@ -48,3 +53,97 @@ func TestUpdateCpusets(t *testing.T) {
 	ct.removeContainer(containerID)
 	gm.Verify(t, gm.NewYamlVerifier(ct.rec.Content()))
 }
+
+func TestCPUPinning(t *testing.T) {
+	files := map[string]string{
+		"/sys/devices/system/cpu/cpu2/topology/thread_siblings_list":  "2,10\n",
+		"/sys/devices/system/cpu/cpu3/topology/thread_siblings_list":  "3,11\n",
+		"/sys/devices/system/cpu/cpu10/topology/thread_siblings_list": "2,10\n",
+		"/sys/devices/system/cpu/cpu11/topology/thread_siblings_list": "3,11\n",
+	}
+	for _, tc := range []struct {
+		name            string
+		cpus            string
+		vcpuNum         int
+		ioThreads       uint
+		expectedCPUTune *libvirtxml.DomainCPUTune
+	}{
+		{
+			name:            "no cpus",
+			vcpuNum:         2,
+			expectedCPUTune: &libvirtxml.DomainCPUTune{},
+		},
+		{
+			name:            "not enough cpus",
+			cpus:            "2",
+			vcpuNum:         2,
+			expectedCPUTune: &libvirtxml.DomainCPUTune{},
+		},
+		{
+			name:    "cpus for vcpus only",
+			cpus:    "2-3,10-11",
+			vcpuNum: 4,
+			expectedCPUTune: &libvirtxml.DomainCPUTune{
+				VCPUPin: []libvirtxml.DomainCPUTuneVCPUPin{
+					{VCPU: 0, CPUSet: "2"},
+					{VCPU: 1, CPUSet: "10"},
+					{VCPU: 2, CPUSet: "3"},
+					{VCPU: 3, CPUSet: "11"},
+				},
+				EmulatorPin: &libvirtxml.DomainCPUTuneEmulatorPin{CPUSet: "2-3,10-11"},
+			},
+		},
+		{
+			name:      "separate cpus for emulator threads and iothreads",
+			cpus:      "2-3,10-11",
+			vcpuNum:   2,
+			ioThreads: 1,
+			expectedCPUTune: &libvirtxml.DomainCPUTune{
+				VCPUPin: []libvirtxml.DomainCPUTuneVCPUPin{
+					{VCPU: 0, CPUSet: "2"},
+					{VCPU: 1, CPUSet: "10"},
+				},
+				EmulatorPin: &libvirtxml.DomainCPUTuneEmulatorPin{CPUSet: "3,11"},
+				IOThreadPin: []libvirtxml.DomainCPUTuneIOThreadPin{
+					{IOThread: 1, CPUSet: "3,11"},
+				},
+			},
+		},
+		{
+			name:    "no cpu topology info",
+			cpus:    "4-5",
+			vcpuNum: 2,
+			expectedCPUTune: &libvirtxml.DomainCPUTune{
+				VCPUPin: []libvirtxml.DomainCPUTuneVCPUPin{
+					{VCPU: 0, CPUSet: "4"},
+					{VCPU: 1, CPUSet: "5"},
+				},
+				EmulatorPin: &libvirtxml.DomainCPUTuneEmulatorPin{CPUSet: "4-5"},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ct := newContainerTester(t, testutils.NewToplevelRecorder(), nil, files)
+			defer ct.teardown()
+			var cpus []int
+			if tc.cpus != "" {
+				cpuSet, err := cpuset.Parse(tc.cpus)
+				if err != nil {
+					t.Fatalf("cpuset.Parse(): %v", err)
+				}
+				if cpus, err = ct.virtTool.orderCPUsByCores(cpuSet); err != nil {
+					t.Fatalf("orderCPUsByCores: %v", err)
+				}
+			}
+			domain := &libvirtxml.Domain{
+				VCPU:      &libvirtxml.DomainVCPU{Value: tc.vcpuNum},
+				IOThreads: tc.ioThreads,
+			}
+			setCPUPinning(domain, cpus)
+			if !reflect.DeepEqual(domain.CPUTune, tc.expectedCPUTune) {
+				t.Errorf("bad cputune:\n%s\ninstead of\n%s",
+					utils.ToJSON(domain.CPUTune), utils.ToJSON(tc.expectedCPUTune))
+			}
+		})
+	}
+}
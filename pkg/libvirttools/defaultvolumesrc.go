@@ -17,14 +17,17 @@ limitations under the License.
 package libvirttools
 
 // GetDefaultVolumeSource returns a volume source that supports
-// root volume, flexvolumes and a ConfigSource for cloud-init
+// root volume, image disks, flexvolumes and a ConfigSource for cloud-init
 func GetDefaultVolumeSource() VMVolumeSource {
 	return CombineVMVolumeSources(
 		GetRootVolume,
+		GetImageDisks,
 		GetBlockVolumes,
 		ScanFlexVolumes,
 		GetFileSystemVolumes,
-		// XXX: GetConfigVolume must go last because it
-		// doesn't produce correct name for cdrom devices
+		// XXX: GetInstallerISOVolume and GetConfigVolume must
+		// go last because they don't produce correct names for
+		// cdrom devices
+		GetInstallerISOVolume,
 		GetConfigVolume)
 }
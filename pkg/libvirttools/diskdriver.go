@@ -41,7 +41,7 @@ type diskDriver interface {
 	address() *libvirtxml.DomainAddress
 }
 
-type diskDriverFactory func(n int) (diskDriver, error)
+type diskDriverFactory func(n int, machineType types.MachineType) (diskDriver, error)
 
 var diskDriverMap = map[types.DiskDriverName]diskDriverFactory{
 	types.DiskDriverVirtio: virtioBlkDriverFactory,
@@ -51,14 +51,15 @@ var diskDriverMap = map[types.DiskDriverName]diskDriverFactory{
 type virtioBlkDriver struct {
 	n        int
 	diskChar int
+	pcie     bool
 }
 
-func virtioBlkDriverFactory(n int) (diskDriver, error) {
+func virtioBlkDriverFactory(n int, machineType types.MachineType) (diskDriver, error) {
 	diskChar := minBlockDevChar + n
 	if diskChar > maxVirtioBlockDevChar {
 		return nil, errors.New("too many virtio block devices")
 	}
-	return &virtioBlkDriver{n, diskChar}, nil
+	return &virtioBlkDriver{n, diskChar, machineType.IsPCIe()}, nil
 }
 
 func (d *virtioBlkDriver) diskPath(domainDef *libvirtxml.Domain) (*diskPath, error) {
@@ -91,6 +92,12 @@ func (d *virtioBlkDriver) address() *libvirtxml.DomainAddress {
 	// use bus1 to have more predictable addressing for virtio devs
 	bus := uint(1)
 	slot := uint(d.n + 1)
+	if d.pcie {
+		// PCIe root ports have just one slot, so each
+		// virtio disk gets its own port
+		bus = pcieRootPortForDisk(d.n)
+		slot = 0
+	}
 	function := uint(0)
 	return &libvirtxml.DomainAddress{
 		PCI: &libvirtxml.DomainAddressPCI{
@@ -107,7 +114,7 @@ type scsiDriver struct {
 	diskChar int
 }
 
-func scsiDriverFactory(n int) (diskDriver, error) {
+func scsiDriverFactory(n int, machineType types.MachineType) (diskDriver, error) {
 	diskChar := minBlockDevChar + n
 	if diskChar > maxScsiBlockDevChar {
 		return nil, errors.New("too many scsi block devices")
@@ -225,6 +232,21 @@ func findControllers(domainDef *libvirtxml.Domain, controllerType string) []libv
 	return r
 }
 
+func findPCIController(pciControllers []libvirtxml.DomainController, bus uint) (*libvirtxml.DomainController, error) {
+	for n, c := range pciControllers {
+		// pci-root / pcie-root controller may have no index
+		// specified, in which case it's 0
+		index := uint(0)
+		if c.Index != nil {
+			index = *c.Index
+		}
+		if index == bus {
+			return &pciControllers[n], nil
+		}
+	}
+	return nil, fmt.Errorf("PCI controller not found for bus %d", bus)
+}
+
 func pciPath(domainDef *libvirtxml.Domain, address *libvirtxml.DomainAddress) (string, string, error) {
 	pciControllers := findControllers(domainDef, "pci")
 	devPath := "/dev/disk/by-path/"
@@ -237,10 +259,10 @@ func pciPath(domainDef *libvirtxml.Domain, address *libvirtxml.DomainAddress) (s
 		if address == nil || address.PCI == nil || address.PCI.Domain == nil || address.PCI.Bus == nil || address.PCI.Slot == nil || address.PCI.Function == nil {
 			return fmt.Errorf("can't make path for device address %#v", address)
 		}
-		if *address.PCI.Bus > uint(len(pciControllers)) {
-			return fmt.Errorf("bad PCI bus number: %#v", address)
+		ctl, err := findPCIController(pciControllers, *address.PCI.Bus)
+		if err != nil {
+			return fmt.Errorf("bad PCI bus number: %#v: %v", address, err)
 		}
-		ctl := pciControllers[*address.PCI.Bus]
 		if ctl.Address != nil && ctl.Address.PCI != nil {
 			if err := recurse(ctl.Address, "pci", depth+1); err != nil {
 				return err
@@ -17,6 +17,7 @@ limitations under the License.
 package libvirttools
 
 import (
+	"reflect"
 	"testing"
 
 	libvirtxml "github.com/libvirt/libvirt-go-xml"
@@ -26,11 +27,12 @@ import (
 
 func TestDiskPath(t *testing.T) {
 	for _, tc := range []struct {
-		name       string
-		driverName types.DiskDriverName
-		diskCount  int
-		devList    libvirtxml.DomainDeviceList
-		diskPaths  []diskPath
+		name        string
+		driverName  types.DiskDriverName
+		machineType types.MachineType
+		diskCount   int
+		devList     libvirtxml.DomainDeviceList
+		diskPaths   []diskPath
 	}{
 		{
 			name:       "scsi driver",
@@ -155,6 +157,131 @@ func TestDiskPath(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:        "virtio driver with q35 machine type",
+			driverName:  types.DiskDriverVirtio,
+			machineType: types.MachineTypeQ35,
+			diskCount:   3,
+			devList: libvirtxml.DomainDeviceList{
+				Disks: []libvirtxml.DomainDisk{
+					{
+						Device: "disk",
+						Target: &libvirtxml.DomainDiskTarget{
+							Dev: "vda",
+							Bus: "virtio",
+						},
+						Address: pciAddress(0, 1, 0, 0),
+					},
+					{
+						Device: "disk",
+						Target: &libvirtxml.DomainDiskTarget{
+							Dev: "vdb",
+							Bus: "virtio",
+						},
+						Address: pciAddress(0, 2, 0, 0),
+					},
+					{
+						Device: "cdrom",
+						Target: &libvirtxml.DomainDiskTarget{
+							Dev: "vdc",
+							Bus: "virtio",
+						},
+						Address:  pciAddress(0, 3, 0, 0),
+						ReadOnly: &libvirtxml.DomainDiskReadOnly{},
+					},
+				},
+				// the order of the controllers doesn't match
+				// their indices here
+				Controllers: []libvirtxml.DomainController{
+					{
+						Type:    "pci",
+						Index:   puint(3),
+						Model:   "pcie-root-port",
+						Address: pciAddress(0, 0, 2, 2),
+					},
+					{
+						Type:  "pci",
+						Index: puint(0),
+						Model: "pcie-root",
+					},
+					{
+						Type:    "pci",
+						Index:   puint(1),
+						Model:   "pcie-root-port",
+						Address: pciAddress(0, 0, 2, 0),
+					},
+					{
+						Type:    "pci",
+						Index:   puint(2),
+						Model:   "pcie-root-port",
+						Address: pciAddress(0, 0, 2, 1),
+					},
+				},
+			},
+			diskPaths: []diskPath{
+				{
+					"/dev/disk/by-path/pci-0000:00:02.0-virtio-pci-0000:01:00.0",
+					"/sys/devices/pci0000:00/0000:00:02.0/0000:01:00.0/virtio*/block/",
+				},
+				{
+					"/dev/disk/by-path/pci-0000:00:02.1-virtio-pci-0000:02:00.0",
+					"/sys/devices/pci0000:00/0000:00:02.1/0000:02:00.0/virtio*/block/",
+				},
+				{
+					"/dev/disk/by-path/pci-0000:00:02.2-virtio-pci-0000:03:00.0",
+					"/sys/devices/pci0000:00/0000:00:02.2/0000:03:00.0/virtio*/block/",
+				},
+			},
+		},
+		{
+			name:        "scsi driver with q35 machine type",
+			driverName:  types.DiskDriverScsi,
+			machineType: types.MachineTypeQ35,
+			diskCount:   1,
+			devList: libvirtxml.DomainDeviceList{
+				Disks: []libvirtxml.DomainDisk{
+					{
+						Device: "disk",
+						Target: &libvirtxml.DomainDiskTarget{
+							Dev: "sda",
+							Bus: "scsi",
+						},
+						Address: scsiAddress(0, 0, 0, 0),
+					},
+				},
+				Controllers: []libvirtxml.DomainController{
+					{
+						Type:  "pci",
+						Index: puint(0),
+						Model: "pcie-root",
+					},
+					{
+						Type:    "pci",
+						Index:   puint(1),
+						Model:   "pcie-root-port",
+						Address: pciAddress(0, 0, 2, 0),
+					},
+					{
+						Type:    "pci",
+						Index:   puint(5),
+						Model:   "pcie-root-port",
+						Address: pciAddress(0, 0, 2, 4),
+					},
+					{
+						Type:    "scsi",
+						Index:   puint(0),
+						Model:   "virtio-scsi",
+						Address: pciAddress(0, 5, 0, 0),
+					},
+				},
+			},
+			diskPaths: []diskPath{
+				{
+					"/dev/disk/by-path/pci-0000:00:02.4-virtio-pci-0000:05:00.0-scsi-0:0:0:0",
+					"/sys/devices/pci0000:00/0000:00:02.4/0000:05:00.0/virtio*/host*/target*:0:0/*:0:0:0/block/",
+				},
+			},
+		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
 			factory, err := getDiskDriverFactory(tc.driverName)
@@ -163,11 +290,14 @@ func TestDiskPath(t *testing.T) {
 			}
 			domain := &libvirtxml.Domain{Devices: &tc.devList}
 			for n := 0; n < tc.diskCount; n++ {
-				driver, err := factory(n)
+				driver, err := factory(n, tc.machineType)
 				if err != nil {
 					t.Errorf("error making driver #%d: %v", n, err)
 					continue
 				}
+				if address := driver.address(); !reflect.DeepEqual(address, tc.devList.Disks[n].Address) {
+					t.Errorf("bad address #%d: expected %#v, got %#v", n, tc.devList.Disks[n].Address, address)
+				}
 				diskPath, err := driver.diskPath(domain)
 				if err != nil {
 					t.Errorf("diskPath() #%d: %v", n, err)
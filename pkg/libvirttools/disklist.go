@@ -81,7 +81,7 @@ func newDiskList(config *types.VMConfig, source VMVolumeSource, owner volumeOwne
 	for _, volume := range vmVols {
 		var driver diskDriver
 		if volume.IsDisk() {
-			driver, err = diskDriverFactory(n)
+			driver, err = diskDriverFactory(n, config.ParsedAnnotations.MachineType)
 			if err != nil {
 				return nil, err
 			}
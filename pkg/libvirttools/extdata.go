@@ -82,6 +82,37 @@ func (l *defaultExternalDataLoader) LoadFileMap(namespace, dsSpec string) (map[s
 	return parseDataAsFileMap(data)
 }
 
+// LoadBootFile implements LoadBootFile method of ExternalDataLoader interface.
+func (l *defaultExternalDataLoader) LoadBootFile(namespace string, src *types.BootFileSource) ([]byte, error) {
+	if err := l.ensureKubeClient(); err != nil {
+		return nil, err
+	}
+	switch src.Kind {
+	case types.BootFileSourceSecret:
+		secret, err := l.kubeClient.CoreV1().Secrets(namespace).Get(src.Name, meta_v1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		if data, found := secret.Data[src.Key]; found {
+			return data, nil
+		}
+	case types.BootFileSourceConfigMap:
+		configmap, err := l.kubeClient.CoreV1().ConfigMaps(namespace).Get(src.Name, meta_v1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		if data, found := configmap.BinaryData[src.Key]; found {
+			return data, nil
+		}
+		if data, found := configmap.Data[src.Key]; found {
+			return []byte(data), nil
+		}
+	default:
+		return nil, fmt.Errorf("unsupported boot file source kind %s. Must be one of (secret, configmap)", src.Kind)
+	}
+	return nil, fmt.Errorf("key %q not found in %s %q", src.Key, src.Kind, src.Name)
+}
+
 func (l *defaultExternalDataLoader) loadUserDataFromDataSource(va *types.VirtletAnnotations, namespace, key string) error {
 	parts := strings.Split(key, "/")
 	if len(parts) != 2 {
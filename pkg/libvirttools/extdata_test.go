@@ -230,3 +230,70 @@ func TestLoadExternalUserData(t *testing.T) {
 		})
 	}
 }
+
+func TestLoadBootFile(t *testing.T) {
+	fc := fakekube.NewSimpleClientset(
+		&v1.ConfigMap{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name:      "bootcfg",
+				Namespace: "testns",
+			},
+			Data: map[string]string{
+				"cmdline": "console=ttyS0",
+			},
+			BinaryData: map[string][]byte{
+				"initrd": []byte("initrd content"),
+			},
+		},
+		&v1.Secret{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name:      "bootsecret",
+				Namespace: "testns",
+			},
+			Data: map[string][]byte{
+				"kernel": []byte("kernel content"),
+			},
+		})
+	loader := &defaultExternalDataLoader{kubeClient: fc}
+	for _, tc := range []struct {
+		name         string
+		src          types.BootFileSource
+		expectedData string
+	}{
+		{
+			name:         "configmap binary data",
+			src:          types.BootFileSource{Kind: types.BootFileSourceConfigMap, Name: "bootcfg", Key: "initrd"},
+			expectedData: "initrd content",
+		},
+		{
+			name:         "configmap data",
+			src:          types.BootFileSource{Kind: types.BootFileSourceConfigMap, Name: "bootcfg", Key: "cmdline"},
+			expectedData: "console=ttyS0",
+		},
+		{
+			name:         "secret",
+			src:          types.BootFileSource{Kind: types.BootFileSourceSecret, Name: "bootsecret", Key: "kernel"},
+			expectedData: "kernel content",
+		},
+		{
+			name: "missing key",
+			src:  types.BootFileSource{Kind: types.BootFileSourceSecret, Name: "bootsecret", Key: "initrd"},
+		},
+		{
+			name: "missing configmap",
+			src:  types.BootFileSource{Kind: types.BootFileSourceConfigMap, Name: "nosuchcfg", Key: "initrd"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := loader.LoadBootFile("testns", &tc.src)
+			switch {
+			case tc.expectedData == "" && err == nil:
+				t.Errorf("LoadBootFile() didn't return an error")
+			case tc.expectedData != "" && err != nil:
+				t.Errorf("LoadBootFile(): %v", err)
+			case string(data) != tc.expectedData:
+				t.Errorf("bad boot file data %q instead of %q", data, tc.expectedData)
+			}
+		})
+	}
+}
@@ -29,7 +29,7 @@ import (
 func GetFileSystemVolumes(config *types.VMConfig, owner volumeOwner) ([]VMVolume, error) {
 	var fsVolumes []VMVolume
 	for index, mount := range config.Mounts {
-		if isRegularFile(mount.HostPath) ||
+		if isRegularFile(mount.HostPath) || isHugetlbfs(mount.HostPath) ||
 			strings.Contains(mount.HostPath, flexvolumeSubdir) ||
 			strings.Contains(mount.HostPath, "kubernetes.io~secret") ||
 			strings.Contains(mount.HostPath, "kubernetes.io~configmap") {
@@ -0,0 +1,159 @@
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package libvirttools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	libvirtxml "github.com/libvirt/libvirt-go-xml"
+
+	"github.com/Mirantis/virtlet/pkg/metadata/types"
+)
+
+const (
+	ovmfCodePath          = "/usr/share/OVMF/OVMF_CODE.fd"
+	ovmfVarsPath          = "/usr/share/OVMF/OVMF_VARS.fd"
+	ovmfSecureCodePath    = "/usr/share/OVMF/OVMF_CODE.secboot.fd"
+	ovmfSecureVarsPath    = "/usr/share/OVMF/OVMF_VARS.ms.fd"
+	aavmfCodePath         = "/usr/share/AAVMF/AAVMF_CODE.fd"
+	aavmfVarsPath         = "/usr/share/AAVMF/AAVMF_VARS.fd"
+	nvramFilenameSuffix   = "_VARS.fd"
+	nvramFilenameTemplate = "*" + nvramFilenameSuffix
+)
+
+// nvramDir is the directory that keeps UEFI variable stores of the
+// VMs. NVRAM files are per-pod, so they survive container restarts,
+// and are removed by the garbage collector after the pod is gone.
+var nvramDir = "/var/lib/virtlet/nvram"
+
+// SetNVRAMDir sets the directory for NVRAM files.
+// It can be useful in tests
+func SetNVRAMDir(dir string) {
+	nvramDir = dir
+}
+
+// isUEFI returns true if the VM uses UEFI firmware.
+func isUEFI(config *types.VMConfig) bool {
+	return config.ParsedAnnotations != nil &&
+		(config.ParsedAnnotations.Firmware == types.FirmwareUEFI ||
+			config.ParsedAnnotations.Firmware == types.FirmwareUEFISecure)
+}
+
+// nvramPath returns the path to NVRAM file for the specified pod and
+// firmware. The firmware is included in the file name so changing it
+// doesn't reuse the variable store made for a different firmware.
+func nvramPath(dir, podSandboxID string, firmware types.Firmware) string {
+	return filepath.Join(dir, fmt.Sprintf("%s-%s%s", podSandboxID, firmware, nvramFilenameSuffix))
+}
+
+// prepareNVRAMDir makes sure the NVRAM directory exists for VMs that
+// use UEFI firmware. libvirt creates the NVRAM file itself from the
+// template, but it needs the directory to be present.
+func prepareNVRAMDir(config *types.VMConfig) error {
+	if !isUEFI(config) {
+		return nil
+	}
+	if err := os.MkdirAll(nvramDir, 0777); err != nil {
+		return fmt.Errorf("error making NVRAM directory %q: %v", nvramDir, err)
+	}
+	return nil
+}
+
+// setFirmware sets up the OVMF (or AAVMF for aarch64 guests) loader
+// and NVRAM in the domain definition for VMs that use UEFI firmware.
+func setFirmware(domain *libvirtxml.Domain, config *types.VMConfig) {
+	if !isUEFI(config) {
+		return
+	}
+	firmware := config.ParsedAnnotations.Firmware
+	codePath, varsPath := ovmfCodePath, ovmfVarsPath
+	if archForConfig(config) == types.ArchAArch64 {
+		codePath, varsPath = aavmfCodePath, aavmfVarsPath
+	}
+	secure := ""
+	if firmware == types.FirmwareUEFISecure {
+		codePath, varsPath = ovmfSecureCodePath, ovmfSecureVarsPath
+		secure = "yes"
+		// Secure Boot requires SMM which is only available
+		// on q35 machine type, so the annotation validation
+		// doesn't allow combining it with pc machine type
+		domain.Features.SMM = &libvirtxml.DomainFeatureSMM{State: "on"}
+	}
+	domain.OS.Loader = &libvirtxml.DomainLoader{
+		Path:     codePath,
+		Readonly: "yes",
+		Secure:   secure,
+		Type:     "pflash",
+	}
+	domain.OS.NVRam = &libvirtxml.DomainNVRam{
+		NVRam:    nvramPath(nvramDir, config.PodSandboxID, firmware),
+		Template: varsPath,
+	}
+}
+
+func (v *VirtualizationTool) retrieveListOfPodSandboxIDs() ([]string, error) {
+	sandboxes, err := v.metadataStore.ListPodSandboxes(nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list pod sandboxes: %v", err)
+	}
+	var ids []string
+	for _, sandbox := range sandboxes {
+		ids = append(ids, sandbox.GetID())
+	}
+	return ids, nil
+}
+
+func (v *VirtualizationTool) removeOrphanNVRAMFiles(podSandboxIDs []string, directory string) []error {
+	files, err := filepath.Glob(filepath.Join(directory, nvramFilenameTemplate))
+	if err != nil {
+		return []error{
+			fmt.Errorf(
+				"error while globbing '%s' files in '%s' directory: %v",
+				nvramFilenameTemplate,
+				directory,
+				err,
+			),
+		}
+	}
+
+	var allErrors []error
+	for _, path := range files {
+		filename := filepath.Base(path)
+
+		filter := func(id string) bool {
+			return strings.HasPrefix(filename, id+"-")
+		}
+
+		if !inList(podSandboxIDs, filter) {
+			if err := os.Remove(path); err != nil {
+				allErrors = append(
+					allErrors,
+					fmt.Errorf(
+						"cannot remove NVRAM file with path '%s': %v",
+						path,
+						err,
+					),
+				)
+			}
+		}
+	}
+
+	return allErrors
+}
@@ -0,0 +1,138 @@
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package libvirttools
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	libvirtxml "github.com/libvirt/libvirt-go-xml"
+
+	"github.com/Mirantis/virtlet/pkg/metadata/types"
+	testutils "github.com/Mirantis/virtlet/pkg/utils/testing"
+)
+
+func TestSetFirmware(t *testing.T) {
+	podSandboxID := "69eec606-0493-5825-73a4-c5e0c0236155"
+	for _, tc := range []struct {
+		name           string
+		firmware       types.Firmware
+		expectedLoader *libvirtxml.DomainLoader
+		expectedNVRam  *libvirtxml.DomainNVRam
+		expectSMM      bool
+	}{
+		{
+			name: "default",
+		},
+		{
+			name:     "bios",
+			firmware: types.FirmwareBIOS,
+		},
+		{
+			name:     "uefi",
+			firmware: types.FirmwareUEFI,
+			expectedLoader: &libvirtxml.DomainLoader{
+				Path:     ovmfCodePath,
+				Readonly: "yes",
+				Type:     "pflash",
+			},
+			expectedNVRam: &libvirtxml.DomainNVRam{
+				NVRam:    nvramPath(nvramDir, podSandboxID, types.FirmwareUEFI),
+				Template: ovmfVarsPath,
+			},
+		},
+		{
+			name:     "uefi with secure boot",
+			firmware: types.FirmwareUEFISecure,
+			expectedLoader: &libvirtxml.DomainLoader{
+				Path:     ovmfSecureCodePath,
+				Readonly: "yes",
+				Secure:   "yes",
+				Type:     "pflash",
+			},
+			expectedNVRam: &libvirtxml.DomainNVRam{
+				NVRam:    nvramPath(nvramDir, podSandboxID, types.FirmwareUEFISecure),
+				Template: ovmfSecureVarsPath,
+			},
+			expectSMM: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			domain := &libvirtxml.Domain{
+				OS: &libvirtxml.DomainOS{
+					Type: &libvirtxml.DomainOSType{Type: "hvm"},
+				},
+				Features: &libvirtxml.DomainFeatureList{},
+			}
+			setFirmware(domain, &types.VMConfig{
+				PodSandboxID:      podSandboxID,
+				ParsedAnnotations: &types.VirtletAnnotations{Firmware: tc.firmware},
+			})
+			if !reflect.DeepEqual(domain.OS.Loader, tc.expectedLoader) {
+				t.Errorf("bad loader: %#v instead of %#v", domain.OS.Loader, tc.expectedLoader)
+			}
+			if !reflect.DeepEqual(domain.OS.NVRam, tc.expectedNVRam) {
+				t.Errorf("bad nvram: %#v instead of %#v", domain.OS.NVRam, tc.expectedNVRam)
+			}
+			if (domain.Features.SMM != nil) != tc.expectSMM {
+				t.Errorf("bad SMM feature setting: %#v", domain.Features.SMM)
+			}
+		})
+	}
+}
+
+func TestNVRAMCleanup(t *testing.T) {
+	ct := newContainerTester(t, testutils.NewToplevelRecorder(), nil, nil)
+	defer ct.teardown()
+
+	directory, err := ioutil.TempDir("", "virtlet-tests-")
+	if err != nil {
+		t.Fatalf("TempDir() returned: %v", err)
+	}
+	defer os.RemoveAll(directory)
+
+	fnames := []string{
+		nvramPath(directory, testUUIDs[0], types.FirmwareUEFI),
+		nvramPath(directory, testUUIDs[1], types.FirmwareUEFI),
+		nvramPath(directory, testUUIDs[1], types.FirmwareUEFISecure),
+		filepath.Join(directory, "some other file"),
+	}
+	for _, fname := range fnames {
+		if err := ioutil.WriteFile(fname, nil, 0644); err != nil {
+			t.Fatalf("Cannot create fake NVRAM file with name %q: %v", fname, err)
+		}
+	}
+
+	// this should only remove the NVRAM file of the pod
+	// corresponding to the first element of testUUIDs slice
+	if errors := ct.virtTool.removeOrphanNVRAMFiles(testUUIDs[1:], directory); len(errors) != 0 {
+		t.Errorf("removeOrphanNVRAMFiles returned errors: %v", errors)
+	}
+
+	postCallFileNames, err := filepath.Glob(filepath.Join(directory, "*"))
+	if err != nil {
+		t.Fatalf("Error globbing names in the temporary directory: %v", err)
+	}
+
+	diff := difference(fnames, postCallFileNames)
+	if !reflect.DeepEqual(diff, fnames[:1]) {
+		t.Errorf("Expected removeOrphanNVRAMFiles to remove %v, but it removed %v", fnames[:1], diff)
+	}
+}
@@ -47,8 +47,17 @@ func (v *VirtualizationTool) GarbageCollect() (allErrors []error) {
 	allErrors = append(allErrors, v.removeOrphanRootVolumes(ids)...)
 	allErrors = append(allErrors, v.removeOrphanQcow2Volumes(ids)...)
 	allErrors = append(allErrors, v.removeOrphanConfigImages(ids, configIsoDir)...)
+	allErrors = append(allErrors, v.removeOrphanBootFiles(ids, configIsoDir)...)
+	allErrors = append(allErrors, v.removeOrphanTPMStates(ids, tpmStateDir)...)
 	allErrors = append(allErrors, v.removeOrphanVirtualBlockDevices(ids, "", "")...)
 
+	podSandboxIDs, err := v.retrieveListOfPodSandboxIDs()
+	if err != nil {
+		allErrors = append(allErrors, err)
+	} else {
+		allErrors = append(allErrors, v.removeOrphanNVRAMFiles(podSandboxIDs, nvramDir)...)
+	}
+
 	return
 }
 
@@ -295,6 +304,45 @@ func (v *VirtualizationTool) removeOrphanConfigImages(ids []string, directory st
 	return allErrors
 }
 
+func (v *VirtualizationTool) removeOrphanBootFiles(ids []string, directory string) []error {
+	files, err := filepath.Glob(filepath.Join(directory, bootFilenameTemplate))
+	if err != nil {
+		return []error{
+			fmt.Errorf(
+				"error while globbing '%s' files in '%s' directory: %v",
+				bootFilenameTemplate,
+				directory,
+				err,
+			),
+		}
+	}
+
+	var allErrors []error
+	for _, path := range files {
+		filename := filepath.Base(path)
+
+		filter := func(id string) bool {
+			return filename == filepath.Base(bootFilePath(directory, id, kernelFileRole)) ||
+				filename == filepath.Base(bootFilePath(directory, id, initrdFileRole))
+		}
+
+		if !inList(ids, filter) {
+			if err := os.Remove(path); err != nil {
+				allErrors = append(
+					allErrors,
+					fmt.Errorf(
+						"cannot remove boot file with path '%s': %v",
+						path,
+						err,
+					),
+				)
+			}
+		}
+	}
+
+	return allErrors
+}
+
 func (v *VirtualizationTool) removeOrphanVirtualBlockDevices(ids []string, devPath, sysfsPath string) []error {
 	idsInUse := make(map[string]bool)
 	for _, id := range ids {
@@ -21,6 +21,8 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
 	"testing"
 
 	libvirtxml "github.com/libvirt/libvirt-go-xml"
@@ -215,6 +217,48 @@ func TestConfigISOsCleanup(t *testing.T) {
 	// no gm validation, because we are testing only file operations in this test
 }
 
+func TestBootFilesCleanup(t *testing.T) {
+	ct := newContainerTester(t, testutils.NewToplevelRecorder(), nil, nil)
+	defer ct.teardown()
+
+	directory, err := ioutil.TempDir("", "virtlet-tests-")
+	if err != nil {
+		t.Fatalf("TempDir() returned: %v", err)
+	}
+	defer os.RemoveAll(directory)
+
+	var fnames []string
+	for _, uuid := range testUUIDs {
+		fnames = append(fnames,
+			bootFilePath(directory, uuid, kernelFileRole),
+			bootFilePath(directory, uuid, initrdFileRole))
+	}
+	fnames = append(fnames, filepath.Join(directory, "config-"+testUUIDs[0]+".iso"))
+	for _, fname := range fnames {
+		if err := ioutil.WriteFile(fname, nil, 0644); err != nil {
+			t.Fatalf("Cannot create fake boot file with name %q: %v", fname, err)
+		}
+	}
+
+	// this should remove only the boot files corresponding to the
+	// first element of testUUIDs slice, keeping other files
+	if errors := ct.virtTool.removeOrphanBootFiles(testUUIDs[1:], directory); len(errors) != 0 {
+		t.Errorf("removeOrphanBootFiles returned errors: %v", errors)
+	}
+
+	postCallFileNames, err := filepath.Glob(filepath.Join(directory, "*"))
+	if err != nil {
+		t.Fatalf("Error globbing names in the temporary directory: %v", err)
+	}
+
+	diff := difference(fnames, postCallFileNames)
+	sort.Strings(diff)
+	expectedDiff := []string{fnames[1], fnames[0]}
+	if !reflect.DeepEqual(diff, expectedDiff) {
+		t.Errorf("Expected removeOrphanBootFiles to remove %v, but it removed %v", expectedDiff, diff)
+	}
+}
+
 func TestDeviceMapperCleanup(t *testing.T) {
 	fakeblockdev.WithFakeRootDevsAndSysfs(t, func(devPaths []string, table, devDir, sysfsDir string) {
 		dmRemoveCmd := "dmsetup remove virtlet-dm-9a322047-1f0d-4395-8e43-6e1b310ce6f3"
@@ -0,0 +1,159 @@
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package libvirttools
+
+import (
+	"encoding/json"
+	"fmt"
+
+	libvirtxml "github.com/libvirt/libvirt-go-xml"
+
+	"github.com/Mirantis/virtlet/pkg/metadata/types"
+	"github.com/Mirantis/virtlet/pkg/utils"
+)
+
+// imageDiskVolume denotes an additional VM disk backed by an image
+// from the image store
+type imageDiskVolume struct {
+	volumeBase
+	index int
+	disk  types.ImageDisk
+}
+
+var _ VMVolume = &imageDiskVolume{}
+
+// GetImageDisks returns volume sources for the additional image-backed
+// disks specified in the pod annotations.
+func GetImageDisks(config *types.VMConfig, owner volumeOwner) ([]VMVolume, error) {
+	if config.ParsedAnnotations == nil {
+		return nil, nil
+	}
+	var vols []VMVolume
+	for n, disk := range config.ParsedAnnotations.ImageDisks {
+		vols = append(vols, &imageDiskVolume{
+			volumeBase: volumeBase{config, owner},
+			index:      n,
+			disk:       disk,
+		})
+	}
+	return vols, nil
+}
+
+func (v *imageDiskVolume) volumeName() string {
+	return fmt.Sprintf("virtlet-%s-imagedisk%d", v.config.DomainUUID, v.index)
+}
+
+// imageRef returns the ref of the image that was pulled for the disk,
+// falling back to the image name if there's none.
+func (v *imageDiskVolume) imageRef() string {
+	if v.index < len(v.config.ImageDiskRefs) {
+		return v.config.ImageDiskRefs[v.index]
+	}
+	return v.disk.Image
+}
+
+// imageFormat returns the format of the specified image file, such as
+// "qcow2" or "raw", as reported by qemu-img.
+func imageFormat(commander utils.Commander, imagePath string) (string, error) {
+	out, err := commander.Command("qemu-img", "info", "-U", "--output", "json", imagePath).Run(nil)
+	if err != nil {
+		return "", fmt.Errorf("error getting image info for %q: %v", imagePath, err)
+	}
+	var info struct {
+		Format string `json:"format"`
+	}
+	if err := json.Unmarshal(out, &info); err != nil {
+		return "", fmt.Errorf("can't parse image info for %q: %v\ninfo:\n%s", imagePath, err, out)
+	}
+	if info.Format == "" {
+		return "", fmt.Errorf("can't determine the format of %q", imagePath)
+	}
+	return info.Format, nil
+}
+
+func (v *imageDiskVolume) IsDisk() bool { return true }
+
+func (v *imageDiskVolume) UUID() string { return "" }
+
+func (v *imageDiskVolume) Setup() (*libvirtxml.DomainDisk, *libvirtxml.DomainFilesystem, error) {
+	imagePath, _, virtualSize, err := v.owner.ImageManager().GetImagePathDigestAndVirtualSize(v.imageRef())
+	if err != nil {
+		return nil, nil, err
+	}
+	format, err := imageFormat(v.owner.Commander(), imagePath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if v.disk.ReadOnly {
+		return &libvirtxml.DomainDisk{
+			Device:   "disk",
+			Driver:   &libvirtxml.DomainDiskDriver{Name: "qemu", Type: format},
+			Source:   &libvirtxml.DomainDiskSource{File: &libvirtxml.DomainDiskSourceFile{File: imagePath}},
+			ReadOnly: &libvirtxml.DomainDiskReadOnly{},
+		}, nil, nil
+	}
+
+	storagePool, err := v.owner.StoragePool()
+	if err != nil {
+		return nil, nil, err
+	}
+	vol, err := storagePool.CreateStorageVol(&libvirtxml.StorageVolume{
+		Type: "file",
+		Name: v.volumeName(),
+		Allocation: &libvirtxml.StorageVolumeSize{
+			Unit:  "b",
+			Value: 0,
+		},
+		Capacity: &libvirtxml.StorageVolumeSize{
+			Unit:  "b",
+			Value: virtualSize,
+		},
+		Target: &libvirtxml.StorageVolumeTarget{
+			Format: &libvirtxml.StorageVolumeTargetFormat{Type: "qcow2"},
+		},
+		BackingStore: &libvirtxml.StorageVolumeBackingStore{
+			Path:   imagePath,
+			Format: &libvirtxml.StorageVolumeTargetFormat{Type: format},
+		},
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("error creating volume for image disk %q: %v", v.disk.Image, err)
+	}
+
+	volPath, err := vol.Path()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error getting image disk volume path: %v", err)
+	}
+
+	return &libvirtxml.DomainDisk{
+		Device: "disk",
+		Driver: &libvirtxml.DomainDiskDriver{Name: "qemu", Type: "qcow2"},
+		Source: &libvirtxml.DomainDiskSource{File: &libvirtxml.DomainDiskSourceFile{File: volPath}},
+	}, nil, nil
+}
+
+func (v *imageDiskVolume) Teardown() error {
+	if v.disk.ReadOnly {
+		return nil
+	}
+	storagePool, err := v.owner.StoragePool()
+	if err != nil {
+		return err
+	}
+	return storagePool.RemoveVolumeByName(v.volumeName())
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2017 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package libvirttools
+
+import (
+	"testing"
+
+	libvirtxml "github.com/libvirt/libvirt-go-xml"
+
+	"github.com/Mirantis/virtlet/pkg/metadata/types"
+	fakeutils "github.com/Mirantis/virtlet/pkg/utils/fake"
+	testutils "github.com/Mirantis/virtlet/pkg/utils/testing"
+	"github.com/Mirantis/virtlet/pkg/virt/fake"
+	"github.com/Mirantis/virtlet/tests/gm"
+)
+
+func TestImageDiskLifeCycle(t *testing.T) {
+	rec := testutils.NewToplevelRecorder()
+	sc := fake.NewFakeStorageConnection(rec)
+	spool, err := sc.CreateStoragePool(&libvirtxml.StoragePool{
+		Name:   "volumes",
+		Target: &libvirtxml.StoragePoolTarget{Path: "/fake/volumes/pool"},
+	})
+	if err != nil {
+		t.Fatalf("CreateStoragePool(): %v", err)
+	}
+	im := newFakeImageManager(
+		rec.Child("image"),
+		fakeImageSpec{
+			name:   "fake/toolchain@" + fakeImageDigest.String(),
+			path:   "/fake/toolchain/path",
+			digest: fakeImageDigest,
+			size:   fakeImageVirtualSize,
+		},
+		fakeImageSpec{
+			name:   "fake/dataset",
+			path:   "/fake/dataset/path",
+			digest: fakeImageDigest,
+			size:   fakeImageVirtualSize,
+		})
+
+	vols, err := GetImageDisks(&types.VMConfig{
+		DomainUUID: testUUID,
+		ParsedAnnotations: &types.VirtletAnnotations{
+			ImageDisks: []types.ImageDisk{
+				{Image: "fake/toolchain", ReadOnly: true},
+				{Image: "fake/dataset"},
+			},
+		},
+		// the ref for the second disk is missing, so the
+		// image name is used instead
+		ImageDiskRefs: []string{"fake/toolchain@" + fakeImageDigest.String()},
+	}, newFakeVolumeOwner(sc, spool.(*fake.FakeStoragePool), im, fakeutils.NewCommander(rec, []fakeutils.CmdSpec{
+		{
+			Match:  "^qemu-img info -U --output json /fake/toolchain/path$",
+			Stdout: `{"format": "raw", "virtual-size": 424242}`,
+		},
+		{
+			Match:  "^qemu-img info -U --output json /fake/dataset/path$",
+			Stdout: `{"format": "qcow2", "virtual-size": 424242}`,
+		},
+	})))
+	if err != nil {
+		t.Fatalf("GetImageDisks(): %v", err)
+	}
+	if len(vols) != 2 {
+		t.Fatalf("GetImageDisks() returned %d volumes instead of 2", len(vols))
+	}
+
+	for _, vol := range vols {
+		disk, fs, err := vol.Setup()
+		if err != nil {
+			t.Fatalf("Setup(): %v", err)
+		}
+		if fs != nil {
+			t.Errorf("Didn't expect a filesystem")
+		}
+		out, err := disk.Marshal()
+		if err != nil {
+			t.Fatalf("error marshalling the volume: %v", err)
+		}
+		rec.Rec("image disk", out)
+	}
+
+	for _, vol := range vols {
+		if err := vol.Teardown(); err != nil {
+			t.Errorf("Teardown(): %v", err)
+		}
+	}
+
+	gm.Verify(t, gm.NewYamlVerifier(rec.Content()))
+}
@@ -157,7 +157,9 @@ func (domain *libvirtDomain) Destroy() error {
 }
 
 func (domain *libvirtDomain) Undefine() error {
-	return domain.d.Undefine()
+	// NVRAM of UEFI VMs is kept so it survives container restarts.
+	// It's removed by the garbage collector after the pod is gone.
+	return domain.d.UndefineFlags(libvirt.DOMAIN_UNDEFINE_KEEP_NVRAM)
 }
 
 func (domain *libvirtDomain) Shutdown() error {
@@ -241,6 +243,16 @@ func (domain *libvirtDomain) GetCPUTime() (uint64, error) {
 	return stats[0].CpuTime, nil
 }
 
+// FSFreeze freezes the filesystems of the guest using the guest agent
+func (domain *libvirtDomain) FSFreeze() error {
+	return domain.d.FSFreeze(nil, 0)
+}
+
+// FSThaw thaws the filesystems of the guest
+func (domain *libvirtDomain) FSThaw() error {
+	return domain.d.FSThaw(nil, 0)
+}
+
 type libvirtSecret struct {
 	s *libvirt.Secret
 }
@@ -0,0 +1,139 @@
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package libvirttools
+
+import (
+	libvirtxml "github.com/libvirt/libvirt-go-xml"
+
+	vconfig "github.com/Mirantis/virtlet/pkg/config"
+	"github.com/Mirantis/virtlet/pkg/metadata/types"
+)
+
+const (
+	// pcieRootPortFirstSlot is the first slot on pcie-root that's
+	// used for the root ports. Slot 1 is left for the video device.
+	pcieRootPortFirstSlot = 2
+	// pcieRootPortsPerSlot is the number of the root ports that are
+	// placed into a single multifunction slot on pcie-root.
+	pcieRootPortsPerSlot = 8
+	// pcieDevicePortCount is the number of root ports that are added
+	// to the domain for the devices which are placed by libvirt
+	// itself, that is, the virtio-scsi and virtio-serial controllers
+	// and the memory balloon device. libvirt adds more root ports if
+	// there are more such devices, e.g. 9pfs volumes.
+	pcieDevicePortCount = 3
+	// pcieHotplugPortCount is the number of spare root ports that are
+	// added to the domain for hotplugging devices into it, as PCIe
+	// root ports don't support hotplugging more than one device.
+	pcieHotplugPortCount = 4
+)
+
+// usesPCIe returns true if the VM uses a machine type with PCIe
+// topology, such as q35.
+func usesPCIe(config *types.VMConfig) bool {
+	return config.ParsedAnnotations != nil && config.ParsedAnnotations.MachineType.IsPCIe()
+}
+
+// setMachineType sets the machine type of the domain. The default
+// x86_64 machine type is left as is.
+func setMachineType(domain *libvirtxml.Domain, config *types.VMConfig) {
+	if config.ParsedAnnotations == nil {
+		return
+	}
+	if machineType := config.ParsedAnnotations.MachineType; machineType != "" && machineType != types.MachineTypePC {
+		domain.OS.Type.Machine = string(machineType)
+	}
+}
+
+// pcieRootPortForDisk returns the index of the PCIe root port for the
+// virtio disk number n. Disks take the first root ports, being
+// followed by network interfaces and hotplug ports.
+func pcieRootPortForDisk(n int) uint {
+	return uint(n + 1)
+}
+
+// pcieRootPortAddress returns the address of the PCIe root port with
+// the specified index on pcie-root.
+func pcieRootPortAddress(index uint) *libvirtxml.DomainAddress {
+	domain := uint(0)
+	bus := uint(0)
+	slot := pcieRootPortFirstSlot + (index-1)/pcieRootPortsPerSlot
+	function := (index - 1) % pcieRootPortsPerSlot
+	multifunction := ""
+	if function == 0 {
+		multifunction = "on"
+	}
+	return &libvirtxml.DomainAddress{
+		PCI: &libvirtxml.DomainAddressPCI{
+			Domain:        &domain,
+			Bus:           &bus,
+			Slot:          &slot,
+			Function:      &function,
+			MultiFunction: multifunction,
+		},
+	}
+}
+
+// setupPCIeTopology adds pcie-root and PCIe root ports to the domains
+// that use q35 or virt machine type. Each virtio disk gets a root port
+// of its own, and more ports are added for the devices placed by
+// libvirt and for hotplugging. The root ports are given explicit
+// indices and addresses so the disk paths used by the cloud-init
+// scripts are predictable. The root ports for the network interfaces
+// are added by vmwrapper along with the interfaces, as libvirt would
+// consider any root ports in the domain definition that are reserved
+// for them free and could place other devices there. This function
+// must be called after the disks are added to the domain.
+func setupPCIeTopology(domain *libvirtxml.Domain, config *types.VMConfig) {
+	if !usesPCIe(config) {
+		return
+	}
+
+	diskPorts := uint(0)
+	for _, disk := range domain.Devices.Disks {
+		if disk.Target == nil || disk.Target.Bus != "virtio" || disk.Address == nil || disk.Address.PCI == nil || disk.Address.PCI.Bus == nil {
+			continue
+		}
+		if *disk.Address.PCI.Bus > diskPorts {
+			diskPorts = *disk.Address.PCI.Bus
+		}
+	}
+
+	rootIndex := uint(0)
+	domain.Devices.Controllers = append(domain.Devices.Controllers, libvirtxml.DomainController{
+		Type:  "pci",
+		Index: &rootIndex,
+		Model: "pcie-root",
+	})
+	for i := uint(1); i <= diskPorts+pcieDevicePortCount+pcieHotplugPortCount; i++ {
+		index := i
+		domain.Devices.Controllers = append(domain.Devices.Controllers, libvirtxml.DomainController{
+			Type:    "pci",
+			Index:   &index,
+			Model:   "pcie-root-port",
+			Address: pcieRootPortAddress(index),
+		})
+	}
+
+	if config.ContainerSideNetwork != nil && len(config.ContainerSideNetwork.Interfaces) != 0 {
+		domain.QEMUCommandline.Envs = append(domain.QEMUCommandline.Envs,
+			libvirtxml.DomainQEMUCommandlineEnv{
+				Name:  vconfig.NetPCIeRootPortsEnvVarName,
+				Value: "1",
+			})
+	}
+}
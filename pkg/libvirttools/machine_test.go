@@ -0,0 +1,176 @@
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package libvirttools
+
+import (
+	"reflect"
+	"testing"
+
+	libvirtxml "github.com/libvirt/libvirt-go-xml"
+
+	"github.com/Mirantis/virtlet/pkg/metadata/types"
+	"github.com/Mirantis/virtlet/pkg/network"
+	"github.com/Mirantis/virtlet/pkg/utils"
+)
+
+func pcieRootPort(index, slot, function uint, multifunction string) libvirtxml.DomainController {
+	address := pciAddress(0, 0, slot, function)
+	address.PCI.MultiFunction = multifunction
+	return libvirtxml.DomainController{
+		Type:    "pci",
+		Index:   puint(index),
+		Model:   "pcie-root-port",
+		Address: address,
+	}
+}
+
+func TestPCIeTopology(t *testing.T) {
+	virtioDisks := []libvirtxml.DomainDisk{
+		{
+			Device:  "disk",
+			Target:  &libvirtxml.DomainDiskTarget{Dev: "vda", Bus: "virtio"},
+			Address: pciAddress(0, 1, 0, 0),
+		},
+		{
+			Device:  "cdrom",
+			Target:  &libvirtxml.DomainDiskTarget{Dev: "vdb", Bus: "virtio"},
+			Address: pciAddress(0, 2, 0, 0),
+		},
+	}
+	scsiDisks := []libvirtxml.DomainDisk{
+		{
+			Device:  "disk",
+			Target:  &libvirtxml.DomainDiskTarget{Dev: "sda", Bus: "scsi"},
+			Address: scsiAddress(0, 0, 0, 0),
+		},
+	}
+	twoInterfaces := &network.ContainerSideNetwork{
+		Interfaces: []*network.InterfaceDescription{
+			{Type: network.InterfaceTypeTap},
+			{Type: network.InterfaceTypeTap},
+		},
+	}
+	for _, tc := range []struct {
+		name                string
+		machineType         types.MachineType
+		disks               []libvirtxml.DomainDisk
+		csn                 *network.ContainerSideNetwork
+		expectedMachine     string
+		expectedControllers []libvirtxml.DomainController
+		expectedEnvs        []libvirtxml.DomainQEMUCommandlineEnv
+	}{
+		{
+			name:        "pc machine type",
+			machineType: types.MachineTypePC,
+			disks:       virtioDisks,
+			csn:         twoInterfaces,
+		},
+		{
+			name:            "q35 without virtio disks and network interfaces",
+			machineType:     types.MachineTypeQ35,
+			disks:           scsiDisks,
+			expectedMachine: "q35",
+			expectedControllers: []libvirtxml.DomainController{
+				{Type: "pci", Index: puint(0), Model: "pcie-root"},
+				pcieRootPort(1, 2, 0, "on"),
+				pcieRootPort(2, 2, 1, ""),
+				pcieRootPort(3, 2, 2, ""),
+				pcieRootPort(4, 2, 3, ""),
+				pcieRootPort(5, 2, 4, ""),
+				pcieRootPort(6, 2, 5, ""),
+				pcieRootPort(7, 2, 6, ""),
+			},
+		},
+		{
+			name:            "q35 with virtio disks and network interfaces",
+			machineType:     types.MachineTypeQ35,
+			disks:           virtioDisks,
+			csn:             twoInterfaces,
+			expectedMachine: "q35",
+			expectedControllers: []libvirtxml.DomainController{
+				{Type: "pci", Index: puint(0), Model: "pcie-root"},
+				pcieRootPort(1, 2, 0, "on"),
+				pcieRootPort(2, 2, 1, ""),
+				pcieRootPort(3, 2, 2, ""),
+				pcieRootPort(4, 2, 3, ""),
+				pcieRootPort(5, 2, 4, ""),
+				pcieRootPort(6, 2, 5, ""),
+				pcieRootPort(7, 2, 6, ""),
+				pcieRootPort(8, 2, 7, ""),
+				pcieRootPort(9, 3, 0, "on"),
+			},
+			expectedEnvs: []libvirtxml.DomainQEMUCommandlineEnv{
+				{Name: "VIRTLET_NET_PCIE_ROOT_PORTS", Value: "1"},
+			},
+		},
+		{
+			// the root ports for network interfaces are added
+			// by vmwrapper, so they don't change the topology
+			name:        "q35 with scsi disks and network interfaces",
+			machineType: types.MachineTypeQ35,
+			disks:       scsiDisks,
+			csn: &network.ContainerSideNetwork{
+				Interfaces: []*network.InterfaceDescription{
+					{Type: network.InterfaceTypeTap},
+					{Type: network.InterfaceTypeTap},
+					{Type: network.InterfaceTypeVF},
+				},
+			},
+			expectedMachine: "q35",
+			expectedControllers: []libvirtxml.DomainController{
+				{Type: "pci", Index: puint(0), Model: "pcie-root"},
+				pcieRootPort(1, 2, 0, "on"),
+				pcieRootPort(2, 2, 1, ""),
+				pcieRootPort(3, 2, 2, ""),
+				pcieRootPort(4, 2, 3, ""),
+				pcieRootPort(5, 2, 4, ""),
+				pcieRootPort(6, 2, 5, ""),
+				pcieRootPort(7, 2, 6, ""),
+			},
+			expectedEnvs: []libvirtxml.DomainQEMUCommandlineEnv{
+				{Name: "VIRTLET_NET_PCIE_ROOT_PORTS", Value: "1"},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			config := &types.VMConfig{
+				ParsedAnnotations:    &types.VirtletAnnotations{MachineType: tc.machineType},
+				ContainerSideNetwork: tc.csn,
+			}
+			domain := &libvirtxml.Domain{
+				OS: &libvirtxml.DomainOS{
+					Type: &libvirtxml.DomainOSType{Type: "hvm"},
+				},
+				Devices:         &libvirtxml.DomainDeviceList{Disks: tc.disks},
+				QEMUCommandline: &libvirtxml.DomainQEMUCommandline{},
+			}
+			setMachineType(domain, config)
+			setupPCIeTopology(domain, config)
+			if domain.OS.Type.Machine != tc.expectedMachine {
+				t.Errorf("bad machine type %q instead of %q", domain.OS.Type.Machine, tc.expectedMachine)
+			}
+			if !reflect.DeepEqual(domain.Devices.Controllers, tc.expectedControllers) {
+				t.Errorf("bad controllers:\n%s\ninstead of\n%s",
+					utils.ToJSON(domain.Devices.Controllers), utils.ToJSON(tc.expectedControllers))
+			}
+			if !reflect.DeepEqual(domain.QEMUCommandline.Envs, tc.expectedEnvs) {
+				t.Errorf("bad qemu env:\n%s\ninstead of\n%s",
+					utils.ToJSON(domain.QEMUCommandline.Envs), utils.ToJSON(tc.expectedEnvs))
+			}
+		})
+	}
+}
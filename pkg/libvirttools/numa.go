@@ -0,0 +1,252 @@
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package libvirttools
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/golang/glog"
+	libvirtxml "github.com/libvirt/libvirt-go-xml"
+	"k8s.io/kubernetes/pkg/kubelet/cm/cpuset"
+
+	"github.com/Mirantis/virtlet/pkg/fs"
+	"github.com/Mirantis/virtlet/pkg/metadata/types"
+)
+
+const (
+	sysfsNodeDir      = "/sys/devices/system/node"
+	numaMemoryMode    = "strict"
+	hugePagesSizeUnit = "KiB"
+)
+
+// getHugePageSizeForPath is used to check whether the path resides on
+// hugetlbfs. It can be replaced in tests
+var getHugePageSizeForPath = fs.GetHugePageSizeForPath
+
+// hostNUMANode describes a host NUMA node along with the CPUs from the
+// container cpuset that belong to it.
+type hostNUMANode struct {
+	id   int
+	cpus cpuset.CPUSet
+}
+
+// hugePageSize returns the size of huge pages (in bytes) to be used
+// for the VM memory, or 0 if the VM doesn't use huge pages. Kubelet
+// mounts hugetlbfs for the emptyDir volumes with HugePages medium
+// using the page size that corresponds to hugepages-* resource
+// requested by the pod. The volume is the only way to find out the
+// page size as CRI doesn't pass hugepages-* limits to the runtime.
+func hugePageSize(config *types.VMConfig) uint64 {
+	for _, m := range config.Mounts {
+		size, err := getHugePageSizeForPath(m.HostPath)
+		if err != nil {
+			glog.V(3).Infof("Can't check whether %q is on hugetlbfs: %v", m.HostPath, err)
+			continue
+		}
+		if size != 0 {
+			return size
+		}
+	}
+	return 0
+}
+
+// isHugetlbfs returns true if the path resides on hugetlbfs.
+func isHugetlbfs(path string) bool {
+	size, err := getHugePageSizeForPath(path)
+	return err == nil && size != 0
+}
+
+// checkHugePages verifies that the memory size of the VM which is
+// backed by huge pages is a multiple of the huge page size, as
+// otherwise QEMU fails to start.
+func checkHugePages(config *types.VMConfig) error {
+	size := hugePageSize(config)
+	if size == 0 {
+		return nil
+	}
+	memory := uint64(config.MemoryLimitInBytes)
+	if memory == 0 {
+		// defaultMemory is in MiB
+		memory = defaultMemory * 1024 * 1024
+	}
+	if memory%size != 0 {
+		return fmt.Errorf("VM memory size %d is not a multiple of huge page size %d", memory, size)
+	}
+	return nil
+}
+
+// setHugePages makes the domain memory backed by huge pages if the
+// pod requests them.
+func setHugePages(domain *libvirtxml.Domain, config *types.VMConfig) {
+	size := hugePageSize(config)
+	if size == 0 {
+		return
+	}
+	domain.MemoryBacking = &libvirtxml.DomainMemoryBacking{
+		MemoryHugePages: &libvirtxml.DomainMemoryHugepages{
+			Hugepages: []libvirtxml.DomainMemoryHugepage{
+				{Size: uint(size / 1024), Unit: hugePagesSizeUnit},
+			},
+		},
+	}
+}
+
+func (v *VirtualizationTool) readSysfsCPUSet(path string) (cpuset.CPUSet, error) {
+	f, err := v.fsys.GetDelimitedReader(path)
+	if err != nil {
+		return cpuset.CPUSet{}, err
+	}
+	defer f.Close()
+
+	s, err := f.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return cpuset.CPUSet{}, err
+	}
+	r, err := cpuset.Parse(strings.TrimSpace(s))
+	if err != nil {
+		return cpuset.CPUSet{}, fmt.Errorf("error parsing %q: %v", path, err)
+	}
+	return r, nil
+}
+
+// hostNUMANodes returns the host NUMA nodes which the specified cpus
+// belong to. It returns nil if there's no NUMA information available
+// on the host.
+func (v *VirtualizationTool) hostNUMANodes(cpus cpuset.CPUSet) ([]hostNUMANode, error) {
+	online, err := v.readSysfsCPUSet(filepath.Join(sysfsNodeDir, "online"))
+	if err != nil {
+		if _, ok := err.(*os.PathError); ok {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var nodes []hostNUMANode
+	for _, id := range online.ToSlice() {
+		nodeCPUs, err := v.readSysfsCPUSet(filepath.Join(sysfsNodeDir, fmt.Sprintf("node%d", id), "cpulist"))
+		if err != nil {
+			return nil, err
+		}
+		if c := nodeCPUs.Intersection(cpus); !c.IsEmpty() {
+			nodes = append(nodes, hostNUMANode{id: id, cpus: c})
+		}
+	}
+	return nodes, nil
+}
+
+// memoryKiB returns the domain memory size in KiB.
+func memoryKiB(memory *libvirtxml.DomainMemory) uint {
+	switch memory.Unit {
+	case "b", "bytes":
+		return memory.Value / 1024
+	case "MiB", "M":
+		return memory.Value * 1024
+	case "GiB", "G":
+		return memory.Value * 1024 * 1024
+	default:
+		return memory.Value
+	}
+}
+
+// setNUMATopology restricts the domain memory to the host NUMA nodes
+// the VM is pinned to. If the VM spans several host NUMA nodes, guest
+// NUMA topology is made to match them, with vCPUs and memory evenly
+// split between the guest nodes. NUMA topology that's specified
+// explicitly using VirtletLibvirtCPUSetting annotation is left
+// untouched.
+func setNUMATopology(domain *libvirtxml.Domain, nodes []hostNUMANode) {
+	generated := domain.NUMATune != nil && len(domain.NUMATune.MemNodes) > 0
+	explicit := domain.CPU != nil && domain.CPU.Numa != nil && !generated
+	domain.NUMATune = nil
+	if generated {
+		domain.CPU.Numa = nil
+		if reflect.DeepEqual(*domain.CPU, libvirtxml.DomainCPU{}) {
+			domain.CPU = nil
+		}
+	}
+	if len(nodes) == 0 {
+		return
+	}
+
+	var nodeIDs []int
+	for _, node := range nodes {
+		nodeIDs = append(nodeIDs, node.id)
+	}
+	domain.NUMATune = &libvirtxml.DomainNUMATune{
+		Memory: &libvirtxml.DomainNUMATuneMemory{
+			Mode:    numaMemoryMode,
+			Nodeset: cpuset.NewCPUSet(nodeIDs...).String(),
+		},
+	}
+
+	vcpuNum := domain.VCPU.Value
+	if explicit || len(nodes) < 2 || vcpuNum < len(nodes) {
+		return
+	}
+
+	memory := memoryKiB(domain.Memory)
+	var cells []libvirtxml.DomainCell
+	for n, node := range nodes {
+		cellID := uint(n)
+		var vcpus []int
+		for i := n * vcpuNum / len(nodes); i < (n+1)*vcpuNum/len(nodes); i++ {
+			vcpus = append(vcpus, i)
+		}
+		cellMemory := memory / uint(len(nodes))
+		if n == len(nodes)-1 {
+			cellMemory = memory - cellMemory*uint(len(nodes)-1)
+		}
+		cells = append(cells, libvirtxml.DomainCell{
+			ID:     &cellID,
+			CPUs:   cpuset.NewCPUSet(vcpus...).String(),
+			Memory: cellMemory,
+			Unit:   "KiB",
+		})
+		domain.NUMATune.MemNodes = append(domain.NUMATune.MemNodes, libvirtxml.DomainNUMATuneMemNode{
+			CellID:  cellID,
+			Mode:    numaMemoryMode,
+			Nodeset: strconv.Itoa(node.id),
+		})
+	}
+	if domain.CPU == nil {
+		domain.CPU = &libvirtxml.DomainCPU{}
+	}
+	domain.CPU.Numa = &libvirtxml.DomainNuma{Cell: cells}
+}
+
+// alignNUMATopology updates NUMA settings of the domain according to
+// the cpuset assigned to the container.
+func (v *VirtualizationTool) alignNUMATopology(domain *libvirtxml.Domain, cpusets string) error {
+	var nodes []hostNUMANode
+	if cpusets != "" {
+		cpus, err := cpuset.Parse(cpusets)
+		if err != nil {
+			return fmt.Errorf("bad cpuset %q: %v", cpusets, err)
+		}
+		if nodes, err = v.hostNUMANodes(cpus); err != nil {
+			return fmt.Errorf("error getting host NUMA nodes: %v", err)
+		}
+	}
+	setNUMATopology(domain, nodes)
+	return nil
+}
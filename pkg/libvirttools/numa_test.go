@@ -0,0 +1,254 @@
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package libvirttools
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	libvirtxml "github.com/libvirt/libvirt-go-xml"
+
+	"github.com/Mirantis/virtlet/pkg/metadata/types"
+	"github.com/Mirantis/virtlet/pkg/utils"
+	testutils "github.com/Mirantis/virtlet/pkg/utils/testing"
+)
+
+func withFakeHugetlbfs(paths map[string]uint64, toRun func()) {
+	oldGetHugePageSizeForPath := getHugePageSizeForPath
+	defer func() {
+		getHugePageSizeForPath = oldGetHugePageSizeForPath
+	}()
+	getHugePageSizeForPath = func(path string) (uint64, error) {
+		if path == "/nonexistent" {
+			return 0, errors.New("no such file or directory")
+		}
+		return paths[path], nil
+	}
+	toRun()
+}
+
+func TestHugePages(t *testing.T) {
+	hugetlbfsPaths := map[string]uint64{
+		"/var/lib/kubelet/pods/foo/volumes/kubernetes.io~empty-dir/hugepages": 2 * 1024 * 1024,
+	}
+	for _, tc := range []struct {
+		name                  string
+		mounts                []types.VMMount
+		expectedMemoryBacking *libvirtxml.DomainMemoryBacking
+	}{
+		{
+			name: "no mounts",
+		},
+		{
+			name: "no hugetlbfs mounts",
+			mounts: []types.VMMount{
+				{ContainerPath: "/data", HostPath: "/var/lib/kubelet/pods/foo/volumes/kubernetes.io~empty-dir/data"},
+				{ContainerPath: "/other", HostPath: "/nonexistent"},
+			},
+		},
+		{
+			name: "hugetlbfs mount",
+			mounts: []types.VMMount{
+				{ContainerPath: "/data", HostPath: "/var/lib/kubelet/pods/foo/volumes/kubernetes.io~empty-dir/data"},
+				{ContainerPath: "/hugepages", HostPath: "/var/lib/kubelet/pods/foo/volumes/kubernetes.io~empty-dir/hugepages"},
+			},
+			expectedMemoryBacking: &libvirtxml.DomainMemoryBacking{
+				MemoryHugePages: &libvirtxml.DomainMemoryHugepages{
+					Hugepages: []libvirtxml.DomainMemoryHugepage{
+						{Size: 2048, Unit: "KiB"},
+					},
+				},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			withFakeHugetlbfs(hugetlbfsPaths, func() {
+				domain := &libvirtxml.Domain{}
+				setHugePages(domain, &types.VMConfig{Mounts: tc.mounts})
+				if !reflect.DeepEqual(domain.MemoryBacking, tc.expectedMemoryBacking) {
+					t.Errorf("bad memory backing:\n%s\ninstead of\n%s",
+						utils.ToJSON(domain.MemoryBacking), utils.ToJSON(tc.expectedMemoryBacking))
+				}
+				for _, m := range tc.mounts {
+					if isHugetlbfs(m.HostPath) != (hugetlbfsPaths[m.HostPath] != 0) {
+						t.Errorf("isHugetlbfs returned a bad value for %q", m.HostPath)
+					}
+				}
+			})
+		})
+	}
+}
+
+func TestCheckHugePages(t *testing.T) {
+	hugetlbfsPaths := map[string]uint64{
+		"/var/lib/kubelet/pods/foo/volumes/kubernetes.io~empty-dir/hugepages": 1024 * 1024 * 1024,
+	}
+	hugetlbfsMounts := []types.VMMount{
+		{ContainerPath: "/hugepages", HostPath: "/var/lib/kubelet/pods/foo/volumes/kubernetes.io~empty-dir/hugepages"},
+	}
+	for _, tc := range []struct {
+		name        string
+		mounts      []types.VMMount
+		memory      int64
+		expectError bool
+	}{
+		{
+			name:   "no hugetlbfs mounts",
+			memory: 1536 * 1024 * 1024,
+		},
+		{
+			name:   "memory limit that is a multiple of the page size",
+			mounts: hugetlbfsMounts,
+			memory: 2 * 1024 * 1024 * 1024,
+		},
+		{
+			name:   "default memory size",
+			mounts: hugetlbfsMounts,
+		},
+		{
+			name:        "memory limit that is not a multiple of the page size",
+			mounts:      hugetlbfsMounts,
+			memory:      1536 * 1024 * 1024,
+			expectError: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			withFakeHugetlbfs(hugetlbfsPaths, func() {
+				err := checkHugePages(&types.VMConfig{Mounts: tc.mounts, MemoryLimitInBytes: tc.memory})
+				switch {
+				case err == nil && tc.expectError:
+					t.Errorf("checkHugePages didn't return an error")
+				case err != nil && !tc.expectError:
+					t.Errorf("checkHugePages returned an error: %v", err)
+				}
+			})
+		})
+	}
+}
+
+func TestNUMATopology(t *testing.T) {
+	files := map[string]string{
+		"/sys/devices/system/node/online":        "0-1\n",
+		"/sys/devices/system/node/node0/cpulist": "0-3,8-11\n",
+		"/sys/devices/system/node/node1/cpulist": "4-7,12-15\n",
+	}
+	cellID := func(id uint) *uint { return &id }
+	for _, tc := range []struct {
+		name             string
+		cpusets          string
+		vcpuNum          int
+		cpu              *libvirtxml.DomainCPU
+		expectedNUMATune *libvirtxml.DomainNUMATune
+		expectedCPU      *libvirtxml.DomainCPU
+	}{
+		{
+			name:    "no cpuset",
+			vcpuNum: 2,
+		},
+		{
+			name:    "single host node",
+			cpusets: "2-3",
+			vcpuNum: 2,
+			expectedNUMATune: &libvirtxml.DomainNUMATune{
+				Memory: &libvirtxml.DomainNUMATuneMemory{Mode: "strict", Nodeset: "0"},
+			},
+		},
+		{
+			name:    "two host nodes",
+			cpusets: "2-5",
+			vcpuNum: 4,
+			expectedNUMATune: &libvirtxml.DomainNUMATune{
+				Memory: &libvirtxml.DomainNUMATuneMemory{Mode: "strict", Nodeset: "0-1"},
+				MemNodes: []libvirtxml.DomainNUMATuneMemNode{
+					{CellID: 0, Mode: "strict", Nodeset: "0"},
+					{CellID: 1, Mode: "strict", Nodeset: "1"},
+				},
+			},
+			expectedCPU: &libvirtxml.DomainCPU{
+				Numa: &libvirtxml.DomainNuma{
+					Cell: []libvirtxml.DomainCell{
+						{ID: cellID(0), CPUs: "0-1", Memory: 524288, Unit: "KiB"},
+						{ID: cellID(1), CPUs: "2-3", Memory: 524288, Unit: "KiB"},
+					},
+				},
+			},
+		},
+		{
+			name:    "two host nodes, single vcpu",
+			cpusets: "3-4",
+			vcpuNum: 1,
+			expectedNUMATune: &libvirtxml.DomainNUMATune{
+				Memory: &libvirtxml.DomainNUMATuneMemory{Mode: "strict", Nodeset: "0-1"},
+			},
+		},
+		{
+			name:    "explicit guest NUMA topology",
+			cpusets: "2-5",
+			vcpuNum: 4,
+			cpu: &libvirtxml.DomainCPU{
+				Numa: &libvirtxml.DomainNuma{
+					Cell: []libvirtxml.DomainCell{
+						{ID: cellID(0), CPUs: "0-3", Memory: 1048576, Unit: "KiB"},
+					},
+				},
+			},
+			expectedNUMATune: &libvirtxml.DomainNUMATune{
+				Memory: &libvirtxml.DomainNUMATuneMemory{Mode: "strict", Nodeset: "0-1"},
+			},
+			expectedCPU: &libvirtxml.DomainCPU{
+				Numa: &libvirtxml.DomainNuma{
+					Cell: []libvirtxml.DomainCell{
+						{ID: cellID(0), CPUs: "0-3", Memory: 1048576, Unit: "KiB"},
+					},
+				},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ct := newContainerTester(t, testutils.NewToplevelRecorder(), nil, files)
+			defer ct.teardown()
+			domain := &libvirtxml.Domain{
+				Memory: &libvirtxml.DomainMemory{Value: 1024, Unit: "MiB"},
+				VCPU:   &libvirtxml.DomainVCPU{Value: tc.vcpuNum},
+				CPU:    tc.cpu,
+			}
+			if err := ct.virtTool.alignNUMATopology(domain, tc.cpusets); err != nil {
+				t.Fatalf("alignNUMATopology: %v", err)
+			}
+			if !reflect.DeepEqual(domain.NUMATune, tc.expectedNUMATune) {
+				t.Errorf("bad numatune:\n%s\ninstead of\n%s",
+					utils.ToJSON(domain.NUMATune), utils.ToJSON(tc.expectedNUMATune))
+			}
+			if !reflect.DeepEqual(domain.CPU, tc.expectedCPU) {
+				t.Errorf("bad cpu:\n%s\ninstead of\n%s",
+					utils.ToJSON(domain.CPU), utils.ToJSON(tc.expectedCPU))
+			}
+
+			// resetting the cpuset removes NUMA settings
+			if err := ct.virtTool.alignNUMATopology(domain, ""); err != nil {
+				t.Fatalf("alignNUMATopology: %v", err)
+			}
+			if domain.NUMATune != nil {
+				t.Errorf("numatune not removed")
+			}
+			if !reflect.DeepEqual(domain.CPU, tc.cpu) {
+				t.Errorf("guest NUMA topology not reset:\n%s", utils.ToJSON(domain.CPU))
+			}
+		})
+	}
+}
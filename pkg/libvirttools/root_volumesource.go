@@ -17,6 +17,7 @@ limitations under the License.
 package libvirttools
 
 import (
+	"errors"
 	"fmt"
 
 	libvirtxml "github.com/libvirt/libvirt-go-xml"
@@ -33,15 +34,20 @@ type rootVolume struct {
 var _ VMVolume = &rootVolume{}
 
 // GetRootVolume returns volume source for root volume clone.
+// For ISO images, the root volume is blank.
 func GetRootVolume(config *types.VMConfig, owner volumeOwner) ([]VMVolume, error) {
 	var vol VMVolume
 	rootDev := config.RootVolumeDevice()
-	if rootDev != nil {
+	isISO := isISOImage(config)
+	switch {
+	case rootDev != nil && isISO:
+		return nil, errors.New("ISO images can't be used with persistent root filesystems")
+	case rootDev != nil:
 		vol = &persistentRootVolume{
 			volumeBase: volumeBase{config, owner},
 			dev:        *rootDev,
 		}
-	} else {
+	default:
 		vol = &rootVolume{
 			volumeBase{config, owner},
 		}
@@ -49,14 +55,38 @@ func GetRootVolume(config *types.VMConfig, owner volumeOwner) ([]VMVolume, error
 	return []VMVolume{vol}, nil
 }
 
+// GetInstallerISOVolume returns volume source for the installer
+// CD-ROM of the VMs that use ISO images.
+func GetInstallerISOVolume(config *types.VMConfig, owner volumeOwner) ([]VMVolume, error) {
+	if !isISOImage(config) {
+		return nil, nil
+	}
+	return []VMVolume{&installerISOVolume{volumeBase{config, owner}}}, nil
+}
+
+func isISOImage(config *types.VMConfig) bool {
+	return config.ParsedAnnotations != nil && config.ParsedAnnotations.ImageType == types.ImageTypeISO
+}
+
 func (v *rootVolume) volumeName() string {
 	return "virtlet_root_" + v.config.DomainUUID
 }
 
 func (v *rootVolume) createVolume() (virt.StorageVolume, error) {
-	imagePath, _, virtualSize, err := v.owner.ImageManager().GetImagePathDigestAndVirtualSize(v.config.Image)
-	if err != nil {
-		return nil, err
+	var virtualSize uint64
+	var backingStore *libvirtxml.StorageVolumeBackingStore
+	// for ISO images, the root volume is blank and the image
+	// is attached as a CD-ROM instead
+	if !isISOImage(v.config) {
+		imagePath, _, imageSize, err := v.owner.ImageManager().GetImagePathDigestAndVirtualSize(v.config.Image)
+		if err != nil {
+			return nil, err
+		}
+		virtualSize = imageSize
+		backingStore = &libvirtxml.StorageVolumeBackingStore{
+			Path:   imagePath,
+			Format: &libvirtxml.StorageVolumeTargetFormat{Type: "qcow2"},
+		}
 	}
 
 	if v.config.ParsedAnnotations != nil && v.config.ParsedAnnotations.RootVolumeSize > 0 &&
@@ -82,10 +112,7 @@ func (v *rootVolume) createVolume() (virt.StorageVolume, error) {
 		Target: &libvirtxml.StorageVolumeTarget{
 			Format: &libvirtxml.StorageVolumeTargetFormat{Type: "qcow2"},
 		},
-		BackingStore: &libvirtxml.StorageVolumeBackingStore{
-			Path:   imagePath,
-			Format: &libvirtxml.StorageVolumeTargetFormat{Type: "qcow2"},
-		},
+		BackingStore: backingStore,
 	})
 }
 
@@ -124,3 +151,50 @@ func (v *rootVolume) Teardown() error {
 	}
 	return storagePool.RemoveVolumeByName(v.volumeName())
 }
+
+// installerISOVolume denotes the CD-ROM with the installer ISO image
+// that's used instead of the root volume backing file for ISO images
+type installerISOVolume struct {
+	volumeBase
+}
+
+var _ VMVolume = &installerISOVolume{}
+
+func (v *installerISOVolume) IsDisk() bool { return true }
+
+func (v *installerISOVolume) UUID() string { return "" }
+
+func (v *installerISOVolume) Setup() (*libvirtxml.DomainDisk, *libvirtxml.DomainFilesystem, error) {
+	imagePath, _, _, err := v.owner.ImageManager().GetImagePathDigestAndVirtualSize(v.config.Image)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &libvirtxml.DomainDisk{
+		Device:   "cdrom",
+		Driver:   &libvirtxml.DomainDiskDriver{Name: "qemu", Type: "raw"},
+		Source:   &libvirtxml.DomainDiskSource{File: &libvirtxml.DomainDiskSourceFile{File: imagePath}},
+		ReadOnly: &libvirtxml.DomainDiskReadOnly{},
+	}, nil, nil
+}
+
+// commit writes the contents of the volume flattened together with
+// its backing image to the specified path as a qcow2 file.
+func (v *rootVolume) commit(targetPath string) error {
+	storagePool, err := v.owner.StoragePool()
+	if err != nil {
+		return err
+	}
+	vol, err := storagePool.LookupVolumeByName(v.volumeName())
+	if err != nil {
+		return err
+	}
+	volPath, err := vol.Path()
+	if err != nil {
+		return fmt.Errorf("error getting root volume path: %v", err)
+	}
+	// -U is needed to read the volume while it's in use by the VM
+	if _, err := v.owner.Commander().Command("qemu-img", "convert", "-U", "-O", "qcow2", volPath, targetPath).Run(nil); err != nil {
+		return fmt.Errorf("error converting the root volume: %v", err)
+	}
+	return nil
+}
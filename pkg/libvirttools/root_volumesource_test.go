@@ -260,6 +260,76 @@ func TestRootVolumeFiles(t *testing.T) {
 	gm.Verify(t, gm.NewYamlVerifier(rec.Content()))
 }
 
+func TestInstallerISOVolumes(t *testing.T) {
+	rec := testutils.NewToplevelRecorder()
+	sc := fake.NewFakeStorageConnection(rec)
+	spool, err := sc.CreateStoragePool(&libvirtxml.StoragePool{
+		Name:   "volumes",
+		Target: &libvirtxml.StoragePoolTarget{Path: "/fake/volumes/pool"},
+	})
+	if err != nil {
+		t.Fatalf("CreateStoragePool(): %v", err)
+	}
+	im := newFakeImageManager(rec.Child("image"))
+	rootVolumeSize := int64(fakeImageVirtualSize * 10)
+	config := &types.VMConfig{
+		DomainUUID: testUUID,
+		Image:      "fake/image1",
+		ParsedAnnotations: &types.VirtletAnnotations{
+			ImageType:      types.ImageTypeISO,
+			RootVolumeSize: rootVolumeSize,
+		},
+	}
+	owner := newFakeVolumeOwner(sc, spool.(*fake.FakeStoragePool), im, fakeutils.NewCommander(nil, nil))
+	rootVolumes, err := GetRootVolume(config, owner)
+	if err != nil {
+		t.Fatalf("GetRootVolume returned an error: %v", err)
+	}
+	if len(rootVolumes) != 1 {
+		t.Fatalf("expected 1 root volume, got %d", len(rootVolumes))
+	}
+	isoVolumes, err := GetInstallerISOVolume(config, owner)
+	if err != nil {
+		t.Fatalf("GetInstallerISOVolume returned an error: %v", err)
+	}
+	if len(isoVolumes) != 1 {
+		t.Fatalf("expected 1 installer ISO volume, got %d", len(isoVolumes))
+	}
+
+	rootDisk, _, err := rootVolumes[0].Setup()
+	if err != nil {
+		t.Fatalf("root volume Setup returned an error: %v", err)
+	}
+	if rootDisk.Device != "disk" {
+		t.Errorf("expected 'disk' as root volume device, received: %s", rootDisk.Device)
+	}
+	virtVol, err := spool.LookupVolumeByName("virtlet_root_" + testUUID)
+	if err != nil {
+		t.Fatalf("couldn't find the root volume: %v", err)
+	}
+	size, err := virtVol.Size()
+	if err != nil {
+		t.Fatalf("couldn't get virt volume size: %v", err)
+	}
+	if int64(size) != rootVolumeSize {
+		t.Errorf("bad root volume size %d instead of %d", size, rootVolumeSize)
+	}
+
+	isoDisk, _, err := isoVolumes[0].Setup()
+	if err != nil {
+		t.Fatalf("ISO volume Setup returned an error: %v", err)
+	}
+	if isoDisk.Device != "cdrom" {
+		t.Errorf("expected 'cdrom' as ISO volume device, received: %s", isoDisk.Device)
+	}
+	if isoDisk.ReadOnly == nil {
+		t.Errorf("the ISO volume is not read-only")
+	}
+	if isoDisk.Source.File == nil || isoDisk.Source.File.File != "/fake/volume/path" {
+		t.Errorf("bad ISO volume source: %#v", isoDisk.Source)
+	}
+}
+
 type fakeVolumeOwner struct {
 	sc           *fake.FakeStorageConnection
 	storagePool  *fake.FakeStoragePool
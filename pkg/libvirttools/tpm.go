@@ -0,0 +1,113 @@
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package libvirttools
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/golang/glog"
+	libvirtxml "github.com/libvirt/libvirt-go-xml"
+
+	vconfig "github.com/Mirantis/virtlet/pkg/config"
+	"github.com/Mirantis/virtlet/pkg/metadata/types"
+)
+
+// tpmStateDir is the directory that keeps the state of emulated TPM
+// devices. swtpm is started by vmwrapper for the VMs that have vTPM
+// enabled. Like the root volume, TPM state of the VM is created
+// along with the container and is removed together with it.
+var tpmStateDir = "/var/lib/virtlet/tpm"
+
+// SetTPMStateDir sets the directory for TPM state.
+// It can be useful in tests
+func SetTPMStateDir(dir string) {
+	tpmStateDir = dir
+}
+
+func tpmStatePath(dir, domainUUID string) string {
+	return filepath.Join(dir, domainUUID)
+}
+
+func hasTPM(config *types.VMConfig) bool {
+	return config.ParsedAnnotations != nil && config.ParsedAnnotations.TPM
+}
+
+// prepareTPMState makes the TPM state directory for the VM if it has
+// vTPM enabled. The directory is made accessible only to the emulator
+// user which runs swtpm via vmwrapper.
+func (v *VirtualizationTool) prepareTPMState(config *types.VMConfig) error {
+	if !hasTPM(config) {
+		return nil
+	}
+	path := tpmStatePath(tpmStateDir, config.DomainUUID)
+	if err := os.MkdirAll(path, 0700); err != nil {
+		return fmt.Errorf("error making TPM state directory %q: %v", path, err)
+	}
+	if err := v.fsys.ChownForEmulator(path, false); err != nil {
+		return fmt.Errorf("error setting the owner of TPM state directory %q: %v", path, err)
+	}
+	return nil
+}
+
+// setTPM passes the TPM state directory to vmwrapper for the VMs that
+// have vTPM enabled, so it starts swtpm and adds the TPM device.
+func setTPM(domain *libvirtxml.Domain, config *types.VMConfig) {
+	if !hasTPM(config) {
+		return
+	}
+	domain.QEMUCommandline.Envs = append(domain.QEMUCommandline.Envs,
+		libvirtxml.DomainQEMUCommandlineEnv{
+			Name:  vconfig.TPMStateDirEnvVarName,
+			Value: tpmStatePath(tpmStateDir, config.DomainUUID),
+		})
+}
+
+// removeTPMState removes the TPM state directory of the VM, if any.
+func removeTPMState(domainUUID string) error {
+	path := tpmStatePath(tpmStateDir, domainUUID)
+	if err := os.RemoveAll(path); err != nil {
+		return fmt.Errorf("error removing TPM state directory %q: %v", path, err)
+	}
+	return nil
+}
+
+func (v *VirtualizationTool) removeOrphanTPMStates(ids []string, directory string) []error {
+	entries, err := ioutil.ReadDir(directory)
+	switch {
+	case os.IsNotExist(err):
+		return nil
+	case err != nil:
+		return []error{fmt.Errorf("error listing TPM state directory %q: %v", directory, err)}
+	}
+
+	var allErrors []error
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() || inList(ids, func(id string) bool { return id == name }) {
+			continue
+		}
+		glog.V(3).Infof("Removing orphan TPM state %q", name)
+		if err := os.RemoveAll(filepath.Join(directory, name)); err != nil {
+			allErrors = append(allErrors, fmt.Errorf("cannot remove TPM state '%s': %v", name, err))
+		}
+	}
+
+	return allErrors
+}
@@ -0,0 +1,128 @@
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package libvirttools
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	libvirtxml "github.com/libvirt/libvirt-go-xml"
+
+	vconfig "github.com/Mirantis/virtlet/pkg/config"
+	"github.com/Mirantis/virtlet/pkg/metadata/types"
+	testutils "github.com/Mirantis/virtlet/pkg/utils/testing"
+)
+
+func TestTPMState(t *testing.T) {
+	rec := testutils.NewToplevelRecorder()
+	rec.AddFilter("ChownForEmulator")
+	ct := newContainerTester(t, rec, nil, nil)
+	defer ct.teardown()
+
+	for _, enableTPM := range []bool{false, true} {
+		config := &types.VMConfig{
+			DomainUUID:        testUUID,
+			ParsedAnnotations: &types.VirtletAnnotations{TPM: enableTPM},
+		}
+		if err := ct.virtTool.prepareTPMState(config); err != nil {
+			t.Fatalf("prepareTPMState(): %v", err)
+		}
+		statePath := tpmStatePath(tpmStateDir, testUUID)
+		fi, err := os.Stat(statePath)
+		switch {
+		case enableTPM && err != nil:
+			t.Errorf("TPM state directory wasn't created: %v", err)
+		case enableTPM && fi.Mode().Perm() != 0700:
+			t.Errorf("bad TPM state directory permissions %v", fi.Mode().Perm())
+		case !enableTPM && !os.IsNotExist(err):
+			t.Errorf("unexpected TPM state directory for a VM without vTPM (stat error: %v)", err)
+		}
+
+		var expectedRecs []*testutils.Record
+		if enableTPM {
+			expectedRecs = []*testutils.Record{
+				{Name: "ChownForEmulator", Value: []interface{}{statePath, false}},
+			}
+		}
+		if !reflect.DeepEqual(rec.Content(), expectedRecs) {
+			t.Errorf("bad ChownForEmulator calls: %#v instead of %#v", rec.Content(), expectedRecs)
+		}
+
+		domain := &libvirtxml.Domain{QEMUCommandline: &libvirtxml.DomainQEMUCommandline{}}
+		setTPM(domain, config)
+		var expectedEnvs []libvirtxml.DomainQEMUCommandlineEnv
+		if enableTPM {
+			expectedEnvs = []libvirtxml.DomainQEMUCommandlineEnv{
+				{Name: vconfig.TPMStateDirEnvVarName, Value: statePath},
+			}
+		}
+		if !reflect.DeepEqual(domain.QEMUCommandline.Envs, expectedEnvs) {
+			t.Errorf("bad emulator env: %#v instead of %#v", domain.QEMUCommandline.Envs, expectedEnvs)
+		}
+
+		if err := removeTPMState(testUUID); err != nil {
+			t.Errorf("removeTPMState(): %v", err)
+		}
+		if _, err := os.Stat(statePath); !os.IsNotExist(err) {
+			t.Errorf("TPM state directory wasn't removed (stat error: %v)", err)
+		}
+	}
+}
+
+func TestTPMStateCleanup(t *testing.T) {
+	ct := newContainerTester(t, testutils.NewToplevelRecorder(), nil, nil)
+	defer ct.teardown()
+
+	directory, err := ioutil.TempDir("", "virtlet-tests-")
+	if err != nil {
+		t.Fatalf("TempDir() returned: %v", err)
+	}
+	defer os.RemoveAll(directory)
+
+	for _, uuid := range testUUIDs {
+		statePath := tpmStatePath(directory, uuid)
+		if err := os.MkdirAll(statePath, 0700); err != nil {
+			t.Fatalf("MkdirAll(): %v", err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(statePath, "tpm2-00.permall"), []byte("state"), 0644); err != nil {
+			t.Fatalf("WriteFile(): %v", err)
+		}
+	}
+
+	// this should remove only the TPM state corresponding to the
+	// first element of testUUIDs slice, keeping other ones
+	if errors := ct.virtTool.removeOrphanTPMStates(testUUIDs[1:], directory); len(errors) != 0 {
+		t.Errorf("removeOrphanTPMStates returned errors: %v", errors)
+	}
+
+	names, err := filepath.Glob(filepath.Join(directory, "*"))
+	if err != nil {
+		t.Fatalf("Error globbing names in the temporary directory: %v", err)
+	}
+	expectedNames := []string{
+		tpmStatePath(directory, testUUIDs[1]),
+		tpmStatePath(directory, testUUIDs[2]),
+	}
+	sort.Strings(expectedNames)
+	if !reflect.DeepEqual(names, expectedNames) {
+		t.Errorf("bad TPM states after cleanup: %v instead of %v", names, expectedNames)
+	}
+}
@@ -44,7 +44,6 @@ const (
 	defaultDomainType = "kvm"
 	defaultEmulator   = "/usr/bin/kvm"
 	noKvmDomainType   = "qemu"
-	noKvmEmulator     = "/usr/bin/qemu-system-x86_64"
 
 	domainStartCheckInterval      = 250 * time.Millisecond
 	domainStartTimeout            = 10 * time.Second
@@ -53,6 +52,10 @@ const (
 	domainDestroyCheckInterval    = 500 * time.Millisecond
 	domainDestroyTimeout          = 5 * time.Second
 
+	// guestAgentChannelName is the name of the virtio channel
+	// that's used to communicate with QEMU guest agent
+	guestAgentChannelName = "org.qemu.guest_agent.0"
+
 	// ContainerNsUUID template for container ns uuid generation
 	ContainerNsUUID = "67b7fb47-7735-4b64-86d2-6d062d121966"
 
@@ -81,28 +84,61 @@ type domainSettings struct {
 	enableSriov      bool
 	cpuModel         string
 	systemUUID       *uuid.UUID
+	kernelPath       string
+	initrdPath       string
+	arch             types.Arch
+}
+
+// bootDevices returns the boot order for the domain. Unless it's
+// set explicitly using VirtletBootOrder annotation, VMs boot from
+// the root disk, and VMs using ISO images fall back to the installer
+// CD-ROM while the blank root disk has nothing to boot from.
+func bootDevices(config *types.VMConfig) []libvirtxml.DomainBootDevice {
+	order := []types.BootDevice{types.BootDeviceHD}
+	if config.ParsedAnnotations != nil {
+		switch {
+		case len(config.ParsedAnnotations.BootOrder) > 0:
+			order = config.ParsedAnnotations.BootOrder
+		case config.ParsedAnnotations.ImageType == types.ImageTypeISO:
+			order = append(order, types.BootDeviceCDROM)
+		}
+	}
+	var r []libvirtxml.DomainBootDevice
+	for _, dev := range order {
+		r = append(r, libvirtxml.DomainBootDevice{Dev: string(dev)})
+	}
+	return r
 }
 
 func (ds *domainSettings) createDomain(config *types.VMConfig) *libvirtxml.Domain {
 	domainType := defaultDomainType
 	emulator := defaultEmulator
-	if !ds.useKvm {
+	osArch := ""
+	switch {
+	case ds.arch != hostArch:
+		// KVM can't run guests of other architectures,
+		// so they're emulated using TCG
 		domainType = noKvmDomainType
-		emulator = noKvmEmulator
+		emulator = ds.arch.EmulatorPath()
+		osArch = string(ds.arch)
+	case !ds.useKvm:
+		domainType = noKvmDomainType
+		emulator = ds.arch.EmulatorPath()
 	}
+	archSettings := guestArchs[ds.arch]
 
 	scsiControllerIndex := uint(0)
 	domain := &libvirtxml.Domain{
 		Devices: &libvirtxml.DomainDeviceList{
-			Emulator: "/vmwrapper",
+			Emulator: vmwrapperForArch(ds.arch),
 			Inputs: []libvirtxml.DomainInput{
-				{Type: "tablet", Bus: "usb"},
+				{Type: "tablet", Bus: archSettings.inputBus},
 			},
 			Graphics: []libvirtxml.DomainGraphic{
 				{VNC: &libvirtxml.DomainGraphicVNC{Port: -1}},
 			},
 			Videos: []libvirtxml.DomainVideo{
-				{Model: libvirtxml.DomainVideoModel{Type: "cirrus"}},
+				{Model: libvirtxml.DomainVideoModel{Type: archSettings.videoModel}},
 			},
 			Controllers: []libvirtxml.DomainController{
 				{Type: "scsi", Index: &scsiControllerIndex, Model: "virtio-scsi"},
@@ -110,13 +146,11 @@ func (ds *domainSettings) createDomain(config *types.VMConfig) *libvirtxml.Domai
 		},
 
 		OS: &libvirtxml.DomainOS{
-			Type: &libvirtxml.DomainOSType{Type: "hvm"},
-			BootDevices: []libvirtxml.DomainBootDevice{
-				{Dev: "hd"},
-			},
+			Type:        &libvirtxml.DomainOSType{Type: "hvm", Arch: osArch},
+			BootDevices: bootDevices(config),
 		},
 
-		Features: &libvirtxml.DomainFeatureList{ACPI: &libvirtxml.DomainFeature{}},
+		Features: &libvirtxml.DomainFeatureList{},
 
 		OnPoweroff: "destroy",
 		OnReboot:   "restart",
@@ -154,11 +188,26 @@ func (ds *domainSettings) createDomain(config *types.VMConfig) *libvirtxml.Domai
 		},
 	}
 
+	if archSettings.acpi {
+		domain.Features.ACPI = &libvirtxml.DomainFeature{}
+	}
+
 	// Set cpu model.
 	// If user understand the cpu definition of libvirt,
 	// the user is very professional, we prior to use it.
 	if config.ParsedAnnotations.CPUSetting != nil {
 		domain.CPU = config.ParsedAnnotations.CPUSetting
+	} else if ds.arch != hostArch {
+		// host CPU model can't be used for the guests
+		// emulated using TCG
+		if archSettings.tcgCPUModel != "" {
+			domain.CPU = &libvirtxml.DomainCPU{
+				Mode: "custom",
+				Model: &libvirtxml.DomainCPUModel{
+					Value: archSettings.tcgCPUModel,
+				},
+			}
+		}
 	} else {
 		switch ds.cpuModel {
 		case types.CPUModelHostModel:
@@ -185,6 +234,23 @@ func (ds *domainSettings) createDomain(config *types.VMConfig) *libvirtxml.Domai
 		}
 	}
 
+	// Topology specified in VirtletLibvirtCPUSetting takes precedence.
+	if topology := config.ParsedAnnotations.VCPUTopology; topology != nil {
+		cpu := libvirtxml.DomainCPU{}
+		if domain.CPU != nil {
+			// don't modify the parsed annotations
+			cpu = *domain.CPU
+		}
+		if cpu.Topology == nil {
+			cpu.Topology = &libvirtxml.DomainCPUTopology{
+				Sockets: topology.Sockets,
+				Cores:   topology.Cores,
+				Threads: topology.Threads,
+			}
+		}
+		domain.CPU = &cpu
+	}
+
 	if ds.systemUUID != nil {
 		domain.SysInfo = &libvirtxml.DomainSysInfo{
 			Type: "smbios",
@@ -199,6 +265,18 @@ func (ds *domainSettings) createDomain(config *types.VMConfig) *libvirtxml.Domai
 		}
 	}
 
+	setMachineType(domain, config)
+	setFirmware(domain, config)
+	setTPM(domain, config)
+	setHugePages(domain, config)
+	setGuestAgentChannel(domain, config)
+
+	if ds.kernelPath != "" {
+		domain.OS.Kernel = ds.kernelPath
+		domain.OS.Initrd = ds.initrdPath
+		domain.OS.Cmdline = config.ParsedAnnotations.KernelCmdline
+	}
+
 	if ds.enableSriov {
 		domain.QEMUCommandline.Envs = append(domain.QEMUCommandline.Envs,
 			libvirtxml.DomainQEMUCommandlineEnv{Name: "VMWRAPPER_KEEP_PRIVS", Value: "1"})
@@ -207,6 +285,23 @@ func (ds *domainSettings) createDomain(config *types.VMConfig) *libvirtxml.Domai
 	return domain
 }
 
+// setGuestAgentChannel adds the virtio channel for QEMU guest agent
+// to the VMs that have it enabled. The guest agent is used to quiesce
+// the guest before committing its root disk.
+func setGuestAgentChannel(domain *libvirtxml.Domain, config *types.VMConfig) {
+	if !config.ParsedAnnotations.GuestAgent {
+		return
+	}
+	domain.Devices.Channels = append(domain.Devices.Channels, libvirtxml.DomainChannel{
+		Source: &libvirtxml.DomainChardevSource{
+			UNIX: &libvirtxml.DomainChardevSourceUNIX{Mode: "bind"},
+		},
+		Target: &libvirtxml.DomainChannelTarget{
+			VirtIO: &libvirtxml.DomainChannelTargetVirtIO{Name: guestAgentChannelName},
+		},
+	})
+}
+
 // VirtualizationConfig specifies configuration options for VirtualizationTool.
 type VirtualizationConfig struct {
 	// True if KVM should be disabled
@@ -349,21 +444,44 @@ func (v *VirtualizationTool) CreateContainer(config *types.VMConfig, netFdKey st
 		useKvm:     !v.config.DisableKVM,
 		cpuModel:   cpuModel,
 		systemUUID: config.ParsedAnnotations.SystemUUID,
+		arch:       archForConfig(config),
 	}
 	if settings.memory == 0 {
 		settings.memory = defaultMemory
 		settings.memoryUnit = defaultMemoryUnit
 	}
 
+	if err := checkHugePages(config); err != nil {
+		return "", err
+	}
+
+	if err := prepareNVRAMDir(config); err != nil {
+		return "", err
+	}
+
+	var err error
+	if settings.kernelPath, settings.initrdPath, err = v.setupBootFiles(config); err != nil {
+		return "", err
+	}
+
+	if err := v.prepareTPMState(config); err != nil {
+		v.removeBootFiles(domainUUID)
+		return "", err
+	}
+
 	domainDef := settings.createDomain(config)
 	diskList, err := newDiskList(config, v.volumeSource, v)
-	if err != nil {
-		return "", err
+	if err == nil {
+		domainDef.Devices.Disks, domainDef.Devices.Filesystems, err = diskList.setup()
 	}
-	domainDef.Devices.Disks, domainDef.Devices.Filesystems, err = diskList.setup()
 	if err != nil {
+		v.removeBootFiles(domainUUID)
+		if err := removeTPMState(domainUUID); err != nil {
+			glog.Warningf("Error removing TPM state after an error: %v", err)
+		}
 		return "", err
 	}
+	setupPCIeTopology(domainDef, config)
 
 	ok := false
 	defer func() {
@@ -663,6 +781,15 @@ func (v *VirtualizationTool) removeDomain(containerID string, config *types.VMCo
 		}
 	}
 
+	v.removeBootFiles(containerID)
+
+	if err := removeTPMState(containerID); err != nil {
+		if failUponVolumeTeardownFailure {
+			return err
+		}
+		glog.Warningf("Error removing TPM state for container %s: %v", containerID, err)
+	}
+
 	diskList, err := newDiskList(config, v.volumeSource, v)
 	if err == nil {
 		err = diskList.teardown()
@@ -960,6 +1087,49 @@ func (v *VirtualizationTool) ListVMStats(filter *types.VMStatsFilter) ([]types.V
 	return statsList, nil
 }
 
+// CommitRootVolume writes the root volume of the specified container
+// flattened together with its backing image to targetPath. If
+// quiesce is true, the guest filesystems are frozen using the guest
+// agent while the volume is being copied.
+func (v *VirtualizationTool) CommitRootVolume(containerID, targetPath string, quiesce bool) error {
+	config, _, err := v.getVMConfigFromMetadata(containerID)
+	if err != nil {
+		return err
+	}
+	if config == nil {
+		return fmt.Errorf("container %q not found", containerID)
+	}
+
+	vols, err := GetRootVolume(config, v)
+	if err != nil {
+		return err
+	}
+	rootVol, ok := vols[0].(*rootVolume)
+	if !ok {
+		return fmt.Errorf("can't commit container %q: committing persistent root filesystems is not supported", containerID)
+	}
+
+	if quiesce {
+		if config.ParsedAnnotations == nil || !config.ParsedAnnotations.GuestAgent {
+			return fmt.Errorf("can't quiesce container %q: the guest agent channel isn't enabled for the VM, use %q annotation to enable it", containerID, "VirtletGuestAgent")
+		}
+		domain, err := v.domainConn.LookupDomainByUUIDString(containerID)
+		if err != nil {
+			return fmt.Errorf("failed to look up domain %q: %v", containerID, err)
+		}
+		if err := domain.FSFreeze(); err != nil {
+			return fmt.Errorf("failed to freeze the filesystems of domain %q (is the guest agent running?): %v", containerID, err)
+		}
+		defer func() {
+			if err := domain.FSThaw(); err != nil {
+				glog.Errorf("Failed to thaw the filesystems of domain %q: %v", containerID, err)
+			}
+		}()
+	}
+
+	return rootVol.commit(targetPath)
+}
+
 // volumeOwner implementation follows
 
 // StoragePool implements volumeOwner StoragePool method
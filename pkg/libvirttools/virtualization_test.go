@@ -20,12 +20,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strconv"
 	"testing"
 	"time"
 
+	cnitypes "github.com/containernetworking/cni/pkg/types"
+	cnicurrent "github.com/containernetworking/cni/pkg/types/current"
 	"github.com/jonboulle/clockwork"
 	v1 "k8s.io/api/core/v1"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -38,6 +42,7 @@ import (
 	"github.com/Mirantis/virtlet/pkg/metadata"
 	fakemeta "github.com/Mirantis/virtlet/pkg/metadata/fake"
 	"github.com/Mirantis/virtlet/pkg/metadata/types"
+	"github.com/Mirantis/virtlet/pkg/network"
 	"github.com/Mirantis/virtlet/pkg/utils"
 	fakeutils "github.com/Mirantis/virtlet/pkg/utils/fake"
 	testutils "github.com/Mirantis/virtlet/pkg/utils/testing"
@@ -80,6 +85,8 @@ func newContainerTester(t *testing.T, rec *testutils.TopLevelRecorder, cmds []fa
 
 	// __config__  is a hint for fake libvirt domain to fix the path so it becomes non-volatile
 	SetConfigIsoDir(filepath.Join(ct.tmpDir, "__config__"))
+	SetNVRAMDir(filepath.Join(ct.tmpDir, "__nvram__"))
+	SetTPMStateDir(filepath.Join(ct.tmpDir, "__tpm__"))
 
 	ct.rec = rec
 	ct.domainConn = fake.NewFakeDomainConnection(ct.rec.Child("domain conn"))
@@ -129,7 +136,7 @@ func (ct *containerTester) teardown() {
 	os.RemoveAll(ct.tmpDir)
 }
 
-func (ct *containerTester) createContainer(sandbox *types.PodSandboxConfig, mounts []types.VMMount, volDevs []types.VMVolumeDevice) string {
+func (ct *containerTester) createContainer(sandbox *types.PodSandboxConfig, mounts []types.VMMount, volDevs []types.VMVolumeDevice, csn *network.ContainerSideNetwork) string {
 	vmConfig := &types.VMConfig{
 		PodSandboxID:         sandbox.Uid,
 		PodName:              sandbox.Name,
@@ -141,6 +148,7 @@ func (ct *containerTester) createContainer(sandbox *types.PodSandboxConfig, moun
 		ContainerAnnotations: map[string]string{"foo": "bar"},
 		Mounts:               mounts,
 		VolumeDevices:        volDevs,
+		ContainerSideNetwork: csn,
 		LogDirectory:         fmt.Sprintf("/var/log/pods/%s", sandbox.Uid),
 		LogPath:              fmt.Sprintf("%s_%d.log", fakeContainerName, fakeContainerAttempt),
 	}
@@ -209,7 +217,7 @@ func TestContainerLifecycle(t *testing.T) {
 		t.Errorf("Unexpected containers when no containers are started: %#v", containers)
 	}
 
-	containerID := ct.createContainer(sandbox, nil, nil)
+	containerID := ct.createContainer(sandbox, nil, nil, nil)
 
 	containers = ct.listContainers(nil)
 	if len(containers) != 1 {
@@ -286,7 +294,7 @@ func TestDomainForcedShutdown(t *testing.T) {
 	sandbox := fakemeta.GetSandboxes(1)[0]
 	ct.setPodSandbox(sandbox)
 
-	containerID := ct.createContainer(sandbox, nil, nil)
+	containerID := ct.createContainer(sandbox, nil, nil, nil)
 	ct.clock.Advance(1 * time.Second)
 	ct.startContainer(containerID)
 
@@ -314,6 +322,46 @@ func TestDomainForcedShutdown(t *testing.T) {
 	gm.Verify(t, gm.NewYamlVerifier(ct.rec.Content()))
 }
 
+func TestCommitRootVolume(t *testing.T) {
+	ct := newContainerTester(t, testutils.NewToplevelRecorder(), []fakeutils.CmdSpec{
+		{Match: "^qemu-img convert -U -O qcow2 "},
+	}, nil)
+	defer ct.teardown()
+
+	sandbox := fakemeta.GetSandboxes(1)[0]
+	sandbox.Annotations["VirtletGuestAgent"] = "true"
+	ct.setPodSandbox(sandbox)
+
+	containerID := ct.createContainer(sandbox, nil, nil, nil)
+	ct.clock.Advance(1 * time.Second)
+	ct.startContainer(containerID)
+
+	ct.rec.Rec("invoking CommitRootVolume()", nil)
+	if err := ct.virtTool.CommitRootVolume(containerID, "/fake/committed-image", true); err != nil {
+		t.Fatalf("CommitRootVolume(): %v", err)
+	}
+	if err := ct.virtTool.CommitRootVolume("nosuchcontainer", "/fake/committed-image", false); err == nil {
+		t.Errorf("CommitRootVolume() didn't return an error for a nonexistent container")
+	}
+	gm.Verify(t, gm.NewYamlVerifier(ct.rec.Content()))
+}
+
+func TestCommitRootVolumeQuiesceWithoutGuestAgent(t *testing.T) {
+	ct := newContainerTester(t, testutils.NewToplevelRecorder(), nil, nil)
+	defer ct.teardown()
+
+	sandbox := fakemeta.GetSandboxes(1)[0]
+	ct.setPodSandbox(sandbox)
+
+	containerID := ct.createContainer(sandbox, nil, nil, nil)
+	ct.clock.Advance(1 * time.Second)
+	ct.startContainer(containerID)
+
+	if err := ct.virtTool.CommitRootVolume(containerID, "/fake/committed-image", true); err == nil {
+		t.Errorf("CommitRootVolume() didn't return an error for quiescing a VM without the guest agent channel")
+	}
+}
+
 func TestDoubleStartError(t *testing.T) {
 	ct := newContainerTester(t, testutils.NewToplevelRecorder(), nil, nil)
 	defer ct.teardown()
@@ -321,7 +369,7 @@ func TestDoubleStartError(t *testing.T) {
 	sandbox := fakemeta.GetSandboxes(1)[0]
 	ct.setPodSandbox(sandbox)
 
-	containerID := ct.createContainer(sandbox, nil, nil)
+	containerID := ct.createContainer(sandbox, nil, nil, nil)
 	ct.clock.Advance(1 * time.Second)
 	ct.startContainer(containerID)
 	if err := ct.virtTool.StartContainer(containerID); err == nil {
@@ -354,6 +402,7 @@ func TestDomainDefinitions(t *testing.T) {
 		volDevs     []volDevice
 		cmds        []fakeutils.CmdSpec
 		objects     []runtime.Object
+		csn         *network.ContainerSideNetwork
 	}{
 		{
 			name: "plain domain",
@@ -390,6 +439,18 @@ func TestDomainDefinitions(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "installer iso with data volume",
+			annotations: map[string]string{
+				"VirtletImageType":      "iso",
+				"VirtletRootVolumeSize": "1Gi",
+			},
+			flexVolumes: map[string]map[string]interface{}{
+				"vol1": {
+					"type": "qcow2",
+				},
+			},
+		},
 		{
 			name: "vcpu count",
 			annotations: map[string]string{
@@ -539,6 +600,49 @@ func TestDomainDefinitions(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "q35 with network interface and scsi disks",
+			annotations: map[string]string{
+				"VirtletMachineType": "q35",
+			},
+			csn: &network.ContainerSideNetwork{
+				Result: &cnicurrent.Result{
+					Interfaces: []*cnicurrent.Interface{
+						{
+							Name:    "cni0",
+							Mac:     "00:11:22:33:44:55",
+							Sandbox: "/var/run/netns/bae464f1-6ee7-4ee2-826e-33293a9de95e",
+						},
+					},
+					IPs: []*cnicurrent.IPConfig{
+						{
+							Version: "4",
+							Address: net.IPNet{
+								IP:   net.IPv4(1, 1, 1, 1),
+								Mask: net.CIDRMask(8, 32),
+							},
+							Gateway:   net.IPv4(1, 2, 3, 4),
+							Interface: 0,
+						},
+					},
+					Routes: []*cnitypes.Route{
+						{
+							Dst: net.IPNet{
+								IP:   net.IPv4zero,
+								Mask: net.CIDRMask(0, 32),
+							},
+						},
+					},
+				},
+				Interfaces: []*network.InterfaceDescription{
+					{
+						Type:         network.InterfaceTypeTap,
+						HardwareAddr: net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55},
+						MTU:          1500,
+					},
+				},
+			},
+		},
 		// TODO: add test cases for rootfs / persistent rootfs file injection
 	} {
 		t.Run(tc.name, func(t *testing.T) {
@@ -604,7 +708,7 @@ func TestDomainDefinitions(t *testing.T) {
 				types.SetExternalDataLoader(&defaultExternalDataLoader{kubeClient: fc})
 			}
 
-			containerID := ct.createContainer(sandbox, mounts, volDevs)
+			containerID := ct.createContainer(sandbox, mounts, volDevs, tc.csn)
 
 			// startContainer will cause fake Domain
 			// to dump the cloudinit iso content
@@ -653,3 +757,44 @@ func TestDomainResourceConstraints(t *testing.T) {
 
 	gm.Verify(t, gm.NewYamlVerifier(ct.rec.Content()))
 }
+
+func TestBootDevices(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		annotations *types.VirtletAnnotations
+		expected    []string
+	}{
+		{
+			name:     "no annotations",
+			expected: []string{"hd"},
+		},
+		{
+			name:        "qcow2 image",
+			annotations: &types.VirtletAnnotations{ImageType: types.ImageTypeQCOW2},
+			expected:    []string{"hd"},
+		},
+		{
+			name:        "iso image",
+			annotations: &types.VirtletAnnotations{ImageType: types.ImageTypeISO},
+			expected:    []string{"hd", "cdrom"},
+		},
+		{
+			name: "explicit boot order",
+			annotations: &types.VirtletAnnotations{
+				ImageType: types.ImageTypeISO,
+				BootOrder: []types.BootDevice{types.BootDeviceCDROM},
+			},
+			expected: []string{"cdrom"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var devs []string
+			for _, d := range bootDevices(&types.VMConfig{ParsedAnnotations: tc.annotations}) {
+				devs = append(devs, d.Dev)
+			}
+			if !reflect.DeepEqual(devs, tc.expected) {
+				t.Errorf("bad boot devices: %v instead of %v", devs, tc.expected)
+			}
+		})
+	}
+}
@@ -17,11 +17,16 @@ limitations under the License.
 package manager
 
 import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
 	"github.com/jonboulle/clockwork"
 	"golang.org/x/net/context"
 	kubeapi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
 
 	"github.com/Mirantis/virtlet/pkg/image"
+	"github.com/Mirantis/virtlet/pkg/metadata/types"
 )
 
 // VirtletImageService handles CRI image service calls.
@@ -71,16 +76,89 @@ func (v *VirtletImageService) ImageStatus(ctx context.Context, in *kubeapi.Image
 // PullImage method implements PullImage from CRI.
 func (v *VirtletImageService) PullImage(ctx context.Context, in *kubeapi.PullImageRequest) (*kubeapi.PullImageResponse, error) {
 	imageName := in.GetImage().GetImage()
+	creds, err := authConfigToCredentials(in.GetAuth())
+	if err != nil {
+		return nil, err
+	}
 
-	ref, err := v.imageStore.PullImage(ctx, imageName, v.imageTranslator)
+	translator := image.TranslatorWithCredentials(v.imageTranslator, creds)
+	ref, err := v.imageStore.PullImage(ctx, imageName, translator)
 	if err != nil {
 		return nil, err
 	}
 
+	// the additional images used by the pod are pulled along
+	// with the VM image, using the same credentials
+	if err := v.pullPodImages(ctx, in.GetSandboxConfig().GetAnnotations(), translator); err != nil {
+		return nil, err
+	}
+
 	response := &kubeapi.PullImageResponse{ImageRef: ref}
 	return response, nil
 }
 
+// podImageNames returns the names of the additional images used by
+// the pod besides its VM image, that is, the images for the image
+// disks followed by the kernel and initrd images, if any.
+func podImageNames(podAnnotations map[string]string) ([]string, error) {
+	imageDisks, err := types.ParseImageDisks(podAnnotations)
+	if err != nil {
+		return nil, err
+	}
+	kernel, initrd, err := types.ParseBootFileSources(podAnnotations)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, disk := range imageDisks {
+		names = append(names, disk.Image)
+	}
+	for _, src := range []*types.BootFileSource{kernel, initrd} {
+		if src != nil && src.Kind == types.BootFileSourceImage {
+			names = append(names, src.Name)
+		}
+	}
+	return names, nil
+}
+
+// pullPodImages pulls the additional images used by the pod unless
+// they're already present in the image store.
+func (v *VirtletImageService) pullPodImages(ctx context.Context, podAnnotations map[string]string, translator image.Translator) error {
+	names, err := podImageNames(podAnnotations)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		img, err := v.imageStore.ImageStatus(name)
+		switch {
+		case err != nil:
+			return err
+		case img != nil:
+			continue
+		}
+		if _, err := v.imageStore.PullImage(ctx, name, translator); err != nil {
+			return fmt.Errorf("error pulling image %q: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// EnsureImage implements EnsureImage method of ImagePuller interface.
+// The images are normally pulled by PullImage along with the VM
+// image, but kubelet doesn't call PullImage if the VM image is
+// already present on the node, so the missing images are pulled
+// here without any credentials.
+func (v *VirtletImageService) EnsureImage(ctx context.Context, name string) (string, error) {
+	img, err := v.imageStore.ImageStatus(name)
+	switch {
+	case err != nil:
+		return "", err
+	case img != nil:
+		return img.Name + "@" + img.Digest, nil
+	}
+	return v.imageStore.PullImage(ctx, name, v.imageTranslator)
+}
+
 // RemoveImage method implements RemoveImage from CRI.
 func (v *VirtletImageService) RemoveImage(ctx context.Context, in *kubeapi.RemoveImageRequest) (*kubeapi.RemoveImageResponse, error) {
 	imageName := in.GetImage().GetImage()
@@ -120,3 +198,35 @@ func imageToKubeapi(img *image.Image) *kubeapi.Image {
 		Size_:    img.Size,
 	}
 }
+
+// authConfigToCredentials converts the credentials passed by kubelet
+// from the image pull secrets to the image server credentials.
+// Registry tokens and identity tokens are used as bearer tokens.
+func authConfigToCredentials(auth *kubeapi.AuthConfig) (*image.Credentials, error) {
+	if auth == nil {
+		return nil, nil
+	}
+	creds := &image.Credentials{
+		Username:    auth.GetUsername(),
+		Password:    auth.GetPassword(),
+		BearerToken: auth.GetRegistryToken(),
+	}
+	if creds.BearerToken == "" {
+		creds.BearerToken = auth.GetIdentityToken()
+	}
+	if creds.Username == "" && creds.Password == "" && auth.GetAuth() != "" {
+		decoded, err := base64.StdEncoding.DecodeString(auth.GetAuth())
+		if err != nil {
+			return nil, fmt.Errorf("error decoding the auth string: %v", err)
+		}
+		parts := strings.SplitN(string(decoded), ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("bad auth string: expected username:password")
+		}
+		creds.Username, creds.Password = parts[0], parts[1]
+	}
+	if creds.Username == "" && creds.Password == "" && creds.BearerToken == "" {
+		return nil, nil
+	}
+	return creds, nil
+}
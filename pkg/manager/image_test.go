@@ -17,9 +17,16 @@ limitations under the License.
 package manager
 
 import (
+	"context"
+	"reflect"
+	"strings"
 	"testing"
 
 	kubeapi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
+
+	"github.com/Mirantis/virtlet/pkg/image"
+	fakeimage "github.com/Mirantis/virtlet/pkg/image/fake"
+	testutils "github.com/Mirantis/virtlet/pkg/utils/testing"
 )
 
 func TestCRIImages(t *testing.T) {
@@ -39,3 +46,126 @@ func TestCRIImages(t *testing.T) {
 	tst.imageFsInfo(&kubeapi.ImageFsInfoRequest{})
 	tst.verify()
 }
+
+func TestEnsureImage(t *testing.T) {
+	tst := makeVirtletCRITester(t)
+	defer tst.teardown()
+	imageService := tst.handler.VirtletImageService
+	ref, err := imageService.EnsureImage(context.Background(), "example.com/dataset")
+	if err != nil {
+		t.Fatalf("EnsureImage(): %v", err)
+	}
+	if !strings.HasPrefix(ref, "example.com/dataset@sha256:") {
+		t.Errorf("bad image ref %q", ref)
+	}
+	secondRef, err := imageService.EnsureImage(context.Background(), "example.com/dataset")
+	switch {
+	case err != nil:
+		t.Errorf("EnsureImage(): %v", err)
+	case secondRef != ref:
+		t.Errorf("EnsureImage() returned %q for the image that's already present instead of %q", secondRef, ref)
+	}
+}
+
+func TestAuthConfigToCredentials(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		auth          *kubeapi.AuthConfig
+		expectedCreds *image.Credentials
+		expectError   bool
+	}{
+		{
+			name: "no auth config",
+		},
+		{
+			name: "empty auth config",
+			auth: &kubeapi.AuthConfig{ServerAddress: "virtlet.cloud"},
+		},
+		{
+			name:          "username and password",
+			auth:          &kubeapi.AuthConfig{Username: "user", Password: "secret"},
+			expectedCreds: &image.Credentials{Username: "user", Password: "secret"},
+		},
+		{
+			name: "auth string",
+			// user:sec:ret
+			auth:          &kubeapi.AuthConfig{Auth: "dXNlcjpzZWM6cmV0"},
+			expectedCreds: &image.Credentials{Username: "user", Password: "sec:ret"},
+		},
+		{
+			name:        "bad auth string",
+			auth:        &kubeapi.AuthConfig{Auth: "dXNlcg=="},
+			expectError: true,
+		},
+		{
+			name:          "registry token",
+			auth:          &kubeapi.AuthConfig{RegistryToken: "regtoken", IdentityToken: "idtoken"},
+			expectedCreds: &image.Credentials{BearerToken: "regtoken"},
+		},
+		{
+			name:          "identity token",
+			auth:          &kubeapi.AuthConfig{IdentityToken: "idtoken"},
+			expectedCreds: &image.Credentials{BearerToken: "idtoken"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			creds, err := authConfigToCredentials(tc.auth)
+			switch {
+			case err != nil && !tc.expectError:
+				t.Errorf("authConfigToCredentials(): %v", err)
+			case err == nil && tc.expectError:
+				t.Errorf("authConfigToCredentials() didn't return the expected error")
+			case !reflect.DeepEqual(creds, tc.expectedCreds):
+				t.Errorf("bad credentials: %#v instead of %#v", creds, tc.expectedCreds)
+			}
+		})
+	}
+}
+
+// credsRecordingStore is a fake image store that records the
+// credentials used to pull the images.
+type credsRecordingStore struct {
+	*fakeimage.FakeStore
+	creds map[string]*image.Credentials
+}
+
+func (s *credsRecordingStore) PullImage(ctx context.Context, name string, translator image.Translator) (string, error) {
+	s.creds[name] = translator(ctx, name).Credentials
+	return s.FakeStore.PullImage(ctx, name, translator)
+}
+
+func TestPullPodImages(t *testing.T) {
+	imageStore := &credsRecordingStore{
+		FakeStore: fakeimage.NewFakeStore(testutils.NewToplevelRecorder()),
+		creds:     make(map[string]*image.Credentials),
+	}
+	imageService := NewVirtletImageService(imageStore, translateImageName, nil)
+	if _, err := imageService.PullImage(context.Background(), &kubeapi.PullImageRequest{
+		Image: &kubeapi.ImageSpec{Image: "example.com/cirros"},
+		Auth:  &kubeapi.AuthConfig{Username: "user", Password: "secret"},
+		SandboxConfig: &kubeapi.PodSandboxConfig{
+			Annotations: map[string]string{
+				"VirtletImageDisks": "- image: example.com/private",
+				"VirtletKernel":     "image/example.com/vmlinuz",
+			},
+		},
+	}); err != nil {
+		t.Fatalf("PullImage(): %v", err)
+	}
+	expectedCreds := &image.Credentials{Username: "user", Password: "secret"}
+	for _, name := range []string{"example.com/cirros", "example.com/private", "example.com/vmlinuz"} {
+		if creds := imageStore.creds[name]; !reflect.DeepEqual(creds, expectedCreds) {
+			t.Errorf("bad credentials for %q: %#v instead of %#v", name, creds, expectedCreds)
+		}
+	}
+
+	// the image disk image is already present, so creating the
+	// VM doesn't pull it again
+	delete(imageStore.creds, "example.com/private")
+	if _, err := imageService.EnsureImage(context.Background(), "example.com/private"); err != nil {
+		t.Fatalf("EnsureImage(): %v", err)
+	}
+	if _, found := imageStore.creds["example.com/private"]; found {
+		t.Errorf("EnsureImage() pulled the image that's already present")
+	}
+}
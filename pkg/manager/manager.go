@@ -22,6 +22,7 @@ import (
 	"time"
 
 	"github.com/golang/glog"
+	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/client-go/tools/clientcmd"
 
 	"github.com/Mirantis/virtlet/pkg/api/virtlet.k8s/v1"
@@ -45,6 +46,10 @@ const (
 	streamerSocketPath        = "/var/lib/libvirt/streamer.sock"
 	volumePoolName            = "volumes"
 	virtletSharedFsDir        = "/var/lib/virtlet/fs"
+
+	// ImageServerSocketPath is the path to the socket that's
+	// used for importing and exporting the images.
+	ImageServerSocketPath = "/run/virtlet-image.sock"
 )
 
 // VirtletManager wraps the Virtlet's Runtime and Image CRI services,
@@ -55,16 +60,20 @@ type VirtletManager struct {
 	fdManager      tapmanager.FDManager
 	diagSet        *diag.Set
 	clientCfg      clientcmd.ClientConfig
+	nodeName       string
 	virtTool       *libvirttools.VirtualizationTool
 	imageStore     image.Store
+	imageServer    *image.Server
 	runtimeService *VirtletRuntimeService
 	imageService   *VirtletImageService
 	server         *Server
 }
 
-// NewVirtletManager creates a new VirtletManager.
-func NewVirtletManager(config *v1.VirtletConfig, fdManager tapmanager.FDManager, clientCfg clientcmd.ClientConfig, diagSet *diag.Set) *VirtletManager {
-	return &VirtletManager{config: config, fdManager: fdManager, diagSet: diagSet, clientCfg: clientCfg}
+// NewVirtletManager creates a new VirtletManager. nodeName is the
+// name of the Kubernetes node Virtlet runs on, which is used when
+// reporting the status of the VirtletImageMappings. It can be empty.
+func NewVirtletManager(config *v1.VirtletConfig, fdManager tapmanager.FDManager, clientCfg clientcmd.ClientConfig, nodeName string, diagSet *diag.Set) *VirtletManager {
+	return &VirtletManager{config: config, fdManager: fdManager, diagSet: diagSet, clientCfg: clientCfg, nodeName: nodeName}
 }
 
 // Run sets up the environment for the runtime and image services and
@@ -92,13 +101,39 @@ func (v *VirtletManager) Run() error {
 	}
 	v.diagSet.RegisterDiagSource("metadata", metadata.GetMetadataDumpSource(v.metadataStore))
 
-	downloader := image.NewDownloader(*v.config.DownloadProtocol)
-	v.imageStore = image.NewFileStore(*v.config.ImageDir, downloader, nil)
-	v.imageStore.SetRefGetter(v.metadataStore.ImagesInUse)
+	downloadLimits := image.DownloadLimits{
+		MaxConcurrentDownloads: *v.config.MaxConcurrentImageDownloads,
+	}
+	if *v.config.ImageDownloadRateLimit != "" {
+		rateLimit, err := resource.ParseQuantity(*v.config.ImageDownloadRateLimit)
+		if err != nil {
+			return fmt.Errorf("bad image download rate limit %q: %v", *v.config.ImageDownloadRateLimit, err)
+		}
+		downloadLimits.MaxBytesPerSecond = rateLimit.Value()
+	}
+	downloader := image.NewLimitedDownloader(*v.config.DownloadProtocol, downloadLimits)
+	imageStore := image.NewFileStore(*v.config.ImageDir, downloader, nil)
+	imageStore.SetRefGetter(v.metadataStore.ImagesInUse)
+	if *v.config.ImageStoreSizeLimit != "" {
+		sizeLimit, err := resource.ParseQuantity(*v.config.ImageStoreSizeLimit)
+		if err != nil {
+			return fmt.Errorf("bad image store size limit %q: %v", *v.config.ImageStoreSizeLimit, err)
+		}
+		if *v.config.ImageStoreLowWatermark > *v.config.ImageStoreHighWatermark {
+			return fmt.Errorf("image store low watermark (%d%%) is above the high watermark (%d%%)", *v.config.ImageStoreLowWatermark, *v.config.ImageStoreHighWatermark)
+		}
+		imageStore.SetSizeLimit(image.SizeLimit{
+			MaxBytes:      uint64(sizeLimit.Value()),
+			HighWatermark: *v.config.ImageStoreHighWatermark,
+			LowWatermark:  *v.config.ImageStoreLowWatermark,
+		})
+	}
+	v.imageStore = imageStore
+	v.diagSet.RegisterDiagSource(image.PullProgressDiagSourceName, image.GetPullProgressSource(v.imageStore))
 
 	var translator image.Translator
 	if !*v.config.SkipImageTranslation {
-		translator = imagetranslation.GetDefaultImageTranslator(*v.config.ImageTranslationConfigsDir, *v.config.EnableRegexpImageTranslation, v.clientCfg)
+		translator = imagetranslation.GetDefaultImageTranslator(*v.config.ImageTranslationConfigsDir, *v.config.EnableRegexpImageTranslation, v.clientCfg, v.nodeName)
 	} else {
 		translator = imagetranslation.GetEmptyImageTranslator()
 	}
@@ -142,8 +177,8 @@ func (v *VirtletManager) Run() error {
 		conn, conn, v.imageStore, v.metadataStore, volSrc, virtConfig,
 		fs.RealFileSystem, utils.DefaultCommander)
 
-	runtimeService := NewVirtletRuntimeService(v.virtTool, v.metadataStore, v.fdManager, streamServer, v.imageStore, nil)
 	imageService := NewVirtletImageService(v.imageStore, translator, nil)
+	runtimeService := NewVirtletRuntimeService(v.virtTool, v.metadataStore, v.fdManager, streamServer, v.imageStore, imageService, nil)
 
 	v.server = NewServer()
 	v.server.Register(runtimeService, imageService)
@@ -156,6 +191,19 @@ func (v *VirtletManager) Run() error {
 		glog.Warning(err)
 	}
 
+	if *v.config.ImagePreloadDir != "" {
+		if err := imageStore.PreloadImages(*v.config.ImagePreloadDir); err != nil {
+			glog.Warningf("Error preloading images: %v", err)
+		}
+	}
+
+	v.imageServer = image.NewServer(v.imageStore)
+	v.imageServer.SetRootVolumeCommitter(v.virtTool.CommitRootVolume)
+	go func() {
+		err := v.imageServer.Serve(ImageServerSocketPath, nil)
+		glog.V(1).Infof("Image server returned: %v", err)
+	}()
+
 	glog.V(1).Infof("Starting server on socket %s", *v.config.CRISocketPath)
 	if err = v.server.Serve(*v.config.CRISocketPath); err != nil {
 		return fmt.Errorf("serving failed: %v", err)
@@ -169,6 +217,9 @@ func (v *VirtletManager) Stop() {
 	if v.server != nil {
 		v.server.Stop()
 	}
+	if v.imageServer != nil {
+		v.imageServer.Stop()
+	}
 }
 
 // recoverAndGC performs the initial actions during VirtletManager
@@ -240,13 +291,50 @@ OUTER:
 			}
 		}
 
+		nicModels, err := types.ParseNICModels(psi.Config.Annotations)
+		if err != nil {
+			allErrors = append(allErrors, fmt.Errorf("can't get NIC models for pod %q: %v", s.GetID(), err))
+			continue
+		}
+
+		netQueueCount, err := types.ParseNetQueueCount(psi.Config.Annotations)
+		if err != nil {
+			allErrors = append(allErrors, fmt.Errorf("can't get net queue count for pod %q: %v", s.GetID(), err))
+			continue
+		}
+
+		networkMode, err := types.ParseNetworkMode(psi.Config.Annotations)
+		if err != nil {
+			allErrors = append(allErrors, fmt.Errorf("can't get network mode for pod %q: %v", s.GetID(), err))
+			continue
+		}
+
+		ingressBandwidth, egressBandwidth, err := types.ParseBandwidthLimits(psi.Config.Annotations)
+		if err != nil {
+			allErrors = append(allErrors, fmt.Errorf("can't get bandwidth limits for pod %q: %v", s.GetID(), err))
+			continue
+		}
+
+		hwAddrs, stableHwAddrs, err := types.ParseMACAddresses(psi.Config.Annotations)
+		if err != nil {
+			allErrors = append(allErrors, fmt.Errorf("can't get MAC addresses for pod %q: %v", s.GetID(), err))
+			continue
+		}
+
 		if err := v.fdManager.Recover(
 			s.GetID(),
 			tapmanager.RecoverPayload{
 				Description: &tapmanager.PodNetworkDesc{
-					PodID:   s.GetID(),
-					PodNs:   psi.Config.Namespace,
-					PodName: psi.Config.Name,
+					PodID:               s.GetID(),
+					PodNs:               psi.Config.Namespace,
+					PodName:             psi.Config.Name,
+					NICModels:           nicModels,
+					NetQueueCount:       netQueueCount,
+					NetworkMode:         networkMode,
+					IngressBandwidth:    ingressBandwidth,
+					EgressBandwidth:     egressBandwidth,
+					HardwareAddrs:       hwAddrs,
+					StableHardwareAddrs: stableHwAddrs,
 				},
 				ContainerSideNetwork:  psi.ContainerSideNetwork,
 				HaveRunningContainers: haveRunningContainers,
@@ -47,9 +47,19 @@ type StreamServer interface {
 	GetPortForward(req *kubeapi.PortForwardRequest) (*kubeapi.PortForwardResponse, error)
 }
 
-// GCHandler performs GC when a container is deleted.
+// GCHandler performs GC when a container is deleted and keeps
+// track of image usage for the image eviction.
 type GCHandler interface {
 	GC() error
+	MarkImageUsed(ref string) error
+}
+
+// ImagePuller makes sure the images referenced by a VM pod besides
+// its container image are present in the image store.
+type ImagePuller interface {
+	// EnsureImage pulls the specified image unless it's already
+	// present in the image store and returns the image ref.
+	EnsureImage(ctx context.Context, name string) (string, error)
 }
 
 // VirtletRuntimeService handles CRI runtime service calls.
@@ -59,6 +69,7 @@ type VirtletRuntimeService struct {
 	fdManager     tapmanager.FDManager
 	streamServer  StreamServer
 	gcHandler     GCHandler
+	imagePuller   ImagePuller
 	clock         clockwork.Clock
 }
 
@@ -69,6 +80,7 @@ func NewVirtletRuntimeService(
 	fdManager tapmanager.FDManager,
 	streamServer StreamServer,
 	gcHandler GCHandler,
+	imagePuller ImagePuller,
 	clock clockwork.Clock) *VirtletRuntimeService {
 	if clock == nil {
 		clock = clockwork.NewRealClock()
@@ -79,6 +91,7 @@ func NewVirtletRuntimeService(
 		fdManager:     fdManager,
 		streamServer:  streamServer,
 		gcHandler:     gcHandler,
+		imagePuller:   imagePuller,
 		clock:         clock,
 	}
 }
@@ -115,6 +128,26 @@ func (v *VirtletRuntimeService) RunPodSandbox(ctx context.Context, in *kubeapi.R
 	}
 	podID := config.Metadata.Uid
 	podNs := config.Metadata.Namespace
+	nicModels, err := types.ParseNICModels(config.Annotations)
+	if err != nil {
+		return nil, err
+	}
+	netQueueCount, err := types.ParseNetQueueCount(config.Annotations)
+	if err != nil {
+		return nil, err
+	}
+	networkMode, err := types.ParseNetworkMode(config.Annotations)
+	if err != nil {
+		return nil, err
+	}
+	ingressBandwidth, egressBandwidth, err := types.ParseBandwidthLimits(config.Annotations)
+	if err != nil {
+		return nil, err
+	}
+	hwAddrs, stableHwAddrs, err := types.ParseMACAddresses(config.Annotations)
+	if err != nil {
+		return nil, err
+	}
 
 	// Check if sandbox already exists, it may happen when virtlet restarts and kubelet "thinks" that sandbox disappered
 	sandbox := v.metadataStore.PodSandbox(podID)
@@ -129,9 +162,16 @@ func (v *VirtletRuntimeService) RunPodSandbox(ctx context.Context, in *kubeapi.R
 
 	state := kubeapi.PodSandboxState_SANDBOX_READY
 	pnd := &tapmanager.PodNetworkDesc{
-		PodID:   podID,
-		PodNs:   podNs,
-		PodName: podName,
+		PodID:               podID,
+		PodNs:               podNs,
+		PodName:             podName,
+		NICModels:           nicModels,
+		NetQueueCount:       netQueueCount,
+		NetworkMode:         networkMode,
+		IngressBandwidth:    ingressBandwidth,
+		EgressBandwidth:     egressBandwidth,
+		HardwareAddrs:       hwAddrs,
+		StableHardwareAddrs: stableHwAddrs,
 	}
 	// Mimic kubelet's method of handling nameservers.
 	// As of k8s 1.5.2, kubelet doesn't use any nameserver information from CNI.
@@ -328,17 +368,74 @@ func (v *VirtletRuntimeService) CreateContainer(ctx context.Context, in *kubeapi
 	if sandboxInfo.ContainerSideNetwork == nil || sandboxInfo.ContainerSideNetwork.Result == nil {
 		fdKey = ""
 	}
+	if err := v.pullImageDisks(ctx, vmConfig); err != nil {
+		return nil, err
+	}
+	if err := v.pullBootImages(ctx, vmConfig); err != nil {
+		return nil, err
+	}
 
 	uuid, err := v.virtTool.CreateContainer(vmConfig, fdKey)
 	if err != nil {
 		glog.Errorf("Error creating container %s: %v", name, err)
 		return nil, err
 	}
+	for _, ref := range append([]string{vmConfig.Image, vmConfig.KernelImageRef, vmConfig.InitrdImageRef}, vmConfig.ImageDiskRefs...) {
+		if ref == "" {
+			continue
+		}
+		if err := v.gcHandler.MarkImageUsed(ref); err != nil {
+			glog.Warningf("Error updating the last use time of image %q: %v", ref, err)
+		}
+	}
 
 	response := &kubeapi.CreateContainerResponse{ContainerId: uuid}
 	return response, nil
 }
 
+// pullImageDisks makes sure the images for the additional image-backed
+// disks of the VM are present in the image store, and stores their refs
+// in the VM config.
+func (v *VirtletRuntimeService) pullImageDisks(ctx context.Context, vmConfig *types.VMConfig) error {
+	imageDisks, err := types.ParseImageDisks(vmConfig.PodAnnotations)
+	if err != nil {
+		return err
+	}
+	for _, disk := range imageDisks {
+		ref, err := v.imagePuller.EnsureImage(ctx, disk.Image)
+		if err != nil {
+			return fmt.Errorf("error pulling image %q for an image disk: %v", disk.Image, err)
+		}
+		vmConfig.ImageDiskRefs = append(vmConfig.ImageDiskRefs, ref)
+	}
+	return nil
+}
+
+// pullBootImages makes sure the images for the kernel and initrd used
+// for direct kernel boot are present in the image store in case if
+// they're specified as images.
+func (v *VirtletRuntimeService) pullBootImages(ctx context.Context, vmConfig *types.VMConfig) error {
+	kernel, initrd, err := types.ParseBootFileSources(vmConfig.PodAnnotations)
+	if err != nil {
+		return err
+	}
+	for _, item := range []struct {
+		src *types.BootFileSource
+		ref *string
+	}{
+		{kernel, &vmConfig.KernelImageRef},
+		{initrd, &vmConfig.InitrdImageRef},
+	} {
+		if item.src == nil || item.src.Kind != types.BootFileSourceImage {
+			continue
+		}
+		if *item.ref, err = v.imagePuller.EnsureImage(ctx, item.src.Name); err != nil {
+			return fmt.Errorf("error pulling boot file image %q: %v", item.src.Name, err)
+		}
+	}
+	return nil
+}
+
 // StartContainer method implements StartContainer from CRI.
 func (v *VirtletRuntimeService) StartContainer(ctx context.Context, in *kubeapi.StartContainerRequest) (*kubeapi.StartContainerResponse, error) {
 	info, err := v.virtTool.ContainerInfo(in.ContainerId)
@@ -246,9 +246,10 @@ func makeVirtletCRITester(t *testing.T) *virtletCRITester {
 		fakefs.NewFakeFileSystem(t, rec, "", nil), commander)
 	virtTool.SetClock(clock)
 	streamServer := newFakeStreamServer(rec.Child("streamServer"))
+	imageService := NewVirtletImageService(imageStore, translateImageName, clock)
 	criHandler := &criHandler{
-		VirtletRuntimeService: NewVirtletRuntimeService(virtTool, metadataStore, fdManager, streamServer, imageStore, clock),
-		VirtletImageService:   NewVirtletImageService(imageStore, translateImageName, clock),
+		VirtletRuntimeService: NewVirtletRuntimeService(virtTool, metadataStore, fdManager, streamServer, imageStore, imageService, clock),
+		VirtletImageService:   imageService,
 	}
 	return &virtletCRITester{
 		t:              t,
@@ -191,6 +191,14 @@ func (b *boltClient) ImagesInUse() (map[string]bool, error) {
 					return fmt.Errorf("containerInfo of container %q not found in Virtlet metadata store", containerMeta.GetID())
 				}
 				result[ci.Config.Image] = true
+				for _, ref := range ci.Config.ImageDiskRefs {
+					result[ref] = true
+				}
+				for _, ref := range []string{ci.Config.KernelImageRef, ci.Config.InitrdImageRef} {
+					if ref != "" {
+						result[ref] = true
+					}
+				}
 			}
 		}
 		return nil
@@ -19,6 +19,8 @@ package types
 import (
 	"encoding/base64"
 	"fmt"
+	"net"
+	"path"
 	"strconv"
 	"strings"
 
@@ -27,12 +29,15 @@ import (
 	uuid "github.com/nu7hatch/gouuid"
 	"k8s.io/apimachinery/pkg/api/resource"
 
+	"github.com/Mirantis/virtlet/pkg/network"
 	"github.com/Mirantis/virtlet/pkg/utils"
 )
 
 const (
 	maxVCPUCount                      = 255
+	maxNetQueueCount                  = 16
 	vcpuCountAnnotationKeyName        = "VirtletVCPUCount"
+	vcpuTopologyKeyName               = "VirtletVCPUTopology"
 	diskDriverKeyName                 = "VirtletDiskDriver"
 	cloudInitMetaDataKeyName          = "VirtletCloudInitMetaData"
 	cloudInitUserDataOverwriteKeyName = "VirtletCloudInitUserDataOverwrite"
@@ -46,6 +51,22 @@ const (
 	chown9pfsMountsKeyName            = "VirtletChown9pfsMounts"
 	systemUUIDKeyName                 = "VirtletSystemUUID"
 	forceDHCPNetworkConfigKeyName     = "VirtletForceDHCPNetworkConfig"
+	imageDisksKeyName                 = "VirtletImageDisks"
+	imageTypeKeyName                  = "VirtletImageType"
+	bootOrderKeyName                  = "VirtletBootOrder"
+	kernelKeyName                     = "VirtletKernel"
+	initrdKeyName                     = "VirtletInitrd"
+	kernelCmdlineKeyName              = "VirtletKernelCmdline"
+	firmwareKeyName                   = "VirtletFirmware"
+	tpmKeyName                        = "VirtletTPM"
+	guestAgentKeyName                 = "VirtletGuestAgent"
+	machineTypeKeyName                = "VirtletMachineType"
+	archKeyName                       = "VirtletArch"
+	nicModelKeyName                   = "VirtletNICModel"
+	netQueueCountKeyName              = "VirtletNetQueueCount"
+	networkModeKeyName                = "VirtletNetworkMode"
+	macAddressesKeyName               = "VirtletMACAddresses"
+	stableMACAddresses                = "stable"
 	// CloudInitUserDataSourceKeyName is the name of user data source key in the pod annotations.
 	CloudInitUserDataSourceKeyName = "VirtletCloudInitUserDataSource"
 	// SSHKeySourceKeyName is the name of ssh key source key in the pod annotations.
@@ -57,6 +78,16 @@ const (
 	// FilesFromDSKeyName is the name of data source key in the pod annotations
 	// for the files to be injected into the rootfs.
 	FilesFromDSKeyName = "VirtletFilesFromDataSource"
+
+	// the same keys are used by kubelet for non-VM pods
+	ingressBandwidthKeyName = "kubernetes.io/ingress-bandwidth"
+	egressBandwidthKeyName  = "kubernetes.io/egress-bandwidth"
+)
+
+var (
+	// the limits are the same as the ones used by kubelet
+	minBandwidth = resource.MustParse("1k")
+	maxBandwidth = resource.MustParse("1P")
 )
 
 // CloudInitImageType specifies the image type used for cloud-init
@@ -74,6 +105,181 @@ const (
 	CPUModelHostModel = "host-model"
 )
 
+// ImageType specifies the type of the VM image.
+type ImageType string
+
+const (
+	// ImageTypeQCOW2 denotes a bootable QCOW2 image which is used
+	// as the backing file for the root volume.
+	ImageTypeQCOW2 ImageType = "qcow2"
+	// ImageTypeISO denotes an installer ISO image which is attached
+	// to the VM as a CD-ROM, with the root volume being blank.
+	ImageTypeISO ImageType = "iso"
+)
+
+// BootDevice specifies a libvirt boot device.
+type BootDevice string
+
+const (
+	// BootDeviceHD denotes booting from the disk.
+	BootDeviceHD BootDevice = "hd"
+	// BootDeviceCDROM denotes booting from the CD-ROM.
+	BootDeviceCDROM BootDevice = "cdrom"
+)
+
+// Firmware specifies the firmware to use for the VM.
+type Firmware string
+
+const (
+	// FirmwareBIOS denotes legacy BIOS.
+	FirmwareBIOS Firmware = "bios"
+	// FirmwareUEFI denotes UEFI firmware (OVMF on x86_64, AAVMF
+	// on aarch64).
+	FirmwareUEFI Firmware = "uefi"
+	// FirmwareUEFISecure denotes UEFI firmware with Secure Boot
+	// enabled.
+	FirmwareUEFISecure Firmware = "uefi-secure"
+)
+
+// MachineType specifies the machine type of the VM.
+type MachineType string
+
+const (
+	// MachineTypePC denotes i440FX machine type with a flat PCI
+	// layout, which is the default.
+	MachineTypePC MachineType = "pc"
+	// MachineTypeQ35 denotes Q35 machine type with PCIe root ports.
+	MachineTypeQ35 MachineType = "q35"
+	// MachineTypeVirt denotes the generic virtual platform used for
+	// aarch64 guests.
+	MachineTypeVirt MachineType = "virt"
+	// MachineTypePSeries denotes POWER machine type used for
+	// ppc64le guests.
+	MachineTypePSeries MachineType = "pseries"
+)
+
+// BootFileSourceKind specifies where a kernel or initrd file used
+// for direct kernel boot comes from.
+type BootFileSourceKind string
+
+const (
+	// BootFileSourceImage denotes an image from the image store.
+	BootFileSourceImage BootFileSourceKind = "image"
+	// BootFileSourceConfigMap denotes a ConfigMap key.
+	BootFileSourceConfigMap BootFileSourceKind = "configmap"
+	// BootFileSourceSecret denotes a Secret key.
+	BootFileSourceSecret BootFileSourceKind = "secret"
+	// BootFileSourceFile denotes a file on a volume that's
+	// mounted into the container.
+	BootFileSourceFile BootFileSourceKind = "file"
+)
+
+// BootFileSource describes a kernel or initrd file used for direct
+// kernel boot.
+type BootFileSource struct {
+	// Kind specifies the kind of the source.
+	Kind BootFileSourceKind
+	// Name is the image name for image sources, the name of the
+	// ConfigMap or Secret for configmap and secret sources and
+	// the path inside the container for file sources.
+	Name string
+	// Key is the key within the ConfigMap or Secret.
+	Key string
+}
+
+// ParseBootFileSource parses the boot file source specification which
+// has one of the following forms: image/<image name>,
+// configmap/<name>/<key>, secret/<name>/<key>, file/<path>.
+func ParseBootFileSource(spec string) (*BootFileSource, error) {
+	parts := strings.SplitN(spec, "/", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return nil, fmt.Errorf("bad boot file source %q", spec)
+	}
+	src := &BootFileSource{Kind: BootFileSourceKind(strings.ToLower(parts[0]))}
+	switch src.Kind {
+	case BootFileSourceImage:
+		src.Name = parts[1]
+	case BootFileSourceFile:
+		// cleaning the rooted path removes any ".." elements
+		// that would point above the root of the container
+		src.Name = path.Clean("/" + parts[1])
+	case BootFileSourceConfigMap, BootFileSourceSecret:
+		nameAndKey := strings.Split(parts[1], "/")
+		if len(nameAndKey) != 2 || nameAndKey[0] == "" || nameAndKey[1] == "" {
+			return nil, fmt.Errorf("bad boot file source %q. Expected %s/name/key", spec, src.Kind)
+		}
+		src.Name, src.Key = nameAndKey[0], nameAndKey[1]
+	default:
+		return nil, fmt.Errorf("bad boot file source %q. Kind must be one of (image, configmap, secret, file)", spec)
+	}
+	return src, nil
+}
+
+// ParseBootFileSources returns the kernel and initrd sources specified
+// in the pod annotations, or nil if they're not specified. It's used
+// to pull the images for these files before the VM is created.
+func ParseBootFileSources(podAnnotations map[string]string) (kernel, initrd *BootFileSource, err error) {
+	if spec, found := podAnnotations[kernelKeyName]; found {
+		if kernel, err = ParseBootFileSource(spec); err != nil {
+			return nil, nil, err
+		}
+	}
+	if spec, found := podAnnotations[initrdKeyName]; found {
+		if initrd, err = ParseBootFileSource(spec); err != nil {
+			return nil, nil, err
+		}
+	}
+	return kernel, initrd, nil
+}
+
+// VCPUTopology specifies how VM vCPUs are arranged into sockets,
+// cores and threads.
+type VCPUTopology struct {
+	// Sockets is the number of CPU sockets.
+	Sockets int
+	// Cores is the number of cores per socket.
+	Cores int
+	// Threads is the number of threads per core.
+	Threads int
+}
+
+// VCPUCount returns the total number of vCPUs for the topology.
+func (t *VCPUTopology) VCPUCount() int {
+	return t.Sockets * t.Cores * t.Threads
+}
+
+// ParseVCPUTopology parses vCPU topology specification which has the
+// form of sockets=<n>,cores=<n>,threads=<n>. The omitted values
+// default to 1.
+func ParseVCPUTopology(spec string) (*VCPUTopology, error) {
+	t := &VCPUTopology{Sockets: 1, Cores: 1, Threads: 1}
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("bad vcpu topology item %q. Expected name=value", item)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, fmt.Errorf("bad vcpu topology item %q: %v", item, err)
+		}
+		switch strings.ToLower(strings.TrimSpace(parts[0])) {
+		case "sockets":
+			t.Sockets = n
+		case "cores":
+			t.Cores = n
+		case "threads":
+			t.Threads = n
+		default:
+			return nil, fmt.Errorf("bad vcpu topology item %q. Name must be one of (sockets, cores, threads)", item)
+		}
+	}
+	return t, nil
+}
+
 // DiskDriverName specifies disk driver name supported by Virtlet.
 type DiskDriverName string
 
@@ -84,11 +290,26 @@ const (
 	DiskDriverScsi DiskDriverName = "scsi"
 )
 
+// ImageDisk describes an additional VM disk that's backed by an image
+// from the image store.
+type ImageDisk struct {
+	// Image is the name of the image.
+	Image string `json:"image"`
+	// ReadOnly specifies that the image is attached to the VM
+	// directly as a read-only disk. Otherwise, a copy-on-write
+	// overlay is created on top of the image and the changes
+	// are discarded when the pod is removed.
+	ReadOnly bool `json:"readOnly,omitempty"`
+}
+
 // VirtletAnnotations contains parsed values for pod annotations supported
 // by Virtlet.
 type VirtletAnnotations struct {
 	// Number of virtual CPUs.
 	VCPUCount int
+	// VCPUTopology specifies the sockets/cores/threads topology
+	// of the vCPUs.
+	VCPUTopology *VCPUTopology
 	// CPU model.
 	CPUModel CPUModelType
 	// Cloud-Init image type to use.
@@ -124,6 +345,42 @@ type VirtletAnnotations struct {
 	// configuration and makes it only provide DHCP. Note that this will
 	// not work for multi-CNI configuration.
 	ForceDHCPNetworkConfig bool
+	// ImageDisks specifies additional image-backed disks for the VM.
+	ImageDisks []ImageDisk
+	// ImageType specifies the type of the VM image.
+	ImageType ImageType
+	// BootOrder specifies the boot devices in the order in which
+	// they're tried. If it's empty, the VM boots from the disk,
+	// or, in case of ISO images, from the disk and then from the
+	// CD-ROM, so the installer is only started while the disk
+	// isn't bootable.
+	BootOrder []BootDevice
+	// Kernel specifies the kernel to use for direct kernel boot.
+	Kernel *BootFileSource
+	// Initrd specifies the initrd to use for direct kernel boot.
+	Initrd *BootFileSource
+	// KernelCmdline specifies the kernel command line for direct
+	// kernel boot.
+	KernelCmdline string
+	// Firmware specifies the firmware to use for the VM.
+	Firmware Firmware
+	// TPM enables emulated TPM 2.0 device for the VM.
+	TPM bool
+	// GuestAgent enables the virtio channel for QEMU guest agent.
+	GuestAgent bool
+	// MachineType specifies the machine type of the VM.
+	MachineType MachineType
+	// Arch specifies the guest architecture.
+	Arch Arch
+	// NICModels specifies the NIC models for the network interfaces
+	// of the VM, in the order of the interfaces.
+	NICModels []network.NICModel
+	// NetQueueCount specifies the number of queues for
+	// multiqueue virtio-net NICs.
+	NetQueueCount int
+	// NetworkMode specifies how the VM is connected to the pod
+	// network.
+	NetworkMode network.Mode
 }
 
 // ExternalDataLoader is used to load extra pod data from
@@ -134,6 +391,9 @@ type ExternalDataLoader interface {
 	LoadCloudInitData(va *VirtletAnnotations, namespace string, podAnnotations map[string]string) error
 	// LoadFileMap loads a set of files from the data sources.
 	LoadFileMap(namespace, dsSpec string) (map[string][]byte, error)
+	// LoadBootFile loads the contents of a kernel or initrd file
+	// from a ConfigMap or Secret.
+	LoadBootFile(namespace string, src *BootFileSource) ([]byte, error)
 }
 
 var externalDataLoader ExternalDataLoader
@@ -149,10 +409,22 @@ func GetExternalDataLoader() ExternalDataLoader {
 }
 
 func (va *VirtletAnnotations) applyDefaults() {
+	if va.VCPUCount <= 0 && va.VCPUTopology != nil {
+		va.VCPUCount = va.VCPUTopology.VCPUCount()
+	}
+
 	if va.VCPUCount <= 0 {
 		va.VCPUCount = 1
 	}
 
+	if va.NetQueueCount <= 0 {
+		// use a queue per vCPU by default
+		va.NetQueueCount = va.VCPUCount
+		if va.NetQueueCount > maxNetQueueCount {
+			va.NetQueueCount = maxNetQueueCount
+		}
+	}
+
 	if va.DiskDriver == "" {
 		va.DiskDriver = DiskDriverScsi
 	}
@@ -160,6 +432,34 @@ func (va *VirtletAnnotations) applyDefaults() {
 	if va.CDImageType == "" {
 		va.CDImageType = CloudInitImageTypeNoCloud
 	}
+
+	if va.ImageType == "" {
+		va.ImageType = ImageTypeQCOW2
+	}
+
+	if va.Arch == "" {
+		va.Arch = ArchX86_64
+	}
+
+	if va.Firmware == "" {
+		va.Firmware = FirmwareBIOS
+		if firmwares := archFirmwares[va.Arch]; len(firmwares) != 0 {
+			va.Firmware = firmwares[0]
+		}
+	}
+
+	if va.MachineType == "" {
+		switch {
+		case va.Firmware == FirmwareUEFISecure:
+			// Secure Boot requires SMM which is only available
+			// on q35 machine type
+			va.MachineType = MachineTypeQ35
+		case len(archMachineTypes[va.Arch]) != 0:
+			va.MachineType = archMachineTypes[va.Arch][0]
+		default:
+			va.MachineType = MachineTypePC
+		}
+	}
 }
 
 func (va *VirtletAnnotations) validate() error {
@@ -168,6 +468,18 @@ func (va *VirtletAnnotations) validate() error {
 		errs = append(errs, fmt.Sprintf("vcpu count %d too big, max is %d", va.VCPUCount, maxVCPUCount))
 	}
 
+	if t := va.VCPUTopology; t != nil {
+		if t.Sockets <= 0 || t.Cores <= 0 || t.Threads <= 0 {
+			errs = append(errs, fmt.Sprintf("bad vcpu topology: sockets=%d, cores=%d, threads=%d. All of the values must be positive", t.Sockets, t.Cores, t.Threads))
+		} else if t.VCPUCount() != va.VCPUCount {
+			errs = append(errs, fmt.Sprintf("vcpu topology with %d vcpus doesn't match vcpu count %d", t.VCPUCount(), va.VCPUCount))
+		}
+	}
+
+	if va.NetQueueCount > maxNetQueueCount {
+		errs = append(errs, fmt.Sprintf("net queue count %d too big, max is %d", va.NetQueueCount, maxNetQueueCount))
+	}
+
 	if va.DiskDriver != DiskDriverVirtio && va.DiskDriver != DiskDriverScsi {
 		errs = append(errs, fmt.Sprintf("bad disk driver %q. Must be either %q or %q", va.DiskDriver, DiskDriverVirtio, DiskDriverScsi))
 	}
@@ -180,6 +492,64 @@ func (va *VirtletAnnotations) validate() error {
 		errs = append(errs, fmt.Sprintf("unknown cpu model type %q. Must be empty or %q", va.CPUModel, CPUModelHostModel))
 	}
 
+	switch va.ImageType {
+	case ImageTypeQCOW2:
+	case ImageTypeISO:
+		if va.RootVolumeSize <= 0 {
+			errs = append(errs, fmt.Sprintf("root volume size must be specified using %s for %q images", rootVolumeSizeKeyName, ImageTypeISO))
+		}
+		if len(va.InjectedFiles) != 0 {
+			errs = append(errs, fmt.Sprintf("can't inject files into the root volume for %q images", ImageTypeISO))
+		}
+	default:
+		errs = append(errs, fmt.Sprintf("unknown image type %q. Must be either %q or %q", va.ImageType, ImageTypeQCOW2, ImageTypeISO))
+	}
+
+	for _, dev := range va.BootOrder {
+		if dev != BootDeviceHD && dev != BootDeviceCDROM {
+			errs = append(errs, fmt.Sprintf("bad boot device %q. Must be either %q or %q", dev, BootDeviceHD, BootDeviceCDROM))
+		}
+	}
+
+	if va.Firmware != FirmwareBIOS && va.Firmware != FirmwareUEFI && va.Firmware != FirmwareUEFISecure {
+		errs = append(errs, fmt.Sprintf("unknown firmware %q. Must be one of %q, %q or %q", va.Firmware, FirmwareBIOS, FirmwareUEFI, FirmwareUEFISecure))
+	}
+
+	machineTypes, archFound := archMachineTypes[va.Arch]
+	if !archFound {
+		errs = append(errs, fmt.Sprintf("unknown architecture %q. Must be one of %q, %q or %q", va.Arch, ArchX86_64, ArchAArch64, ArchPPC64LE))
+	}
+
+	switch va.MachineType {
+	case MachineTypePC, MachineTypeQ35, MachineTypeVirt, MachineTypePSeries:
+		if archFound && !machineTypeInList(va.MachineType, machineTypes) {
+			errs = append(errs, fmt.Sprintf("machine type %q is not supported for %q architecture", va.MachineType, va.Arch))
+		}
+		if va.MachineType == MachineTypePC && va.Firmware == FirmwareUEFISecure {
+			errs = append(errs, fmt.Sprintf("%q firmware requires %q machine type", FirmwareUEFISecure, MachineTypeQ35))
+		}
+	default:
+		errs = append(errs, fmt.Sprintf("unknown machine type %q. Must be one of %q, %q, %q or %q", va.MachineType, MachineTypePC, MachineTypeQ35, MachineTypeVirt, MachineTypePSeries))
+	}
+
+	if archFound && !firmwareInList(va.Firmware, archFirmwares[va.Arch]) {
+		errs = append(errs, fmt.Sprintf("firmware %q is not supported for %q architecture", va.Firmware, va.Arch))
+	}
+
+	if va.TPM && va.Arch != ArchX86_64 {
+		errs = append(errs, fmt.Sprintf("TPM is only supported for %q architecture", ArchX86_64))
+	}
+
+	if va.Kernel == nil && (va.Initrd != nil || va.KernelCmdline != "") {
+		errs = append(errs, fmt.Sprintf("%s and %s can only be used together with %s", initrdKeyName, kernelCmdlineKeyName, kernelKeyName))
+	}
+
+	for n, disk := range va.ImageDisks {
+		if disk.Image == "" {
+			errs = append(errs, fmt.Sprintf("image disk #%d: image name not specified", n+1))
+		}
+	}
+
 	if errs != nil {
 		return fmt.Errorf("bad virtlet annotations. Errors:\n%s", strings.Join(errs, "\n"))
 	}
@@ -187,6 +557,24 @@ func (va *VirtletAnnotations) validate() error {
 	return nil
 }
 
+func machineTypeInList(machineType MachineType, machineTypes []MachineType) bool {
+	for _, mt := range machineTypes {
+		if mt == machineType {
+			return true
+		}
+	}
+	return false
+}
+
+func firmwareInList(firmware Firmware, firmwares []Firmware) bool {
+	for _, f := range firmwares {
+		if f == firmware {
+			return true
+		}
+	}
+	return false
+}
+
 func loadAnnotations(ns string, podAnnotations map[string]string) (*VirtletAnnotations, error) {
 	var va VirtletAnnotations
 	if err := va.parsePodAnnotations(ns, podAnnotations); err != nil {
@@ -230,11 +618,8 @@ func (va *VirtletAnnotations) parsePodAnnotations(ns string, podAnnotations map[
 		}
 	}
 
-	if vcpuCountStr, found := podAnnotations[vcpuCountAnnotationKeyName]; found {
-		var err error
-		if va.VCPUCount, err = strconv.Atoi(vcpuCountStr); err != nil {
-			return fmt.Errorf("error parsing cpu count for VM pod: %q: %v", vcpuCountStr, err)
-		}
+	if err := va.parseCPUAnnotations(podAnnotations); err != nil {
+		return err
 	}
 
 	if metaDataStr, found := podAnnotations[cloudInitMetaDataKeyName]; found {
@@ -331,5 +716,205 @@ func (va *VirtletAnnotations) parsePodAnnotations(ns string, podAnnotations map[
 		va.ForceDHCPNetworkConfig = true
 	}
 
+	var err error
+	if va.ImageDisks, err = ParseImageDisks(podAnnotations); err != nil {
+		return err
+	}
+
+	va.ImageType = ImageType(strings.ToLower(podAnnotations[imageTypeKeyName]))
+
+	if bootOrderStr, found := podAnnotations[bootOrderKeyName]; found {
+		for _, dev := range strings.Split(bootOrderStr, ",") {
+			if dev = strings.TrimSpace(dev); dev != "" {
+				va.BootOrder = append(va.BootOrder, BootDevice(strings.ToLower(dev)))
+			}
+		}
+	}
+
+	if va.Kernel, va.Initrd, err = ParseBootFileSources(podAnnotations); err != nil {
+		return err
+	}
+	va.KernelCmdline = podAnnotations[kernelCmdlineKeyName]
+	va.Firmware = Firmware(strings.ToLower(podAnnotations[firmwareKeyName]))
+	va.MachineType = MachineType(strings.ToLower(podAnnotations[machineTypeKeyName]))
+	va.Arch = Arch(strings.ToLower(podAnnotations[archKeyName]))
+
+	if podAnnotations[tpmKeyName] == "true" {
+		va.TPM = true
+	}
+
+	if podAnnotations[guestAgentKeyName] == "true" {
+		va.GuestAgent = true
+	}
+
+	if va.NICModels, err = ParseNICModels(podAnnotations); err != nil {
+		return err
+	}
+
+	if va.NetworkMode, err = ParseNetworkMode(podAnnotations); err != nil {
+		return err
+	}
+
 	return nil
 }
+
+// parseCPUAnnotations parses the vCPU count and topology along with
+// the net queue count which depends on them by default.
+func (va *VirtletAnnotations) parseCPUAnnotations(podAnnotations map[string]string) error {
+	if vcpuCountStr, found := podAnnotations[vcpuCountAnnotationKeyName]; found {
+		var err error
+		if va.VCPUCount, err = strconv.Atoi(vcpuCountStr); err != nil {
+			return fmt.Errorf("error parsing cpu count for VM pod: %q: %v", vcpuCountStr, err)
+		}
+	}
+
+	if vcpuTopologyStr, found := podAnnotations[vcpuTopologyKeyName]; found {
+		var err error
+		if va.VCPUTopology, err = ParseVCPUTopology(vcpuTopologyStr); err != nil {
+			return err
+		}
+	}
+
+	if netQueueCountStr, found := podAnnotations[netQueueCountKeyName]; found {
+		var err error
+		if va.NetQueueCount, err = strconv.Atoi(netQueueCountStr); err != nil {
+			return fmt.Errorf("error parsing net queue count for VM pod: %q: %v", netQueueCountStr, err)
+		}
+	}
+
+	return nil
+}
+
+// ParseNetQueueCount returns the number of queues for multiqueue
+// virtio-net NICs. If it's not specified in the pod annotations, the
+// vCPU count is used. It's used to pass the queue count to tapmanager
+// when the pod network is set up.
+func ParseNetQueueCount(podAnnotations map[string]string) (int, error) {
+	var va VirtletAnnotations
+	if err := va.parseCPUAnnotations(podAnnotations); err != nil {
+		return 0, err
+	}
+	va.applyDefaults()
+	if err := va.validate(); err != nil {
+		return 0, err
+	}
+	return va.NetQueueCount, nil
+}
+
+// ParseNICModels returns the NIC models for the network interfaces
+// specified in the pod annotations as a comma-separated list, or nil
+// if they're not specified. It's used to pass the NIC models to
+// tapmanager when the pod network is set up.
+func ParseNICModels(podAnnotations map[string]string) ([]network.NICModel, error) {
+	spec := strings.TrimSpace(podAnnotations[nicModelKeyName])
+	if spec == "" {
+		return nil, nil
+	}
+	var models []network.NICModel
+	for _, item := range strings.Split(spec, ",") {
+		model := network.NICModel(strings.ToLower(strings.TrimSpace(item)))
+		if !model.IsValid() {
+			return nil, fmt.Errorf("bad NIC model %q. Must be one of %q, %q, %q or %q", model, network.NICModelVirtio, network.NICModelE1000, network.NICModelE1000E, network.NICModelRTL8139)
+		}
+		models = append(models, model)
+	}
+	return models, nil
+}
+
+// ParseNetworkMode returns the network mode specified in the pod
+// annotations, defaulting to bridge mode. It's used to pass the
+// network mode to tapmanager when the pod network is set up.
+func ParseNetworkMode(podAnnotations map[string]string) (network.Mode, error) {
+	mode := network.Mode(strings.ToLower(strings.TrimSpace(podAnnotations[networkModeKeyName])))
+	switch mode {
+	case "":
+		return network.ModeBridge, nil
+	case network.ModeBridge, network.ModeMacvtap:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("bad network mode %q. Must be either %q or %q", mode, network.ModeBridge, network.ModeMacvtap)
+	}
+}
+
+// ParseMACAddresses returns the hardware addresses for the network
+// interfaces of the VM that are specified in the pod annotations as a
+// comma-separated list, in the order of the interfaces. If the
+// annotation is set to "stable", nil list is returned along with true
+// as the second value, meaning that the addresses must be derived
+// from the namespace and the name of the pod. It's used to pass the
+// hardware addresses to tapmanager when the pod network is set up.
+func ParseMACAddresses(podAnnotations map[string]string) ([]net.HardwareAddr, bool, error) {
+	spec := strings.TrimSpace(podAnnotations[macAddressesKeyName])
+	switch {
+	case spec == "":
+		return nil, false, nil
+	case strings.ToLower(spec) == stableMACAddresses:
+		return nil, true, nil
+	}
+
+	var hwAddrs []net.HardwareAddr
+	seen := make(map[string]bool)
+	for _, item := range strings.Split(spec, ",") {
+		hwAddr, err := net.ParseMAC(strings.TrimSpace(item))
+		switch {
+		case err != nil:
+			return nil, false, fmt.Errorf("error parsing MAC address %q: %v", item, err)
+		case len(hwAddr) != 6:
+			return nil, false, fmt.Errorf("bad MAC address %q: must be a 48-bit one", item)
+		case hwAddr[0]&1 != 0:
+			return nil, false, fmt.Errorf("bad MAC address %q: must be a unicast one", item)
+		case hwAddr.String() == "00:00:00:00:00:00":
+			return nil, false, fmt.Errorf("bad MAC address %q: must not be all zeros", item)
+		case seen[hwAddr.String()]:
+			return nil, false, fmt.Errorf("duplicate MAC address %q", item)
+		}
+		seen[hwAddr.String()] = true
+		hwAddrs = append(hwAddrs, hwAddr)
+	}
+	return hwAddrs, false, nil
+}
+
+// ParseBandwidthLimits returns the limits for the ingress and egress
+// traffic of the pod in bits per second that are specified using the
+// standard Kubernetes pod annotations, with zero meaning no limit.
+// It's used to pass the limits to tapmanager when the pod network
+// is set up.
+func ParseBandwidthLimits(podAnnotations map[string]string) (ingress, egress int64, err error) {
+	if ingress, err = parseBandwidth(podAnnotations, ingressBandwidthKeyName); err != nil {
+		return 0, 0, err
+	}
+	if egress, err = parseBandwidth(podAnnotations, egressBandwidthKeyName); err != nil {
+		return 0, 0, err
+	}
+	return ingress, egress, nil
+}
+
+func parseBandwidth(podAnnotations map[string]string, keyName string) (int64, error) {
+	str, found := podAnnotations[keyName]
+	if !found {
+		return 0, nil
+	}
+	q, err := resource.ParseQuantity(str)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing %s for VM pod: %q: %v", keyName, str, err)
+	}
+	if q.Cmp(minBandwidth) < 0 || q.Cmp(maxBandwidth) > 0 {
+		return 0, fmt.Errorf("bad %s %q: must be between %s and %s", keyName, str, minBandwidth.String(), maxBandwidth.String())
+	}
+	return q.Value(), nil
+}
+
+// ParseImageDisks returns the additional image-backed disks specified
+// in the pod annotations. It's used to pull the images for these
+// disks before the VM is created.
+func ParseImageDisks(podAnnotations map[string]string) ([]ImageDisk, error) {
+	imageDisksStr, found := podAnnotations[imageDisksKeyName]
+	if !found {
+		return nil, nil
+	}
+	var imageDisks []ImageDisk
+	if err := yaml.Unmarshal([]byte(imageDisksStr), &imageDisks); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal image disks: %v", err)
+	}
+	return imageDisks, nil
+}
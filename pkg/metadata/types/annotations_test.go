@@ -21,6 +21,8 @@ import (
 	"testing"
 
 	uuid "github.com/nu7hatch/gouuid"
+
+	"github.com/Mirantis/virtlet/pkg/network"
 )
 
 func TestVirtletAnnotations(t *testing.T) {
@@ -35,54 +37,125 @@ func TestVirtletAnnotations(t *testing.T) {
 			name:        "nil annotations",
 			annotations: nil,
 			va: &VirtletAnnotations{
-				VCPUCount:   1,
-				DiskDriver:  "scsi",
-				CDImageType: "nocloud",
+				VCPUCount:     1,
+				NetQueueCount: 1,
+				DiskDriver:    "scsi",
+				CDImageType:   "nocloud",
+				ImageType:     "qcow2",
+				Firmware:      "bios",
+				MachineType:   "pc",
+				Arch:          "x86_64",
+				NetworkMode:   "bridge",
 			},
 		},
 		{
 			name:        "empty annotations",
 			annotations: map[string]string{},
 			va: &VirtletAnnotations{
-				VCPUCount:   1,
-				DiskDriver:  "scsi",
-				CDImageType: "nocloud",
+				VCPUCount:     1,
+				NetQueueCount: 1,
+				DiskDriver:    "scsi",
+				CDImageType:   "nocloud",
+				ImageType:     "qcow2",
+				Firmware:      "bios",
+				MachineType:   "pc",
+				Arch:          "x86_64",
+				NetworkMode:   "bridge",
 			},
 		},
 		{
 			name:        "non empty cloud init type annotation",
 			annotations: map[string]string{"VirtletCloudInitImageType": "configdrive"},
 			va: &VirtletAnnotations{
-				VCPUCount:   1,
-				DiskDriver:  "scsi",
-				CDImageType: "configdrive",
+				VCPUCount:     1,
+				NetQueueCount: 1,
+				DiskDriver:    "scsi",
+				CDImageType:   "configdrive",
+				ImageType:     "qcow2",
+				Firmware:      "bios",
+				MachineType:   "pc",
+				Arch:          "x86_64",
+				NetworkMode:   "bridge",
 			},
 		},
 		{
 			name:        "negative vcpu count (default)",
 			annotations: map[string]string{"VirtletVCPUCount": "-1"},
 			va: &VirtletAnnotations{
-				VCPUCount:   1,
-				DiskDriver:  "scsi",
-				CDImageType: "nocloud",
+				VCPUCount:     1,
+				NetQueueCount: 1,
+				DiskDriver:    "scsi",
+				CDImageType:   "nocloud",
+				ImageType:     "qcow2",
+				Firmware:      "bios",
+				MachineType:   "pc",
+				Arch:          "x86_64",
+				NetworkMode:   "bridge",
 			},
 		},
 		{
 			name:        "zero vcpu count (default)",
 			annotations: map[string]string{"VirtletVCPUCount": "0"},
 			va: &VirtletAnnotations{
-				VCPUCount:   1,
-				DiskDriver:  "scsi",
-				CDImageType: "nocloud",
+				VCPUCount:     1,
+				NetQueueCount: 1,
+				DiskDriver:    "scsi",
+				CDImageType:   "nocloud",
+				ImageType:     "qcow2",
+				Firmware:      "bios",
+				MachineType:   "pc",
+				Arch:          "x86_64",
+				NetworkMode:   "bridge",
 			},
 		},
 		{
 			name:        "vcpu count specified",
 			annotations: map[string]string{"VirtletVCPUCount": "4"},
 			va: &VirtletAnnotations{
-				VCPUCount:   4,
-				DiskDriver:  "scsi",
-				CDImageType: "nocloud",
+				VCPUCount:     4,
+				NetQueueCount: 4,
+				DiskDriver:    "scsi",
+				CDImageType:   "nocloud",
+				ImageType:     "qcow2",
+				Firmware:      "bios",
+				MachineType:   "pc",
+				Arch:          "x86_64",
+				NetworkMode:   "bridge",
+			},
+		},
+		{
+			name:        "vcpu topology",
+			annotations: map[string]string{"VirtletVCPUTopology": "sockets=2,cores=2"},
+			va: &VirtletAnnotations{
+				VCPUCount:     4,
+				NetQueueCount: 4,
+				VCPUTopology:  &VCPUTopology{Sockets: 2, Cores: 2, Threads: 1},
+				DiskDriver:    "scsi",
+				CDImageType:   "nocloud",
+				ImageType:     "qcow2",
+				Firmware:      "bios",
+				MachineType:   "pc",
+				Arch:          "x86_64",
+				NetworkMode:   "bridge",
+			},
+		},
+		{
+			name: "vcpu topology with vcpu count",
+			annotations: map[string]string{
+				"VirtletVCPUCount":    "8",
+				"VirtletVCPUTopology": "sockets=1, cores=4, threads=2",
+			},
+			va: &VirtletAnnotations{
+				VCPUCount:     8,
+				NetQueueCount: 8,
+				VCPUTopology:  &VCPUTopology{Sockets: 1, Cores: 4, Threads: 2},
+				DiskDriver:    "scsi",
+				CDImageType:   "nocloud",
+				ImageType:     "qcow2",
+				Firmware:      "bios",
+				MachineType:   "pc",
+				Arch:          "x86_64",
+				NetworkMode:   "bridge",
 			},
 		},
 		{
@@ -90,8 +163,14 @@ func TestVirtletAnnotations(t *testing.T) {
 			annotations: map[string]string{"VirtletRootVolumeSize": "1Gi"},
 			va: &VirtletAnnotations{
 				VCPUCount:      1,
+				NetQueueCount:  1,
 				DiskDriver:     "scsi",
 				CDImageType:    "nocloud",
+				ImageType:      "qcow2",
+				Firmware:       "bios",
+				MachineType:    "pc",
+				Arch:           "x86_64",
+				NetworkMode:    "bridge",
 				RootVolumeSize: 1073741824,
 			},
 		},
@@ -107,7 +186,8 @@ func TestVirtletAnnotations(t *testing.T) {
 				"VirtletSSHKeys": "key1\n\nkey2\n",
 			},
 			va: &VirtletAnnotations{
-				VCPUCount: 1,
+				VCPUCount:     1,
+				NetQueueCount: 1,
 				MetaData: map[string]interface{}{
 					"instance-id": "foobar",
 				},
@@ -121,6 +201,11 @@ func TestVirtletAnnotations(t *testing.T) {
 				SSHKeys:     []string{"key1", "key2"},
 				DiskDriver:  "scsi",
 				CDImageType: "nocloud",
+				ImageType:   "qcow2",
+				Firmware:    "bios",
+				MachineType: "pc",
+				Arch:        "x86_64",
+				NetworkMode: "bridge",
 			},
 		},
 		{
@@ -130,9 +215,15 @@ func TestVirtletAnnotations(t *testing.T) {
 			},
 			va: &VirtletAnnotations{
 				VCPUCount:         1,
+				NetQueueCount:     1,
 				UserDataOverwrite: true,
 				DiskDriver:        "scsi",
 				CDImageType:       "nocloud",
+				ImageType:         "qcow2",
+				Firmware:          "bios",
+				MachineType:       "pc",
+				Arch:              "x86_64",
+				NetworkMode:       "bridge",
 			},
 		},
 		{
@@ -142,9 +233,15 @@ func TestVirtletAnnotations(t *testing.T) {
 			},
 			va: &VirtletAnnotations{
 				VCPUCount:      1,
+				NetQueueCount:  1,
 				UserDataScript: "#!/bin/sh\necho hi\n",
 				DiskDriver:     "scsi",
 				CDImageType:    "nocloud",
+				ImageType:      "qcow2",
+				Firmware:       "bios",
+				MachineType:    "pc",
+				Arch:           "x86_64",
+				NetworkMode:    "bridge",
 			},
 		},
 		{
@@ -153,7 +250,8 @@ func TestVirtletAnnotations(t *testing.T) {
 				"VirtletSystemUUID": "53008994-44c0-4017-ad44-9c49758083da",
 			},
 			va: &VirtletAnnotations{
-				VCPUCount: 1,
+				VCPUCount:     1,
+				NetQueueCount: 1,
 				SystemUUID: &uuid.UUID{
 					0x53, 0, 0x89, 0x94,
 					0x44, 0xc0, 0x40, 0x17, 0xad, 0x44,
@@ -161,6 +259,11 @@ func TestVirtletAnnotations(t *testing.T) {
 				},
 				DiskDriver:  "scsi",
 				CDImageType: "nocloud",
+				ImageType:   "qcow2",
+				Firmware:    "bios",
+				MachineType: "pc",
+				Arch:        "x86_64",
+				NetworkMode: "bridge",
 			},
 		},
 		{
@@ -170,16 +273,307 @@ func TestVirtletAnnotations(t *testing.T) {
 			},
 			va: &VirtletAnnotations{
 				VCPUCount:              1,
+				NetQueueCount:          1,
 				DiskDriver:             "scsi",
 				CDImageType:            "nocloud",
+				ImageType:              "qcow2",
+				Firmware:               "bios",
+				MachineType:            "pc",
+				Arch:                   "x86_64",
+				NetworkMode:            "bridge",
 				ForceDHCPNetworkConfig: true,
 			},
 		},
 		// bad metadata items follow
+		{
+			name: "image disks",
+			annotations: map[string]string{
+				"VirtletImageDisks": `
+                                  - image: example.com/toolchain
+                                    readOnly: true
+                                  - image: example.com/dataset`,
+			},
+			va: &VirtletAnnotations{
+				VCPUCount:     1,
+				NetQueueCount: 1,
+				DiskDriver:    "scsi",
+				CDImageType:   "nocloud",
+				ImageType:     "qcow2",
+				Firmware:      "bios",
+				MachineType:   "pc",
+				Arch:          "x86_64",
+				NetworkMode:   "bridge",
+				ImageDisks: []ImageDisk{
+					{Image: "example.com/toolchain", ReadOnly: true},
+					{Image: "example.com/dataset"},
+				},
+			},
+		},
+		{
+			name: "iso image",
+			annotations: map[string]string{
+				"VirtletImageType":      "ISO",
+				"VirtletRootVolumeSize": "4Gi",
+				"VirtletBootOrder":      "cdrom, hd",
+			},
+			va: &VirtletAnnotations{
+				VCPUCount:      1,
+				NetQueueCount:  1,
+				DiskDriver:     "scsi",
+				CDImageType:    "nocloud",
+				ImageType:      "iso",
+				Firmware:       "bios",
+				MachineType:    "pc",
+				Arch:           "x86_64",
+				NetworkMode:    "bridge",
+				RootVolumeSize: 4294967296,
+				BootOrder:      []BootDevice{"cdrom", "hd"},
+			},
+		},
+		{
+			name: "direct kernel boot",
+			annotations: map[string]string{
+				"VirtletKernel":        "image/example.com/kernels/vmlinuz:4.19",
+				"VirtletInitrd":        "configmap/boot-files/initrd",
+				"VirtletKernelCmdline": "console=ttyS0 root=/dev/sda",
+			},
+			va: &VirtletAnnotations{
+				VCPUCount:     1,
+				NetQueueCount: 1,
+				DiskDriver:    "scsi",
+				CDImageType:   "nocloud",
+				ImageType:     "qcow2",
+				Firmware:      "bios",
+				MachineType:   "pc",
+				Arch:          "x86_64",
+				NetworkMode:   "bridge",
+				Kernel: &BootFileSource{
+					Kind: BootFileSourceImage,
+					Name: "example.com/kernels/vmlinuz:4.19",
+				},
+				Initrd: &BootFileSource{
+					Kind: BootFileSourceConfigMap,
+					Name: "boot-files",
+					Key:  "initrd",
+				},
+				KernelCmdline: "console=ttyS0 root=/dev/sda",
+			},
+		},
+		{
+			name: "guest agent",
+			annotations: map[string]string{
+				"VirtletGuestAgent": "true",
+			},
+			va: &VirtletAnnotations{
+				VCPUCount:     1,
+				NetQueueCount: 1,
+				DiskDriver:    "scsi",
+				CDImageType:   "nocloud",
+				ImageType:     "qcow2",
+				Firmware:      "bios",
+				MachineType:   "pc",
+				Arch:          "x86_64",
+				NetworkMode:   "bridge",
+				GuestAgent:    true,
+			},
+		},
+		{
+			name: "uefi firmware and tpm",
+			annotations: map[string]string{
+				"VirtletFirmware": "UEFI-Secure",
+				"VirtletTPM":      "true",
+			},
+			va: &VirtletAnnotations{
+				VCPUCount:     1,
+				NetQueueCount: 1,
+				DiskDriver:    "scsi",
+				CDImageType:   "nocloud",
+				ImageType:     "qcow2",
+				Firmware:      "uefi-secure",
+				MachineType:   "q35",
+				Arch:          "x86_64",
+				NetworkMode:   "bridge",
+				TPM:           true,
+			},
+		},
+		{
+			name: "q35 machine type",
+			annotations: map[string]string{
+				"VirtletMachineType": "Q35",
+			},
+			va: &VirtletAnnotations{
+				VCPUCount:     1,
+				NetQueueCount: 1,
+				DiskDriver:    "scsi",
+				CDImageType:   "nocloud",
+				ImageType:     "qcow2",
+				Firmware:      "bios",
+				MachineType:   "q35",
+				Arch:          "x86_64",
+				NetworkMode:   "bridge",
+			},
+		},
+		{
+			name: "aarch64 guest",
+			annotations: map[string]string{
+				"VirtletArch": "AArch64",
+			},
+			va: &VirtletAnnotations{
+				VCPUCount:     1,
+				NetQueueCount: 1,
+				DiskDriver:    "scsi",
+				CDImageType:   "nocloud",
+				ImageType:     "qcow2",
+				Firmware:      "uefi",
+				MachineType:   "virt",
+				Arch:          "aarch64",
+				NetworkMode:   "bridge",
+			},
+		},
+		{
+			name: "ppc64le guest",
+			annotations: map[string]string{
+				"VirtletArch": "ppc64le",
+			},
+			va: &VirtletAnnotations{
+				VCPUCount:     1,
+				NetQueueCount: 1,
+				DiskDriver:    "scsi",
+				CDImageType:   "nocloud",
+				ImageType:     "qcow2",
+				Firmware:      "bios",
+				MachineType:   "pseries",
+				Arch:          "ppc64le",
+				NetworkMode:   "bridge",
+			},
+		},
+		{
+			name: "nic models",
+			annotations: map[string]string{
+				"VirtletNICModel": "E1000, virtio",
+			},
+			va: &VirtletAnnotations{
+				VCPUCount:     1,
+				NetQueueCount: 1,
+				DiskDriver:    "scsi",
+				CDImageType:   "nocloud",
+				ImageType:     "qcow2",
+				Firmware:      "bios",
+				MachineType:   "pc",
+				Arch:          "x86_64",
+				NetworkMode:   "bridge",
+				NICModels:     []network.NICModel{"e1000", "virtio"},
+			},
+		},
+		{
+			name: "net queue count",
+			annotations: map[string]string{
+				"VirtletVCPUCount":     "4",
+				"VirtletNetQueueCount": "2",
+			},
+			va: &VirtletAnnotations{
+				VCPUCount:     4,
+				NetQueueCount: 2,
+				DiskDriver:    "scsi",
+				CDImageType:   "nocloud",
+				ImageType:     "qcow2",
+				Firmware:      "bios",
+				MachineType:   "pc",
+				Arch:          "x86_64",
+				NetworkMode:   "bridge",
+			},
+		},
+		{
+			name:        "macvtap network mode",
+			annotations: map[string]string{"VirtletNetworkMode": "Macvtap"},
+			va: &VirtletAnnotations{
+				VCPUCount:     1,
+				NetQueueCount: 1,
+				DiskDriver:    "scsi",
+				CDImageType:   "nocloud",
+				ImageType:     "qcow2",
+				Firmware:      "bios",
+				MachineType:   "pc",
+				Arch:          "x86_64",
+				NetworkMode:   "macvtap",
+			},
+		},
+		{
+			name:        "net queue count limited by default",
+			annotations: map[string]string{"VirtletVCPUCount": "32"},
+			va: &VirtletAnnotations{
+				VCPUCount:     32,
+				NetQueueCount: 16,
+				DiskDriver:    "scsi",
+				CDImageType:   "nocloud",
+				ImageType:     "qcow2",
+				Firmware:      "bios",
+				MachineType:   "pc",
+				Arch:          "x86_64",
+				NetworkMode:   "bridge",
+			},
+		},
+		{
+			name: "kernel from a volume",
+			annotations: map[string]string{
+				"VirtletKernel": "file/boot/vmlinuz",
+			},
+			va: &VirtletAnnotations{
+				VCPUCount:     1,
+				NetQueueCount: 1,
+				DiskDriver:    "scsi",
+				CDImageType:   "nocloud",
+				ImageType:     "qcow2",
+				Firmware:      "bios",
+				MachineType:   "pc",
+				Arch:          "x86_64",
+				NetworkMode:   "bridge",
+				Kernel: &BootFileSource{
+					Kind: BootFileSourceFile,
+					Name: "/boot/vmlinuz",
+				},
+			},
+		},
+		{
+			name: "kernel path with dot-dot elements",
+			annotations: map[string]string{
+				"VirtletKernel": "file/data/../../../etc/./shadow",
+			},
+			va: &VirtletAnnotations{
+				VCPUCount:     1,
+				NetQueueCount: 1,
+				DiskDriver:    "scsi",
+				CDImageType:   "nocloud",
+				ImageType:     "qcow2",
+				Firmware:      "bios",
+				MachineType:   "pc",
+				Arch:          "x86_64",
+				NetworkMode:   "bridge",
+				Kernel: &BootFileSource{
+					Kind: BootFileSourceFile,
+					Name: "/etc/shadow",
+				},
+			},
+		},
 		{
 			name:        "bad vcpu count",
 			annotations: map[string]string{"VirtletVCPUCount": "256"},
 		},
+		{
+			name: "vcpu topology not matching vcpu count",
+			annotations: map[string]string{
+				"VirtletVCPUCount":    "2",
+				"VirtletVCPUTopology": "sockets=2,cores=2",
+			},
+		},
+		{
+			name:        "bad vcpu topology",
+			annotations: map[string]string{"VirtletVCPUTopology": "sockets=2,dies=2"},
+		},
+		{
+			name:        "zero vcpu topology value",
+			annotations: map[string]string{"VirtletVCPUTopology": "sockets=0"},
+		},
 		{
 			name:        "bad disk driver",
 			annotations: map[string]string{"VirtletDiskDriver": "ducttape"},
@@ -196,6 +590,118 @@ func TestVirtletAnnotations(t *testing.T) {
 				"VirtletCloudInitImageType": "ducttape",
 			},
 		},
+		{
+			name: "bad image disks",
+			annotations: map[string]string{
+				"VirtletImageDisks": "{",
+			},
+		},
+		{
+			name: "image disk without image name",
+			annotations: map[string]string{
+				"VirtletImageDisks": "- readOnly: true",
+			},
+		},
+		{
+			name: "bad image type",
+			annotations: map[string]string{
+				"VirtletImageType": "vmdk",
+			},
+		},
+		{
+			name: "iso image without root volume size",
+			annotations: map[string]string{
+				"VirtletImageType": "iso",
+			},
+		},
+		{
+			name: "bad boot device",
+			annotations: map[string]string{
+				"VirtletBootOrder": "hd,floppy",
+			},
+		},
+		{
+			name: "bad firmware",
+			annotations: map[string]string{
+				"VirtletFirmware": "coreboot",
+			},
+		},
+		{
+			name: "bad machine type",
+			annotations: map[string]string{
+				"VirtletMachineType": "virt",
+			},
+		},
+		{
+			name: "secure boot with pc machine type",
+			annotations: map[string]string{
+				"VirtletFirmware":    "uefi-secure",
+				"VirtletMachineType": "pc",
+			},
+		},
+		{
+			name: "bad architecture",
+			annotations: map[string]string{
+				"VirtletArch": "mips",
+			},
+		},
+		{
+			name: "machine type not matching architecture",
+			annotations: map[string]string{
+				"VirtletArch":        "aarch64",
+				"VirtletMachineType": "q35",
+			},
+		},
+		{
+			name: "bios firmware for aarch64",
+			annotations: map[string]string{
+				"VirtletArch":     "aarch64",
+				"VirtletFirmware": "bios",
+			},
+		},
+		{
+			name: "tpm for ppc64le",
+			annotations: map[string]string{
+				"VirtletArch": "ppc64le",
+				"VirtletTPM":  "true",
+			},
+		},
+		{
+			name:        "unsupported riscv64 architecture",
+			annotations: map[string]string{"VirtletArch": "riscv64"},
+		},
+		{
+			name:        "bad net queue count",
+			annotations: map[string]string{"VirtletNetQueueCount": "17"},
+		},
+		{
+			name:        "bad network mode",
+			annotations: map[string]string{"VirtletNetworkMode": "ipvtap"},
+		},
+		{
+			name: "bad nic model",
+			annotations: map[string]string{
+				"VirtletNICModel": "e1000,ne2k_pci",
+			},
+		},
+		{
+			name: "bad kernel source",
+			annotations: map[string]string{
+				"VirtletKernel": "http/example.com/vmlinuz",
+			},
+		},
+		{
+			name: "configmap kernel source without a key",
+			annotations: map[string]string{
+				"VirtletKernel": "configmap/boot-files",
+			},
+		},
+		{
+			name: "kernel command line without a kernel",
+			annotations: map[string]string{
+				"VirtletKernelCmdline": "console=ttyS0",
+			},
+		},
 		{
 			name: "bad cloud-init user-data",
 			annotations: map[string]string{
@@ -218,3 +724,128 @@ func TestVirtletAnnotations(t *testing.T) {
 		})
 	}
 }
+
+func TestParseMACAddresses(t *testing.T) {
+	for _, testCase := range []struct {
+		name        string
+		annotations map[string]string
+		hwAddrs     []string
+		stable      bool
+		err         bool
+	}{
+		{
+			name:        "no MAC addresses",
+			annotations: map[string]string{},
+		},
+		{
+			name:        "MAC addresses",
+			annotations: map[string]string{"VirtletMACAddresses": "52:54:00:12:34:56, 52:54:00:AB:CD:EF"},
+			hwAddrs:     []string{"52:54:00:12:34:56", "52:54:00:ab:cd:ef"},
+		},
+		{
+			name:        "stable MAC addresses",
+			annotations: map[string]string{"VirtletMACAddresses": "Stable"},
+			stable:      true,
+		},
+		{
+			name:        "bad MAC address",
+			annotations: map[string]string{"VirtletMACAddresses": "52:54:00:12:34"},
+			err:         true,
+		},
+		{
+			name:        "too long MAC address",
+			annotations: map[string]string{"VirtletMACAddresses": "00:00:00:00:fe:80:00:00:00:00:00:00:02:00:5e:10:00:00:00:01"},
+			err:         true,
+		},
+		{
+			name:        "multicast MAC address",
+			annotations: map[string]string{"VirtletMACAddresses": "01:00:5e:00:00:01"},
+			err:         true,
+		},
+		{
+			name:        "zero MAC address",
+			annotations: map[string]string{"VirtletMACAddresses": "00:00:00:00:00:00"},
+			err:         true,
+		},
+		{
+			name:        "duplicate MAC addresses",
+			annotations: map[string]string{"VirtletMACAddresses": "52:54:00:12:34:56,52:54:00:12:34:56"},
+			err:         true,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			hwAddrs, stable, err := ParseMACAddresses(testCase.annotations)
+			if testCase.err {
+				if err == nil {
+					t.Errorf("invalid MAC addresses considered valid:\n%#v", testCase.annotations)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error parsing MAC addresses: %v", err)
+			}
+			var strs []string
+			for _, hwAddr := range hwAddrs {
+				strs = append(strs, hwAddr.String())
+			}
+			if !reflect.DeepEqual(strs, testCase.hwAddrs) || stable != testCase.stable {
+				t.Errorf("MAC addresses mismatch: got %v (stable: %v) instead of %v (stable: %v)", strs, stable, testCase.hwAddrs, testCase.stable)
+			}
+		})
+	}
+}
+
+func TestParseBandwidthLimits(t *testing.T) {
+	for _, testCase := range []struct {
+		name            string
+		annotations     map[string]string
+		ingress, egress int64
+		err             bool
+	}{
+		{
+			name:        "no limits",
+			annotations: map[string]string{},
+		},
+		{
+			name: "ingress and egress limits",
+			annotations: map[string]string{
+				"kubernetes.io/ingress-bandwidth": "10M",
+				"kubernetes.io/egress-bandwidth":  "1G",
+			},
+			ingress: 10000000,
+			egress:  1000000000,
+		},
+		{
+			name:        "egress limit only",
+			annotations: map[string]string{"kubernetes.io/egress-bandwidth": "512k"},
+			egress:      512000,
+		},
+		{
+			name:        "bad limit",
+			annotations: map[string]string{"kubernetes.io/ingress-bandwidth": "fast"},
+			err:         true,
+		},
+		{
+			name:        "limit too small",
+			annotations: map[string]string{"kubernetes.io/ingress-bandwidth": "100"},
+			err:         true,
+		},
+		{
+			name:        "limit too big",
+			annotations: map[string]string{"kubernetes.io/egress-bandwidth": "2P"},
+			err:         true,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			ingress, egress, err := ParseBandwidthLimits(testCase.annotations)
+			switch {
+			case testCase.err && err == nil:
+				t.Errorf("invalid bandwidth limits considered valid:\n%#v", testCase.annotations)
+			case !testCase.err && err != nil:
+				t.Errorf("unexpected error parsing bandwidth limits: %v", err)
+			case ingress != testCase.ingress || egress != testCase.egress:
+				t.Errorf("bandwidth limits mismatch: got %d/%d instead of %d/%d", ingress, egress, testCase.ingress, testCase.egress)
+			}
+		})
+	}
+}
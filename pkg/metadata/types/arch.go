@@ -0,0 +1,81 @@
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package types
+
+import (
+	"runtime"
+)
+
+// Arch specifies the guest architecture. The values match the
+// architecture names used by libvirt.
+type Arch string
+
+const (
+	// ArchX86_64 denotes x86_64 architecture, which is the default.
+	ArchX86_64 Arch = "x86_64"
+	// ArchAArch64 denotes 64-bit ARM architecture.
+	ArchAArch64 Arch = "aarch64"
+	// ArchPPC64LE denotes little-endian 64-bit POWER architecture.
+	ArchPPC64LE Arch = "ppc64le"
+)
+
+var goArchs = map[string]Arch{
+	"amd64":   ArchX86_64,
+	"arm64":   ArchAArch64,
+	"ppc64le": ArchPPC64LE,
+}
+
+// archMachineTypes lists the machine types supported for each guest
+// architecture. The first one is the default.
+var archMachineTypes = map[Arch][]MachineType{
+	ArchX86_64:  {MachineTypePC, MachineTypeQ35},
+	ArchAArch64: {MachineTypeVirt},
+	ArchPPC64LE: {MachineTypePSeries},
+}
+
+// archFirmwares lists the firmwares supported for each guest
+// architecture. The first one is the default.
+var archFirmwares = map[Arch][]Firmware{
+	ArchX86_64:  {FirmwareBIOS, FirmwareUEFI, FirmwareUEFISecure},
+	ArchAArch64: {FirmwareUEFI},
+	ArchPPC64LE: {FirmwareBIOS},
+}
+
+// HostArch returns the architecture of the host.
+func HostArch() Arch {
+	if arch, found := goArchs[runtime.GOARCH]; found {
+		return arch
+	}
+	return Arch(runtime.GOARCH)
+}
+
+// EmulatorPath returns the path to QEMU system emulator binary
+// for the architecture.
+func (a Arch) EmulatorPath() string {
+	qemuArch := string(a)
+	if a == ArchPPC64LE {
+		// qemu-system-ppc64 handles both big and little endian guests
+		qemuArch = "ppc64"
+	}
+	return "/usr/bin/qemu-system-" + qemuArch
+}
+
+// IsPCIe returns true if the machine type provides PCIe topology
+// with pcie-root controller.
+func (mt MachineType) IsPCIe() bool {
+	return mt == MachineTypeQ35 || mt == MachineTypeVirt
+}
@@ -275,6 +275,15 @@ type VMConfig struct {
 	// Path relative to LogDirectory for container to store the
 	// log (STDOUT and STDERR) on the host.
 	LogPath string
+	// Image refs for the additional image-backed disks specified
+	// in the pod annotations, in the same order. Populated after
+	// the images are pulled.
+	ImageDiskRefs []string
+	// Image refs for the kernel and initrd used for direct kernel
+	// boot in case if they come from the image store. Populated
+	// after the images are pulled.
+	KernelImageRef string
+	InitrdImageRef string
 }
 
 // RootVolumeDevice returns the volume device that should be used for
@@ -0,0 +1,191 @@
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nettools
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/vishvananda/netlink"
+
+	"github.com/Mirantis/virtlet/pkg/network"
+)
+
+const (
+	// minBurst is the minimum burst size in bytes for the
+	// bandwidth limits
+	minBurst = 32768
+	// tbfLatency is the maximum amount of time a packet can
+	// sit in the tbf queue
+	tbfLatency = "25ms"
+)
+
+// BandwidthLimits specifies the limits for the traffic of the VM
+// in bits per second. Zero value means no limit.
+type BandwidthLimits struct {
+	// Ingress is the limit for the traffic that goes to the VM
+	Ingress int64
+	// Egress is the limit for the traffic that comes from the VM
+	Egress int64
+}
+
+// burst returns the burst size in bytes for the rate specified in
+// bits per second, which corresponds to 100ms of traffic
+func burst(rate int64) string {
+	b := rate / 8 / 10
+	if b < minBurst {
+		b = minBurst
+	}
+	return strconv.FormatInt(b, 10)
+}
+
+// shapeCommand returns tc command that shapes the traffic leaving
+// the device using tbf qdisc
+func shapeCommand(devName string, rate int64) []string {
+	return []string{
+		"qdisc", "replace", "dev", devName, "root",
+		"tbf", "rate", strconv.FormatInt(rate, 10) + "bit",
+		"burst", burst(rate), "latency", tbfLatency,
+	}
+}
+
+// policeCommands return tc commands that drop the traffic entering
+// the device above the specified rate
+func policeCommands(devName string, rate int64) [][]string {
+	return [][]string{
+		{"qdisc", "replace", "dev", devName, "ingress"},
+		policeFilterCommand(devName, []string{"parent", "ffff:"}, rate),
+	}
+}
+
+// policeFilterCommand returns tc command that adds the policing
+// filter to the specified parent
+func policeFilterCommand(devName string, parent []string, rate int64) []string {
+	cmd := append([]string{"filter", "replace", "dev", devName}, parent...)
+	return append(cmd,
+		"protocol", "all", "prio", "1", "handle", "800::800",
+		"u32", "match", "u32", "0", "0",
+		"police", "rate", strconv.FormatInt(rate, 10)+"bit",
+		"burst", burst(rate), "drop")
+}
+
+// dhcpFilterCommands returns tc commands that keep the DHCP traffic
+// of the VM inside the pod in macvtap mode. There's no bridge there,
+// so ebtables can't be used. Instead, the frames that are sent by
+// the macvtap and the DHCP macvlan to the outside network are
+// filtered on the egress of the CNI interface using clsact qdisc.
+// The same qdisc is then used for policing the traffic that goes
+// to the VM, as it can't be combined with ingress qdisc.
+func dhcpFilterCommands(devName string, ipv6 bool) [][]string {
+	filter := func(prio int, protocol string, match ...string) []string {
+		cmd := []string{
+			"filter", "add", "dev", devName, "egress",
+			"protocol", protocol, "prio", strconv.Itoa(prio), "u32",
+		}
+		return append(append(cmd, match...), "action", "drop")
+	}
+	cmds := [][]string{
+		{"qdisc", "add", "dev", devName, "clsact"},
+		// dhcp responses originate from the dhcp macvlan
+		filter(2, "ip", "match", "ip", "protocol", "17", "0xff", "match", "ip", "sport", "67", "0xffff"),
+		// dhcp requests originate from the VM
+		filter(3, "ip", "match", "ip", "protocol", "17", "0xff", "match", "ip", "dport", "67", "0xffff"),
+	}
+	if ipv6 {
+		cmds = append(cmds,
+			filter(4, "ipv6", "match", "ip6", "protocol", "17", "0xff", "match", "ip6", "sport", "547", "0xffff"),
+			filter(5, "ipv6", "match", "ip6", "protocol", "17", "0xff", "match", "ip6", "dport", "547", "0xffff"),
+			// router advertisements are sent by the dhcp
+			// macvlan, too (ICMPv6 type 134)
+			filter(6, "ipv6", "match", "ip6", "protocol", "58", "0xff", "match", "u8", "134", "0xff", "at", "40"))
+	}
+	return cmds
+}
+
+// bandwidthCommands returns tc commands that apply the bandwidth
+// limits to the interfaces of the container side network. The
+// traffic going to the VM in bridge mode is shaped on tapX and the
+// traffic coming from the VM is policed there. In macvtap mode the
+// traffic going to the VM bypasses the qdiscs of the macvtap, so
+// the limits are applied to the CNI interface instead, with the
+// directions reversed. SR-IOV interfaces are not limited.
+func bandwidthCommands(csn *network.ContainerSideNetwork, limits BandwidthLimits) [][]string {
+	var cmds [][]string
+	for i, desc := range csn.Interfaces {
+		switch desc.Type {
+		case network.InterfaceTypeTap:
+			tapInterfaceName := fmt.Sprintf(tapInterfaceNameTemplate, i)
+			if limits.Ingress > 0 {
+				cmds = append(cmds, shapeCommand(tapInterfaceName, limits.Ingress))
+			}
+			if limits.Egress > 0 {
+				cmds = append(cmds, policeCommands(tapInterfaceName, limits.Egress)...)
+			}
+		case network.InterfaceTypeMacvtap:
+			// clsact qdisc is added along with the DHCP filters
+			if limits.Ingress > 0 {
+				cmds = append(cmds, policeFilterCommand(desc.Name, []string{"ingress"}, limits.Ingress))
+			}
+			if limits.Egress > 0 {
+				cmds = append(cmds, shapeCommand(desc.Name, limits.Egress))
+			}
+		}
+	}
+	return cmds
+}
+
+// SetupBandwidthLimits applies the bandwidth limits to the tap and
+// macvtap interfaces of the container side network. It replaces any
+// limits that were applied before, so it can be used during the
+// recovery, too.
+func SetupBandwidthLimits(csn *network.ContainerSideNetwork, limits BandwidthLimits) error {
+	return runTCCommands(csn.NsPath, bandwidthCommands(csn, limits))
+}
+
+// runTCCommands runs the specified tc commands in the network
+// namespace
+func runTCCommands(nsPath string, cmds [][]string) error {
+	for _, cmd := range cmds {
+		args := append([]string{"--net=" + nsPath, "tc"}, cmd...)
+		if out, err := exec.Command("nsenter", args...).CombinedOutput(); err != nil {
+			return fmt.Errorf("[netns %q] tc %s failed: %v\nOut:\n%s", nsPath, strings.Join(cmd, " "), err, out)
+		}
+	}
+
+	return nil
+}
+
+// clearBandwidthLimits removes the qdiscs that are used to apply
+// bandwidth limits and, in macvtap mode, DHCP filters from the link
+func clearBandwidthLimits(link netlink.Link) error {
+	qdiscs, err := netlink.QdiscList(link)
+	if err != nil {
+		return fmt.Errorf("failed to list qdiscs for %q: %v", link.Attrs().Name, err)
+	}
+	for _, qdisc := range qdiscs {
+		switch qdisc.Type() {
+		case "tbf", "ingress", "clsact":
+			if err := netlink.QdiscDel(qdisc); err != nil {
+				return fmt.Errorf("failed to remove %s qdisc from %q: %v", qdisc.Type(), link.Attrs().Name, err)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nettools
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Mirantis/virtlet/pkg/network"
+)
+
+func TestBandwidthCommands(t *testing.T) {
+	csn := &network.ContainerSideNetwork{
+		NsPath: "/var/run/netns/test",
+		Interfaces: []*network.InterfaceDescription{
+			{Type: network.InterfaceTypeTap, Name: "eth0"},
+			{Type: network.InterfaceTypeVF, Name: "eth1"},
+			{Type: network.InterfaceTypeMacvtap, Name: "eth2"},
+		},
+	}
+	for _, testCase := range []struct {
+		name     string
+		limits   BandwidthLimits
+		expected []string
+	}{
+		{
+			name: "no limits",
+		},
+		{
+			name:   "ingress and egress limits",
+			limits: BandwidthLimits{Ingress: 10000000, Egress: 1000000},
+			expected: []string{
+				"qdisc replace dev tap0 root tbf rate 10000000bit burst 125000 latency 25ms",
+				"qdisc replace dev tap0 ingress",
+				"filter replace dev tap0 parent ffff: protocol all prio 1 handle 800::800 u32 match u32 0 0 police rate 1000000bit burst 32768 drop",
+				"filter replace dev eth2 ingress protocol all prio 1 handle 800::800 u32 match u32 0 0 police rate 10000000bit burst 125000 drop",
+				"qdisc replace dev eth2 root tbf rate 1000000bit burst 32768 latency 25ms",
+			},
+		},
+		{
+			name:   "egress limit only",
+			limits: BandwidthLimits{Egress: 80000000},
+			expected: []string{
+				"qdisc replace dev tap0 ingress",
+				"filter replace dev tap0 parent ffff: protocol all prio 1 handle 800::800 u32 match u32 0 0 police rate 80000000bit burst 1000000 drop",
+				"qdisc replace dev eth2 root tbf rate 80000000bit burst 1000000 latency 25ms",
+			},
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			var cmds []string
+			for _, cmd := range bandwidthCommands(csn, testCase.limits) {
+				cmds = append(cmds, strings.Join(cmd, " "))
+			}
+			if !reflect.DeepEqual(cmds, testCase.expected) {
+				t.Errorf("bad tc commands: got\n%s\ninstead of\n%s", strings.Join(cmds, "\n"), strings.Join(testCase.expected, "\n"))
+			}
+		})
+	}
+}
+
+func TestDHCPFilterCommands(t *testing.T) {
+	for _, testCase := range []struct {
+		name     string
+		ipv6     bool
+		expected []string
+	}{
+		{
+			name: "ipv4",
+			expected: []string{
+				"qdisc add dev eth0 clsact",
+				"filter add dev eth0 egress protocol ip prio 2 u32 match ip protocol 17 0xff match ip sport 67 0xffff action drop",
+				"filter add dev eth0 egress protocol ip prio 3 u32 match ip protocol 17 0xff match ip dport 67 0xffff action drop",
+			},
+		},
+		{
+			name: "ipv6",
+			ipv6: true,
+			expected: []string{
+				"qdisc add dev eth0 clsact",
+				"filter add dev eth0 egress protocol ip prio 2 u32 match ip protocol 17 0xff match ip sport 67 0xffff action drop",
+				"filter add dev eth0 egress protocol ip prio 3 u32 match ip protocol 17 0xff match ip dport 67 0xffff action drop",
+				"filter add dev eth0 egress protocol ipv6 prio 4 u32 match ip6 protocol 17 0xff match ip6 sport 547 0xffff action drop",
+				"filter add dev eth0 egress protocol ipv6 prio 5 u32 match ip6 protocol 17 0xff match ip6 dport 547 0xffff action drop",
+				"filter add dev eth0 egress protocol ipv6 prio 6 u32 match ip6 protocol 58 0xff match u8 134 0xff at 40 action drop",
+			},
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			var cmds []string
+			for _, cmd := range dhcpFilterCommands("eth0", testCase.ipv6) {
+				cmds = append(cmds, strings.Join(cmd, " "))
+			}
+			if !reflect.DeepEqual(cmds, testCase.expected) {
+				t.Errorf("bad tc commands: got\n%s\ninstead of\n%s", strings.Join(cmds, "\n"), strings.Join(testCase.expected, "\n"))
+			}
+		})
+	}
+}
@@ -35,6 +35,7 @@ package nettools
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"errors"
 	"fmt"
 	"log"
@@ -58,6 +59,7 @@ const (
 	defaultMTU                  = 1500
 	tapInterfaceNameTemplate    = "tap%d"
 	containerBridgeNameTemplate = "br%d"
+	dhcpInterfaceNameTemplate   = "dhcp%d"
 	loopbackInterfaceName       = "lo"
 	// Address for dhcp server internal interface
 	internalDhcpAddr = "169.254.254.2/24"
@@ -287,16 +289,46 @@ func findLinkByAddress(links []netlink.Link, address net.IPNet) (netlink.Link, e
 	return nil, fmt.Errorf("interface with address %q not found in the container namespace", address.String())
 }
 
+// isSingleInterfaceResult returns true if the CNI result contains
+// either a single IP address or an IPv4 and an IPv6 address that
+// belong to the same interface.
+func isSingleInterfaceResult(netConfig *cnicurrent.Result) bool {
+	switch len(netConfig.IPs) {
+	case 1:
+		return true
+	case 2:
+		ip1, ip2 := netConfig.IPs[0], netConfig.IPs[1]
+		return ip1.Interface == ip2.Interface && (ip1.Address.IP.To4() == nil) != (ip2.Address.IP.To4() == nil)
+	default:
+		return false
+	}
+}
+
+// hasRoutesForAllFamilies returns true if the CNI result contains
+// at least one route for each address family of its IP addresses.
+func hasRoutesForAllFamilies(netConfig *cnicurrent.Result) bool {
+	hasRoutes := make(map[bool]bool)
+	for _, route := range netConfig.Routes {
+		hasRoutes[route.Dst.IP.To4() == nil] = true
+	}
+	for _, ipConfig := range netConfig.IPs {
+		if !hasRoutes[ipConfig.Address.IP.To4() == nil] {
+			return false
+		}
+	}
+	return true
+}
+
 // ValidateAndFixCNIResult verifies that netConfig contains proper list of
 // ips, routes, interfaces and if something is missing it tries to complement
 // that using patch for Weave or for plugins which return their netConfig
 // in v0.2.0 version of CNI SPEC
 func ValidateAndFixCNIResult(netConfig *cnicurrent.Result, nsPath string, allLinks []netlink.Link) (*cnicurrent.Result, error) {
-	// If there are no routes provided, we consider it a broken
-	// config and extract interface config instead. That's the
-	// case with Weave CNI plugin. We don't do this for multiple CNI
-	// at this point.
-	if len(netConfig.IPs) == 1 && (cni.GetPodIP(netConfig) == "" || len(netConfig.Routes) == 0) {
+	// If there are no routes provided for an address family, we
+	// consider it a broken config and extract interface config
+	// instead. That's the case with Weave CNI plugin. We don't do
+	// this for multiple CNI at this point.
+	if isSingleInterfaceResult(netConfig) && (cni.GetPodIP(netConfig) == "" || !hasRoutesForAllFamilies(netConfig)) {
 		dnsInfo := netConfig.DNS
 
 		veth, err := FindVeth(allLinks)
@@ -385,14 +417,15 @@ func getContainerLinks(info *cnicurrent.Result) ([]netlink.Link, error) {
 
 // StripLink removes addresses from the link
 // along with any routes related to the link, except
-// those created by the kernel
+// those created by the kernel. IPv6 link-local
+// addresses are kept.
 func StripLink(link netlink.Link) error {
-	routes, err := netlink.RouteList(link, FAMILY_V4)
+	routes, err := netlink.RouteList(link, FAMILY_ALL)
 	if err != nil {
 		return fmt.Errorf("failed to list routes: %v", err)
 	}
 
-	addrs, err := netlink.AddrList(link, FAMILY_V4)
+	addrs, err := netlink.AddrList(link, FAMILY_ALL)
 	if err != nil {
 		return fmt.Errorf("failed to get addresses for link: %v", err)
 	}
@@ -408,6 +441,9 @@ func StripLink(link netlink.Link) error {
 	}
 
 	for _, addr := range addrs {
+		if isIPv6LinkLocal(addr.IP) {
+			continue
+		}
 		if err = netlink.AddrDel(link, &addr); err != nil {
 			return fmt.Errorf("error deleting address from the route: %v", err)
 		}
@@ -416,21 +452,28 @@ func StripLink(link netlink.Link) error {
 	return nil
 }
 
-// ExtractLinkInfo extracts ip address and netmask from veth
+func isIPv6LinkLocal(ip net.IP) bool {
+	return ip.To4() == nil && ip.IsLinkLocalUnicast()
+}
+
+// hasIPv6 returns true if the CNI result contains any IPv6 addresses
+func hasIPv6(info *cnicurrent.Result) bool {
+	for _, ipConfig := range info.IPs {
+		if ipConfig.Version == "6" {
+			return true
+		}
+	}
+	return false
+}
+
+// ExtractLinkInfo extracts ip addresses and netmasks from veth
 // interface in the current namespace, together with routes for this
 // interface.
 // There must be exactly one veth interface in the namespace
-// and exactly one address associated with veth.
+// and at most one IPv4 and one IPv6 address associated with veth,
+// not counting IPv6 link-local addresses.
 // Returns interface info struct and error, if any.
 func ExtractLinkInfo(link netlink.Link, nsPath string) (*cnicurrent.Result, error) {
-	addrs, err := netlink.AddrList(link, FAMILY_V4)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get addresses for link: %v", err)
-	}
-	if len(addrs) != 1 {
-		return nil, fmt.Errorf("expected exactly one address for link, but got %v", addrs)
-	}
-
 	result := &cnicurrent.Result{
 		Interfaces: []*cnicurrent.Interface{
 			{
@@ -439,16 +482,41 @@ func ExtractLinkInfo(link netlink.Link, nsPath string) (*cnicurrent.Result, erro
 				Sandbox: nsPath,
 			},
 		},
-		IPs: []*cnicurrent.IPConfig{
-			{
-				Version:   "4",
+	}
+
+	for _, f := range []struct {
+		family  int
+		version string
+	}{
+		{FAMILY_V4, "4"},
+		{FAMILY_V6, "6"},
+	} {
+		allAddrs, err := netlink.AddrList(link, f.family)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get addresses for link: %v", err)
+		}
+		var addrs []netlink.Addr
+		for _, addr := range allAddrs {
+			if !isIPv6LinkLocal(addr.IP) {
+				addrs = append(addrs, addr)
+			}
+		}
+		if len(addrs) > 1 {
+			return nil, fmt.Errorf("expected at most one IPv%s address for link, but got %v", f.version, addrs)
+		}
+		if len(addrs) == 1 {
+			result.IPs = append(result.IPs, &cnicurrent.IPConfig{
+				Version:   f.version,
 				Interface: 0,
 				Address:   *addrs[0].IPNet,
-			},
-		},
+			})
+		}
+	}
+	if len(result.IPs) == 0 {
+		return nil, errors.New("expected an address for link, but got none")
 	}
 
-	routes, err := netlink.RouteList(link, FAMILY_V4)
+	routes, err := netlink.RouteList(link, FAMILY_ALL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list routes: %v", err)
 	}
@@ -464,13 +532,26 @@ func ExtractLinkInfo(link netlink.Link, nsPath string) (*cnicurrent.Result, erro
 		case (route.Dst == nil || route.Dst.IP == nil) && route.Gw == nil:
 			// route has only Src
 		case (route.Dst == nil || route.Dst.IP == nil):
-			result.IPs[0].Gateway = route.Gw
+			dst := net.IPNet{
+				IP:   net.IP{0, 0, 0, 0},
+				Mask: net.IPMask{0, 0, 0, 0},
+			}
+			version := "4"
+			if route.Gw.To4() == nil {
+				dst = net.IPNet{
+					IP:   net.IPv6zero,
+					Mask: net.CIDRMask(0, 128),
+				}
+				version = "6"
+			}
+			for _, ipConfig := range result.IPs {
+				if ipConfig.Version == version {
+					ipConfig.Gateway = route.Gw
+				}
+			}
 			result.Routes = append(result.Routes, &cnitypes.Route{
-				Dst: net.IPNet{
-					IP:   net.IP{0, 0, 0, 0},
-					Mask: net.IPMask{0, 0, 0, 0},
-				},
-				GW: route.Gw,
+				Dst: dst,
+				GW:  route.Gw,
 			})
 		default:
 			result.Routes = append(result.Routes, &cnitypes.Route{
@@ -503,18 +584,29 @@ func bringUpLoopback() error {
 	return nil
 }
 
-func updateEbTables(nsPath, interfaceName, command string) error {
+func updateEbTables(nsPath, interfaceName, command string, ipv6 bool) error {
+	type ebRule struct {
+		chain string
+		match []string
+	}
 	// block/unblock DHCP traffic from/to CNI-provided link
-	for _, item := range []struct{ chain, opt string }{
+	rules := []ebRule{
 		// dhcp responses originate from bridge itself
-		{"OUTPUT", "--ip-source-port"},
+		{"OUTPUT", []string{"-p", "IPV4", "--ip-protocol", "UDP", "--ip-source-port", "67"}},
 		// dhcp requests originate from the VM
-		{"FORWARD", "--ip-destination-port"},
-	} {
-		if out, err := exec.Command(
-			"nsenter", "--net="+nsPath,
-			"ebtables", command, item.chain, "-p", "IPV4", "--ip-protocol", "UDP",
-			item.opt, "67", "--out-if", interfaceName, "-j", "DROP").CombinedOutput(); err != nil {
+		{"FORWARD", []string{"-p", "IPV4", "--ip-protocol", "UDP", "--ip-destination-port", "67"}},
+	}
+	if ipv6 {
+		rules = append(rules,
+			ebRule{"OUTPUT", []string{"-p", "IPV6", "--ip6-protocol", "UDP", "--ip6-source-port", "547"}},
+			ebRule{"FORWARD", []string{"-p", "IPV6", "--ip6-protocol", "UDP", "--ip6-destination-port", "547"}},
+			// router advertisements are sent by the bridge, too
+			ebRule{"OUTPUT", []string{"-p", "IPV6", "--ip6-protocol", "ipv6-icmp", "--ip6-icmp-type", "router-advertisement"}})
+	}
+	for _, rule := range rules {
+		args := append([]string{"--net=" + nsPath, "ebtables", command, rule.chain}, rule.match...)
+		args = append(args, "--out-if", interfaceName, "-j", "DROP")
+		if out, err := exec.Command("nsenter", args...).CombinedOutput(); err != nil {
 			return fmt.Errorf("[netns %q] ebtables failed: %v\nOut:\n%s", nsPath, err, out)
 		}
 	}
@@ -545,7 +637,20 @@ func SetHardwareAddr(link netlink.Link, hwAddr net.HardwareAddr) error {
 	return nil
 }
 
-func setupTapAndGetInterfaceDescription(link netlink.Link, nsPath string, ifaceNo int) (*network.InterfaceDescription, error) {
+// TapSettings specifies the settings for a tap interface.
+type TapSettings struct {
+	// QueueCount specifies the number of queues for multiqueue
+	// tap. Values less than 2 denote a single queue tap.
+	QueueCount int
+	// Vhost specifies whether vhost-net should be used for the
+	// tap if it's available on the node.
+	Vhost bool
+	// Macvtap specifies whether a macvtap should be used instead
+	// of a tap connected to the CNI interface using a bridge.
+	Macvtap bool
+}
+
+func setupTapAndGetInterfaceDescription(link netlink.Link, nsPath string, ifaceNo int, settings TapSettings, ipv6 bool) (*network.InterfaceDescription, error) {
 	hwAddr := link.Attrs().HardwareAddr
 	ifaceName := link.Attrs().Name
 
@@ -560,7 +665,7 @@ func setupTapAndGetInterfaceDescription(link netlink.Link, nsPath string, ifaceN
 	}
 
 	tapInterfaceName := fmt.Sprintf(tapInterfaceNameTemplate, ifaceNo)
-	tap, err := CreateTAP(tapInterfaceName, mtu)
+	tap, err := CreateTAP(tapInterfaceName, mtu, settings.QueueCount > 1)
 	if err != nil {
 		return nil, err
 	}
@@ -576,7 +681,7 @@ func setupTapAndGetInterfaceDescription(link netlink.Link, nsPath string, ifaceN
 	}
 
 	// Add ebtables DHCP blocking rules
-	if err := updateEbTables(nsPath, ifaceName, "-A"); err != nil {
+	if err := updateEbTables(nsPath, ifaceName, "-A", ipv6); err != nil {
 		return nil, err
 	}
 
@@ -591,20 +696,137 @@ func setupTapAndGetInterfaceDescription(link netlink.Link, nsPath string, ifaceN
 		return nil, err
 	}
 
+	desc := &network.InterfaceDescription{
+		Type:         network.InterfaceTypeTap,
+		Name:         ifaceName,
+		HardwareAddr: hwAddr,
+		MTU:          uint16(mtu),
+		Vhost:        settings.Vhost,
+	}
+	if settings.QueueCount > 1 {
+		desc.QueueCount = settings.QueueCount
+	}
+
 	glog.V(3).Infof("Opening tap interface %q for link %q", tapInterfaceName, ifaceName)
-	fo, err := OpenTAP(tapInterfaceName)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open tap: %v", err)
+	if err := openTapFiles(desc, tapInterfaceName); err != nil {
+		return nil, err
 	}
 	glog.V(3).Infof("Adding interface %q as %q", ifaceName, tapInterfaceName)
 
-	return &network.InterfaceDescription{
-		Type:         network.InterfaceTypeTap,
+	return desc, nil
+}
+
+func setupMacvtapAndGetInterfaceDescription(link netlink.Link, nsPath string, ifaceNo int, settings TapSettings, ipv6 bool) (*network.InterfaceDescription, error) {
+	hwAddr := link.Attrs().HardwareAddr
+	ifaceName := link.Attrs().Name
+
+	mtu := link.Attrs().MTU
+
+	// The VM takes over the hardware address of the CNI
+	// interface, so the macvtap gets it
+	newHwAddr, err := GenerateMacAddress()
+	if err == nil {
+		err = SetHardwareAddr(link, newHwAddr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	tapInterfaceName := fmt.Sprintf(tapInterfaceNameTemplate, ifaceNo)
+	if _, err := CreateMacvtap(tapInterfaceName, link, hwAddr); err != nil {
+		return nil, err
+	}
+
+	// The frames sent by macvtap in bridge mode don't reach the
+	// parent link itself but are seen by its other macvlans, so
+	// the DHCP server gets a macvlan of its own
+	dhcpInterfaceName := fmt.Sprintf(dhcpInterfaceNameTemplate, ifaceNo)
+	dhcpLink, err := CreateMacvlan(dhcpInterfaceName, link)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := netlink.AddrAdd(dhcpLink, mustParseAddr(internalDhcpAddr)); err != nil {
+		return nil, fmt.Errorf("failed to set address for the dhcp interface: %v", err)
+	}
+
+	// Add tc DHCP blocking filters
+	if err := runTCCommands(nsPath, dhcpFilterCommands(ifaceName, ipv6)); err != nil {
+		return nil, err
+	}
+
+	if err := bringUpLoopback(); err != nil {
+		return nil, err
+	}
+
+	desc := &network.InterfaceDescription{
+		Type:         network.InterfaceTypeMacvtap,
 		Name:         ifaceName,
-		Fo:           fo,
 		HardwareAddr: hwAddr,
 		MTU:          uint16(mtu),
-	}, nil
+		Vhost:        settings.Vhost,
+	}
+	if settings.QueueCount > 1 {
+		desc.QueueCount = settings.QueueCount
+	}
+
+	glog.V(3).Infof("Opening macvtap interface %q for link %q", tapInterfaceName, ifaceName)
+	if err := openTapFiles(desc, tapInterfaceName); err != nil {
+		return nil, err
+	}
+	glog.V(3).Infof("Adding interface %q as %q", ifaceName, tapInterfaceName)
+
+	return desc, nil
+}
+
+// openTapFiles opens the tap or macvtap device queues for the
+// interface along with vhost-net files, if vhost-net is enabled for
+// it. If vhost-net isn't available on the node, it gets disabled
+// for the interface.
+func openTapFiles(desc *network.InterfaceDescription, tapInterfaceName string) error {
+	if desc.Type == network.InterfaceTypeMacvtap {
+		queueCount := desc.QueueCount
+		if queueCount < 1 {
+			queueCount = 1
+		}
+		fos, err := OpenMacvtapQueues(tapInterfaceName, queueCount)
+		if err != nil {
+			return err
+		}
+		desc.Fo, desc.QueueFos = fos[0], fos[1:]
+	} else if desc.QueueCount > 1 {
+		fos, err := OpenTAPQueues(tapInterfaceName, desc.QueueCount)
+		if err != nil {
+			return fmt.Errorf("failed to open tap queues: %v", err)
+		}
+		desc.Fo, desc.QueueFos = fos[0], fos[1:]
+	} else {
+		fo, err := OpenTAP(tapInterfaceName)
+		if err != nil {
+			return fmt.Errorf("failed to open tap: %v", err)
+		}
+		desc.Fo, desc.QueueFos = fo, nil
+	}
+
+	desc.VhostFos = nil
+	if !desc.Vhost {
+		return nil
+	}
+	queueCount := 1 + len(desc.QueueFos)
+	for i := 0; i < queueCount; i++ {
+		fo, err := OpenVhostNet()
+		if err != nil {
+			glog.Warningf("Can't open vhost-net, disabling vhost for %q: %v", tapInterfaceName, err)
+			for _, f := range desc.VhostFos {
+				f.Close()
+			}
+			desc.VhostFos = nil
+			desc.Vhost = false
+			return nil
+		}
+		desc.VhostFos = append(desc.VhostFos, fo)
+	}
+	return nil
 }
 
 // SetupContainerSideNetwork sets up networking in container
@@ -615,10 +837,15 @@ func setupTapAndGetInterfaceDescription(link netlink.Link, nsPath string, ifaceN
 // with X denoting an link index in info.Interfaces list.
 // Each bridge gets assigned a link-local address to be used
 // for dhcp server.
+// The settings of tapX are determined by tapSettings function, which
+// may be nil if single queue taps without vhost-net are to be used.
+// If macvtap is requested in the settings, tapX is a macvtap on top
+// of the CNI interface and there's no brX. In this case, dhcpX
+// macvlan is used to get the link-local address for the dhcp server.
 // In case of SR-IOV VFs this function only sets up a device to be passed to VM.
 // The function should be called from within container namespace.
 // Returns container network struct and an error, if any.
-func SetupContainerSideNetwork(info *cnicurrent.Result, nsPath string, allLinks []netlink.Link, enableSriov bool, hostNS ns.NetNS) (*network.ContainerSideNetwork, error) {
+func SetupContainerSideNetwork(info *cnicurrent.Result, nsPath string, allLinks []netlink.Link, enableSriov bool, hostNS ns.NetNS, tapSettings func(ifaceNo int) TapSettings) (*network.ContainerSideNetwork, error) {
 	contLinks, err := getContainerLinks(info)
 	if err != nil {
 		return nil, err
@@ -644,7 +871,16 @@ func SetupContainerSideNetwork(info *cnicurrent.Result, nsPath string, allLinks
 				return nil, err
 			}
 		} else {
-			if ifDesc, err = setupTapAndGetInterfaceDescription(link, nsPath, i); err != nil {
+			var settings TapSettings
+			if tapSettings != nil {
+				settings = tapSettings(i)
+			}
+			if settings.Macvtap {
+				ifDesc, err = setupMacvtapAndGetInterfaceDescription(link, nsPath, i, settings, hasIPv6(info))
+			} else {
+				ifDesc, err = setupTapAndGetInterfaceDescription(link, nsPath, i, settings, hasIPv6(info))
+			}
+			if err != nil {
 				return nil, err
 			}
 		}
@@ -656,8 +892,11 @@ func SetupContainerSideNetwork(info *cnicurrent.Result, nsPath string, allLinks
 }
 
 // RecoverContainerSideNetwork tries to populate ContainerSideNetwork
-// structure based on a network namespace that was already adjusted for Virtlet
-func RecoverContainerSideNetwork(csn *network.ContainerSideNetwork, nsPath string, allLinks []netlink.Link, hostNS ns.NetNS) error {
+// structure based on a network namespace that was already adjusted for Virtlet.
+// reopenTaps must be false if the VM may still be running, because
+// reopening a multiqueue tap or a macvtap that's in use by the VM
+// adds a queue to it instead of failing.
+func RecoverContainerSideNetwork(csn *network.ContainerSideNetwork, nsPath string, allLinks []netlink.Link, hostNS ns.NetNS, reopenTaps bool) error {
 	if len(csn.Result.Interfaces) == 0 {
 		return fmt.Errorf("wrong cni configuration: no interfaces defined: %s", spew.Sdump(csn.Result))
 	}
@@ -672,7 +911,7 @@ func RecoverContainerSideNetwork(csn *network.ContainerSideNetwork, nsPath strin
 		oldDescs[desc.Name] = desc
 	}
 
-	for _, link := range contLinks {
+	for i, link := range contLinks {
 		// Skip missing link which is already used by running VM
 		if link == nil {
 			continue
@@ -705,9 +944,15 @@ func RecoverContainerSideNetwork(csn *network.ContainerSideNetwork, nsPath strin
 			bindDeviceToVFIO(devIdentifier)
 		} else {
 			ifaceType = network.InterfaceTypeTap
-			// It's OK if OpenTAP failed as the device is busy and used by running VM
-			if fo, err := OpenTAP(link.Attrs().Name); err == nil {
-				desc.Fo = fo
+			if desc.Type == network.InterfaceTypeMacvtap {
+				ifaceType = network.InterfaceTypeMacvtap
+			}
+			tapInterfaceName := fmt.Sprintf(tapInterfaceNameTemplate, i)
+			// It's OK if opening the tap failed as the device is busy and used by running VM
+			if reopenTaps {
+				if err := openTapFiles(desc, tapInterfaceName); err != nil {
+					glog.V(3).Infof("Not reopening tap %q: %v", tapInterfaceName, err)
+				}
 			}
 		}
 		if desc.Type != ifaceType {
@@ -773,6 +1018,47 @@ func ConfigureLink(link netlink.Link, info *cnicurrent.Result) error {
 	return nil
 }
 
+// teardownMacvtap removes the macvtap and the dhcp macvlan that
+// were set up on top of the CNI link along with the DHCP filters and
+// bandwidth limits and restores the hardware address of the link.
+func teardownMacvtap(contLink netlink.Link, ifaceNo int, hwAddr net.HardwareAddr) error {
+	if err := clearBandwidthLimits(contLink); err != nil {
+		return err
+	}
+
+	for _, name := range []string{
+		fmt.Sprintf(tapInterfaceNameTemplate, ifaceNo),
+		fmt.Sprintf(dhcpInterfaceNameTemplate, ifaceNo),
+	} {
+		link, err := netlink.LinkByName(name)
+		if err != nil {
+			return err
+		}
+		if err := netlink.LinkDel(link); err != nil {
+			return err
+		}
+	}
+
+	return SetHardwareAddr(contLink, hwAddr)
+}
+
+// DHCPLinkNames returns the names of the links in the container
+// network namespace that are connected to the VM interfaces and are
+// used to serve DHCP, in the order of the interfaces. The name is
+// empty for the interfaces that have no such link, i.e. SR-IOV VFs.
+func DHCPLinkNames(csn *network.ContainerSideNetwork) []string {
+	names := make([]string, len(csn.Interfaces))
+	for i, desc := range csn.Interfaces {
+		switch desc.Type {
+		case network.InterfaceTypeTap:
+			names[i] = fmt.Sprintf(containerBridgeNameTemplate, i)
+		case network.InterfaceTypeMacvtap:
+			names[i] = fmt.Sprintf(dhcpInterfaceNameTemplate, i)
+		}
+	}
+	return names
+}
+
 // Teardown cleans up container network configuration.
 // It does so by invoking teardown sequence which removes ebtables rules, links
 // and addresses in an order opposite to that of their creation in SetupContainerSideNetwork.
@@ -780,7 +1066,7 @@ func ConfigureLink(link netlink.Link, info *cnicurrent.Result) error {
 // as it was before SetupContainerSideNetwork() call.
 func Teardown(csn *network.ContainerSideNetwork) error {
 	for _, i := range csn.Interfaces {
-		i.Fo.Close()
+		i.CloseFiles()
 	}
 
 	contLinks, err := getContainerLinks(csn.Result)
@@ -793,12 +1079,18 @@ func Teardown(csn *network.ContainerSideNetwork) error {
 			return fmt.Errorf("missing %d link during teardown", i)
 		}
 
-		// Remove ebtables DHCP rules
-		if err := updateEbTables(csn.NsPath, contLink.Attrs().Name, "-D"); err != nil {
-			return nil
+		if csn.Interfaces[i].Type == network.InterfaceTypeMacvtap {
+			if err := teardownMacvtap(contLink, i, csn.Interfaces[i].HardwareAddr); err != nil {
+				return err
+			}
+		} else {
+			// Remove ebtables DHCP rules
+			if err := updateEbTables(csn.NsPath, contLink.Attrs().Name, "-D", hasIPv6(csn.Result)); err != nil {
+				return nil
+			}
 		}
 
-		if !isSriovVf(contLink) {
+		if csn.Interfaces[i].Type != network.InterfaceTypeMacvtap && !isSriovVf(contLink) {
 			tapInterfaceName := fmt.Sprintf(tapInterfaceNameTemplate, i)
 			tap, err := netlink.LinkByName(tapInterfaceName)
 			if err != nil {
@@ -848,6 +1140,51 @@ func Teardown(csn *network.ContainerSideNetwork) error {
 	return nil
 }
 
+// SetHardwareAddrs sets the hardware addresses of the CNI interfaces
+// in the container network namespace to the ones returned by hwAddr
+// function and updates the CNI result accordingly. The VM interfaces
+// get these hardware addresses. hwAddr may return nil for the
+// interfaces that should keep the addresses set by CNI.
+func SetHardwareAddrs(info *cnicurrent.Result, hwAddr func(ifaceNo int) net.HardwareAddr) error {
+	contLinks, err := getContainerLinks(info)
+	if err != nil {
+		return err
+	}
+
+	for i, link := range contLinks {
+		if link == nil {
+			return fmt.Errorf("missing link #%d in the container network namespace (Virtlet pod restarted?)", i)
+		}
+		newHwAddr := hwAddr(i)
+		if newHwAddr == nil {
+			continue
+		}
+		ifaceName := link.Attrs().Name
+		if err := SetHardwareAddr(link, newHwAddr); err != nil {
+			return fmt.Errorf("can't set hardware address %q for %q: %v", newHwAddr, ifaceName, err)
+		}
+		for _, iface := range info.Interfaces {
+			if iface.Name == ifaceName && iface.Sandbox != "" {
+				iface.Mac = newHwAddr.String()
+			}
+		}
+	}
+
+	return nil
+}
+
+// StableMacAddress returns a locally administrated unicast hardware
+// address for the specified interface of the pod that's derived from
+// the namespace and the name of the pod, so it doesn't change when
+// the pod is recreated.
+func StableMacAddress(podNs, podName string, ifaceNo int) net.HardwareAddr {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s/%s/%d", podNs, podName, ifaceNo)))
+	mac := net.HardwareAddr(sum[:6])
+	// locally administred unicast
+	mac[0] = mac[0]&^1 | 2
+	return mac
+}
+
 // GenerateMacAddress returns a random locally administrated unicast
 // hardware address.
 // Copied from:
@@ -27,6 +27,7 @@ import (
 const (
 	FAMILY_ALL     = netlink.FAMILY_ALL
 	FAMILY_V4      = netlink.FAMILY_V4
+	FAMILY_V6      = netlink.FAMILY_V6
 	RTPROT_KERNEL  = syscall.RTPROT_KERNEL
 	SCOPE_LINK     = netlink.SCOPE_LINK
 	SCOPE_UNIVERSE = netlink.SCOPE_UNIVERSE
@@ -29,6 +29,8 @@ import (
 	cnicurrent "github.com/containernetworking/cni/pkg/types/current"
 	"github.com/davecgh/go-spew/spew"
 	"github.com/vishvananda/netlink"
+
+	"github.com/Mirantis/virtlet/pkg/network"
 )
 
 const (
@@ -347,6 +349,79 @@ func TestExtractLinkInfo(t *testing.T) {
 	})
 }
 
+func withFakeDualStackCNIVethAndGateways(t *testing.T, mtu int, toRun func(hostNS, contNS ns.NetNS, origHostVeth, origContVeth netlink.Link)) {
+	withFakeCNIVethAndGateway(t, mtu, func(hostNS, contNS ns.NetNS, origHostVeth, origContVeth netlink.Link) {
+		if err := netlink.AddrAdd(origContVeth, parseAddr("fd00:1234::5/64")); err != nil {
+			log.Panicf("failed to add IPv6 addr for origContVeth: %v", err)
+		}
+		addTestRoute(t, &netlink.Route{
+			LinkIndex: origContVeth.Attrs().Index,
+			Gw:        net.ParseIP("fd00:1234::1"),
+			Scope:     SCOPE_UNIVERSE,
+		})
+
+		toRun(hostNS, contNS, origHostVeth, origContVeth)
+	})
+}
+
+func expectedExtractedDualStackLinkInfo(contNsPath string) *cnicurrent.Result {
+	expectedInfo := expectedExtractedLinkInfo(contNsPath)
+	expectedInfo.IPs = append(expectedInfo.IPs, &cnicurrent.IPConfig{
+		Version:   "6",
+		Interface: 0,
+		Address: net.IPNet{
+			IP:   net.ParseIP("fd00:1234::5"),
+			Mask: net.CIDRMask(64, 128),
+		},
+		Gateway: net.ParseIP("fd00:1234::1"),
+	})
+	expectedInfo.Routes = append(expectedInfo.Routes, &cnitypes.Route{
+		Dst: net.IPNet{
+			IP:   net.IPv6zero,
+			Mask: net.CIDRMask(0, 128),
+		},
+		GW: net.ParseIP("fd00:1234::1"),
+	})
+	return expectedInfo
+}
+
+func TestExtractDualStackLinkInfo(t *testing.T) {
+	withFakeDualStackCNIVethAndGateways(t, defaultMTU, func(hostNS, contNS ns.NetNS, origHostVeth, origContVeth netlink.Link) {
+		info, err := ExtractLinkInfo(origContVeth, contNS.Path())
+		if err != nil {
+			log.Panicf("failed to grab interface info: %v", err)
+		}
+		expectedInfo := expectedExtractedDualStackLinkInfo(contNS.Path())
+		if !reflect.DeepEqual(info, expectedInfo) {
+			t.Errorf("interface info mismatch. Expected:\n%s\nActual:\n%s",
+				spew.Sdump(expectedInfo), spew.Sdump(*info))
+		}
+
+		if err := StripLink(origContVeth); err != nil {
+			log.Panicf("StripLink() failed: %v", err)
+		}
+		verifyNoAddressAndRoutes(t, origContVeth)
+		if addrs, err := netlink.AddrList(origContVeth, FAMILY_V6); err != nil {
+			t.Errorf("failed to get IPv6 addresses for veth: %v", err)
+		} else {
+			for _, addr := range addrs {
+				if !addr.IP.IsLinkLocalUnicast() {
+					t.Errorf("unexpected IPv6 address remains on the interface: %s", addr.IP)
+				}
+			}
+		}
+		if routes, err := netlink.RouteList(origContVeth, FAMILY_V6); err != nil {
+			t.Errorf("failed to get IPv6 route list: %v", err)
+		} else {
+			for _, route := range routes {
+				if route.Protocol != RTPROT_KERNEL {
+					t.Errorf("unexpected IPv6 route remains on the interface: %s", spew.Sdump(route))
+				}
+			}
+		}
+	})
+}
+
 func verifyContainerSideNetwork(t *testing.T, origContVeth netlink.Link, contNsPath string, hostNS ns.NetNS, mtu int) {
 	allLinks, err := netlink.LinkList()
 	if err != nil {
@@ -355,7 +430,7 @@ func verifyContainerSideNetwork(t *testing.T, origContVeth netlink.Link, contNsP
 
 	origHwAddr := origContVeth.Attrs().HardwareAddr
 	expectedInfo := expectedExtractedLinkInfo(contNsPath)
-	csn, err := SetupContainerSideNetwork(expectedInfo, contNsPath, allLinks, false, hostNS)
+	csn, err := SetupContainerSideNetwork(expectedInfo, contNsPath, allLinks, false, hostNS, nil)
 	if err != nil {
 		log.Panicf("failed to set up container side network: %v", err)
 	}
@@ -511,7 +586,7 @@ func TestTeardownContainerSideNetwork(t *testing.T) {
 			log.Panicf("error listing links: %v", err)
 		}
 
-		csn, err := SetupContainerSideNetwork(expectedExtractedLinkInfo(contNS.Path()), contNS.Path(), allLinks, false, hostNS)
+		csn, err := SetupContainerSideNetwork(expectedExtractedLinkInfo(contNS.Path()), contNS.Path(), allLinks, false, hostNS, nil)
 		if err != nil {
 			log.Panicf("failed to set up container side network: %v", err)
 		}
@@ -534,6 +609,67 @@ func TestTeardownContainerSideNetwork(t *testing.T) {
 	})
 }
 
+func TestRecoverMultiqueueTap(t *testing.T) {
+	withFakeCNIVethAndGateway(t, defaultMTU, func(hostNS, contNS ns.NetNS, origHostVeth, origContVeth netlink.Link) {
+		if err := StripLink(origContVeth); err != nil {
+			log.Panicf("StripLink() failed: %v", err)
+		}
+		allLinks, err := netlink.LinkList()
+		if err != nil {
+			log.Panicf("error listing links: %v", err)
+		}
+
+		csn, err := SetupContainerSideNetwork(expectedExtractedLinkInfo(contNS.Path()), contNS.Path(), allLinks, false, hostNS, func(ifaceNo int) TapSettings {
+			return TapSettings{QueueCount: 2}
+		})
+		if err != nil {
+			log.Panicf("failed to set up container side network: %v", err)
+		}
+
+		// the description as it's stored in the metadata,
+		// without the open files
+		recoveredCSN := func() *network.ContainerSideNetwork {
+			desc := *csn.Interfaces[0]
+			desc.Fo, desc.QueueFos, desc.VhostFos = nil, nil, nil
+			return &network.ContainerSideNetwork{
+				Result:     csn.Result,
+				NsPath:     csn.NsPath,
+				Interfaces: []*network.InterfaceDescription{&desc},
+			}
+		}
+
+		// the VM is still running and uses the tap queues
+		runningCSN := recoveredCSN()
+		if err := RecoverContainerSideNetwork(runningCSN, contNS.Path(), allLinks, hostNS, false); err != nil {
+			log.Panicf("failed to recover container side network: %v", err)
+		}
+		if desc := runningCSN.Interfaces[0]; desc.Fo != nil || desc.QueueFos != nil {
+			t.Errorf("the tap queues of the running VM were reopened")
+			desc.CloseFiles()
+		}
+
+		// the VM has exited
+		if err := csn.Interfaces[0].CloseFiles(); err != nil {
+			log.Panicf("failed to close the tap queues: %v", err)
+		}
+		exitedCSN := recoveredCSN()
+		if err := RecoverContainerSideNetwork(exitedCSN, contNS.Path(), allLinks, hostNS, true); err != nil {
+			log.Panicf("failed to recover container side network: %v", err)
+		}
+		desc := exitedCSN.Interfaces[0]
+		if desc.Fo == nil || len(desc.QueueFos) != 1 {
+			t.Errorf("the tap queues were not reopened: %s", spew.Sdump(desc))
+		}
+		if err := desc.CloseFiles(); err != nil {
+			log.Panicf("failed to close the tap queues: %v", err)
+		}
+
+		if err := Teardown(csn); err != nil {
+			log.Panicf("failed to tear down container side network: %v", err)
+		}
+	})
+}
+
 func TestFindingLinkByAddress(t *testing.T) {
 	withFakeCNIVeth(t, defaultMTU, func(hostNS, contNS ns.NetNS, origHostVeth, origContVeth netlink.Link) {
 		expectedInfo := expectedExtractedLinkInfo(contNS.Path())
@@ -560,6 +696,60 @@ func TestFindingLinkByAddress(t *testing.T) {
 	})
 }
 
+func TestSetHardwareAddrs(t *testing.T) {
+	withMultipleInterfacesConfigured(t, func(contNS ns.NetNS, innerLinks []netlink.Link) {
+		newHwAddr, err := net.ParseMAC("42:a4:a6:22:80:30")
+		if err != nil {
+			t.Fatalf("ParseMAC(): %v", err)
+		}
+		info := expectedExtractedLinkInfoForMultipleInterfaces(contNS.Path())
+		if err := SetHardwareAddrs(info, func(ifaceNo int) net.HardwareAddr {
+			if ifaceNo == 1 {
+				return newHwAddr
+			}
+			return nil
+		}); err != nil {
+			t.Fatalf("SetHardwareAddrs(): %v", err)
+		}
+
+		expectedInfo := expectedExtractedLinkInfoForMultipleInterfaces(contNS.Path())
+		expectedInfo.Interfaces[1].Mac = newHwAddr.String()
+		if !reflect.DeepEqual(info, expectedInfo) {
+			t.Errorf("result different than expected:\nActual:\n%s\nExpected:\n%s",
+				spew.Sdump(info), spew.Sdump(expectedInfo))
+		}
+
+		for n, expectedHwAddr := range []string{innerHwAddr, newHwAddr.String()} {
+			link, err := netlink.LinkByName(innerLinks[n].Attrs().Name)
+			if err != nil {
+				t.Fatalf("LinkByName(): %v", err)
+			}
+			if hwAddr := link.Attrs().HardwareAddr.String(); hwAddr != expectedHwAddr {
+				t.Errorf("bad hardware address for %q: %s instead of %s", link.Attrs().Name, hwAddr, expectedHwAddr)
+			}
+		}
+	})
+}
+
+func TestStableMacAddress(t *testing.T) {
+	hwAddr := StableMacAddress("default", "vm", 0)
+	if hwAddr[0]&1 != 0 || hwAddr[0]&2 == 0 {
+		t.Errorf("%s is not a locally administered unicast address", hwAddr)
+	}
+	if other := StableMacAddress("default", "vm", 0); other.String() != hwAddr.String() {
+		t.Errorf("the address is not stable: %s != %s", other, hwAddr)
+	}
+	for _, other := range []net.HardwareAddr{
+		StableMacAddress("default", "vm", 1),
+		StableMacAddress("default", "vm2", 0),
+		StableMacAddress("kube-system", "vm", 0),
+	} {
+		if other.String() == hwAddr.String() {
+			t.Errorf("the address %s is not unique", hwAddr)
+		}
+	}
+}
+
 func withMultipleInterfacesConfigured(t *testing.T, toRun func(contNS ns.NetNS, innerLinks []netlink.Link)) {
 	withHostAndContNS(t, func(hostNS, contNS ns.NetNS) {
 		var origContVeths [2]netlink.Link
@@ -655,3 +845,68 @@ func TestMultiInterfacesWithMissingInterface(t *testing.T) {
 		}
 	})
 }
+
+func TestValidateAndFixDualStackCNIResult(t *testing.T) {
+	withFakeDualStackCNIVethAndGateways(t, defaultMTU, func(hostNS, contNS ns.NetNS, origHostVeth, origContVeth netlink.Link) {
+		allLinks, err := netlink.LinkList()
+		if err != nil {
+			log.Panicf("LinkList() failed: %v", err)
+		}
+		dns := cnitypes.DNS{Nameservers: []string{"10.1.90.10", "fd00:96::10"}}
+		for _, tc := range []struct {
+			name   string
+			routes []*cnitypes.Route
+		}{
+			{
+				name: "no routes",
+			},
+			{
+				name: "no IPv6 routes",
+				routes: []*cnitypes.Route{
+					{
+						Dst: net.IPNet{
+							IP:   net.IP{0, 0, 0, 0},
+							Mask: net.IPMask{0, 0, 0, 0},
+						},
+						GW: net.IP{10, 1, 90, 1},
+					},
+				},
+			},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				// the result without the gateways and default
+				// routes, like the one returned by Weave
+				infoToFix := &cnicurrent.Result{
+					IPs: []*cnicurrent.IPConfig{
+						{
+							Version: "4",
+							Address: net.IPNet{
+								IP:   net.IP{10, 1, 90, 5},
+								Mask: net.IPMask{255, 255, 255, 0},
+							},
+						},
+						{
+							Version: "6",
+							Address: net.IPNet{
+								IP:   net.ParseIP("fd00:1234::5"),
+								Mask: net.CIDRMask(64, 128),
+							},
+						},
+					},
+					Routes: tc.routes,
+					DNS:    dns,
+				}
+				result, err := ValidateAndFixCNIResult(infoToFix, contNS.Path(), allLinks)
+				if err != nil {
+					t.Fatalf("error during validate/fix cni result: %v", err)
+				}
+				expectedInfo := expectedExtractedDualStackLinkInfo(contNS.Path())
+				expectedInfo.DNS = dns
+				if !reflect.DeepEqual(result, expectedInfo) {
+					t.Errorf("result different than expected:\nActual:\n%s\nExpected:\n%s",
+						spew.Sdump(result), spew.Sdump(expectedInfo))
+				}
+			})
+		}
+	})
+}
@@ -27,6 +27,7 @@ import (
 const (
 	FAMILY_ALL     = 0
 	FAMILY_V4      = 0
+	FAMILY_V6      = 0
 	RTPROT_KERNEL  = 0
 	SCOPE_LINK     = 0
 	SCOPE_UNIVERSE = 0
@@ -20,8 +20,11 @@ package nettools
 
 import (
 	"fmt"
+	"io/ioutil"
 	"net"
 	"os"
+	"path/filepath"
+	"strings"
 	"syscall"
 	"unsafe"
 
@@ -31,6 +34,9 @@ import (
 const (
 	sizeOfIfReq = 40
 	ifnamsiz    = 16
+	// IFF_MULTI_QUEUE is missing from syscall package
+	iffMultiQueue = 0x100
+	vhostNetPath  = "/dev/vhost-net"
 )
 
 // Had to duplicate ifReq here as it's not exported
@@ -42,6 +48,27 @@ type ifReq struct {
 
 // OpenTAP opens a tap device and returns an os.File for it
 func OpenTAP(devName string) (*os.File, error) {
+	return openTAP(devName, false)
+}
+
+// OpenTAPQueues opens the specified number of queues of a multiqueue
+// tap device and returns os.File objects for them
+func OpenTAPQueues(devName string, queueCount int) ([]*os.File, error) {
+	var files []*os.File
+	for i := 0; i < queueCount; i++ {
+		tapFile, err := openTAP(devName, true)
+		if err != nil {
+			for _, f := range files {
+				f.Close()
+			}
+			return nil, err
+		}
+		files = append(files, tapFile)
+	}
+	return files, nil
+}
+
+func openTAP(devName string, multiQueue bool) (*os.File, error) {
 	tapFile, err := os.OpenFile("/dev/net/tun", os.O_RDWR, 0)
 	if err != nil {
 		return nil, err
@@ -56,16 +83,29 @@ func OpenTAP(devName string) (*os.File, error) {
 	// Raw protocol ethernet frame.
 	// This extra 4-byte header breaks connectivity as in this case kernel truncates initial package
 	req.Flags = uint16(syscall.IFF_TAP | syscall.IFF_NO_PI | syscall.IFF_ONE_QUEUE)
+	if multiQueue {
+		// each open file becomes a separate queue of the device
+		req.Flags |= iffMultiQueue
+	}
 	copy(req.Name[:15], devName)
 	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, tapFile.Fd(), uintptr(syscall.TUNSETIFF), uintptr(unsafe.Pointer(&req)))
 	if errno != 0 {
+		tapFile.Close()
 		return nil, fmt.Errorf("tuntap IOCTL TUNSETIFF failed, errno %v", errno)
 	}
 	return tapFile, nil
 }
 
-// CreateTAP sets up a tap link and brings it up
-func CreateTAP(devName string, mtu int) (netlink.Link, error) {
+// OpenVhostNet opens vhost-net device which is used by the emulator
+// to move virtio-net packet processing into the kernel
+func OpenVhostNet() (*os.File, error) {
+	return os.OpenFile(vhostNetPath, os.O_RDWR, 0)
+}
+
+// CreateTAP sets up a tap link and brings it up. If multiQueue is
+// true, a multiqueue tap device is created, which must be opened
+// using OpenTAPQueues.
+func CreateTAP(devName string, mtu int, multiQueue bool) (netlink.Link, error) {
 	tap := &netlink.Tuntap{
 		LinkAttrs: netlink.LinkAttrs{
 			Name:  devName,
@@ -74,6 +114,9 @@ func CreateTAP(devName string, mtu int) (netlink.Link, error) {
 		},
 		Mode: netlink.TUNTAP_MODE_TAP,
 	}
+	if multiQueue {
+		tap.Flags = netlink.TUNTAP_MULTI_QUEUE_DEFAULTS
+	}
 
 	if err := netlink.LinkAdd(tap); err != nil {
 		return nil, fmt.Errorf("failed to create tap interface: %v", err)
@@ -90,3 +133,106 @@ func CreateTAP(devName string, mtu int) (netlink.Link, error) {
 
 	return tap, nil
 }
+
+// CreateMacvtap sets up a macvtap link in bridge mode on top of the
+// parent link and brings it up
+func CreateMacvtap(devName string, parent netlink.Link, hwAddr net.HardwareAddr) (netlink.Link, error) {
+	macvtap := &netlink.Macvtap{
+		Macvlan: netlink.Macvlan{
+			LinkAttrs: netlink.LinkAttrs{
+				Name:         devName,
+				ParentIndex:  parent.Attrs().Index,
+				HardwareAddr: hwAddr,
+				MTU:          parent.Attrs().MTU,
+			},
+			Mode: netlink.MACVLAN_MODE_BRIDGE,
+		},
+	}
+	return addMacvlanLink(macvtap, devName)
+}
+
+// CreateMacvlan sets up a macvlan link in bridge mode on top of the
+// parent link and brings it up
+func CreateMacvlan(devName string, parent netlink.Link) (netlink.Link, error) {
+	macvlan := &netlink.Macvlan{
+		LinkAttrs: netlink.LinkAttrs{
+			Name:        devName,
+			ParentIndex: parent.Attrs().Index,
+			MTU:         parent.Attrs().MTU,
+		},
+		Mode: netlink.MACVLAN_MODE_BRIDGE,
+	}
+	return addMacvlanLink(macvlan, devName)
+}
+
+func addMacvlanLink(link netlink.Link, devName string) (netlink.Link, error) {
+	if err := netlink.LinkAdd(link); err != nil {
+		return nil, fmt.Errorf("failed to create %s interface %q: %v", link.Type(), devName, err)
+	}
+
+	// re-read the link to get its index
+	link, err := netlink.LinkByName(devName)
+	if err != nil {
+		return nil, fmt.Errorf("can't find %q after creating it: %v", devName, err)
+	}
+
+	if err := netlink.LinkSetUp(link); err != nil {
+		return nil, fmt.Errorf("failed to set %q up: %v", devName, err)
+	}
+
+	return link, nil
+}
+
+// mkdev returns device number for the major and minor numbers,
+// same as makedev() from glibc does
+func mkdev(major, minor uint32) int {
+	return int(uint64(minor&0xff) | uint64(major&0xfff)<<8 |
+		uint64(minor&^0xff)<<12 | uint64(major&^0xfff)<<32)
+}
+
+// OpenMacvtapQueues opens the specified number of queues of a macvtap
+// device and returns os.File objects for them. It must be called with
+// sysfs remounted for the network namespace of the device.
+func OpenMacvtapQueues(devName string, queueCount int) ([]*os.File, error) {
+	link, err := netlink.LinkByName(devName)
+	if err != nil {
+		return nil, fmt.Errorf("can't find macvtap %q: %v", devName, err)
+	}
+
+	devNumPath := fmt.Sprintf("/sys/class/net/%s/macvtap/tap%d/dev", devName, link.Attrs().Index)
+	devNumStr, err := ioutil.ReadFile(devNumPath)
+	if err != nil {
+		return nil, fmt.Errorf("can't get the device number of macvtap %q: %v", devName, err)
+	}
+	var major, minor uint32
+	if _, err := fmt.Sscanf(strings.TrimSpace(string(devNumStr)), "%d:%d", &major, &minor); err != nil {
+		return nil, fmt.Errorf("bad device number %q for macvtap %q: %v", devNumStr, devName, err)
+	}
+
+	// udev doesn't create device nodes for macvtaps in the network
+	// namespaces other than the host one, so a temporary device
+	// node is used to open the macvtap
+	tmpDir, err := ioutil.TempDir("", "macvtap")
+	if err != nil {
+		return nil, fmt.Errorf("can't create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	devPath := filepath.Join(tmpDir, devName)
+	if err := syscall.Mknod(devPath, syscall.S_IFCHR|0600, mkdev(major, minor)); err != nil {
+		return nil, fmt.Errorf("can't create device node for macvtap %q: %v", devName, err)
+	}
+
+	// each open file becomes a separate queue of the device
+	var files []*os.File
+	for i := 0; i < queueCount; i++ {
+		f, err := os.OpenFile(devPath, os.O_RDWR, 0)
+		if err != nil {
+			for _, f := range files {
+				f.Close()
+			}
+			return nil, fmt.Errorf("can't open macvtap %q: %v", devName, err)
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
@@ -20,6 +20,7 @@ package nettools
 
 import (
 	"errors"
+	"net"
 	"os"
 
 	"github.com/vishvananda/netlink"
@@ -30,7 +31,40 @@ func OpenTAP(devName string) (*os.File, error) {
 	return nil, errors.New("not implemented")
 }
 
-// CreateTAP sets up a tap link and brings it up
-func CreateTAP(devName string, mtu int) (netlink.Link, error) {
+// OpenTAPQueues opens the specified number of queues of a multiqueue
+// tap device and returns os.File objects for them
+func OpenTAPQueues(devName string, queueCount int) ([]*os.File, error) {
+	return nil, errors.New("not implemented")
+}
+
+// OpenVhostNet opens vhost-net device which is used by the emulator
+// to move virtio-net packet processing into the kernel
+func OpenVhostNet() (*os.File, error) {
+	return nil, errors.New("not implemented")
+}
+
+// CreateTAP sets up a tap link and brings it up. If multiQueue is
+// true, a multiqueue tap device is created, which must be opened
+// using OpenTAPQueues.
+func CreateTAP(devName string, mtu int, multiQueue bool) (netlink.Link, error) {
+	return nil, errors.New("not implemented")
+}
+
+// CreateMacvtap sets up a macvtap link in bridge mode on top of the
+// parent link and brings it up
+func CreateMacvtap(devName string, parent netlink.Link, hwAddr net.HardwareAddr) (netlink.Link, error) {
+	return nil, errors.New("not implemented")
+}
+
+// CreateMacvlan sets up a macvlan link in bridge mode on top of the
+// parent link and brings it up
+func CreateMacvlan(devName string, parent netlink.Link) (netlink.Link, error) {
+	return nil, errors.New("not implemented")
+}
+
+// OpenMacvtapQueues opens the specified number of queues of a macvtap
+// device and returns os.File objects for them. It must be called with
+// sysfs remounted for the network namespace of the device.
+func OpenMacvtapQueues(devName string, queueCount int) ([]*os.File, error) {
 	return nil, errors.New("not implemented")
 }
@@ -31,8 +31,102 @@ const (
 	InterfaceTypeTap InterfaceType = iota
 	// InterfaceTypeVF is a marker for SR-IOV VF type interface.
 	InterfaceTypeVF
+	// InterfaceTypeMacvtap is a marker for macvtap type interface.
+	InterfaceTypeMacvtap
 )
 
+// Mode specifies how the tap interfaces of the VM are connected
+// to the pod network.
+type Mode string
+
+const (
+	// ModeBridge denotes tap interfaces that are connected to CNI
+	// interfaces using bridges, which is the default.
+	ModeBridge Mode = "bridge"
+	// ModeMacvtap denotes macvtap interfaces that are set up on top
+	// of CNI interfaces.
+	ModeMacvtap Mode = "macvtap"
+)
+
+// UsesTap returns true if the interface type denotes an interface
+// that's passed to the VM using tap file descriptors.
+func (t InterfaceType) UsesTap() bool {
+	return t == InterfaceTypeTap || t == InterfaceTypeMacvtap
+}
+
+// NICModel specifies the model of the virtual NIC for a tap interface.
+type NICModel string
+
+const (
+	// NICModelVirtio denotes virtio-net NIC, which is the default.
+	NICModelVirtio NICModel = "virtio"
+	// NICModelE1000 denotes Intel 82540EM NIC.
+	NICModelE1000 NICModel = "e1000"
+	// NICModelE1000E denotes Intel 82574L PCIe NIC.
+	NICModelE1000E NICModel = "e1000e"
+	// NICModelRTL8139 denotes Realtek RTL8139 NIC.
+	NICModelRTL8139 NICModel = "rtl8139"
+)
+
+var nicModelDevices = map[NICModel]string{
+	NICModelVirtio:  "virtio-net-pci",
+	NICModelE1000:   "e1000",
+	NICModelE1000E:  "e1000e",
+	NICModelRTL8139: "rtl8139",
+}
+
+var nicModelDrivers = map[NICModel]string{
+	NICModelVirtio:  "virtio_net",
+	NICModelE1000:   "e1000",
+	NICModelE1000E:  "e1000e",
+	NICModelRTL8139: "8139cp",
+}
+
+// IsValid returns true if the NIC model is a known one.
+func (m NICModel) IsValid() bool {
+	_, found := nicModelDevices[m]
+	return found
+}
+
+// QEMUDevice returns the name of QEMU device for the NIC model.
+// virtio-net device is used if the model isn't set.
+func (m NICModel) QEMUDevice() string {
+	if device, found := nicModelDevices[m]; found {
+		return device
+	}
+	return nicModelDevices[NICModelVirtio]
+}
+
+// GuestDriver returns the name of Linux kernel driver that handles
+// the NIC model in the guest.
+func (m NICModel) GuestDriver() string {
+	if driver, found := nicModelDrivers[m]; found {
+		return driver
+	}
+	return nicModelDrivers[NICModelVirtio]
+}
+
+// IsVirtio returns true for virtio-net NIC model, which is the only
+// one that supports multiqueue and vhost-net acceleration.
+func (m NICModel) IsVirtio() bool {
+	return m == "" || m == NICModelVirtio
+}
+
+// NICModelForInterface returns the NIC model for the network interface
+// with the specified index given the list of models. The last model
+// in the list is used for the interfaces beyond the end of the list,
+// and virtio is used if the list is empty.
+func NICModelForInterface(models []NICModel, index int) NICModel {
+	switch {
+	case len(models) == 0:
+		return NICModelVirtio
+	case index < len(models):
+		return models[index]
+	default:
+		return models[len(models)-1]
+	}
+}
+
 // InterfaceDescription holds all data required by tapmanager to identify
 // network interface.
 type InterfaceDescription struct {
@@ -45,6 +139,13 @@ type InterfaceDescription struct {
 	// The json tag is here so that bogus File object doesn't get stored
 	// in the metadata db.
 	Fo *os.File `json:"-"`
+	// QueueFos contains open File objects for the queues of a
+	// multiqueue tap device except for the first one which is
+	// stored in Fo.
+	QueueFos []*os.File `json:"-"`
+	// VhostFos contains open File objects for /dev/vhost-net,
+	// one per tap queue.
+	VhostFos []*os.File `json:"-"`
 	// Name contains original interface name for sr-iov interface.
 	Name string
 	// HardwareAddr contains original hardware address for CNI-created
@@ -56,6 +157,33 @@ type InterfaceDescription struct {
 	MTU uint16
 	// VlanID contains vlan id of sr-iov vf interface.
 	VlanID int
+	// QueueCount contains the number of queues for multiqueue tap
+	// interface. It's 0 for single queue taps.
+	QueueCount int
+	// Vhost is true if vhost-net is used for the tap interface.
+	Vhost bool
+}
+
+// Files returns open File objects for the interface in the order in
+// which they're passed to the emulator, that is, the tap queues
+// followed by vhost-net ones.
+func (d *InterfaceDescription) Files() []*os.File {
+	files := append([]*os.File{d.Fo}, d.QueueFos...)
+	return append(files, d.VhostFos...)
+}
+
+// CloseFiles closes open File objects for the interface.
+func (d *InterfaceDescription) CloseFiles() error {
+	var firstErr error
+	for _, f := range d.Files() {
+		if f == nil {
+			continue
+		}
+		if err := f.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
 }
 
 // ContainerSideNetwork struct describes the container (VM) network
@@ -105,7 +105,11 @@ func (s *Server) PortForward(podSandboxID string, port int32, stream io.ReadWrit
 		return fmt.Errorf("unable to do port forwarding: %v", err)
 	}
 
-	args := []string{"-", fmt.Sprintf("TCP4:%s:%d", ip, port)}
+	addr := fmt.Sprintf("TCP4:%s:%d", ip, port)
+	if net.ParseIP(ip).To4() == nil {
+		addr = fmt.Sprintf("TCP6:[%s]:%d", ip, port)
+	}
+	args := []string{"-", addr}
 
 	command := exec.Command(socatPath, args...)
 	command.Stdout = stream
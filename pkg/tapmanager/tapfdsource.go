@@ -50,6 +50,13 @@ type InterfaceDescription struct {
 	HardwareAddr net.HardwareAddr      `json:"mac"`
 	FdIndex      int                   `json:"fdIndex"`
 	PCIAddress   string                `json:"pciAddress"`
+	Model        network.NICModel      `json:"model,omitempty"`
+	// QueueCount is the number of tap queue fds starting at
+	// FdIndex for multiqueue taps. It's 0 for single queue taps.
+	QueueCount int `json:"queueCount,omitempty"`
+	// Vhost is true if the tap queue fds are followed by the
+	// same number of vhost-net fds.
+	Vhost bool `json:"vhost,omitempty"`
 }
 
 // PodNetworkDesc contains the data that are required by TapFDSource
@@ -63,6 +70,41 @@ type PodNetworkDesc struct {
 	PodName string `json:"podName"`
 	// DNS specifies DNS settings for the pod
 	DNS *cnitypes.DNS
+	// NICModels specifies the NIC models for the tap interfaces
+	// of the pod in the order of the interfaces
+	NICModels []network.NICModel `json:"nicModels,omitempty"`
+	// NetQueueCount specifies the number of queues for the taps
+	// of virtio-net NICs
+	NetQueueCount int `json:"netQueueCount,omitempty"`
+	// NetworkMode specifies how the VM is connected to the pod
+	// network
+	NetworkMode network.Mode `json:"networkMode,omitempty"`
+	// IngressBandwidth specifies the limit for the traffic going
+	// to the VM in bits per second, 0 meaning no limit
+	IngressBandwidth int64 `json:"ingressBandwidth,omitempty"`
+	// EgressBandwidth specifies the limit for the traffic coming
+	// from the VM in bits per second, 0 meaning no limit
+	EgressBandwidth int64 `json:"egressBandwidth,omitempty"`
+	// HardwareAddrs specifies the hardware addresses for the VM
+	// interfaces in the order of the interfaces
+	HardwareAddrs []net.HardwareAddr `json:"macAddresses,omitempty"`
+	// StableHardwareAddrs specifies that the hardware addresses
+	// for the VM interfaces must be derived from the namespace
+	// and the name of the pod
+	StableHardwareAddrs bool `json:"stableMacAddresses,omitempty"`
+}
+
+// hardwareAddr returns the hardware address for the specified VM
+// interface or nil if the one set by CNI is to be used
+func (pnd *PodNetworkDesc) hardwareAddr(ifaceNo int) net.HardwareAddr {
+	switch {
+	case pnd.StableHardwareAddrs:
+		return nettools.StableMacAddress(pnd.PodNs, pnd.PodName, ifaceNo)
+	case ifaceNo < len(pnd.HardwareAddrs):
+		return pnd.HardwareAddrs[ifaceNo]
+	default:
+		return nil
+	}
 }
 
 // GetFDPayload contains the data that are required by TapFDSource
@@ -102,19 +144,22 @@ type TapFDSource struct {
 	dummyNetworkNsPath string
 	fdMap              map[string]*podNetwork
 	enableSriov        bool
+	enableVhost        bool
 	calicoSubnetSize   int
 }
 
 var _ FDSource = &TapFDSource{}
 
 // NewTapFDSource returns a TapFDSource for the specified CNI plugin &
-// config dir
-func NewTapFDSource(cniClient cni.Client, enableSriov bool, calicoSubnetSize int) (*TapFDSource, error) {
+// config dir. enableVhost specifies whether vhost-net should be used
+// for virtio-net NICs.
+func NewTapFDSource(cniClient cni.Client, enableSriov, enableVhost bool, calicoSubnetSize int) (*TapFDSource, error) {
 	s := &TapFDSource{
 		cniClient:        cniClient,
 		fdMap:            make(map[string]*podNetwork),
 		calicoSubnetSize: calicoSubnetSize,
 		enableSriov:      enableSriov,
+		enableVhost:      enableVhost,
 	}
 
 	return s, nil
@@ -191,8 +236,12 @@ func (s *TapFDSource) GetFDs(key string, data []byte) ([]int, []byte, error) {
 		}
 		glog.V(3).Infof("CNI Result after fix:\n%s", spew.Sdump(netConfig))
 
+		if err := nettools.SetHardwareAddrs(netConfig, pnd.hardwareAddr); err != nil {
+			return nil, err
+		}
+
 		var err error
-		if csn, err = nettools.SetupContainerSideNetwork(netConfig, netNSPath, allLinks, s.enableSriov, hostNS); err != nil {
+		if csn, err = nettools.SetupContainerSideNetwork(netConfig, netNSPath, allLinks, s.enableSriov, hostNS, s.tapSettings(pnd)); err != nil {
 			return nil, err
 		}
 
@@ -201,7 +250,9 @@ func (s *TapFDSource) GetFDs(key string, data []byte) ([]int, []byte, error) {
 		}
 
 		for _, i := range csn.Interfaces {
-			fds = append(fds, int(i.Fo.Fd()))
+			for _, f := range i.Files() {
+				fds = append(fds, int(f.Fd()))
+			}
 		}
 		return csn, nil
 	}); err != nil {
@@ -212,6 +263,23 @@ func (s *TapFDSource) GetFDs(key string, data []byte) ([]int, []byte, error) {
 	return fds, respData, nil
 }
 
+// tapSettings returns a function that gives the settings for the
+// taps of the pod. Multiqueue and vhost-net are only supported by
+// virtio-net NICs.
+func (s *TapFDSource) tapSettings(pnd *PodNetworkDesc) func(ifaceNo int) nettools.TapSettings {
+	macvtap := pnd.NetworkMode == network.ModeMacvtap
+	return func(ifaceNo int) nettools.TapSettings {
+		if !network.NICModelForInterface(pnd.NICModels, ifaceNo).IsVirtio() {
+			return nettools.TapSettings{Macvtap: macvtap}
+		}
+		return nettools.TapSettings{
+			QueueCount: pnd.NetQueueCount,
+			Vhost:      s.enableVhost,
+			Macvtap:    macvtap,
+		}
+	}
+}
+
 // Release implements Release method of FDSource interface
 func (s *TapFDSource) Release(key string) error {
 	s.Lock()
@@ -269,13 +337,23 @@ func (s *TapFDSource) GetInfo(key string) ([]byte, error) {
 		return nil, fmt.Errorf("bad fd key: %q", key)
 	}
 	var descriptions []InterfaceDescription
+	// the fds are laid out in the same order as they're
+	// returned by GetFDs() and RetrieveFDs()
+	fdIndex := 0
 	for i, iface := range pn.csn.Interfaces {
-		descriptions = append(descriptions, InterfaceDescription{
-			FdIndex:      i,
+		desc := InterfaceDescription{
+			FdIndex:      fdIndex,
 			HardwareAddr: iface.HardwareAddr,
 			Type:         iface.Type,
 			PCIAddress:   iface.PCIAddress,
-		})
+			Model:        network.NICModelForInterface(pn.pnd.NICModels, i),
+			Vhost:        len(iface.VhostFos) != 0,
+		}
+		if len(iface.QueueFos) != 0 {
+			desc.QueueCount = len(iface.QueueFos) + 1
+		}
+		descriptions = append(descriptions, desc)
+		fdIndex += len(iface.Files())
 	}
 	data, err := json.Marshal(descriptions)
 	if err != nil {
@@ -297,7 +375,7 @@ func (s *TapFDSource) Stop() error {
 			<-pn.doneCh
 		}
 		for _, i := range pn.csn.Interfaces {
-			if err := i.Fo.Close(); err != nil {
+			if err := i.CloseFiles(); err != nil {
 				errors = append(errors, fmt.Sprintf("error closing tap fd: %v", err))
 			}
 		}
@@ -334,7 +412,7 @@ func (s *TapFDSource) Recover(key string, data []byte) error {
 		}
 	}
 	return s.setupNetNS(key, pnd, func(netNSPath string, allLinks []netlink.Link, hostNS ns.NetNS) (*network.ContainerSideNetwork, error) {
-		if err := nettools.RecoverContainerSideNetwork(csn, netNSPath, allLinks, hostNS); err != nil {
+		if err := nettools.RecoverContainerSideNetwork(csn, netNSPath, allLinks, hostNS, !payload.HaveRunningContainers); err != nil {
 			return nil, err
 		}
 		return csn, nil
@@ -367,7 +445,7 @@ func (s *TapFDSource) RetrieveFDs(key string) ([]int, error) {
 			return fmt.Errorf("error listing the links: %v", err)
 		}
 
-		return nettools.RecoverContainerSideNetwork(podNet.csn, netNSPath, allLinks, hostNS)
+		return nettools.RecoverContainerSideNetwork(podNet.csn, netNSPath, allLinks, hostNS, true)
 	}); err != nil {
 		return nil, err
 	}
@@ -377,7 +455,9 @@ func (s *TapFDSource) RetrieveFDs(key string) ([]int, error) {
 		if ifDesc.Fo == nil {
 			return nil, fmt.Errorf("failed to open tap interface %q", ifDesc.Name)
 		}
-		fds = append(fds, int(ifDesc.Fo.Fd()))
+		for _, f := range ifDesc.Files() {
+			fds = append(fds, int(f.Fd()))
+		}
 	}
 	return fds, nil
 }
@@ -402,10 +482,21 @@ func (s *TapFDSource) setupNetNS(key string, pnd *PodNetworkDesc, initNet func(n
 			return err
 		}
 
+		if err := nettools.SetupBandwidthLimits(csn, nettools.BandwidthLimits{
+			Ingress: pnd.IngressBandwidth,
+			Egress:  pnd.EgressBandwidth,
+		}); err != nil {
+			return err
+		}
+
 		dhcpServer = dhcp.NewServer(csn)
 		if err := dhcpServer.SetupListener("0.0.0.0"); err != nil {
 			return fmt.Errorf("Failed to set up dhcp listener: %v", err)
 		}
+		if err := dhcpServer.SetupIPv6Listeners(nettools.DHCPLinkNames(csn)); err != nil {
+			dhcpServer.Close()
+			return fmt.Errorf("Failed to set up IPv6 listeners: %v", err)
+		}
 		go func() {
 			doneCh <- vmNS.Do(func(ns.NetNS) error {
 				err := dhcpServer.Serve()
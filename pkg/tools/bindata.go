@@ -66,7 +66,7 @@ func (fi bindataFileInfo) Sys() interface{} {
 	return nil
 }
 
-var _deployDataVirtletDsYaml = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xd4\x5a\xdd\x6f\xdb\x38\x12\x7f\xcf\x5f\x31\x68\x80\x6b\x0b\x9c\xe2\xa4\xb8\xbd\x76\x8d\xbb\x87\x34\xf6\x66\x8d\x26\xb6\xe1\x7c\x74\xdf\x0c\x9a\x1a\xcb\x5c\x53\xa4\x96\xa4\x94\xf8\xfe\xfa\x03\x49\x49\xd1\x97\x1d\x27\x4d\x82\x6e\x5e\xea\x92\x9c\x1f\x67\x86\xf3\x49\x31\x08\x82\x03\x92\xb0\x5b\x54\x9a\x49\xd1\x07\x92\x24\xba\x97\x9d\x1c\xac\x99\x08\xfb\x30\x20\x18\x4b\x71\x85\xe6\x20\x46\x43\x42\x62\x48\xff\x00\x40\x90\x18\xfb\x90\x31\x65\x38\x9a\xfc\xff\x3a\x21\x14\xfb\xb0\x4e\x17\x18\xe8\x8d\x36\x18\x1f\xe8\x04\xa9\x5d\xae\x91\x23\x35\x52\xd9\xdf\x00\x31\x31\x74\x75\x41\x16\xc8\xb5\x1f\x00\x50\xa9\x30\xac\x0e\x69\x30\x4e\x38\x31\x98\xd3\x54\x36\xb7\x7f\x4d\x06\xec\x1f\xaf\x41\x76\x82\x02\x14\x2c\xd9\xbf\x95\xd4\x66\x8c\xe6\x4e\xaa\x75\x1f\x8c\x4a\x31\x1f\x0f\x85\x9e\x4a\xce\xe8\xa6\x0f\x67\x3c\xd5\x06\xd5\x6f\x4c\x69\xf3\x9d\x99\xd5\xef\x9e\x24\x5f\x78\xe8\x20\xa6\xa3\x01\x30\xed\x00\xc0\x48\xf8\x70\xf2\x11\x50\x90\x05\x47\xb8\xbd\xd4\x76\x44\xa7\x2a\x63\x19\x16\x7c\x00\x95\xc2\x10\x26\x50\x81\x42\x6d\x88\x7a\x80\xfb\x60\x24\x2c\x10\xe8\x0a\xe9\x1a\xc3\x8f\x40\x44\x08\x1f\x3e\x7d\xb4\x20\x39\xa4\x59\x21\xa4\x1a\x41\x2e\x41\x68\x14\x06\x15\x30\x01\x4c\xb0\x0a\x6c\x45\xbc\xe9\x68\x50\x13\xed\x10\x16\x52\x1a\x6d\x14\x49\x20\x51\x92\x62\x98\x2a\x04\x81\x18\x3a\x4e\xa9\x42\x62\x10\x88\xc5\x5a\xb2\x28\x26\x89\x45\xaf\x1c\xe9\xc3\x49\xe7\x80\x1a\x55\xc6\x28\x9e\x52\x2a\x53\x61\xc6\xb5\x63\x29\xf7\x94\x82\x6f\xec\x71\xc0\x6d\xae\x81\x44\x86\x1a\xa4\x70\xd2\x08\x19\xa2\x86\x3b\x66\x56\x80\xf7\x46\x91\x99\x3f\xb6\xff\x16\xda\x72\xc7\x9a\x43\x91\xe5\xd2\x8a\xba\x79\x38\x64\x4b\x7d\xda\x1a\x05\x50\xf8\x57\xca\x14\x86\x83\x54\x31\x11\x5d\xd1\x15\x86\x29\x67\x22\x1a\x45\x42\x96\xc3\xc3\x7b\xa4\xa9\xb1\x56\x5f\xa1\xf4\x98\x57\xb9\xc9\x5e\xa3\x8a\x75\x7d\x3a\xf0\x16\x3c\xbc\x4f\x14\x6a\xeb\x33\x8d\x79\xbb\x62\x8d\x9b\x7e\x4d\x9c\xc6\x0a\x00\x99\xa0\x22\xd6\x27\x60\x24\x5a\x93\x19\xe1\x29\xb6\x60\x2d\x70\x43\xb7\x56\xee\xb3\xe2\xdc\x4b\x82\x43\xb8\x5e\x61\xc3\x28\x80\xca\x84\xa1\x2e\x00\xde\x6b\x58\x72\xbc\xcf\x24\x4f\x63\x84\x50\xb1\xac\xb4\x9b\x43\x6b\x09\xf6\x64\x42\x5c\x92\x94\x1b\x77\xfe\xee\xd4\x78\x1a\x31\x01\x21\x53\xce\x30\x51\xe8\x54\xa1\x06\xb3\x22\x0f\x16\xec\xe8\x98\x72\xba\xb3\xdb\x59\xd3\xc2\x10\x16\x1b\xe0\x6c\x61\xf7\x86\x7f\x94\x7e\x80\xf7\x4c\x9b\xc2\x0c\xac\xb5\x1e\x14\x52\x7a\xf7\x4e\x14\x26\x44\x61\x60\xcf\xa3\x54\x05\x8b\x49\x84\x7d\x88\x99\x22\xc2\x30\xdd\xab\xc7\x80\x7c\x7e\x9a\x72\x5e\xb8\xf0\x68\x39\x96\x66\xaa\xd0\x7a\x4b\xb9\x8a\xca\x38\x26\x22\x7c\xd0\x70\x00\xbd\xea\x76\x47\x7a\x55\x4e\x79\x1d\x5d\x5a\xfb\xd6\x55\x02\xcf\xe4\xfa\x8b\x0e\x1e\x34\x19\x78\x1d\xe9\x20\x64\xaa\x72\x7a\xb1\x25\x9e\x12\xb3\xea\x43\x2f\xd7\x66\x50\x27\x68\xe1\xaa\xb4\x6a\x16\x87\x30\x90\xe2\xbd\x01\x12\x86\xf0\xce\xa3\x29\x99\x90\x88\x38\xeb\x85\xaf\xcc\xeb\x9c\x49\x41\xf8\xbb\x7f\x02\x33\x70\xc7\x38\x07\x4e\xe8\xda\x6f\x0e\x28\x8c\xda\x6c\x61\xa9\xba\x57\xb1\x7f\x28\xe9\x1a\x95\x96\x74\xbd\x85\x28\x23\xca\x12\xf6\xfc\xc2\xa3\xda\xca\x02\x84\xcb\x68\x0b\xb5\x3d\xee\xea\xec\x21\x2c\xa5\xf2\x26\xc5\x44\xe4\x6c\xca\x6f\xc1\xd9\xa2\x97\x9b\x4e\xcf\x9d\xad\xf6\x76\xe3\xe2\x47\xcd\x32\x8a\x4d\x33\xa2\x02\xce\x16\x3b\x36\x0e\x9a\x4b\x4a\xa1\x31\xdb\x42\x56\x9d\x09\x5a\x7a\xb0\x4c\x36\x0d\xb1\x3b\x49\xd9\x88\x49\x53\xc5\xcc\xc6\xba\x2d\xde\x9b\xaa\x93\x27\x8a\x65\x8c\x63\x84\x61\x2d\x68\x03\xa0\xc8\xda\x96\xf7\xed\xe6\xeb\x70\x3e\x9e\x0c\x86\xf3\xf1\xe9\xe5\xb0\x02\xe3\xa2\xc7\x6f\x4a\xc6\xf5\x00\xb2\x64\xc8\xc3\x19\x2e\x9b\x61\xa5\x9a\xfc\xb3\x93\xc6\xa4\x23\xf2\x92\xda\xd4\x79\x64\x35\x6e\xa3\x7c\x8b\x9b\xdb\xd1\xec\xfa\x62\x78\x3d\x1f\x8c\xae\x4e\xbf\x5e\x0c\xe7\xdf\x6e\x2f\x1f\x67\xc9\xa7\x99\x4b\x92\x7c\xc3\x4d\x07\x67\x35\x05\x06\x7e\x71\x63\x89\x0b\xb4\x21\xd3\x36\x39\xce\xd7\x59\xdc\x98\x96\x89\xf7\x89\x86\x3e\x9b\x4c\x5f\xcd\x46\x93\xdb\xf9\xd5\xcd\x74\x3a\x99\x5d\xbf\x19\xdb\x5a\x31\x99\xcd\x75\x9a\x24\x52\x99\xe7\x31\x3e\x98\x7c\x1f\x5f\x4c\x4e\x07\xf3\xe9\x6c\x72\x3d\x39\x9b\x5c\xbc\x9d\xce\xe5\x9d\xe0\x92\x84\xf3\x44\x49\x23\xa9\xe4\xcf\x13\xe0\x62\x72\x7e\x31\xbc\x1d\xbe\x1d\xdf\x5c\x46\x1c\x33\x7c\x26\xbb\x67\xa7\x17\xa3\xb3\xc9\xfc\xea\xe6\xeb\x78\xf8\x76\x86\x42\x09\x67\x54\x06\x3a\x5d\x08\x7c\xa2\xa1\x8c\x2e\x4f\xcf\x87\xf3\xd9\xf0\x7c\xf8\xc7\x74\x7e\x3d\x3b\x1d\x5f\x5d\x9c\x5e\x8f\x26\xe3\x37\xe3\xdd\xc5\xec\xb9\xc2\x08\xef\x93\xb9\x51\x44\x68\xee\x92\xd6\xf3\xf4\x3f\x3b\xfd\x3e\x1f\x0c\x6f\x47\x67\xc3\xab\x37\x93\x40\x91\xbb\x79\x88\xb6\xca\xd5\xcf\x74\xd2\x3c\x24\x5e\x4c\xce\xcf\x47\xe3\xf3\x37\x0f\x8b\x5c\x46\x11\x13\xcd\x25\xfb\x5a\xfc\xf4\x66\x7e\x39\x19\xbc\xa1\x87\xd2\x24\x0d\x62\x19\x3e\xd5\x45\x6d\x3a\x74\x26\x32\x99\x58\x95\xcf\xde\x8c\xdf\xbc\xa0\x9b\x2b\x29\xcd\xbc\x5e\xf7\x3d\x41\xcf\xde\x51\x2b\x1e\x7a\xd5\x25\x44\x1f\x7a\x68\x68\x51\x6b\xe4\x05\x51\xd1\x0c\xd0\x56\x23\x50\xd6\x61\xbe\x80\xda\xbb\x88\x3e\x84\x91\x00\x4a\x34\xc2\x9d\xed\x23\xfe\x44\x6a\x80\x4b\x4a\x78\x59\xbb\x3b\x04\x3b\x7b\x47\x84\xb1\x0d\x83\x6d\x4a\x99\x01\x21\x0d\xc8\xe5\x92\x51\x46\x38\xdf\x00\xc9\x08\xe3\xae\x71\x95\x02\x5f\xa0\x46\xcf\x05\xd9\xa7\x3c\xaf\xd6\x68\x56\x67\x45\x11\xf9\x17\xc6\x69\xab\x48\xab\x0d\xd6\x69\xf5\x46\xf7\x96\xba\x47\x23\x25\xd3\xa4\x45\xd8\x18\xae\x93\xda\xb2\x30\x96\x61\xca\x6b\x91\xc3\x13\xb6\xc7\x15\x92\x70\x22\xf8\xa6\x65\x28\x55\x48\xdb\xbe\xb7\xb0\x1a\x83\x7b\x01\xbd\x76\x7f\xd1\xee\x62\x7e\xac\x6c\xee\xa6\x6e\x1a\x36\x6c\x31\xf8\x36\xb5\x6d\x5d\x1e\xa1\x0e\x6c\x4f\x83\x46\x57\xdc\xc2\x76\xaa\x5c\x46\xae\x07\x66\x65\x77\xbb\x42\x85\xb0\x40\x4a\xdc\xcd\x8c\x59\xa1\xba\x63\x1a\xcb\x8e\xd7\xa9\x2a\x51\x32\x4c\x29\x02\x2a\x25\x55\x15\x92\xb3\x35\x82\x59\xb1\x8a\xf1\x1e\xc2\x4d\x7e\xdb\x23\x6d\x13\x1c\xe4\xd7\x32\x74\x45\x54\x88\x19\x2c\x19\x47\x78\xef\x75\x20\xa3\x5e\x16\xeb\x1e\x59\x86\x9f\x7f\x59\x2c\x16\xc1\x17\xfc\xf5\x73\x70\x72\x82\x9f\x83\x5f\x7f\xf9\xf7\x49\x70\xfc\xe9\x5f\x9f\x8e\x09\x3d\x3e\x3e\x3e\xfe\xd4\xa3\x4c\x29\xa9\x83\x2c\x9e\x1f\x1f\x71\x19\xbd\xef\xc3\x58\x82\x4e\xe9\xca\x23\x4a\x55\x76\xee\x9b\x76\x53\x15\xeb\x60\x7b\x37\x57\x61\xa5\xdd\x03\xe6\xca\x7c\x9c\xba\x7d\x68\x4f\xe9\xca\x9e\xd3\x57\x59\x4f\x61\x02\xb5\x9e\x2a\xb9\xc0\x2a\x09\xde\x3f\xdc\x13\xfa\xbf\x56\x38\xf2\x2c\xf6\x16\x4c\xf4\x2a\xe1\xc8\x8f\x06\xb4\x31\xa0\x25\x25\x06\x02\xb8\x19\x8f\xfe\xe8\x37\x0d\xb0\x57\x35\xb8\x40\x49\xf8\x8f\x95\xac\x27\x52\xce\x1b\x81\xbc\xf3\xb6\xe3\x67\x0f\xe4\xaf\x1d\xa1\xdf\x3e\x94\x1d\xfa\x40\xec\xae\xc1\xaa\x51\x1e\x88\xc2\xf2\xea\x11\x16\x1b\xd0\x69\x82\x2a\x66\xdb\x82\xe0\xcf\x96\x20\xde\xee\x16\xa4\xc0\xdd\x7a\x34\x3f\x55\xe0\xaf\xa3\xa4\xda\xf1\x60\x43\x84\xbb\xcd\x53\x02\x0d\xea\xf2\x62\x2f\xbf\xd1\xeb\x79\xb3\xef\xd9\x65\xad\x8d\xf6\xb8\x35\xec\x96\x3b\xdf\xa4\x97\xc8\xb0\x6d\x32\x16\xd5\x4e\x74\xde\x3e\xee\xa3\xe9\xe7\xc7\xfa\xa6\x2f\x37\x2a\xd4\x26\xa7\x6e\x38\xb0\xbf\x83\x4a\x4f\xd8\x4e\x1e\x4e\x9a\xc7\x79\xa9\x69\xe3\xb0\x48\xcb\x4b\x97\xd1\x48\x24\xa4\x36\x8c\x42\x92\xaa\x44\x6a\x7c\x8d\x0c\x25\xd0\xec\xbc\xf3\x2d\xec\xce\xad\xfb\x81\x93\x69\x15\xa1\x8f\x17\xaa\x3f\x77\x5a\x8c\x54\x42\xe7\x2b\x24\xdc\xac\xe6\x89\xdd\x0c\x02\x12\x86\x2a\x4f\x93\x56\x65\xb9\x21\x55\xef\x97\x2b\x76\xba\x77\x22\x7c\x7e\xcb\x91\xc5\xfa\xa9\xed\xc6\x4b\x04\xc3\xdf\xa5\x36\xd7\xf2\xac\xf1\x4d\xef\xc7\xc3\xe1\xcb\xb8\xf8\xcb\x86\xa3\xed\xb2\x3e\x2d\x21\x6d\x4b\x9c\xbb\x53\xae\x3f\xd1\xca\xc7\x33\x8b\x5a\xa9\xee\x6d\x18\x59\x49\x6d\x40\x91\x3b\xf0\x17\x41\x40\x28\x45\xad\x4b\x7b\x74\x9f\x5a\x2d\x7e\xd5\xbb\xda\x1c\x36\xa5\xd9\x49\xd8\xed\xce\x1d\x71\x60\x27\x4a\x57\x85\xd1\xa5\xa6\x9d\x20\xb5\xf2\xa1\x55\x51\xec\x24\xad\x56\x4d\xcd\x3a\xea\x10\xae\x27\x83\x49\x1f\x42\x57\xaf\xd9\xe6\x86\xca\x10\xf3\x2f\x4f\xe0\x73\xb0\xab\x56\xad\x95\xb8\x26\xeb\x81\x70\xc5\xb4\xaf\xdb\xf2\x6a\x0b\xce\x66\x23\xdb\x63\xdd\x6f\x80\x09\x6d\x08\xf7\x19\xc5\x16\xb4\xd5\x0d\x99\xf0\x47\xe9\x0b\xbd\xf2\x63\xf6\xd1\x3e\xa2\xec\xfa\xe0\xb5\xe5\x9b\xd9\xa3\x78\x5d\x51\xa2\x2b\x46\xec\x05\xd4\x74\xf6\xae\x10\xf0\x38\x50\x25\x2a\x34\x3f\xe2\xed\x24\xfe\x81\xaa\x68\xcf\x9a\x68\x2f\x25\x74\x46\xa4\xad\xf1\x68\x1f\xc8\xe6\xc1\xd4\xbe\x1d\xee\xa3\xcf\xb2\x18\xaa\xc6\xd3\xae\x38\xbc\x17\xd8\xce\x53\x7e\x0a\x58\x57\x21\xbc\xab\x0c\xde\x8b\xbb\x0e\xb5\x37\x6a\xb8\xbd\xf8\xaa\x17\x4a\xdd\x45\xd6\x4e\xa0\xad\xfd\x64\xab\x9b\x0c\x1e\xee\x81\xfb\xdb\x32\x75\xe0\xeb\xd5\xce\x52\x75\x77\x41\xdb\x7c\x5d\xa5\x16\x84\x1e\x91\xd4\xac\xa4\x62\xff\x73\x6b\x8e\xd6\x5f\xf4\x11\x93\xbd\xec\x64\x81\x86\x14\xef\xae\xf2\x87\x47\x33\xc9\xf1\x2b\x13\x21\x13\xd1\x8e\x07\x58\x4a\x72\xcc\x2f\xb0\x49\xc2\xce\x6d\x6e\xd8\xb1\xd3\x01\x40\x6b\x8f\x16\xa4\x4e\x17\x7f\x22\xb5\x25\x4e\x90\xaf\xbe\xaa\xbd\xf4\xd9\xff\x11\x98\xd5\x40\x7b\xbf\xa7\xe9\xe4\x19\x6f\xcf\x94\x4d\x6e\x76\x7d\x50\xea\x24\x4f\xf1\x01\xbc\x7b\xe7\x7e\x28\xd4\x32\x55\x14\xcb\xf1\xf2\xd5\x93\xce\x07\xdc\xdb\x24\xf7\x3b\x43\xb5\x78\x58\xe7\xee\xe3\xf2\xff\x44\x68\x5e\xe2\x94\x3b\x64\x2c\xd9\x09\x6c\x41\x8e\xaa\x90\xa9\x21\x51\x2e\x4f\x4d\x9a\x86\x2c\x25\xf7\x9e\x5d\xfb\x2f\x67\xda\xff\xb8\x23\x86\xae\x5e\x49\x82\xc2\x7d\x52\x8d\xca\xce\xfc\xb0\x20\x81\xed\x67\x94\x8f\x49\x0d\xa1\x5e\xd5\xd3\x8a\x2c\x66\x0d\x22\x58\xe4\xcb\x5e\xd0\xed\x5a\x47\x5d\xf5\xbf\xa7\x80\x9f\xe7\x85\xa1\x87\xf5\xbe\xd0\xf7\x66\xfc\xba\xa1\x28\x7e\x38\xe4\x57\xd0\xcf\x36\x43\xfa\x9b\x84\xa9\x80\xaa\x70\xbb\xd1\x93\x84\xe1\xbd\x41\xe1\xde\x12\xe6\x98\x5d\x8e\x90\x6a\x23\xe3\x62\x30\x44\xf7\xe8\x31\x4f\x45\x15\x5f\xc8\x83\x53\x7b\x9b\xa2\x93\x5e\x7f\xd1\x1d\xe8\xf9\xac\xcb\x63\x31\x49\x12\x26\x22\x5d\x9d\x28\x2d\xb4\x98\xa9\x6c\x59\xc6\x92\x57\xf7\xc3\x9a\x3e\x5f\xde\xbc\x2c\xec\xcb\x9a\x54\xe3\x71\x55\x27\xe0\x33\xb2\xdb\xff\x03\x00\x00\xff\xff\x3a\x1f\x89\x21\xb5\x2d\x00\x00")
+var _deployDataVirtletDsYaml = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xd4\x5a\xeb\x6f\xe3\x36\x12\xff\x9e\xbf\x62\xb0\x01\x6e\x5b\xe0\x14\x27\x8b\xeb\xb5\x35\xee\x3e\x64\x13\x37\x67\x34\xb1\x03\xe7\xd1\x7e\x33\x68\x6a\x2c\xf3\x4c\x91\x2a\x49\x29\xf1\xfd\xf5\x87\x91\x28\x5b\x2f\x3f\x92\x4d\x82\x16\x09\xb0\x59\x92\xf3\xe3\x70\x5e\x9c\x19\x2a\x08\x82\x23\x96\x88\x47\x34\x56\x68\xd5\x07\x96\x24\xb6\x97\x9d\x1d\x2d\x85\x0a\xfb\x70\xc9\x30\xd6\xea\x0e\xdd\x51\x8c\x8e\x85\xcc\xb1\xfe\x11\x80\x62\x31\xf6\x21\x13\xc6\x49\x74\xfe\xff\x36\x61\x1c\xfb\xb0\x4c\x67\x18\xd8\x95\x75\x18\x1f\xd9\x04\x39\x2d\xb7\x28\x91\x3b\x6d\xe8\x6f\x80\x98\x39\xbe\xb8\x66\x33\x94\xb6\x18\x00\x30\xa9\x72\xa2\x0e\xe9\x30\x4e\x24\x73\xe8\x69\x2a\x9b\x03\xb4\x19\xa0\x1f\x59\x83\xec\x04\x05\x28\x59\xa2\x9f\x85\xb6\x6e\x84\xee\x49\x9b\x65\x1f\x9c\x49\xd1\x8f\x87\xca\xde\x6a\x29\xf8\xaa\x0f\x17\x32\xb5\x0e\xcd\x2f\xc2\x58\xf7\x9b\x70\x8b\xff\x14\x24\x7e\xe1\x71\x0e\x71\x3b\xbc\x04\x61\x73\x00\x70\x1a\xbe\x3b\xfb\x1e\x50\xb1\x99\x44\x78\xbc\xb1\x34\x62\x53\x93\x89\x0c\x4b\x3e\x80\x6b\xe5\x98\x50\x68\xc0\xa0\x75\xcc\x6c\xe0\xbe\x73\x1a\x66\x08\x7c\x81\x7c\x89\xe1\xf7\xc0\x54\x08\xdf\x7d\xf9\x9e\x40\x3c\xa4\x5b\x20\xa4\x16\x41\xcf\x41\x59\x54\x0e\x0d\x08\x05\x42\x89\x0a\xac\x87\xf3\xbc\xd5\x8e\x76\x0c\x33\xad\x9d\x75\x86\x25\x90\x18\xcd\x31\x4c\x0d\x82\x42\x0c\x73\x4e\xb9\x41\xe6\x10\x18\x61\xcd\x45\x14\xb3\x84\xd0\x2b\x2a\xdd\x68\xda\x03\x5a\x34\x99\xe0\x78\xce\xb9\x4e\x95\x1b\xd5\xd4\xb2\xde\x53\x2b\xb9\x22\x75\xc0\xa3\x97\x40\xa2\x43\x0b\x5a\xe5\xa7\x51\x3a\x44\x0b\x4f\xc2\x2d\x00\x9f\x9d\x61\x93\xc2\x16\xfe\x5d\x4a\x2b\x57\xab\x87\x62\xf3\x39\x1d\x75\xb5\x51\x32\x51\x9f\xb7\x46\x01\x0c\xfe\x91\x0a\x83\xe1\x65\x6a\x84\x8a\xee\xf8\x02\xc3\x54\x0a\x15\x0d\x23\xa5\xd7\xc3\x83\x67\xe4\xa9\x23\xab\xaf\x50\x16\x98\x77\xde\x64\xef\xd1\xc4\x15\x9b\xa2\xdf\xa0\xb0\xe0\xc1\x73\x62\xd0\x92\xcf\x34\xe6\x69\xc5\x12\x57\xfd\xda\x71\x1a\x2b\x00\x74\x82\x86\x91\x4f\xc0\x50\xb5\x26\x33\x26\x53\x6c\xc1\x12\x70\x43\xb6\x74\xee\x8b\x52\xef\x6b\x82\x63\xb8\x5f\x60\xc3\x28\x80\xeb\x44\xa0\x2d\x01\x3e\x5b\x98\x4b\x7c\xce\xb4\x4c\x63\x84\xd0\x88\x6c\x6d\x37\xc7\x64\x09\xa4\x99\x10\xe7\x2c\x95\x2e\x77\x69\xd2\x44\x22\xd3\x48\x28\x08\x85\xc9\x0d\x13\x95\x4d\x0d\x5a\x70\x0b\xb6\xb1\xe0\x9c\x4e\x98\x5c\x76\xb4\x1d\x99\x16\x86\x30\x5b\x81\x14\x33\xda\x1b\xfe\x56\xb2\x00\xf8\x2c\xac\x2b\xcd\x80\xac\xd5\xa3\x04\xde\xbd\x13\x83\x09\x33\x18\x90\x3e\xfc\x14\x80\x88\x59\x84\x7d\x88\x85\x61\xca\x09\xdb\xf3\x60\xf5\xf9\xdb\x54\xca\xd2\x85\x87\xf3\x91\x76\xb7\x06\xc9\x5b\xd6\xab\xb8\x8e\x63\xa6\xc2\x8d\x84\x03\xe8\x55\xb7\x3b\xb1\x8b\xf5\x54\x21\xa3\x1b\xb2\xef\x8a\x4a\x4a\x26\x97\x3f\xd9\x60\x23\xc9\xa0\x90\x91\x0d\x42\x51\x8a\x93\x7e\x62\x22\xbe\x65\x6e\xd1\x87\x9e\x97\x66\x50\x27\x68\xe1\x9a\xb4\x6a\x16\xc7\x70\xa9\xd5\x67\x07\x2c\x0c\xe1\x53\x81\x66\x74\xc2\x22\x96\x5b\x2f\x7c\x15\x85\xcc\x85\x56\x4c\x7e\xfa\x3b\x08\x07\x4f\x42\x4a\x90\x8c\x2f\x21\x5f\x0e\xa8\x9c\x59\x6d\x61\xa9\xba\x57\xb9\x7f\xa8\xf9\x12\x8d\xd5\x7c\xb9\x85\x28\x63\xa6\x67\x52\xd5\x2b\x16\x9e\xd4\x56\x96\x20\x52\x47\x5b\xa8\x49\xdd\xd5\xd9\x63\x98\x6b\x53\x98\x94\x50\x51\x6e\x53\xc5\x16\x52\xcc\x7a\xde\x74\x7a\xb9\xee\x6d\x61\x37\x79\xfc\xa8\x59\x46\xb9\x69\xc6\x4c\x20\xc5\x6c\xc7\xc6\x41\x73\x49\x49\x1a\x62\xb6\x85\xac\x3a\x13\xd4\x66\x4a\x26\x9b\x86\xd8\x7d\x49\xd1\x65\xc8\x53\x23\xdc\x8a\xdc\x16\x9f\xdd\xc6\xa2\x00\x12\x23\x32\x21\x31\xc2\xb0\x16\xb4\x01\x50\x65\x6d\xcb\xfb\xf5\xe1\xeb\x60\x3a\x1a\x5f\x0e\xa6\xa3\xf3\x9b\xc1\x7a\xda\x47\x8f\x5f\x8c\x8e\xab\xd8\x00\x73\x81\x32\x9c\xe0\xbc\x3e\x0a\x50\xbd\xfc\xb3\xb3\xc6\x64\x4e\x54\x9c\x94\xae\xce\x13\x92\x38\x45\xf9\x16\x37\x8f\xc3\xc9\xfd\xf5\xe0\x7e\x7a\x39\xbc\x3b\xff\x7a\x3d\x98\xfe\xfa\x78\xb3\x9f\xa5\xe2\x9a\xb9\x61\xc9\xaf\xb8\xea\xe0\xac\x26\xc0\xa0\x58\xdc\x58\x92\x07\xda\x50\x58\xba\x1c\xa7\xcb\x2c\x6e\x4c\xeb\x84\x1c\x84\xc9\x86\x3c\x9b\x4c\xdf\x4d\x86\xe3\xc7\xe9\xdd\xc3\xed\xed\x78\x72\xff\x61\x6c\x5b\x23\x74\x36\xb5\x69\x92\x68\xe3\x1a\x0b\x0e\x64\xfc\x72\xfc\xdb\xe8\x7a\x7c\x7e\x39\xbd\x9d\x8c\xef\xc7\x17\xe3\xeb\x0f\x63\x3e\xd4\x4f\x4a\x6a\x16\x4e\x13\xa3\x9d\xe6\x5a\xbe\xee\x00\xd7\xe3\xab\xeb\xc1\xe3\xe0\xe3\xf8\x96\x3a\x92\x98\xa1\x6c\xcc\x1d\xc8\xee\xc5\xf9\xf5\xf0\x62\x3c\xbd\x7b\xf8\x3a\x1a\x7c\x9c\xa1\x70\x26\x05\xd7\x81\x4d\x67\x0a\xdd\xcb\x18\x1f\xde\x9c\x5f\x0d\xa6\x93\xc1\xd5\xe0\xf7\xdb\xe9\xfd\xe4\x7c\x74\x77\x7d\x7e\x3f\x1c\x8f\x3e\x8c\xf7\x3c\x66\x4f\x0d\x46\xf8\x9c\x4c\x9d\x61\xca\xca\xfc\xd2\x7a\xd9\x31\x4a\xf9\x4f\xce\x7f\x9b\x5e\x0e\x1e\x87\x17\x83\xbb\x0f\x3b\x81\x61\x4f\xd3\x10\x29\xcb\xb5\xaf\x63\xba\x0c\x89\xd7\xe3\xab\xab\xe1\xe8\xea\xc3\x18\x2f\xc3\xa2\xd4\x51\x24\x54\xf4\x3a\xe6\x2f\x6e\x1f\xa6\x37\xe3\xcb\x0f\xf4\x50\x9e\xa4\x41\xac\xc3\x97\xba\x28\x5d\x87\xb9\x89\x8c\xc7\x74\x0b\x4d\x3e\x8c\x5f\x9f\xd0\x4d\x8d\xd6\x6e\x5a\xcf\xfb\x5e\x20\xe7\xc2\x51\x2b\x1e\x7a\xd7\x75\x88\x3e\xf4\xd0\xf1\x32\xd7\xf0\x09\x51\x59\x0c\xf0\x56\x21\x50\x6e\xe2\x13\xa8\x7a\x92\xbc\x23\x89\x3e\x86\xa1\x02\xce\x2c\xc2\x13\xd5\x11\xff\x45\xee\x40\x6a\xce\x64\x29\x8e\x02\x81\x66\x9f\x98\x72\x54\x30\x50\x51\x2a\x1c\x28\xed\x40\xcf\xe7\x82\x0b\x26\xe5\x0a\x58\xc6\x84\xa4\xbb\x19\xb4\xc2\x37\xc8\xd1\xfd\x41\x0e\x49\xcf\xab\x39\x1a\xc9\xcc\x93\xf6\xfe\xc0\x38\x3d\x6a\x6a\xb9\x36\x58\xa7\xb5\x2b\xdb\x9b\xdb\x1e\x8f\x8c\x4e\x93\x16\x61\x63\xb8\x4e\x4a\x69\x61\xac\xc3\x54\xd6\x22\x47\xa1\x92\xf6\xb8\x41\x16\x8e\x95\x5c\xb5\x0c\xa5\x0a\x49\xe5\x7b\x85\xa6\xc0\x6a\x0c\x1e\x04\xf4\xde\xf5\x45\xbb\x8a\xf9\xb6\xb4\xb9\x9b\xda\x2b\xb5\x45\xdd\x1c\x6f\x53\x53\xe9\xb2\x87\x3a\xa0\x9a\x06\xdd\x46\x47\x45\x79\x2b\x75\x94\xd7\xc0\x62\x5d\xdd\x2e\xd0\x20\xcc\x90\x33\x72\x02\xed\x16\x68\x9e\x84\xc5\x12\xa6\x28\xc5\x12\xa3\xc3\x94\x23\xa0\x31\xda\x54\x21\xa5\x58\x22\xb8\x85\xa8\x18\xef\x31\x3c\xf8\x6e\x8f\x86\xc4\x60\xe0\xdb\x32\x7c\xc1\x4c\x88\x19\xcc\x85\x44\xf8\x5c\x54\x47\x3a\xea\x65\xb1\xed\xb1\x79\xf8\xe3\x0f\xb3\xd9\x2c\xf8\x09\x7f\xfe\x31\x38\x3b\xc3\x1f\x83\x9f\x7f\xf8\xe7\x59\x70\xfa\xe5\x1f\x5f\x4e\x19\x3f\x3d\x3d\x3d\xfd\xd2\xe3\xc2\x18\x6d\x83\x2c\x9e\x9e\x9e\x48\x1d\x7d\xee\xc3\x88\x9a\x53\x7c\x51\x20\x6a\xb3\xae\xdc\x57\xad\x30\x95\xc5\x36\xd8\x5e\xcd\x55\x58\x69\x51\x96\xc2\xdc\x4f\xed\x57\xbe\xb2\x2a\x7b\x4d\x5d\x45\x9e\x22\x14\x5a\x7b\x6b\xf4\xcc\xb7\x1a\x7d\xc5\xf5\xbc\xe9\x13\x6e\x09\x47\x3e\x24\xcd\x84\xea\x55\xc2\x11\xfd\x06\x10\xf0\xc6\x80\xd5\x9c\x39\x08\xe0\x61\x34\xfc\xbd\xdf\x34\xc0\xf2\xdf\xdc\xe0\x02\xa3\xe1\x5f\x74\xb2\x9e\x4a\xa5\x3c\xaa\x8b\xa2\xe9\x15\x7f\x89\x40\xfe\xde\x11\xfa\xe3\x43\xd9\x71\x11\x88\xf3\x36\x58\x35\xca\x03\x33\xb8\x6e\x3d\x52\xd3\xcb\xa6\x09\x9a\x58\xa8\x2d\x9c\xff\xd9\x2e\x88\x8f\xeb\x82\x00\xec\x51\xcd\x9e\x7d\xbc\xad\x6c\x0b\xdd\x6f\x1c\xf8\xeb\x28\xa9\xcd\xcf\x8a\xcf\xc8\xf3\x6e\x9e\x51\xe8\xd0\xae\x1b\x7b\xbe\xa3\xd7\x2b\xcc\xbe\x47\xcb\x5a\x1b\x1d\xd0\x35\x6c\x73\x4e\xf2\xf5\x9b\xf4\xa8\x83\xde\x89\x4a\x13\x9d\xdd\xc7\x43\x24\xfd\xfa\x58\x5f\x5d\xd1\x91\xa1\x36\x39\xcd\x87\x03\xfa\x3b\xa8\xd4\x84\x55\x40\xdf\x02\xd6\xe1\x21\xbc\xd4\xa4\x71\x5c\x5e\xcb\xd4\x51\x0c\x05\x8b\x94\xb6\x4e\x70\x48\x52\x93\x68\x8b\xed\x4d\x4a\xad\xef\xdf\xc7\xaf\x6c\x21\x28\x74\x3b\x7b\xbe\xa5\xdd\xe5\xeb\xbe\x41\x33\xad\x24\x74\x7f\xa2\xfa\xe7\xbe\x16\x23\x93\xf0\xe9\x02\x99\x74\x0b\x6a\x24\xcd\x10\x02\x16\x86\xc6\x5f\x93\x24\x32\x6f\x48\xd5\xfe\x72\x29\x8d\xaa\x05\xee\xbb\x08\x5f\x5f\x72\x64\xb1\x7d\x69\xb9\x51\x3a\x6b\x93\x89\xd2\xfa\xdb\xe3\x6d\x43\xa0\x97\xc6\x7b\xbd\x7e\xdb\xd9\xb3\x53\xd3\x30\xcb\x9d\xb6\x19\xec\xb7\xba\xf8\xdb\x86\xa3\xed\x67\x7d\xd9\x85\xb4\xed\xe2\xdc\x7d\xe5\x16\x1a\x5d\x2b\xf3\x38\x47\xad\x64\xf7\x14\x46\xe8\xb9\x02\x0c\x7b\xa2\x5c\x54\x70\x04\xc6\x39\xda\x12\x20\x28\x9e\x81\x09\xdf\x8f\xd0\x6f\xd2\xe6\xb0\x79\x9a\x9d\x84\xdd\xee\xdc\x11\x07\x76\xa2\x74\x65\x18\x5d\x62\xda\x09\x52\x4b\x1f\x5a\x19\xc5\x4e\xd2\x6a\xd6\xd4\xcc\xa3\x8e\xe1\x7e\x7c\x39\xa6\xa7\x26\xca\xd7\xa8\xb8\xe1\x3a\x44\xff\xf2\x04\xe4\xf0\x58\xb4\x1d\xc8\x4a\xf2\x22\x6b\x43\xb8\x10\xf4\x66\x2c\x65\x99\x6d\xc1\xc5\x64\x48\x2f\xda\xcf\x2b\x10\xca\x3a\x26\x8b\x2e\x23\x75\x26\xaa\x1b\x0a\x95\x33\xeb\x13\xbd\xf5\x63\xf6\xc9\x21\x47\xd9\xf5\xe0\xb5\xe5\xcd\x6c\x2f\x5e\x57\x94\xe8\x8a\x11\x07\x01\x35\x9d\xbd\x2b\x04\xec\x07\xd2\x51\x13\x40\x47\x87\x10\x7f\x43\x56\x74\x60\x4e\xb4\x9f\xf7\x6d\x11\x69\x6b\x3c\x3a\x04\xb2\xa9\x98\xda\xdb\xe1\x7e\x00\x1d\xad\x93\xa1\x6a\x3c\xed\x8a\xc3\x07\x81\xed\xd4\xf2\x4b\xc0\xba\x12\xe1\x5d\x69\xf0\x41\xdc\x75\x88\xbd\x91\xc3\x1d\xc4\x57\x3d\x51\xea\x4e\xb2\x76\x02\x6d\xad\x27\x5b\xd5\x64\xb0\xe9\x03\x57\x71\xea\xdd\xdf\x3c\x7d\xe8\x4e\x55\x77\x27\xb4\xcd\xaf\xab\xcc\x8c\xf1\x13\x96\xba\x85\x36\xe2\x7f\x79\x88\x3a\x59\xfe\x64\x4f\x84\xee\x65\x67\x33\x74\xac\xfc\xee\xca\x7f\x78\x34\xd1\x12\xbf\x0a\x15\x52\xfb\x7e\xfb\x07\x58\x46\x4b\xf4\x0d\x6c\x96\x88\x2b\xba\x1b\x76\xec\x74\x04\xd0\xda\xa3\x05\x69\xd3\x19\x75\x7d\x6d\xff\x28\xf0\xab\xef\x6a\x5f\xfa\x1c\xfe\x11\x18\x49\xa0\xbd\xdf\xcb\x64\xf2\x8a\x6f\xcf\x0c\xd5\xe3\xb4\x3e\x58\xcb\xc4\x5f\xf1\x01\x7c\xfa\x94\xff\x61\xd0\xea\xd4\xf0\xf2\xea\x2f\x0d\x21\x66\x89\xf5\x03\xf4\xda\x5d\xfc\x9d\xa1\x99\x6d\xd6\xe5\xfd\x38\xff\x9f\x08\xdd\x5b\x68\xb9\xe3\x8c\x6b\x76\x02\x4a\xc8\xd1\x94\x67\x6a\x9c\xc8\x9f\xa7\x76\x9a\xc6\x59\xd6\xdc\x17\xec\xd2\xd1\xa4\xc8\xbf\xb7\x09\xe0\x89\x3e\x66\x7a\xa7\x13\x78\x2d\x05\xa9\x45\x43\xfa\xfb\xe6\x83\x04\xf4\xf9\x84\x41\xd7\x71\xa8\x77\xf5\xb4\xf2\x16\x23\x83\x08\x66\x7e\xd9\x1b\xba\x5d\x4b\xd5\x55\xff\x7b\x09\xf8\x95\x4f\x0c\xc9\x2b\xfa\x50\xf8\x42\x9f\xb8\x7e\xef\x50\x14\x6f\x94\xfc\x0e\xf2\xd9\x66\x48\x7f\x91\x30\x15\x70\x13\x6e\x37\x7a\x96\x08\x7c\x76\xa8\x68\x1b\xeb\x31\xbb\x1c\x21\xb5\x4e\xc7\xe5\x60\x88\xf9\x47\x8f\xfe\x2a\xaa\xf8\x82\x0f\x4e\xed\x6d\x3c\x2f\xb4\x41\x07\xba\x9f\xcd\xef\xb1\x98\x25\x89\x50\x91\xad\x4e\xac\x2d\xb4\x9c\xa9\x6c\xb9\x8e\x25\x11\xd6\x62\xca\x1b\xb0\xd0\xb3\x8e\xb9\x74\x4b\x0c\x4b\x93\x90\xe2\xf0\xfb\x1a\x76\x55\x83\x6f\x6f\xd0\x64\x18\x6f\x6b\xc4\x55\x49\xac\x3f\xe3\x6e\x00\x6e\x3d\xe6\x76\xe8\xff\x0f\x00\x72\xbf\xf2\xcf\x27\x2e\x00\x00")
 
 func deployDataVirtletDsYamlBytes() ([]byte, error) {
 	return bindataRead(
@@ -81,7 +81,7 @@ func deployDataVirtletDsYaml() (*asset, error) {
 		return nil, err
 	}
 
-	info := bindataFileInfo{name: "deploy/data/virtlet-ds.yaml", size: 11701, mode: os.FileMode(420), modTime: time.Unix(1522279343, 0)}
+	info := bindataFileInfo{name: "deploy/data/virtlet-ds.yaml", size: 11815, mode: os.FileMode(420), modTime: time.Unix(1522279343, 0)}
 	a := &asset{bytes: bytes, info: info}
 	return a, nil
 }
@@ -20,12 +20,15 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"sort"
 	"strings"
 	"testing"
 
 	"github.com/spf13/cobra"
 	v1 "k8s.io/api/core/v1"
+
+	virtlet_v1 "github.com/Mirantis/virtlet/pkg/api/virtlet.k8s/v1"
 )
 
 type fakeKubeClient struct {
@@ -36,6 +39,8 @@ type fakeKubeClient struct {
 	expectedPortForwards    []string
 	portForwardStopChannels []chan struct{}
 	logs                    map[string]string
+	stdin                   map[string]string
+	imageMappings           []virtlet_v1.VirtletImageMapping
 }
 
 var _ KubeClient = &fakeKubeClient{}
@@ -79,6 +84,13 @@ func (c *fakeKubeClient) ExecInContainer(podName, containerName, namespace strin
 		return 0, fmt.Errorf("unexpected command: %s", key)
 	}
 	delete(c.expectedCommands, key)
+	if stdin != nil && c.stdin != nil {
+		bs, err := ioutil.ReadAll(stdin)
+		if err != nil {
+			return 0, fmt.Errorf("ReadAll(): %v", err)
+		}
+		c.stdin[key] = string(bs)
+	}
 	if stdout != nil {
 		if _, err := io.WriteString(stdout, out); err != nil {
 			return 0, fmt.Errorf("WriteString(): %v", err)
@@ -127,6 +139,13 @@ func (c *fakeKubeClient) PodLogs(podName, containerName, namespace string, tailL
 	return []byte(l), nil
 }
 
+func (c *fakeKubeClient) GetVirtletImageMappings(namespace string) ([]virtlet_v1.VirtletImageMapping, error) {
+	if namespace != "kube-system" {
+		return nil, fmt.Errorf("unexpected namespace %q", namespace)
+	}
+	return c.imageMappings, nil
+}
+
 func (c *fakeKubeClient) GetNamesOfNodesMarkedForVirtlet() ([]string, error) {
 	return nil, errors.New("not implemented")
 }
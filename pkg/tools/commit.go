@@ -0,0 +1,96 @@
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tools
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/renstrom/dedent"
+	"github.com/spf13/cobra"
+)
+
+// commitCommand saves the root disk of a VM pod as a new image
+type commitCommand struct {
+	client    KubeClient
+	out       io.Writer
+	podName   string
+	imageName string
+	quiesce   bool
+}
+
+// NewCommitCmd returns a cobra.Command that saves the root disk of a VM pod
+// as a new image in the Virtlet image store on the pod's node.
+func NewCommitCmd(client KubeClient, out io.Writer) *cobra.Command {
+	c := &commitCommand{client: client, out: out}
+	cmd := &cobra.Command{
+		Use:   "commit pod image",
+		Short: "Save the root disk of a VM pod as an image",
+		Long: dedent.Dedent(`
+                        This command flattens the root disk of the specified VM pod
+                        together with its base image and stores the result in the
+                        Virtlet image store on the pod's node under the specified
+                        image name. The VM keeps running while its disk is being copied.
+                        Use --quiesce to freeze the guest filesystems during the copy,
+                        which requires the QEMU guest agent to be running in the VM
+                        and the pod to have VirtletGuestAgent annotation set to "true".
+                        Pods with persistent root filesystems can't be committed.
+                `),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 2 {
+				return errors.New("Must specify the pod and the image name")
+			}
+			c.podName = args[0]
+			c.imageName = args[1]
+			return c.Run()
+		},
+	}
+	cmd.Flags().BoolVar(&c.quiesce, "quiesce", false, "freeze the guest filesystems using the guest agent while copying the disk")
+	return cmd
+}
+
+// Run executes the command.
+func (c *commitCommand) Run() error {
+	vmPodInfo, err := c.client.GetVMPodInfo(c.podName)
+	if err != nil {
+		return fmt.Errorf("can't get VM pod info for %q: %v", c.podName, err)
+	}
+
+	virtletCmd := []string{
+		"virtlet",
+		"--image-commit", strings.TrimPrefix(c.imageName, virtletImagePrefix),
+		"--container-id", vmPodInfo.ContainerID,
+	}
+	if c.quiesce {
+		virtletCmd = append(virtletCmd, "--quiesce")
+	}
+	var buf bytes.Buffer
+	exitCode, err := c.client.ExecInContainer(
+		vmPodInfo.VirtletPodName, "virtlet", "kube-system", nil, &buf, os.Stderr, virtletCmd)
+	switch {
+	case err != nil:
+		return fmt.Errorf("error committing the image in Virtlet pod %q: %v", vmPodInfo.VirtletPodName, err)
+	case exitCode != 0:
+		return fmt.Errorf("error committing the image in Virtlet pod %q: exit code %d", vmPodInfo.VirtletPodName, exitCode)
+	}
+	fmt.Fprintf(c.out, "%s: %s\n", vmPodInfo.NodeName, strings.TrimSpace(buf.String()))
+	return nil
+}
@@ -0,0 +1,424 @@
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/renstrom/dedent"
+	"github.com/spf13/cobra"
+
+	virtlet_v1 "github.com/Mirantis/virtlet/pkg/api/virtlet.k8s/v1"
+	"github.com/Mirantis/virtlet/pkg/diag"
+	"github.com/Mirantis/virtlet/pkg/image"
+	"github.com/Mirantis/virtlet/pkg/imagetranslation"
+)
+
+const (
+	// virtletImagePrefix is the prefix of the Virtlet image
+	// names that's stripped by CRI proxy
+	virtletImagePrefix = "virtlet.cloud/"
+)
+
+type imagePullsCommand struct {
+	client   KubeClient
+	out      io.Writer
+	nodeName string
+}
+
+// NewImagePullsCommand returns a new cobra.Command that displays
+// the progress of the image pulls on the nodes.
+func NewImagePullsCommand(client KubeClient, out io.Writer) *cobra.Command {
+	c := &imagePullsCommand{client: client, out: out}
+	cmd := &cobra.Command{
+		Use:   "pulls",
+		Short: "Display the progress of the image pulls",
+		Long:  "Display the progress of the image downloads that are currently performed by Virtlet on the nodes",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 {
+				return errors.New("This command does not accept arguments")
+			}
+			return c.Run()
+		},
+	}
+	cmd.Flags().StringVar(&c.nodeName, "node", "", "the name of the target node")
+	return cmd
+}
+
+func (c *imagePullsCommand) getNodePulls(podName string) ([]image.PullProgress, error) {
+	var buf bytes.Buffer
+	exitCode, err := c.client.ExecInContainer(
+		podName, "virtlet", "kube-system", nil, &buf, os.Stderr,
+		[]string{"virtlet", "--diag", "--diag-sources", image.PullProgressDiagSourceName})
+	switch {
+	case err != nil:
+		return nil, fmt.Errorf("error getting image pulls from Virtlet pod %q: %v", podName, err)
+	case exitCode != 0:
+		return nil, fmt.Errorf("error getting image pulls from Virtlet pod %q: exit code %d", podName, exitCode)
+	}
+	dr, err := diag.DecodeDiagnostics(buf.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	cur, found := dr.Children[image.PullProgressDiagSourceName]
+	switch {
+	case !found:
+		return nil, fmt.Errorf("Virtlet pod %q didn't return the image pull info", podName)
+	case cur.Error != "":
+		return nil, fmt.Errorf("error getting image pulls from Virtlet pod %q: %s", podName, cur.Error)
+	}
+	var pulls []image.PullProgress
+	if err := json.Unmarshal([]byte(cur.Data), &pulls); err != nil {
+		return nil, fmt.Errorf("error unmarshalling image pull info from Virtlet pod %q: %v", podName, err)
+	}
+	return pulls, nil
+}
+
+func formatByteCount(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func formatPullProgress(p image.PullProgress) string {
+	if p.BytesTotal <= 0 {
+		return formatByteCount(p.BytesDone)
+	}
+	return fmt.Sprintf("%s / %s (%d%%)", formatByteCount(p.BytesDone), formatByteCount(p.BytesTotal), p.BytesDone*100/p.BytesTotal)
+}
+
+// Run executes the command.
+func (c *imagePullsCommand) Run() error {
+	podNames, nodeNames, err := c.client.GetVirtletPodAndNodeNames()
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(c.out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "NODE\tIMAGE\tURL\tPROGRESS\tWAITERS\tSTARTED")
+	var errs []string
+	for n, nodeName := range nodeNames {
+		if c.nodeName != "" && nodeName != c.nodeName {
+			continue
+		}
+		pulls, err := c.getNodePulls(podNames[n])
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("node %q: %v", nodeName, err))
+			continue
+		}
+		for _, p := range pulls {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\n", nodeName, p.Name, p.URL, formatPullProgress(p), p.Waiters, p.StartedAt.UTC().Format(time.RFC3339))
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if len(errs) != 0 {
+		return fmt.Errorf("error encountered on some of the nodes:\n%s", strings.Join(errs, "\n"))
+	}
+	return nil
+}
+
+type imageImportCommand struct {
+	client    KubeClient
+	out       io.Writer
+	nodeName  string
+	imageName string
+	filePath  string
+}
+
+// NewImageImportCommand returns a new cobra.Command that imports
+// an image file into the image stores on the nodes.
+func NewImageImportCommand(client KubeClient, out io.Writer) *cobra.Command {
+	c := &imageImportCommand{client: client, out: out}
+	cmd := &cobra.Command{
+		Use:   "import file",
+		Short: "Import an image file into the Virtlet image stores",
+		Long:  "Import a local QCOW2 image file into the Virtlet image stores under the specified image name, on all the nodes or the specified one",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return errors.New("Must specify the image file")
+			}
+			if c.imageName == "" {
+				return errors.New("Must specify the image name using --name")
+			}
+			c.filePath = args[0]
+			return c.Run()
+		},
+	}
+	cmd.Flags().StringVar(&c.nodeName, "node", "", "the name of the target node (default: all the nodes)")
+	cmd.Flags().StringVar(&c.imageName, "name", "", "the name of the image")
+	return cmd
+}
+
+func (c *imageImportCommand) importOnNode(podName, imageName string) (string, error) {
+	f, err := os.Open(c.filePath)
+	if err != nil {
+		return "", fmt.Errorf("can't open the image file: %v", err)
+	}
+	defer f.Close()
+	var buf bytes.Buffer
+	exitCode, err := c.client.ExecInContainer(
+		podName, "virtlet", "kube-system", f, &buf, os.Stderr,
+		[]string{"virtlet", "--image-import", imageName})
+	switch {
+	case err != nil:
+		return "", fmt.Errorf("error importing the image into Virtlet pod %q: %v", podName, err)
+	case exitCode != 0:
+		return "", fmt.Errorf("error importing the image into Virtlet pod %q: exit code %d", podName, exitCode)
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// Run executes the command.
+func (c *imageImportCommand) Run() error {
+	imageName := strings.TrimPrefix(c.imageName, virtletImagePrefix)
+	podNames, nodeNames, err := c.client.GetVirtletPodAndNodeNames()
+	if err != nil {
+		return err
+	}
+	var errs []string
+	for n, nodeName := range nodeNames {
+		if c.nodeName != "" && nodeName != c.nodeName {
+			continue
+		}
+		ref, err := c.importOnNode(podNames[n], imageName)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("node %q: %v", nodeName, err))
+			continue
+		}
+		fmt.Fprintf(c.out, "%s: %s\n", nodeName, ref)
+	}
+	if len(errs) != 0 {
+		return fmt.Errorf("error encountered on some of the nodes:\n%s", strings.Join(errs, "\n"))
+	}
+	return nil
+}
+
+type imageExportCommand struct {
+	client    KubeClient
+	out       io.Writer
+	nodeName  string
+	imageName string
+	filePath  string
+}
+
+// NewImageExportCommand returns a new cobra.Command that exports
+// an image from the image store on a node into a file.
+func NewImageExportCommand(client KubeClient, out io.Writer) *cobra.Command {
+	c := &imageExportCommand{client: client, out: out}
+	cmd := &cobra.Command{
+		Use:   "export image file",
+		Short: "Export an image from a Virtlet image store",
+		Long:  "Save the data of the specified image from the Virtlet image store on a node into a local file, or stdout if the file name is '-'",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 2 {
+				return errors.New("Must specify the image name and the file")
+			}
+			c.imageName = args[0]
+			c.filePath = args[1]
+			return c.Run()
+		},
+	}
+	cmd.Flags().StringVar(&c.nodeName, "node", "", "the name of the source node (may be omitted if there's just one node)")
+	return cmd
+}
+
+func (c *imageExportCommand) getVirtletPodName() (string, error) {
+	if c.nodeName != "" {
+		podName, err := c.client.GetVirtletPodNameForNode(c.nodeName)
+		if err != nil {
+			return "", fmt.Errorf("couldn't get Virtlet pod name for node %q: %v", c.nodeName, err)
+		}
+		return podName, nil
+	}
+	podNames, _, err := c.client.GetVirtletPodAndNodeNames()
+	switch {
+	case err != nil:
+		return "", err
+	case len(podNames) != 1:
+		return "", errors.New("Must specify the node using --node")
+	}
+	return podNames[0], nil
+}
+
+// Run executes the command.
+func (c *imageExportCommand) Run() (err error) {
+	podName, err := c.getVirtletPodName()
+	if err != nil {
+		return err
+	}
+	w := c.out
+	if c.filePath != "-" {
+		f, err := os.Create(c.filePath)
+		if err != nil {
+			return fmt.Errorf("can't create the image file: %v", err)
+		}
+		defer func() {
+			if cerr := f.Close(); err == nil && cerr != nil {
+				err = fmt.Errorf("error closing the image file: %v", cerr)
+			}
+			if err != nil {
+				os.Remove(c.filePath)
+			}
+		}()
+		w = f
+	}
+	exitCode, err := c.client.ExecInContainer(
+		podName, "virtlet", "kube-system", nil, w, os.Stderr,
+		[]string{"virtlet", "--image-export", strings.TrimPrefix(c.imageName, virtletImagePrefix)})
+	switch {
+	case err != nil:
+		return fmt.Errorf("error exporting the image from Virtlet pod %q: %v", podName, err)
+	case exitCode != 0:
+		return fmt.Errorf("error exporting the image from Virtlet pod %q: exit code %d", podName, exitCode)
+	}
+	return nil
+}
+
+type imageTranslateCommand struct {
+	client      KubeClient
+	out         io.Writer
+	imageName   string
+	allowRegexp bool
+}
+
+// NewImageTranslateCommand returns a new cobra.Command that performs
+// a dry run of the image name translation.
+func NewImageTranslateCommand(client KubeClient, out io.Writer) *cobra.Command {
+	c := &imageTranslateCommand{client: client, out: out}
+	cmd := &cobra.Command{
+		Use:   "translate image",
+		Short: "Show how an image name is translated",
+		Long: dedent.Dedent(`
+                        Translate the image name using the VirtletImageMappings in kube-system
+                        namespace and show the matching rule along with the resulting URL.
+                        The translation configs stored in the files on the nodes are
+                        not taken into account. The Secrets referenced by the transport
+                        profiles are not resolved.`),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return errors.New("Must specify the image name")
+			}
+			c.imageName = args[0]
+			return c.Run()
+		},
+	}
+	cmd.Flags().BoolVar(&c.allowRegexp, "allow-regexp", true, "use the regexp-based rules, too (must match Virtlet's enableRegexpImageTranslation setting)")
+	return cmd
+}
+
+type imageMappingSource struct {
+	mappings []virtlet_v1.VirtletImageMapping
+}
+
+var _ imagetranslation.ConfigSource = imageMappingSource{}
+
+// Configs implements ConfigSource Configs
+func (s imageMappingSource) Configs(ctx context.Context) ([]imagetranslation.TranslationConfig, error) {
+	var r []imagetranslation.TranslationConfig
+	for n := range s.mappings {
+		r = append(r, &s.mappings[n])
+	}
+	return r, nil
+}
+
+// Description implements ConfigSource Description
+func (s imageMappingSource) Description() string {
+	return "VirtletImageMappings"
+}
+
+// Run executes the command.
+func (c *imageTranslateCommand) Run() error {
+	imageName := strings.TrimPrefix(c.imageName, virtletImagePrefix)
+	mappings, err := c.client.GetVirtletImageMappings("kube-system")
+	if err != nil {
+		return fmt.Errorf("can't get VirtletImageMappings: %v", err)
+	}
+	translator := imagetranslation.NewImageNameTranslator(c.allowRegexp)
+	translator.LoadConfigs(context.Background(), imageMappingSource{mappings})
+	match := translator.FindRule(imageName)
+	if match == nil {
+		fmt.Fprintf(c.out, "No matching rule found, %q is used as the image URL\n", imageName)
+		return nil
+	}
+
+	w := tabwriter.NewWriter(c.out, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(w, "Mapping:\t%s\n", match.ConfigName)
+	if match.Rule.Name != "" {
+		fmt.Fprintf(w, "Rule:\t#%d (name %q)\n", match.RuleIndex+1, match.Rule.Name)
+	} else {
+		fmt.Fprintf(w, "Rule:\t#%d (regexp %q)\n", match.RuleIndex+1, match.Rule.Regex)
+	}
+	fmt.Fprintf(w, "URL:\t%s\n", match.Endpoint.URL)
+	if len(match.Endpoint.Mirrors) != 0 {
+		mirrorSelection := virtlet_v1.MirrorSelectionOrdered
+		if match.Endpoint.FastestMirror {
+			mirrorSelection = virtlet_v1.MirrorSelectionFastest
+		}
+		fmt.Fprintf(w, "Mirrors:\t%s (%s)\n", strings.Join(match.Endpoint.Mirrors, ", "), mirrorSelection)
+	}
+	if match.Rule.Transport != "" {
+		fmt.Fprintf(w, "Transport profile:\t%s\n", match.Rule.Transport)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	for _, m := range mappings {
+		if m.Name != match.ConfigName {
+			continue
+		}
+		if problems := imagetranslation.ValidateTranslation(&m.Spec); len(problems) != 0 {
+			fmt.Fprintf(c.out, "Problems found in the mapping:\n")
+			for _, p := range problems {
+				fmt.Fprintf(c.out, "  %s\n", p)
+			}
+		}
+	}
+	return nil
+}
+
+// NewImageCommand returns a new cobra.Command that handles
+// the operations on Virtlet image stores.
+func NewImageCommand(client KubeClient, out io.Writer) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "image",
+		Short: "Manage Virtlet images",
+		Long:  "Inspect and manage the images in the Virtlet image stores on the nodes",
+	}
+	cmd.AddCommand(NewImagePullsCommand(client, out))
+	cmd.AddCommand(NewImageImportCommand(client, out))
+	cmd.AddCommand(NewImageExportCommand(client, out))
+	cmd.AddCommand(NewImageTranslateCommand(client, out))
+	return cmd
+}
@@ -0,0 +1,381 @@
+/*
+Copyright 2018 Mirantis
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tools
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	virtlet_v1 "github.com/Mirantis/virtlet/pkg/api/virtlet.k8s/v1"
+	"github.com/Mirantis/virtlet/pkg/diag"
+)
+
+func fakePullDiagResult(data string) string {
+	return string(diag.Result{
+		Name:  "diagnostics",
+		IsDir: true,
+		Children: map[string]diag.Result{
+			"image-pulls": {
+				Name: "image-pulls",
+				Ext:  "json",
+				Data: data,
+			},
+		},
+	}.ToJSON())
+}
+
+func TestImagePullsCommand(t *testing.T) {
+	pullsCmd := "virtlet --diag --diag-sources image-pulls"
+	for _, tc := range []struct {
+		name             string
+		args             string
+		expectedCommands map[string]string
+		expectedOutput   string
+		errSubstring     string
+	}{
+		{
+			name: "all nodes",
+			args: "pulls",
+			expectedCommands: map[string]string{
+				"virtlet-foo42/virtlet/kube-system: " + pullsCmd: fakePullDiagResult(
+					`[{"name":"cirros","url":"https://example.com/cirros.img","bytesDone":1048576,"bytesTotal":4194304,"waiters":2,"startedAt":"2018-05-01T10:00:00Z"}]`),
+				"virtlet-bar42/virtlet/kube-system: " + pullsCmd: fakePullDiagResult(
+					`[{"name":"ubuntu","url":"https://example.com/ubuntu.img","bytesDone":100,"bytesTotal":-1,"waiters":1,"startedAt":"2018-05-01T10:01:00Z"}]`),
+			},
+			expectedOutput: "NODE         IMAGE   URL                             PROGRESS               WAITERS  STARTED\n" +
+				"kube-node-1  cirros  https://example.com/cirros.img  1.0MiB / 4.0MiB (25%)  2        2018-05-01T10:00:00Z\n" +
+				"kube-node-2  ubuntu  https://example.com/ubuntu.img  100B                   1        2018-05-01T10:01:00Z\n",
+		},
+		{
+			name: "single node",
+			args: "pulls --node kube-node-2",
+			expectedCommands: map[string]string{
+				"virtlet-bar42/virtlet/kube-system: " + pullsCmd: fakePullDiagResult("[]"),
+			},
+			expectedOutput: "NODE  IMAGE  URL  PROGRESS  WAITERS  STARTED\n",
+		},
+		{
+			name: "bad data",
+			args: "pulls --node kube-node-1",
+			expectedCommands: map[string]string{
+				"virtlet-foo42/virtlet/kube-system: " + pullsCmd: fakePullDiagResult("{"),
+			},
+			errSubstring: "error unmarshalling image pull info",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &fakeKubeClient{
+				t: t,
+				virtletPods: map[string]string{
+					"kube-node-1": "virtlet-foo42",
+					"kube-node-2": "virtlet-bar42",
+				},
+				expectedCommands: tc.expectedCommands,
+			}
+			var out bytes.Buffer
+			cmd := NewImageCommand(c, &out)
+			cmd.SetArgs(strings.Split(tc.args, " "))
+			cmd.SilenceUsage = true
+			cmd.SilenceErrors = true
+			switch err := cmd.Execute(); {
+			case err != nil && tc.errSubstring == "":
+				t.Errorf("image command returned an unexpected error: %v", err)
+			case err == nil && tc.errSubstring != "":
+				t.Errorf("Didn't get expected error (substring %q), output: %q", tc.errSubstring, out.String())
+			case err != nil && !strings.Contains(err.Error(), tc.errSubstring):
+				t.Errorf("Didn't get expected substring %q in the error: %v", tc.errSubstring, err)
+			case err == nil && out.String() != tc.expectedOutput:
+				t.Errorf("Unexpected output from the command:\n%s\n-- instead of --\n%s", out.String(), tc.expectedOutput)
+			}
+			for c := range tc.expectedCommands {
+				t.Errorf("command not executed: %q", c)
+			}
+		})
+	}
+}
+
+func TestImageImportCommand(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "image-import")
+	if err != nil {
+		t.Fatalf("TempDir(): %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	imageFile := filepath.Join(tmpDir, "cirros.img")
+	if err := ioutil.WriteFile(imageFile, []byte("image data"), 0644); err != nil {
+		t.Fatalf("WriteFile(): %v", err)
+	}
+
+	importCmd := "virtlet --image-import example.com/cirros"
+	for _, tc := range []struct {
+		name             string
+		args             string
+		expectedCommands map[string]string
+		expectedOutput   string
+		errSubstring     string
+	}{
+		{
+			name: "all nodes",
+			args: "import --name virtlet.cloud/example.com/cirros " + imageFile,
+			expectedCommands: map[string]string{
+				"virtlet-foo42/virtlet/kube-system: " + importCmd: "example.com/cirros@sha256:abcdef\n",
+				"virtlet-bar42/virtlet/kube-system: " + importCmd: "example.com/cirros@sha256:abcdef\n",
+			},
+			expectedOutput: "kube-node-1: example.com/cirros@sha256:abcdef\n" +
+				"kube-node-2: example.com/cirros@sha256:abcdef\n",
+		},
+		{
+			name: "single node",
+			args: "import --node kube-node-2 --name example.com/cirros " + imageFile,
+			expectedCommands: map[string]string{
+				"virtlet-bar42/virtlet/kube-system: " + importCmd: "example.com/cirros@sha256:abcdef\n",
+			},
+			expectedOutput: "kube-node-2: example.com/cirros@sha256:abcdef\n",
+		},
+		{
+			name:         "no name",
+			args:         "import " + imageFile,
+			errSubstring: "Must specify the image name",
+		},
+		{
+			name:         "nonexistent file",
+			args:         "import --node kube-node-1 --name example.com/cirros " + filepath.Join(tmpDir, "nosuchfile"),
+			errSubstring: "can't open the image file",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &fakeKubeClient{
+				t: t,
+				virtletPods: map[string]string{
+					"kube-node-1": "virtlet-foo42",
+					"kube-node-2": "virtlet-bar42",
+				},
+				expectedCommands: tc.expectedCommands,
+				stdin:            make(map[string]string),
+			}
+			expectedStdin := make(map[string]string)
+			for k := range tc.expectedCommands {
+				expectedStdin[k] = "image data"
+			}
+			var out bytes.Buffer
+			cmd := NewImageCommand(c, &out)
+			cmd.SetArgs(strings.Split(tc.args, " "))
+			cmd.SilenceUsage = true
+			cmd.SilenceErrors = true
+			switch err := cmd.Execute(); {
+			case err != nil && tc.errSubstring == "":
+				t.Errorf("image import command returned an unexpected error: %v", err)
+			case err == nil && tc.errSubstring != "":
+				t.Errorf("Didn't get expected error (substring %q), output: %q", tc.errSubstring, out.String())
+			case err != nil && !strings.Contains(err.Error(), tc.errSubstring):
+				t.Errorf("Didn't get expected substring %q in the error: %v", tc.errSubstring, err)
+			case err == nil && out.String() != tc.expectedOutput:
+				t.Errorf("Unexpected output from the command:\n%s\n-- instead of --\n%s", out.String(), tc.expectedOutput)
+			case err == nil && !reflect.DeepEqual(c.stdin, expectedStdin):
+				t.Errorf("Bad image data passed to the commands: %#v instead of %#v", c.stdin, expectedStdin)
+			}
+			for c := range tc.expectedCommands {
+				t.Errorf("command not executed: %q", c)
+			}
+		})
+	}
+}
+
+func TestImageExportCommand(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "image-export")
+	if err != nil {
+		t.Fatalf("TempDir(): %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	imageFile := filepath.Join(tmpDir, "cirros.img")
+
+	exportCmd := "virtlet --image-export example.com/cirros"
+	for _, tc := range []struct {
+		name             string
+		args             string
+		virtletPods      map[string]string
+		expectedCommands map[string]string
+		expectedOutput   string
+		expectedFile     string
+		errSubstring     string
+	}{
+		{
+			name: "to file",
+			args: "export --node kube-node-2 virtlet.cloud/example.com/cirros " + imageFile,
+			virtletPods: map[string]string{
+				"kube-node-1": "virtlet-foo42",
+				"kube-node-2": "virtlet-bar42",
+			},
+			expectedCommands: map[string]string{
+				"virtlet-bar42/virtlet/kube-system: " + exportCmd: "image data",
+			},
+			expectedFile: "image data",
+		},
+		{
+			name: "single node to stdout",
+			args: "export example.com/cirros -",
+			virtletPods: map[string]string{
+				"kube-node-1": "virtlet-foo42",
+			},
+			expectedCommands: map[string]string{
+				"virtlet-foo42/virtlet/kube-system: " + exportCmd: "image data",
+			},
+			expectedOutput: "image data",
+		},
+		{
+			name: "no node",
+			args: "export example.com/cirros " + imageFile,
+			virtletPods: map[string]string{
+				"kube-node-1": "virtlet-foo42",
+				"kube-node-2": "virtlet-bar42",
+			},
+			errSubstring: "Must specify the node",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			os.Remove(imageFile)
+			c := &fakeKubeClient{
+				t:                t,
+				virtletPods:      tc.virtletPods,
+				expectedCommands: tc.expectedCommands,
+			}
+			var out bytes.Buffer
+			cmd := NewImageCommand(c, &out)
+			cmd.SetArgs(strings.Split(tc.args, " "))
+			cmd.SilenceUsage = true
+			cmd.SilenceErrors = true
+			switch err := cmd.Execute(); {
+			case err != nil && tc.errSubstring == "":
+				t.Errorf("image export command returned an unexpected error: %v", err)
+			case err == nil && tc.errSubstring != "":
+				t.Errorf("Didn't get expected error (substring %q), output: %q", tc.errSubstring, out.String())
+			case err != nil && !strings.Contains(err.Error(), tc.errSubstring):
+				t.Errorf("Didn't get expected substring %q in the error: %v", tc.errSubstring, err)
+			case err == nil && out.String() != tc.expectedOutput:
+				t.Errorf("Unexpected output from the command:\n%s\n-- instead of --\n%s", out.String(), tc.expectedOutput)
+			}
+			if tc.expectedFile != "" {
+				switch bs, err := ioutil.ReadFile(imageFile); {
+				case err != nil:
+					t.Errorf("ReadFile(): %v", err)
+				case string(bs) != tc.expectedFile:
+					t.Errorf("Bad exported image data: %q instead of %q", bs, tc.expectedFile)
+				}
+			}
+			for c := range tc.expectedCommands {
+				t.Errorf("command not executed: %q", c)
+			}
+		})
+	}
+}
+
+func TestImageTranslateCommand(t *testing.T) {
+	mappings := []virtlet_v1.VirtletImageMapping{
+		{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name:      "mapping1",
+				Namespace: "kube-system",
+			},
+			Spec: virtlet_v1.ImageTranslation{
+				Prefix: "test",
+				Rules: []virtlet_v1.TranslationRule{
+					{
+						Name:      "cirros",
+						URL:       "https://example.com/cirros.img",
+						Transport: "profile1",
+					},
+					{
+						Regex:           `^ubuntu/(\d+\.\d+)$`,
+						URL:             "https://example.com/ubuntu-$1.img",
+						Mirrors:         []string{"https://mirror.example.com/ubuntu-$1.img"},
+						MirrorSelection: virtlet_v1.MirrorSelectionFastest,
+						Transport:       "nosuchprofile",
+					},
+				},
+				Transports: map[string]virtlet_v1.TransportProfile{
+					"profile1": {},
+				},
+			},
+		},
+	}
+	for _, tc := range []struct {
+		name           string
+		args           string
+		expectedOutput string
+		errSubstring   string
+	}{
+		{
+			name: "name",
+			args: "translate virtlet.cloud/test/cirros",
+			expectedOutput: "Mapping:            mapping1\n" +
+				"Rule:               #1 (name \"cirros\")\n" +
+				"URL:                https://example.com/cirros.img\n" +
+				"Transport profile:  profile1\n" +
+				"Problems found in the mapping:\n" +
+				"  rule #2: unknown transport profile \"nosuchprofile\"\n",
+		},
+		{
+			name: "regexp",
+			args: "translate test/ubuntu/16.04",
+			expectedOutput: "Mapping:            mapping1\n" +
+				"Rule:               #2 (regexp \"^ubuntu/(\\\\d+\\\\.\\\\d+)$\")\n" +
+				"URL:                https://example.com/ubuntu-16.04.img\n" +
+				"Mirrors:            https://mirror.example.com/ubuntu-16.04.img (fastest)\n" +
+				"Transport profile:  nosuchprofile\n" +
+				"Problems found in the mapping:\n" +
+				"  rule #2: unknown transport profile \"nosuchprofile\"\n",
+		},
+		{
+			name:           "regexp disabled",
+			args:           "translate --allow-regexp=false test/ubuntu/16.04",
+			expectedOutput: "No matching rule found, \"test/ubuntu/16.04\" is used as the image URL\n",
+		},
+		{
+			name:         "no image name",
+			args:         "translate",
+			errSubstring: "Must specify the image name",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &fakeKubeClient{
+				t:             t,
+				imageMappings: mappings,
+			}
+			var out bytes.Buffer
+			cmd := NewImageCommand(c, &out)
+			cmd.SetArgs(strings.Split(tc.args, " "))
+			cmd.SilenceUsage = true
+			cmd.SilenceErrors = true
+			switch err := cmd.Execute(); {
+			case err != nil && tc.errSubstring == "":
+				t.Errorf("image command returned an unexpected error: %v", err)
+			case err == nil && tc.errSubstring != "":
+				t.Errorf("Didn't get expected error (substring %q), output: %q", tc.errSubstring, out.String())
+			case err != nil && !strings.Contains(err.Error(), tc.errSubstring):
+				t.Errorf("Didn't get expected substring %q in the error: %v", tc.errSubstring, err)
+			case err == nil && out.String() != tc.expectedOutput:
+				t.Errorf("Unexpected output from the command:\n%s\n-- instead of --\n%s", out.String(), tc.expectedOutput)
+			}
+		})
+	}
+}
@@ -40,6 +40,9 @@ import (
 	"k8s.io/client-go/tools/remotecommand"
 	"k8s.io/client-go/transport/spdy"
 	"k8s.io/client-go/util/exec"
+
+	virtlet_v1 "github.com/Mirantis/virtlet/pkg/api/virtlet.k8s/v1"
+	virtletclient "github.com/Mirantis/virtlet/pkg/client/clientset/versioned"
 )
 
 const (
@@ -157,6 +160,9 @@ type KubeClient interface {
 	// Retrieves the logs for the specified pod. If tailLines is
 	// non-zero, it limits the numer of lines to be retrieved.
 	PodLogs(podName, containerName, namespace string, tailLines int64) ([]byte, error)
+	// GetVirtletImageMappings returns the list of VirtletImageMappings
+	// in the specified namespace.
+	GetVirtletImageMappings(namespace string) ([]virtlet_v1.VirtletImageMapping, error)
 }
 
 type remoteExecutor interface {
@@ -490,3 +496,19 @@ func (c *RealKubeClient) PodLogs(podName, containerName, namespace string, tailL
 	}
 	return c.client.CoreV1().Pods(namespace).GetLogs(podName, opts).Do().Raw()
 }
+
+// GetVirtletImageMappings implements GetVirtletImageMappings method of KubeClient interface.
+func (c *RealKubeClient) GetVirtletImageMappings(namespace string) ([]virtlet_v1.VirtletImageMapping, error) {
+	if err := c.setup(); err != nil {
+		return nil, err
+	}
+	virtletClient, err := virtletclient.NewForConfig(c.config)
+	if err != nil {
+		return nil, fmt.Errorf("can't create Virtlet api client: %v", err)
+	}
+	list, err := virtletClient.VirtletV1().VirtletImageMappings(namespace).List(meta_v1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
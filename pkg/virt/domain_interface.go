@@ -109,4 +109,10 @@ type Domain interface {
 	GetRSS() (uint64, error)
 	// GetCPUTime returns cpu time used by VM in nanoseconds per core
 	GetCPUTime() (uint64, error)
+	// FSFreeze freezes the filesystems of the guest using the
+	// guest agent
+	FSFreeze() error
+	// FSThaw thaws the filesystems of the guest that were frozen
+	// by FSFreeze
+	FSThaw() error
 }
@@ -311,6 +311,21 @@ func (d *FakeDomain) GetRSS() (uint64, error) {
 	return 0, nil
 }
 
+// FSFreeze implements FSFreeze method of Domain interface.
+func (d *FakeDomain) FSFreeze() error {
+	d.rec.Rec("FSFreeze", nil)
+	if d.state != virt.DomainStateRunning {
+		return fmt.Errorf("FSFreeze(): domain %q is not running", d.def.Name)
+	}
+	return nil
+}
+
+// FSThaw implements FSThaw method of Domain interface.
+func (d *FakeDomain) FSThaw() error {
+	d.rec.Rec("FSThaw", nil)
+	return nil
+}
+
 // FakeSecret is a fake implementation of Secret interace.
 type FakeSecret struct {
 	rec       testutils.Recorder
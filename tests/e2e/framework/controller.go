@@ -151,6 +151,14 @@ func (c *Controller) CreateVirtletImageMapping(mapping virtlet_v1.VirtletImageMa
 	return c.virtletClient.VirtletImageMappings("kube-system").Create(&mapping)
 }
 
+func (c *Controller) UpdateVirtletImageMapping(mapping virtlet_v1.VirtletImageMapping) (*virtlet_v1.VirtletImageMapping, error) {
+	return c.virtletClient.VirtletImageMappings("kube-system").Update(&mapping)
+}
+
+func (c *Controller) GetVirtletImageMapping(name string) (*virtlet_v1.VirtletImageMapping, error) {
+	return c.virtletClient.VirtletImageMappings("kube-system").Get(name, metav1.GetOptions{})
+}
+
 func (c *Controller) DeleteVirtletImageMapping(name string) error {
 	return c.virtletClient.VirtletImageMappings("kube-system").Delete(name, &metav1.DeleteOptions{})
 }
@@ -70,4 +70,23 @@ var _ = Describe("Image URL", func() {
 		Expect(err).NotTo(HaveOccurred())
 		deleteVM(vm)
 	})
+
+	It("Picks up the changes in CRD without restarting Virtlet", func() {
+		vim, err := controller.GetVirtletImageMapping(vimName)
+		Expect(err).NotTo(HaveOccurred())
+		vim.Spec.Rules = append(vim.Spec.Rules, virtlet_v1.TranslationRule{
+			Name: "updated-test-image",
+			URL:  vim.Spec.Rules[0].URL,
+		})
+		_, err = controller.UpdateVirtletImageMapping(*vim)
+		Expect(err).NotTo(HaveOccurred())
+
+		vm := controller.VM("cirros-vm-with-updated-image-mapping")
+		Expect(vm.CreateAndWait(VMOptions{
+			Image: "updated-test-image" + digestSuffix,
+		}.ApplyDefaults(), time.Minute*5, nil)).To(Succeed())
+		_, err = vm.Pod()
+		Expect(err).NotTo(HaveOccurred())
+		deleteVM(vm)
+	})
 })
@@ -125,7 +125,7 @@ func (v *VirtletManager) Run() {
 		DisableLogging:       pbool(true),
 		DisableKVM:           pbool(os.Getenv(disableKvmEnvVar) != ""),
 	})
-	v.manager = manager.NewVirtletManager(cfg, &fakeFDManager{}, nil, diag.NewDiagSet())
+	v.manager = manager.NewVirtletManager(cfg, &fakeFDManager{}, nil, "", diag.NewDiagSet())
 	v.doneCh = make(chan struct{})
 	go func() {
 		if err := v.manager.Run(); err != nil {
@@ -198,7 +198,7 @@ func (vnt *vmNetworkTester) setupClientTap() error {
 	return vnt.clientNS.Do(func(ns.NetNS) error {
 		for n := 0; n < vnt.linkCount; n++ {
 			linkName := fmt.Sprintf("tap%d", n)
-			clientTapLink, err := nettools.CreateTAP(linkName, 1500)
+			clientTapLink, err := nettools.CreateTAP(linkName, 1500, false)
 			if err != nil {
 				return fmt.Errorf("CreateTAP() in the client netns: %v", err)
 			}
@@ -249,7 +249,7 @@ func TestVmNetwork(t *testing.T) {
 		if err != nil {
 			return fmt.Errorf("LinkList() failed: %v", err)
 		}
-		csn, err = nettools.SetupContainerSideNetwork(info, contNS.Path(), allLinks, false, hostNS)
+		csn, err = nettools.SetupContainerSideNetwork(info, contNS.Path(), allLinks, false, hostNS, nil)
 		if err != nil {
 			return fmt.Errorf("failed to set up container side network: %v", err)
 		}
@@ -331,7 +331,7 @@ func (tst *tapFDSourceTester) setupServerAndConnectToFDServer() *tapmanager.FDCl
 		tst.t.Fatalf("the server and/or the client is already present")
 	}
 
-	src, err := tapmanager.NewTapFDSource(tst.cniClient, false, 24)
+	src, err := tapmanager.NewTapFDSource(tst.cniClient, false, false, 24)
 	if err != nil {
 		tst.t.Fatalf("Error creating tap fd source: %v", err)
 	}
@@ -433,6 +433,7 @@ func TestTapFDSource(t *testing.T) {
 			interfaceDesc: []tapmanager.InterfaceDescription{
 				{
 					Type:         network.InterfaceTypeTap,
+					Model:        network.NICModelVirtio,
 					HardwareAddr: mustParseMAC(clientMacAddrs[0]),
 					FdIndex:      0,
 					PCIAddress:   "",
@@ -459,6 +460,7 @@ func TestTapFDSource(t *testing.T) {
 			interfaceDesc: []tapmanager.InterfaceDescription{
 				{
 					Type:         network.InterfaceTypeTap,
+					Model:        network.NICModelVirtio,
 					HardwareAddr: mustParseMAC(clientMacAddrs[0]),
 					FdIndex:      0,
 					PCIAddress:   "",
@@ -539,12 +541,14 @@ func TestTapFDSource(t *testing.T) {
 			interfaceDesc: []tapmanager.InterfaceDescription{
 				{
 					Type:         network.InterfaceTypeTap,
+					Model:        network.NICModelVirtio,
 					HardwareAddr: mustParseMAC(clientMacAddrs[0]),
 					FdIndex:      0,
 					PCIAddress:   "",
 				},
 				{
 					Type:         network.InterfaceTypeTap,
+					Model:        network.NICModelVirtio,
 					HardwareAddr: mustParseMAC(clientMacAddrs[1]),
 					FdIndex:      1,
 					PCIAddress:   "",
@@ -673,12 +677,14 @@ func TestTapFDSource(t *testing.T) {
 			interfaceDesc: []tapmanager.InterfaceDescription{
 				{
 					Type:         network.InterfaceTypeTap,
+					Model:        network.NICModelVirtio,
 					HardwareAddr: mustParseMAC(clientMacAddrs[0]),
 					FdIndex:      0,
 					PCIAddress:   "",
 				},
 				{
 					Type:         network.InterfaceTypeTap,
+					Model:        network.NICModelVirtio,
 					HardwareAddr: mustParseMAC(clientMacAddrs[1]),
 					FdIndex:      1,
 					PCIAddress:   "",
@@ -704,6 +710,7 @@ func TestTapFDSource(t *testing.T) {
 			interfaceDesc: []tapmanager.InterfaceDescription{
 				{
 					Type:         network.InterfaceTypeTap,
+					Model:        network.NICModelVirtio,
 					HardwareAddr: mustParseMAC(clientMacAddrs[0]),
 					FdIndex:      0,
 					PCIAddress:   "",
@@ -808,6 +815,7 @@ func TestTapFDSource(t *testing.T) {
 			interfaceDesc: []tapmanager.InterfaceDescription{
 				{
 					Type:         network.InterfaceTypeTap,
+					Model:        network.NICModelVirtio,
 					HardwareAddr: mustParseMAC(clientMacAddrs[0]),
 					FdIndex:      0,
 					PCIAddress:   "",
@@ -944,12 +952,14 @@ func TestTapFDSource(t *testing.T) {
 			interfaceDesc: []tapmanager.InterfaceDescription{
 				{
 					Type:         network.InterfaceTypeTap,
+					Model:        network.NICModelVirtio,
 					HardwareAddr: mustParseMAC(clientMacAddrs[0]),
 					FdIndex:      0,
 					PCIAddress:   "",
 				},
 				{
 					Type:         network.InterfaceTypeTap,
+					Model:        network.NICModelVirtio,
 					HardwareAddr: mustParseMAC(clientMacAddrs[1]),
 					FdIndex:      1,
 					PCIAddress:   "",